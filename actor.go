@@ -0,0 +1,280 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrActorClosed is returned by Actor.Send once Close has been called.
+	ErrActorClosed = errors.New("conc: actor is closed")
+	// ErrActorStopped is returned by Actor.Send once the actor has stopped
+	// because its handler panicked.
+	ErrActorStopped = errors.New("conc: actor stopped after a panic")
+	// ErrMailboxFull is returned by Actor.Send when the message is dropped
+	// because the mailbox is full. See MailboxPolicy.
+	ErrMailboxFull = errors.New("conc: actor mailbox is full")
+)
+
+// DefaultMailboxSize is the default number of messages an Actor queues.
+const DefaultMailboxSize = 64
+
+// MailboxPolicy controls what Actor.Send does when the mailbox is full.
+type MailboxPolicy int
+
+const (
+	// MailboxBlock makes Send wait for room in the mailbox, so that a slow
+	// actor slows its senders down. This is the default.
+	MailboxBlock MailboxPolicy = iota
+
+	// MailboxDropNewest drops the message being sent, and Send returns
+	// ErrMailboxFull.
+	MailboxDropNewest
+
+	// MailboxDropOldest drops the oldest message in the mailbox to make
+	// room for the message being sent, for actors that only care about the
+	// latest messages, such as state updates. If the mailbox size is 0,
+	// there is no message to drop but the one being sent, as with
+	// MailboxDropNewest.
+	MailboxDropOldest
+)
+
+// NewActor creates an Actor that handles each message with handle.
+func NewActor[T any](handle func(msg T)) *Actor[T] {
+	return &Actor[T]{
+		handle:      handle,
+		mailboxSize: DefaultMailboxSize,
+	}
+}
+
+// Actor owns a stateful component that many goroutines send messages to:
+// the messages are queued in a bounded mailbox, and handled one at a time,
+// in the order they were queued, by a goroutine of its own, so that the
+// state of the component needs no locking:
+//
+//	counts := make(map[string]int)
+//	a := conc.NewActor(func(word string) { counts[word]++ })
+//	for _, word := range words {
+//		a.Send(ctx, word)
+//	}
+//	a.Close(ctx) // counts can be read once Close returns
+//
+// The goroutine is started on the first message. Close stops the actor
+// gracefully, handling the messages that were already queued before
+// returning, so that shutting down loses nothing that Send accepted.
+//
+// If the handler panics, the panic is passed to ReportPanic and, unless the
+// actor is configured with WithRestarts, the actor stops, dropping the
+// messages left in the mailbox, and Close propagates the panic.
+//
+// The With methods configure the actor, and must be called before the first
+// message is sent. An Actor must be created with NewActor, and Close must be
+// called to release its goroutine.
+type Actor[T any] struct {
+	handle      func(T)
+	mailboxSize int
+	policy      MailboxPolicy
+	onDrop      func(T)
+	maxRestarts int
+	onRestart   func(*RecoveredPanic)
+
+	initOnce sync.Once
+	mailbox  chan T
+	// closing is closed once Close is called, and stopped once the handler
+	// has panicked for the last time, with the panic in panicked. done is
+	// closed once the goroutine of the actor has exited.
+	closing  chan struct{}
+	stopped  chan struct{}
+	done     chan struct{}
+	panicked *RecoveredPanic
+
+	mu     sync.Mutex
+	closed bool
+	// sending counts the calls to Send in progress, so that the mailbox is
+	// only closed once they have returned
+	sending sync.WaitGroup
+}
+
+// WithMailboxSize sets the number of messages that can be queued for the
+// actor before Send blocks or drops messages, according to the policy set
+// with WithMailboxPolicy. Defaults to DefaultMailboxSize. Panics if n < 0.
+func (a *Actor[T]) WithMailboxSize(n int) *Actor[T] {
+	if n < 0 {
+		panic("mailbox size must not be negative")
+	}
+	a.mailboxSize = n
+	return a
+}
+
+// WithMailboxPolicy sets what Send does when the mailbox is full. If onDrop
+// is non-nil, it is called with each message that is dropped, from the
+// goroutine that called Send. See MailboxPolicy.
+func (a *Actor[T]) WithMailboxPolicy(policy MailboxPolicy, onDrop func(msg T)) *Actor[T] {
+	a.policy = policy
+	a.onDrop = onDrop
+	return a
+}
+
+// WithRestarts configures the actor to go on with the next message after
+// its handler panics, up to n times, rather than stop. Each panic is passed
+// to onRestart, if it is non-nil, which can reset the state of the component
+// before the next message. The panic after the last restart stops the actor.
+// Panics if n < 1.
+func (a *Actor[T]) WithRestarts(n int, onRestart func(*RecoveredPanic)) *Actor[T] {
+	if n < 1 {
+		panic("number of restarts must be greater than zero")
+	}
+	a.maxRestarts = n
+	a.onRestart = onRestart
+	return a
+}
+
+func (a *Actor[T]) init() {
+	a.initOnce.Do(func() {
+		a.mailbox = make(chan T, a.mailboxSize)
+		a.closing = make(chan struct{})
+		a.stopped = make(chan struct{})
+		a.done = make(chan struct{})
+		go a.run()
+	})
+}
+
+// Send queues msg for the actor. If the mailbox is full, Send waits for
+// room, or drops a message, according to the policy set with
+// WithMailboxPolicy. Send returns ctx.Err() if ctx is done while waiting,
+// ErrMailboxFull if it dropped msg, ErrActorClosed once Close has been
+// called, and ErrActorStopped once the actor has stopped after a panic.
+func (a *Actor[T]) Send(ctx context.Context, msg T) error {
+	a.init()
+
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return ErrActorClosed
+	}
+	a.sending.Add(1)
+	a.mu.Unlock()
+	defer a.sending.Done()
+
+	select {
+	case <-a.stopped:
+		return ErrActorStopped
+	case <-a.closing:
+		return ErrActorClosed
+	default:
+	}
+	select {
+	case a.mailbox <- msg:
+		return nil
+	default:
+	}
+
+	switch {
+	case a.policy == MailboxDropNewest, a.policy == MailboxDropOldest && cap(a.mailbox) == 0:
+		a.drop(msg)
+		return ErrMailboxFull
+	case a.policy == MailboxDropOldest:
+		for {
+			select {
+			case a.mailbox <- msg:
+				return nil
+			default:
+			}
+			select {
+			case old := <-a.mailbox:
+				a.drop(old)
+			default:
+			}
+		}
+	}
+
+	select {
+	case a.mailbox <- msg:
+		return nil
+	case <-a.stopped:
+		return ErrActorStopped
+	case <-a.closing:
+		return ErrActorClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Actor[T]) drop(msg T) {
+	if a.onDrop != nil {
+		a.onDrop(msg)
+	}
+}
+
+// Len returns the number of messages waiting in the mailbox.
+func (a *Actor[T]) Len() int {
+	a.init()
+	return len(a.mailbox)
+}
+
+// Close stops the actor from accepting messages, and waits for it to handle
+// the messages that were already queued, or for ctx to be done, in which
+// case it returns ctx.Err() and the actor goes on with the messages in the
+// background. Calls to Send that are waiting for room return
+// ErrActorClosed. If the actor stopped because its handler panicked, the
+// panic is propagated. Close can be called more than once.
+func (a *Actor[T]) Close(ctx context.Context) error {
+	a.init()
+
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.closing)
+		go func() {
+			// The calls to Send in progress return promptly now that
+			// closing is closed, after which nothing can be sent anymore.
+			a.sending.Wait()
+			close(a.mailbox)
+		}()
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if a.panicked != nil {
+		a.panicked.Propagate()
+	}
+	return nil
+}
+
+// run handles the messages of the actor until the mailbox is closed, or the
+// handler panics for the last time.
+func (a *Actor[T]) run() {
+	defer close(a.done)
+
+	restarts := 0
+	for msg := range a.mailbox {
+		var pc PanicCatcher
+		recovered := pc.TryRecovered(func() { a.handle(msg) })
+		if recovered == nil {
+			continue
+		}
+		ReportPanic(recovered)
+		if restarts < a.maxRestarts {
+			restarts++
+			if a.onRestart != nil {
+				// A panic in onRestart stops the actor.
+				var restartPC PanicCatcher
+				r := recovered
+				recovered = restartPC.TryRecovered(func() { a.onRestart(r) })
+				ReportPanic(recovered)
+			} else {
+				recovered = nil
+			}
+		}
+		if recovered != nil {
+			a.panicked = recovered
+			close(a.stopped)
+			return
+		}
+	}
+}
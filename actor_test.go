@@ -0,0 +1,236 @@
+package conc
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleActor() {
+	ctx := context.Background()
+	counts := make(map[string]int)
+	a := NewActor(func(word string) { counts[word]++ })
+
+	var wg WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Go(func() {
+			for _, word := range []string{"a", "b", "a"} {
+				if err := a.Send(ctx, word); err != nil {
+					panic(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if err := a.Close(ctx); err != nil {
+		panic(err)
+	}
+	fmt.Println(counts["a"], counts["b"])
+
+	// Output:
+	// 6 3
+}
+
+func TestActor(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("handles messages in order", func(t *testing.T) {
+		var got []int
+		a := NewActor(func(i int) { got = append(got, i) }).WithMailboxSize(1)
+		for i := 0; i < 100; i++ {
+			require.NoError(t, a.Send(ctx, i))
+		}
+		require.NoError(t, a.Close(ctx))
+		require.Len(t, got, 100)
+		for i, v := range got {
+			require.Equal(t, i, v)
+		}
+	})
+
+	t.Run("handles messages one at a time", func(t *testing.T) {
+		var running, maxRunning atomic.Int64
+		a := NewActor(func(int) {
+			if n := running.Add(1); n > maxRunning.Load() {
+				maxRunning.Store(n)
+			}
+			time.Sleep(time.Microsecond)
+			running.Add(-1)
+		})
+		var wg WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Go(func() {
+				for j := 0; j < 10; j++ {
+					require.NoError(t, a.Send(ctx, j))
+				}
+			})
+		}
+		wg.Wait()
+		require.NoError(t, a.Close(ctx))
+		require.Equal(t, int64(1), maxRunning.Load())
+	})
+
+	t.Run("blocks when the mailbox is full", func(t *testing.T) {
+		unblock := make(chan struct{})
+		a := NewActor(func(int) { <-unblock }).WithMailboxSize(1)
+		require.NoError(t, a.Send(ctx, 1))
+		require.Eventually(t, func() bool { return a.Len() == 0 }, time.Second, time.Millisecond)
+		require.NoError(t, a.Send(ctx, 2))
+		require.Equal(t, 1, a.Len())
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, a.Send(timeoutCtx, 3), context.DeadlineExceeded)
+
+		sent := make(chan error)
+		go func() { sent <- a.Send(ctx, 4) }()
+		time.Sleep(10 * time.Millisecond)
+		close(unblock)
+		require.NoError(t, <-sent)
+		require.NoError(t, a.Close(ctx))
+	})
+
+	t.Run("drops the newest message", func(t *testing.T) {
+		unblock := make(chan struct{})
+		var got, dropped []int
+		a := NewActor(func(i int) {
+			<-unblock
+			got = append(got, i)
+		}).WithMailboxSize(1).WithMailboxPolicy(MailboxDropNewest, func(i int) {
+			dropped = append(dropped, i)
+		})
+		require.NoError(t, a.Send(ctx, 1))
+		require.Eventually(t, func() bool { return a.Len() == 0 }, time.Second, time.Millisecond)
+		require.NoError(t, a.Send(ctx, 2))
+		require.ErrorIs(t, a.Send(ctx, 3), ErrMailboxFull)
+		close(unblock)
+		require.NoError(t, a.Close(ctx))
+		require.Equal(t, []int{1, 2}, got)
+		require.Equal(t, []int{3}, dropped)
+	})
+
+	t.Run("drops the oldest message", func(t *testing.T) {
+		unblock := make(chan struct{})
+		var got, dropped []int
+		a := NewActor(func(i int) {
+			<-unblock
+			got = append(got, i)
+		}).WithMailboxSize(2).WithMailboxPolicy(MailboxDropOldest, func(i int) {
+			dropped = append(dropped, i)
+		})
+		require.NoError(t, a.Send(ctx, 1))
+		require.Eventually(t, func() bool { return a.Len() == 0 }, time.Second, time.Millisecond)
+		for i := 2; i <= 5; i++ {
+			require.NoError(t, a.Send(ctx, i))
+		}
+		close(unblock)
+		require.NoError(t, a.Close(ctx))
+		require.Equal(t, []int{1, 4, 5}, got)
+		require.Equal(t, []int{2, 3}, dropped)
+	})
+
+	t.Run("drops the message sent without a mailbox", func(t *testing.T) {
+		unblock := make(chan struct{})
+		a := NewActor(func(int) { <-unblock }).WithMailboxSize(0).WithMailboxPolicy(MailboxDropOldest, nil)
+		// The message is only accepted once the actor is waiting for it
+		require.Eventually(t, func() bool { return a.Send(ctx, 1) == nil }, time.Second, time.Millisecond)
+		require.ErrorIs(t, a.Send(ctx, 2), ErrMailboxFull)
+		close(unblock)
+		require.NoError(t, a.Close(ctx))
+	})
+
+	t.Run("close handles queued messages", func(t *testing.T) {
+		unblock := make(chan struct{})
+		var handled atomic.Int64
+		a := NewActor(func(int) {
+			<-unblock
+			handled.Add(1)
+		}).WithMailboxSize(10)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, a.Send(ctx, i))
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, a.Close(timeoutCtx), context.DeadlineExceeded)
+		require.ErrorIs(t, a.Send(ctx, 10), ErrActorClosed)
+
+		close(unblock)
+		require.NoError(t, a.Close(ctx))
+		require.Equal(t, int64(10), handled.Load())
+		require.NoError(t, a.Close(ctx))
+	})
+
+	t.Run("close unblocks senders", func(t *testing.T) {
+		unblock := make(chan struct{})
+		a := NewActor(func(int) { <-unblock }).WithMailboxSize(0)
+		require.NoError(t, a.Send(ctx, 1))
+		sent := make(chan error)
+		go func() { sent <- a.Send(ctx, 2) }()
+		time.Sleep(10 * time.Millisecond)
+		closed := make(chan error)
+		go func() { closed <- a.Close(ctx) }()
+		require.ErrorIs(t, <-sent, ErrActorClosed)
+		close(unblock)
+		require.NoError(t, <-closed)
+	})
+
+	t.Run("close without messages", func(t *testing.T) {
+		a := NewActor(func(int) {})
+		require.Equal(t, 0, a.Len())
+		require.NoError(t, a.Close(ctx))
+	})
+
+	t.Run("stops after a panic", func(t *testing.T) {
+		a := NewActor(func(int) { panic("super bad thing") })
+		require.NoError(t, a.Send(ctx, 1))
+		require.Eventually(t, func() bool {
+			return a.Send(ctx, 2) == ErrActorStopped
+		}, time.Second, time.Millisecond)
+		var recovered *RecoveredPanic
+		func() {
+			defer func() { recovered, _ = recover().(*RecoveredPanic) }()
+			_ = a.Close(ctx)
+		}()
+		require.NotNil(t, recovered)
+		require.Equal(t, "super bad thing", recovered.Value)
+	})
+
+	t.Run("restarts after a panic", func(t *testing.T) {
+		var got []int
+		var restarts []any
+		a := NewActor(func(i int) {
+			if i%2 == 0 {
+				panic(i)
+			}
+			got = append(got, i)
+		}).WithRestarts(2, func(recovered *RecoveredPanic) {
+			restarts = append(restarts, recovered.Value)
+		})
+		for i := 1; i <= 5; i++ {
+			require.NoError(t, a.Send(ctx, i))
+		}
+		require.NoError(t, a.Close(ctx))
+		require.Equal(t, []int{1, 3, 5}, got)
+		require.Equal(t, []any{2, 4}, restarts)
+	})
+
+	t.Run("stops after the last restart", func(t *testing.T) {
+		a := NewActor(func(i int) { panic(i) }).WithRestarts(1, nil)
+		for i := 1; i <= 3; i++ {
+			// The actor may stop before the last message is sent
+			_ = a.Send(ctx, i)
+		}
+		require.Panics(t, func() { _ = a.Close(ctx) })
+	})
+
+	t.Run("panics on invalid options", func(t *testing.T) {
+		require.Panics(t, func() { NewActor(func(int) {}).WithMailboxSize(-1) })
+		require.Panics(t, func() { NewActor(func(int) {}).WithRestarts(0, nil) })
+	})
+}
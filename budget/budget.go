@@ -0,0 +1,93 @@
+// Package budget splits the deadline of a context across the sequential
+// phases of a pipeline, so that an early phase, such as a ContextPool fanning
+// out requests, cannot use up the whole deadline of a request and starve the
+// phases after it.
+package budget
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Split creates a Budget for len(weights) sequential phases that share the
+// time left until the deadline of ctx in proportion to their weights:
+//
+//	b := budget.Split(ctx, 3, 1)
+//
+//	fetchCtx, cancel := b.Next() // 3/4 of the time left
+//	p := pool.New().WithContext(fetchCtx)
+//	...
+//	err := p.Wait()
+//	cancel()
+//
+//	renderCtx, cancel := b.Next() // whatever is left
+//	defer cancel()
+//
+// Each share is computed when its phase starts, from the time left then, so
+// the time a phase does not use goes to the phases after it. Panics if there
+// are no weights, or if a weight is less than 1.
+func Split(ctx context.Context, weights ...int) *Budget {
+	if len(weights) == 0 {
+		panic("budget must have at least one phase")
+	}
+	total := 0
+	for _, w := range weights {
+		if w < 1 {
+			panic("phase weight must be greater than zero")
+		}
+		total += w
+	}
+	return &Budget{ctx: ctx, weights: weights, remaining: total}
+}
+
+// Budget hands out the contexts of the phases of a deadline split with
+// Split.
+type Budget struct {
+	ctx     context.Context
+	weights []int
+
+	mu sync.Mutex
+	// next is the index of the next phase, and remaining is the total
+	// weight of the phases that have not started
+	next      int
+	remaining int
+}
+
+// Next starts the next phase, returning a context derived from the context
+// passed to Split, with a deadline at the phase's share of the time left. The
+// last phase gets the parent's deadline itself. If the parent has no
+// deadline, the phases do not get one either. The cancel function releases
+// the resources of the context, as for context.WithDeadline, and should be
+// called when the phase ends. Panics if every phase has started.
+func (b *Budget) Next() (context.Context, context.CancelFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.next == len(b.weights) {
+		panic("budget: every phase has already started")
+	}
+	weight := b.weights[b.next]
+	remaining := b.remaining
+	b.next++
+	b.remaining -= weight
+
+	deadline, ok := b.ctx.Deadline()
+	if !ok || weight == remaining {
+		return context.WithCancel(b.ctx)
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		// The parent is done or about to be, which the context reports
+		return context.WithCancel(b.ctx)
+	}
+	share := time.Duration(float64(left) * float64(weight) / float64(remaining))
+	return context.WithDeadline(b.ctx, time.Now().Add(share))
+}
+
+// Phases returns the number of phases that have not started.
+func (b *Budget) Phases() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.weights) - b.next
+}
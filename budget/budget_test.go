@@ -0,0 +1,82 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("shares the deadline by weight", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 40*time.Second)
+		defer cancel()
+		parentDeadline, _ := ctx.Deadline()
+
+		b := Split(ctx, 3, 1)
+		require.Equal(t, 2, b.Phases())
+
+		first, cancelFirst := b.Next()
+		defer cancelFirst()
+		deadline, ok := first.Deadline()
+		require.True(t, ok)
+		require.InDelta(t, 30*time.Second, time.Until(deadline), float64(time.Second))
+
+		last, cancelLast := b.Next()
+		defer cancelLast()
+		deadline, ok = last.Deadline()
+		require.True(t, ok)
+		require.Equal(t, parentDeadline, deadline)
+		require.Zero(t, b.Phases())
+
+		require.Panics(t, func() { b.Next() })
+	})
+
+	t.Run("unused time goes to later phases", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		b := Split(ctx, 1, 1, 1)
+		_, cancelFirst := b.Next()
+		cancelFirst()
+
+		// The first phase ended at once, so the second gets half of
+		// almost all of the time
+		second, cancelSecond := b.Next()
+		defer cancelSecond()
+		deadline, _ := second.Deadline()
+		require.Greater(t, time.Until(deadline), 120*time.Millisecond)
+	})
+
+	t.Run("phase deadline expires before the parent", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		phase, cancelPhase := Split(ctx, 1, 99).Next()
+		defer cancelPhase()
+		<-phase.Done()
+		require.ErrorIs(t, phase.Err(), context.DeadlineExceeded)
+		require.NoError(t, ctx.Err())
+	})
+
+	t.Run("no parent deadline", func(t *testing.T) {
+		t.Parallel()
+		phase, cancel := Split(context.Background(), 1, 1).Next()
+		_, ok := phase.Deadline()
+		require.False(t, ok)
+		cancel()
+		require.ErrorIs(t, phase.Err(), context.Canceled)
+	})
+
+	t.Run("panics on invalid weights", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { Split(context.Background()) })
+		require.Panics(t, func() { Split(context.Background(), 1, 0) })
+	})
+}
@@ -0,0 +1,302 @@
+// Package bus is an in-process publish/subscribe event bus. Messages are
+// published on typed topics, queued for each subscriber in a bounded queue of
+// its own, and handled on a pool of goroutines shared by the subscribers of
+// the bus, with each handler isolated from the panics of the others:
+//
+//	b := bus.New()
+//	orders := bus.NewTopic[OrderPlaced](b, "orders")
+//
+//	orders.Subscribe(func(o OrderPlaced) { sendReceipt(o) })
+//	orders.SubscribeWith(bus.SubscribeOptions[OrderPlaced]{
+//		Policy: conc.MailboxDropOldest,
+//	}, func(o OrderPlaced) { updateDashboard(o) })
+//
+//	orders.Publish(ctx, OrderPlaced{ID: 42})
+//	...
+//	b.Close(ctx) // handles the messages already published
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sourcegraph/conc"
+	"github.com/sourcegraph/conc/pool"
+)
+
+// ErrBusClosed is returned by Topic.Publish once Close has been called.
+var ErrBusClosed = errors.New("bus: bus is closed")
+
+// New creates a new Bus.
+func New() *Bus {
+	return &Bus{
+		pool:      pool.New(),
+		queueSize: conc.DefaultMailboxSize,
+		topics:    make(map[string]any),
+		subs:      make(map[closer]struct{}),
+	}
+}
+
+// Bus owns the topics that messages are published on, and the goroutines
+// that handle them. Close must be called to stop the subscriptions and
+// release the goroutines.
+//
+// The With methods configure the bus, and must be called before the first
+// topic is created.
+type Bus struct {
+	pool         *pool.Pool
+	queueSize    int
+	panicHandler func(*conc.RecoveredPanic)
+
+	mu     sync.Mutex
+	closed bool
+	topics map[string]any
+	subs   map[closer]struct{}
+
+	waitOnce sync.Once
+}
+
+// closer is a subscription of any type.
+type closer interface {
+	close(ctx context.Context) error
+}
+
+// WithMaxGoroutines limits the number of goroutines that run handlers, for
+// all the subscriptions of the bus together. A handler that publishes a
+// message can wait for room in a queue whose subscriber waits for a
+// goroutine, so the limit should leave room for such chains. Defaults to
+// runtime.GOMAXPROCS(0). Panics if n < 1. See pool.Pool.WithMaxGoroutines.
+func (b *Bus) WithMaxGoroutines(n int) *Bus {
+	b.pool.WithMaxGoroutines(n)
+	return b
+}
+
+// WithQueueSize sets the default number of messages that can be queued for
+// each subscriber. Defaults to conc.DefaultMailboxSize. Panics if n < 1.
+func (b *Bus) WithQueueSize(n int) *Bus {
+	if n < 1 {
+		panic("queue size must be greater than zero")
+	}
+	b.queueSize = n
+	return b
+}
+
+// WithPanicHandler configures the bus to call handler with every panic raised
+// by a subscriber's handler. The subscriber goes on with the next message
+// either way. Defaults to conc.ReportPanic. The handler may be called
+// concurrently from multiple goroutines.
+func (b *Bus) WithPanicHandler(handler func(*conc.RecoveredPanic)) *Bus {
+	b.panicHandler = handler
+	return b
+}
+
+// Close stops the bus from accepting messages, and waits for the subscribers
+// to handle the messages that were already published, or for ctx to be
+// done, in which case it returns ctx.Err() and the messages are handled in
+// the background. Close can be called again to wait for them.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]closer, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		if err := s.close(ctx); err != nil {
+			return err
+		}
+	}
+	b.waitOnce.Do(b.pool.Wait)
+	return nil
+}
+
+func (b *Bus) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// NewTopic returns the topic of b with the given name, creating it if it does
+// not exist yet, so that the packages of a service can share a topic by name.
+// Panics if the topic exists with another message type.
+func NewTopic[T any](b *Bus, name string) *Topic[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if existing, ok := b.topics[name]; ok {
+		t, ok := existing.(*Topic[T])
+		if !ok {
+			panic(fmt.Sprintf("bus: topic %q already exists with another message type", name))
+		}
+		return t
+	}
+	t := &Topic[T]{bus: b, name: name}
+	b.topics[name] = t
+	return t
+}
+
+// Topic is a named stream of messages of type T, created with NewTopic.
+type Topic[T any] struct {
+	bus  *Bus
+	name string
+
+	mu   sync.RWMutex
+	subs []*Subscription[T]
+}
+
+// Name returns the name of the topic.
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Publish queues msg for each subscriber of the topic, in the order they
+// subscribed. When the queue of a subscriber is full, Publish waits for room
+// or drops a message, according to the policy of the subscriber, so a slow
+// subscriber with the default policy slows its publishers down. Publish
+// returns ctx.Err() if ctx is done while waiting, in which case the
+// subscribers after the one it was waiting for do not get msg, and
+// ErrBusClosed once the bus is closed. Messages published from one goroutine
+// are handled by each subscriber in the order they were published.
+func (t *Topic[T]) Publish(ctx context.Context, msg T) error {
+	if t.bus.isClosed() {
+		return ErrBusClosed
+	}
+
+	t.mu.RLock()
+	subs := t.subs
+	t.mu.RUnlock()
+
+	for _, s := range subs {
+		switch err := s.actor.Send(ctx, msg); {
+		case err == nil, errors.Is(err, conc.ErrMailboxFull):
+		case errors.Is(err, conc.ErrActorClosed):
+			// The subscriber unsubscribed, or the bus is being closed
+			if t.bus.isClosed() {
+				return ErrBusClosed
+			}
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribeOptions configures a subscription. The zero value of each field
+// leaves the corresponding setting at its default.
+type SubscribeOptions[T any] struct {
+	// QueueSize is the number of messages that can be queued for the
+	// subscriber. Defaults to the queue size of the bus. See
+	// Bus.WithQueueSize.
+	QueueSize int
+
+	// Policy is what Publish does when the queue is full. Defaults to
+	// conc.MailboxBlock.
+	Policy conc.MailboxPolicy
+
+	// OnDrop is called with each message that is dropped because of the
+	// policy, from the goroutine that called Publish.
+	OnDrop func(msg T)
+}
+
+// Subscribe calls handler with each message published on the topic from now
+// on, one message at a time and in order, with the default options. Panics if
+// the bus is closed.
+func (t *Topic[T]) Subscribe(handler func(msg T)) *Subscription[T] {
+	return t.SubscribeWith(SubscribeOptions[T]{}, handler)
+}
+
+// SubscribeWith is like Subscribe, with the given options.
+func (t *Topic[T]) SubscribeWith(opts SubscribeOptions[T], handler func(msg T)) *Subscription[T] {
+	queueSize := opts.QueueSize
+	if queueSize == 0 {
+		queueSize = t.bus.queueSize
+	}
+	s := &Subscription[T]{topic: t}
+	s.actor = conc.NewActor(func(msg T) { s.deliver(handler, msg) }).
+		WithMailboxSize(queueSize).
+		WithMailboxPolicy(opts.Policy, opts.OnDrop)
+
+	t.bus.mu.Lock()
+	defer t.bus.mu.Unlock()
+	if t.bus.closed {
+		panic("bus: subscribed to a closed Bus")
+	}
+	t.bus.subs[s] = struct{}{}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Copy on write, so that Publish can use the slice without the lock
+	subs := make([]*Subscription[T], len(t.subs), len(t.subs)+1)
+	copy(subs, t.subs)
+	t.subs = append(subs, s)
+	return s
+}
+
+// Subscription is a subscriber of a topic, created with Topic.Subscribe.
+type Subscription[T any] struct {
+	topic *Topic[T]
+	actor *conc.Actor[T]
+}
+
+// deliver runs handler with msg on the pool of the bus, and waits for it, so
+// that the subscriber handles one message at a time.
+func (s *Subscription[T]) deliver(handler func(T), msg T) {
+	b := s.topic.bus
+	done := make(chan struct{})
+	b.pool.Go(func() {
+		defer close(done)
+		var pc conc.PanicCatcher
+		recovered := pc.TryRecovered(func() {
+			conc.Label(s.topic.name, func() { handler(msg) })
+		})
+		if recovered == nil {
+			return
+		}
+		if b.panicHandler != nil {
+			b.panicHandler(recovered)
+		} else {
+			conc.ReportPanic(recovered)
+		}
+	})
+	<-done
+}
+
+// Len returns the number of messages waiting to be handled by the
+// subscriber.
+func (s *Subscription[T]) Len() int {
+	return s.actor.Len()
+}
+
+// Unsubscribe stops the subscriber from getting the messages published from
+// now on, and waits for it to handle the messages that were already queued,
+// or for ctx to be done, in which case it returns ctx.Err() and the messages
+// are handled in the background.
+func (s *Subscription[T]) Unsubscribe(ctx context.Context) error {
+	t := s.topic
+	t.mu.Lock()
+	subs := make([]*Subscription[T], 0, len(t.subs))
+	for _, other := range t.subs {
+		if other != s {
+			subs = append(subs, other)
+		}
+	}
+	t.subs = subs
+	t.mu.Unlock()
+
+	if err := s.close(ctx); err != nil {
+		// Leave the subscription to Bus.Close to wait for
+		return err
+	}
+	t.bus.mu.Lock()
+	delete(t.bus.subs, s)
+	t.bus.mu.Unlock()
+	return nil
+}
+
+func (s *Subscription[T]) close(ctx context.Context) error {
+	return s.actor.Close(ctx)
+}
@@ -0,0 +1,224 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc"
+)
+
+func ExampleTopic() {
+	ctx := context.Background()
+	b := New()
+	greetings := NewTopic[string](b, "greetings")
+
+	var received []string
+	greetings.Subscribe(func(msg string) { received = append(received, msg) })
+	for _, msg := range []string{"hello", "bonjour", "hola"} {
+		if err := greetings.Publish(ctx, msg); err != nil {
+			panic(err)
+		}
+	}
+	if err := b.Close(ctx); err != nil {
+		panic(err)
+	}
+	fmt.Println(received)
+
+	// Output:
+	// [hello bonjour hola]
+}
+
+func TestBus(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("every subscriber gets every message in order", func(t *testing.T) {
+		b := New().WithMaxGoroutines(2)
+		topic := NewTopic[int](b, "ints")
+		got := make([][]int, 3)
+		for i := range got {
+			i := i
+			topic.Subscribe(func(msg int) { got[i] = append(got[i], msg) })
+		}
+		for i := 0; i < 100; i++ {
+			require.NoError(t, topic.Publish(ctx, i))
+		}
+		require.NoError(t, b.Close(ctx))
+		for _, msgs := range got {
+			require.Len(t, msgs, 100)
+			for i, msg := range msgs {
+				require.Equal(t, i, msg)
+			}
+		}
+	})
+
+	t.Run("topics are shared by name", func(t *testing.T) {
+		b := New()
+		defer b.Close(ctx)
+		topic := NewTopic[int](b, "ints")
+		require.Same(t, topic, NewTopic[int](b, "ints"))
+		require.Equal(t, "ints", topic.Name())
+		require.NotSame(t, topic, NewTopic[int](b, "other"))
+		require.Panics(t, func() { NewTopic[string](b, "ints") })
+	})
+
+	t.Run("limits the goroutines running handlers", func(t *testing.T) {
+		b := New().WithMaxGoroutines(2)
+		topic := NewTopic[int](b, "ints")
+		var running, maxRunning atomic.Int64
+		var mu sync.Mutex
+		for i := 0; i < 5; i++ {
+			topic.Subscribe(func(int) {
+				n := running.Add(1)
+				mu.Lock()
+				if n > maxRunning.Load() {
+					maxRunning.Store(n)
+				}
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+			})
+		}
+		for i := 0; i < 10; i++ {
+			require.NoError(t, topic.Publish(ctx, i))
+		}
+		require.NoError(t, b.Close(ctx))
+		require.LessOrEqual(t, maxRunning.Load(), int64(2))
+	})
+
+	t.Run("slow subscribers block publishers", func(t *testing.T) {
+		b := New().WithQueueSize(1)
+		topic := NewTopic[int](b, "ints")
+		unblock := make(chan struct{})
+		sub := topic.Subscribe(func(int) { <-unblock })
+		require.NoError(t, topic.Publish(ctx, 1))
+		require.Eventually(t, func() bool { return sub.Len() == 0 }, time.Second, time.Millisecond)
+		require.NoError(t, topic.Publish(ctx, 2))
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, topic.Publish(timeoutCtx, 3), context.DeadlineExceeded)
+		close(unblock)
+		require.NoError(t, b.Close(ctx))
+	})
+
+	t.Run("overflow policy", func(t *testing.T) {
+		b := New()
+		topic := NewTopic[int](b, "ints")
+		unblock := make(chan struct{})
+		var got, dropped []int
+		sub := topic.SubscribeWith(SubscribeOptions[int]{
+			QueueSize: 2,
+			Policy:    conc.MailboxDropOldest,
+			OnDrop:    func(msg int) { dropped = append(dropped, msg) },
+		}, func(msg int) {
+			<-unblock
+			got = append(got, msg)
+		})
+		require.NoError(t, topic.Publish(ctx, 1))
+		require.Eventually(t, func() bool { return sub.Len() == 0 }, time.Second, time.Millisecond)
+		for i := 2; i <= 5; i++ {
+			require.NoError(t, topic.Publish(ctx, i))
+		}
+		close(unblock)
+		require.NoError(t, b.Close(ctx))
+		require.Equal(t, []int{1, 4, 5}, got)
+		require.Equal(t, []int{2, 3}, dropped)
+	})
+
+	t.Run("panics are isolated", func(t *testing.T) {
+		var panics []*conc.RecoveredPanic
+		var mu sync.Mutex
+		b := New().WithPanicHandler(func(recovered *conc.RecoveredPanic) {
+			mu.Lock()
+			defer mu.Unlock()
+			panics = append(panics, recovered)
+		})
+		topic := NewTopic[int](b, "ints")
+		var handled atomic.Int64
+		topic.Subscribe(func(msg int) {
+			if msg%2 == 0 {
+				panic("super bad thing")
+			}
+		})
+		topic.Subscribe(func(int) { handled.Add(1) })
+		for i := 0; i < 4; i++ {
+			require.NoError(t, topic.Publish(ctx, i))
+		}
+		require.NoError(t, b.Close(ctx))
+		require.Equal(t, int64(4), handled.Load())
+		require.Len(t, panics, 2)
+		require.Equal(t, "super bad thing", panics[0].Value)
+		require.Equal(t, "ints", panics[0].Task)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		b := New()
+		topic := NewTopic[int](b, "ints")
+		var first, second []int
+		sub := topic.Subscribe(func(msg int) { first = append(first, msg) })
+		topic.Subscribe(func(msg int) { second = append(second, msg) })
+		require.NoError(t, topic.Publish(ctx, 1))
+		require.NoError(t, sub.Unsubscribe(ctx))
+		require.NoError(t, topic.Publish(ctx, 2))
+		require.NoError(t, b.Close(ctx))
+		require.Equal(t, []int{1}, first)
+		require.Equal(t, []int{1, 2}, second)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		b := New()
+		topic := NewTopic[int](b, "ints")
+		unblock := make(chan struct{})
+		var handled atomic.Int64
+		topic.Subscribe(func(int) {
+			<-unblock
+			handled.Add(1)
+		})
+		for i := 0; i < 3; i++ {
+			require.NoError(t, topic.Publish(ctx, i))
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, b.Close(timeoutCtx), context.DeadlineExceeded)
+		require.ErrorIs(t, topic.Publish(ctx, 3), ErrBusClosed)
+		require.Panics(t, func() { topic.Subscribe(func(int) {}) })
+
+		close(unblock)
+		require.NoError(t, b.Close(ctx))
+		require.Equal(t, int64(3), handled.Load())
+	})
+
+	t.Run("concurrent publishers", func(t *testing.T) {
+		b := New()
+		topic := NewTopic[int](b, "ints")
+		var handled atomic.Int64
+		for i := 0; i < 3; i++ {
+			topic.Subscribe(func(int) { handled.Add(1) })
+		}
+		var wg conc.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Go(func() {
+				for j := 0; j < 10; j++ {
+					require.NoError(t, topic.Publish(ctx, j))
+				}
+			})
+		}
+		wg.Wait()
+		require.NoError(t, b.Close(ctx))
+		require.Equal(t, int64(300), handled.Load())
+	})
+
+	t.Run("panics on invalid options", func(t *testing.T) {
+		require.Panics(t, func() { New().WithQueueSize(0) })
+		require.Panics(t, func() { New().WithMaxGoroutines(0) })
+	})
+}
@@ -0,0 +1,44 @@
+package conc
+
+import (
+	"context"
+	"runtime"
+)
+
+// Checkpoint returns ctx.Err() if ctx is done, and nil otherwise. It is meant
+// to be called periodically from the loops of long-running, CPU-bound tasks,
+// which would not otherwise notice that they were canceled:
+//
+//	for i, row := range rows {
+//		if i%1000 == 0 {
+//			if err := conc.Checkpoint(ctx); err != nil {
+//				return err
+//			}
+//		}
+//		process(row)
+//	}
+//
+// If ctx carries a pressure function, set with WithPressure, as the contexts
+// passed to the tasks of a pool.ContextPool do, Checkpoint also yields the
+// processor with runtime.Gosched while the function reports pressure, so that
+// other goroutines get to run.
+func Checkpoint(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if pressure, ok := ctx.Value(pressureKey{}).(func() bool); ok && pressure() {
+		runtime.Gosched()
+	}
+	return nil
+}
+
+type pressureKey struct{}
+
+// WithPressure returns a copy of ctx for which Checkpoint yields whenever
+// pressure returns true. pressure is called on every call to Checkpoint, so
+// it must be cheap. WithPressure is meant for code that runs tasks on behalf
+// of its callers, such as the pools in the pool package, which report
+// pressure while tasks are waiting for a goroutine.
+func WithPressure(ctx context.Context, pressure func() bool) context.Context {
+	return context.WithValue(ctx, pressureKey{}, pressure)
+}
@@ -0,0 +1,43 @@
+package conc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not done", func(t *testing.T) {
+		require.NoError(t, Checkpoint(context.Background()))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, Checkpoint(ctx), context.Canceled)
+	})
+
+	t.Run("checks pressure", func(t *testing.T) {
+		var calls atomic.Int64
+		ctx := WithPressure(context.Background(), func() bool {
+			calls.Add(1)
+			return true
+		})
+		require.NoError(t, Checkpoint(ctx))
+		require.NoError(t, Checkpoint(ctx))
+		require.Equal(t, int64(2), calls.Load())
+	})
+
+	t.Run("does not check pressure once done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(WithPressure(context.Background(), func() bool {
+			t.Fatal("should not be called")
+			return false
+		}))
+		cancel()
+		require.ErrorIs(t, Checkpoint(ctx), context.Canceled)
+	})
+}
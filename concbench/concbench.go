@@ -0,0 +1,329 @@
+// Package concbench measures the throughput and latency of conc's pools on
+// the machine it runs on, so that configurations can be compared for a given
+// workload before picking one:
+//
+//	w := concbench.Workload{Name: "rpc", Tasks: 10000, TaskSize: 50 * time.Microsecond}
+//	for _, cfg := range concbench.Configs() {
+//		fmt.Println(concbench.Run(cfg, w))
+//	}
+//
+// Benchmark runs a configuration from a Go benchmark, and Guard fails a test
+// if a configuration regresses relative to a baseline measured on the same
+// machine, which is how conc guards the hot paths of its pools when its tests
+// are run with CONCBENCH_GUARD=1.
+package concbench
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/conc"
+	"github.com/sourcegraph/conc/pool"
+)
+
+// Runner runs tasks concurrently until Wait is called, as pool.Pool and
+// conc.WaitGroup do.
+type Runner interface {
+	Go(f func())
+	Wait()
+}
+
+// Config is a way of running tasks to measure.
+type Config struct {
+	// Name identifies the configuration in results and benchmarks.
+	Name string
+
+	// New returns a Runner to run the tasks of one run of a workload with.
+	New func() Runner
+}
+
+// Configs returns the standard configurations: a sync.WaitGroup starting a
+// goroutine per task, the baseline that the others are compared with,
+// conc.WaitGroup, and pools with the settings on their hot paths.
+func Configs() []Config {
+	return []Config{
+		{Name: "sync.WaitGroup", New: func() Runner { return &syncRunner{} }},
+		{Name: "conc.WaitGroup", New: func() Runner { return &conc.WaitGroup{} }},
+		{Name: "pool", New: func() Runner { return pool.New() }},
+		{Name: "pool/max=1", New: func() Runner { return pool.New().WithMaxGoroutines(1) }},
+		{Name: "pool/unlimited", New: func() Runner { return pool.New().WithUnlimitedGoroutines() }},
+		{Name: "pool/errors", New: func() Runner { return errorRunner{pool.New().WithErrors()} }},
+		{Name: "pool/context", New: func() Runner {
+			return contextRunner{pool.New().WithContext(context.Background())}
+		}},
+	}
+}
+
+// Baseline returns the configuration that the others are compared with, a
+// sync.WaitGroup starting a goroutine per task.
+func Baseline() Config {
+	return Configs()[0]
+}
+
+type syncRunner struct {
+	wg sync.WaitGroup
+}
+
+func (r *syncRunner) Go(f func()) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		f()
+	}()
+}
+
+func (r *syncRunner) Wait() {
+	r.wg.Wait()
+}
+
+type errorRunner struct {
+	p *pool.ErrorPool
+}
+
+func (r errorRunner) Go(f func()) {
+	r.p.Go(func() error {
+		f()
+		return nil
+	})
+}
+
+func (r errorRunner) Wait() {
+	_ = r.p.Wait()
+}
+
+type contextRunner struct {
+	p *pool.ContextPool
+}
+
+func (r contextRunner) Go(f func()) {
+	r.p.Go(func(context.Context) error {
+		f()
+		return nil
+	})
+}
+
+func (r contextRunner) Wait() {
+	_ = r.p.Wait()
+}
+
+// Workload describes the tasks of a run.
+type Workload struct {
+	// Name identifies the workload in results and benchmarks.
+	Name string
+
+	// Tasks is the number of tasks of a run. Defaults to 10000.
+	Tasks int
+
+	// TaskSize is how long each task keeps its goroutine busy, spinning
+	// rather than sleeping, as CPU-bound work does. Tasks are empty if it is
+	// 0, which measures the overhead of running them.
+	TaskSize time.Duration
+
+	// Submitters is the number of goroutines that submit the tasks to the
+	// same Runner at once, which measures the contention between them.
+	// Defaults to 1.
+	Submitters int
+}
+
+// Workloads returns the standard workloads: empty, small and larger tasks,
+// submitted from one goroutine or from one per CPU.
+func Workloads() []Workload {
+	return workloads(runtime.GOMAXPROCS(0))
+}
+
+// workloads returns the standard workloads for procs CPUs.
+func workloads(procs int) []Workload {
+	submitterCounts := []int{1}
+	if procs > 1 {
+		submitterCounts = append(submitterCounts, procs)
+	}
+	var workloads []Workload
+	for _, size := range []time.Duration{0, time.Microsecond, 50 * time.Microsecond} {
+		for _, submitters := range submitterCounts {
+			name := "empty"
+			if size > 0 {
+				name = size.String()
+			}
+			tasks := 10000
+			if size >= 50*time.Microsecond {
+				tasks = 1000
+			}
+			workloads = append(workloads, Workload{
+				Name:       fmt.Sprintf("%s/submitters=%d", name, submitters),
+				Tasks:      tasks,
+				TaskSize:   size,
+				Submitters: submitters,
+			})
+		}
+	}
+	return workloads
+}
+
+func (w Workload) withDefaults() Workload {
+	if w.Tasks < 1 {
+		w.Tasks = 10000
+	}
+	if w.Submitters < 1 {
+		w.Submitters = 1
+	}
+	if w.Submitters > w.Tasks {
+		w.Submitters = w.Tasks
+	}
+	return w
+}
+
+// Result is the measurement of one run of a workload with a configuration.
+type Result struct {
+	Config   string
+	Workload Workload
+
+	// Elapsed is the time from the submission of the first task to the
+	// return of Wait.
+	Elapsed time.Duration
+
+	// The percentiles of the latency of the tasks, from the call to Go to
+	// the start of the task.
+	LatencyP50 time.Duration
+	LatencyP99 time.Duration
+	LatencyMax time.Duration
+}
+
+// TasksPerSecond returns the throughput of the run.
+func (r Result) TasksPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Workload.Tasks) / r.Elapsed.Seconds()
+}
+
+// String formats r as a line of a table of results.
+func (r Result) String() string {
+	return fmt.Sprintf("%-20s %-28s %12.0f tasks/s  p50 %-10v p99 %-10v max %v",
+		r.Config, r.Workload.Name, r.TasksPerSecond(), r.LatencyP50, r.LatencyP99, r.LatencyMax)
+}
+
+// Run runs w once with cfg, and measures it.
+func Run(cfg Config, w Workload) Result {
+	w = w.withDefaults()
+	latencies := make([]time.Duration, w.Tasks)
+	submitted := make([]time.Time, w.Tasks)
+	runner := cfg.New()
+
+	start := time.Now()
+	if w.Submitters == 1 {
+		submit(runner, w, 0, w.Tasks, submitted, latencies)
+	} else {
+		var wg sync.WaitGroup
+		per := (w.Tasks + w.Submitters - 1) / w.Submitters
+		for from := 0; from < w.Tasks; from += per {
+			to := from + per
+			if to > w.Tasks {
+				to = w.Tasks
+			}
+			from := from
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				submit(runner, w, from, to, submitted, latencies)
+			}()
+		}
+		wg.Wait()
+	}
+	runner.Wait()
+	elapsed := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return Result{
+		Config:     cfg.Name,
+		Workload:   w,
+		Elapsed:    elapsed,
+		LatencyP50: latencies[len(latencies)/2],
+		LatencyP99: latencies[len(latencies)*99/100],
+		LatencyMax: latencies[len(latencies)-1],
+	}
+}
+
+// submit submits the tasks from to to of w to runner.
+func submit(runner Runner, w Workload, from, to int, submitted []time.Time, latencies []time.Duration) {
+	for i := from; i < to; i++ {
+		i := i
+		submitted[i] = time.Now()
+		runner.Go(func() {
+			started := time.Now()
+			latencies[i] = started.Sub(submitted[i])
+			spin(started, w.TaskSize)
+		})
+	}
+}
+
+// spin keeps the goroutine busy for d from start.
+func spin(start time.Time, d time.Duration) {
+	for d > 0 && time.Since(start) < d {
+	}
+}
+
+// Benchmark runs w with cfg b.N times, reporting the throughput in tasks/s
+// and the 99th percentile of the latency in p99-ns alongside the time per
+// run.
+func Benchmark(b *testing.B, cfg Config, w Workload) {
+	b.Helper()
+	b.ReportAllocs()
+	var tasks float64
+	var elapsed, p99 time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := Run(cfg, w)
+		tasks += float64(r.Workload.Tasks)
+		elapsed += r.Elapsed
+		p99 += r.LatencyP99
+	}
+	if elapsed > 0 {
+		b.ReportMetric(tasks/elapsed.Seconds(), "tasks/s")
+	}
+	b.ReportMetric(float64(p99.Nanoseconds())/float64(b.N), "p99-ns")
+}
+
+// guardRuns is the number of times Guard runs each configuration.
+const guardRuns = 5
+
+// Guard fails tb if cfg is more than maxSlowdown times slower than baseline
+// on w. The two are run in turn, several times, and the fastest run of each is
+// compared, so that noise from the rest of the machine affects them alike and
+// does not fail the guard.
+func Guard(tb testing.TB, baseline, cfg Config, w Workload, maxSlowdown float64) {
+	tb.Helper()
+	guard(tb, baseline, cfg, w, maxSlowdown, func(c Config) time.Duration {
+		return Run(c, w).Elapsed
+	})
+}
+
+// reporter is the part of testing.TB used by guard, so that it can be
+// tested.
+type reporter interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// guard is Guard, with the time of a run of w with a configuration
+// measured by measure, so that it can be tested without timing real work.
+func guard(r reporter, baseline, cfg Config, w Workload, maxSlowdown float64, measure func(Config) time.Duration) {
+	r.Helper()
+	var best [2]time.Duration
+	for i := 0; i < guardRuns; i++ {
+		for j, c := range []Config{baseline, cfg} {
+			if elapsed := measure(c); i == 0 || elapsed < best[j] {
+				best[j] = elapsed
+			}
+		}
+	}
+	slowdown := float64(best[1]) / float64(best[0])
+	if slowdown > maxSlowdown {
+		r.Errorf("concbench: %s is %.1fx slower than %s on %s, more than %.1fx (%v vs %v)",
+			cfg.Name, slowdown, baseline.Name, w.Name, maxSlowdown, best[1], best[0])
+	}
+}
@@ -0,0 +1,159 @@
+package concbench
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReporter struct {
+	errors []string
+}
+
+func (r *fakeReporter) Helper() {}
+
+func (r *fakeReporter) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("measures every configuration", func(t *testing.T) {
+		for _, cfg := range Configs() {
+			for _, submitters := range []int{1, 3} {
+				r := Run(cfg, Workload{Name: "small", Tasks: 100, Submitters: submitters})
+				require.Equal(t, cfg.Name, r.Config)
+				require.Equal(t, 100, r.Workload.Tasks)
+				require.Positive(t, r.Elapsed)
+				require.Positive(t, r.TasksPerSecond())
+				require.LessOrEqual(t, r.LatencyP50, r.LatencyP99)
+				require.LessOrEqual(t, r.LatencyP99, r.LatencyMax)
+				require.Contains(t, r.String(), cfg.Name)
+			}
+		}
+	})
+
+	t.Run("tasks take their size", func(t *testing.T) {
+		cfg := Config{Name: "serial", New: func() Runner { return &serialRunner{} }}
+		r := Run(cfg, Workload{Tasks: 10, TaskSize: time.Millisecond})
+		require.GreaterOrEqual(t, r.Elapsed, 10*time.Millisecond)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		r := Run(Baseline(), Workload{})
+		require.Equal(t, 10000, r.Workload.Tasks)
+		require.Equal(t, 1, r.Workload.Submitters)
+	})
+
+	t.Run("standard workloads", func(t *testing.T) {
+		for procs, want := range map[int]int{1: 3, 4: 6} {
+			names := make(map[string]bool)
+			for _, w := range workloads(procs) {
+				require.False(t, names[w.Name], w.Name)
+				names[w.Name] = true
+			}
+			require.Len(t, names, want)
+		}
+	})
+}
+
+// serialRunner runs tasks one after another, in the goroutine that submits
+// them.
+type serialRunner struct{}
+
+func (serialRunner) Go(f func()) { f() }
+func (serialRunner) Wait()       {}
+
+func TestGuard(t *testing.T) {
+	t.Parallel()
+
+	w := Workload{Name: "sized"}
+	baseline := Config{Name: "baseline"}
+	cfg := Config{Name: "pool"}
+
+	// runs returns a measure function that takes the times of the baseline
+	// and of cfg from successive pairs of runs, cycling through them.
+	runs := func(pairs ...[2]time.Duration) func(Config) time.Duration {
+		var n [2]int
+		return func(c Config) time.Duration {
+			j := 0
+			if c.Name == cfg.Name {
+				j = 1
+			}
+			d := pairs[n[j]%len(pairs)][j]
+			n[j]++
+			return d
+		}
+	}
+	const ms = time.Millisecond
+
+	t.Run("passes within the limit", func(t *testing.T) {
+		var r fakeReporter
+		guard(&r, baseline, cfg, w, 2, runs([2]time.Duration{10 * ms, 19 * ms}))
+		require.Empty(t, r.errors)
+	})
+
+	t.Run("fails beyond the limit", func(t *testing.T) {
+		var r fakeReporter
+		guard(&r, baseline, cfg, w, 2, runs([2]time.Duration{10 * ms, 25 * ms}))
+		require.Len(t, r.errors, 1)
+		require.Contains(t, r.errors[0], "pool is 2.5x slower than baseline on sized, more than 2.0x")
+	})
+
+	t.Run("compares the fastest runs", func(t *testing.T) {
+		var r fakeReporter
+		// One pair of runs is 5x apart, but the fastest runs are not.
+		guard(&r, baseline, cfg, w, 2, runs(
+			[2]time.Duration{50 * ms, 50 * ms},
+			[2]time.Duration{10 * ms, 50 * ms},
+			[2]time.Duration{50 * ms, 15 * ms},
+		))
+		require.Empty(t, r.errors)
+	})
+}
+
+// TestHotPaths guards the hot paths of the pools against regressions, by
+// checking that running empty tasks, which measures the overhead of the
+// pools, stays within a few times the cost of starting a goroutine per task.
+// It times real work, so it only runs when CONCBENCH_GUARD=1 is set, on a
+// quiet machine that can run the tasks in parallel, and without the race
+// detector, which slows the pools down far more than plain goroutines.
+func TestHotPaths(t *testing.T) {
+	if os.Getenv("CONCBENCH_GUARD") != "1" {
+		t.Skip("measures performance; set CONCBENCH_GUARD=1 to run")
+	}
+	if raceEnabled {
+		t.Skip("measures performance, which the race detector distorts")
+	}
+	if runtime.GOMAXPROCS(0) < 2 {
+		t.Skip("measures performance, which needs GOMAXPROCS >= 2")
+	}
+
+	for _, w := range Workloads() {
+		if w.TaskSize != 0 {
+			continue
+		}
+		for _, cfg := range Configs()[1:] {
+			Guard(t, Baseline(), cfg, w, 5)
+		}
+	}
+}
+
+func BenchmarkConfigs(b *testing.B) {
+	for _, w := range Workloads() {
+		w := w
+		b.Run(w.Name, func(b *testing.B) {
+			for _, cfg := range Configs() {
+				cfg := cfg
+				b.Run(cfg.Name, func(b *testing.B) {
+					Benchmark(b, cfg, w)
+				})
+			}
+		})
+	}
+}
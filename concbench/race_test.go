@@ -0,0 +1,5 @@
+//go:build race
+
+package concbench
+
+const raceEnabled = true
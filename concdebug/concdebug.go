@@ -0,0 +1,157 @@
+// Package concdebug serves the state of the pools and tasks of a process over
+// HTTP, for debugging. Like net/http/pprof, importing it for its side effects
+// registers its handler with http.DefaultServeMux, at /debug/conc:
+//
+//	import _ "github.com/sourcegraph/conc/concdebug"
+//
+// The page lists the pools that have goroutines running, with their limits and
+// the number of tasks waiting for them (see pool.LivePools), the tasks running
+// in a call to conc.Label, for how long they have been running, and the most
+// recent panics (see conc.RecentPanics). Adding ?stacks=1 to the URL includes
+// the stacks of the panics, and ?format=json returns the same information as
+// JSON. To serve it elsewhere, use Handler.
+//
+// The page shows the names of pools and the labels and panic values of tasks,
+// so, like the pprof handlers, it should not be exposed publicly.
+package concdebug
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sourcegraph/conc"
+	"github.com/sourcegraph/conc/pool"
+)
+
+func init() {
+	http.Handle("/debug/conc", Handler())
+}
+
+// Handler returns a handler that serves the state of the pools and tasks of
+// the process. See the package documentation for the format.
+func Handler() http.Handler {
+	return http.HandlerFunc(serve)
+}
+
+// Snapshot is the state of the pools and tasks of the process at a point in
+// time, as served by Handler.
+type Snapshot struct {
+	Time   time.Time
+	Pools  []pool.LivePool
+	Tasks  []Task
+	Panics []Panic
+}
+
+// Task is a task running in a call to conc.Label.
+type Task struct {
+	Label   string
+	Started time.Time
+	// Running is how long the task has been running for.
+	Running time.Duration
+}
+
+// Panic is a recent panic. See conc.RecentPanics.
+type Panic struct {
+	Time time.Time
+	// Task is the label of the task that panicked, if any.
+	Task string
+	// Message is the panic value formatted with conc.FormatPanicValue.
+	Message string
+	// Stack is the stack of the goroutine that panicked. It is only set if
+	// the stacks were asked for.
+	Stack string `json:",omitempty"`
+}
+
+// TakeSnapshot returns the current state of the pools and tasks of the
+// process. If stacks is true, the stacks of the panics are included.
+func TakeSnapshot(stacks bool) Snapshot {
+	now := time.Now()
+	s := Snapshot{Time: now, Pools: pool.LivePools()}
+	for _, task := range conc.RunningTasks() {
+		s.Tasks = append(s.Tasks, Task{
+			Label:   task.Label,
+			Started: task.Started,
+			Running: now.Sub(task.Started),
+		})
+	}
+	for _, recovered := range conc.RecentPanics() {
+		p := Panic{
+			Time:    recovered.Time,
+			Task:    recovered.Task,
+			Message: conc.FormatPanicValue(recovered.Value),
+		}
+		if stacks {
+			p.Stack = string(recovered.Stack)
+		}
+		s.Panics = append(s.Panics, p)
+	}
+	return s
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	stacks, _ := strconv.ParseBool(r.FormValue("stacks"))
+	s := TakeSnapshot(stacks)
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.FormValue("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		_ = enc.Encode(s)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writeText(w, s)
+}
+
+// writeText writes s as tables of plain text.
+func writeText(w io.Writer, s Snapshot) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "pools: %d\n", len(s.Pools))
+	if len(s.Pools) > 0 {
+		fmt.Fprintln(tw, "NAME\tGOROUTINES\tLIMIT\tQUEUED")
+		for _, p := range s.Pools {
+			name := p.Name
+			if name == "" {
+				name = "(unnamed)"
+			}
+			limit := "unlimited"
+			if p.MaxGoroutines != math.MaxInt {
+				limit = strconv.Itoa(p.MaxGoroutines)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\n", name, p.Goroutines, limit, p.Queued)
+		}
+	}
+
+	fmt.Fprintf(tw, "\ntasks: %d\n", len(s.Tasks))
+	if len(s.Tasks) > 0 {
+		fmt.Fprintln(tw, "RUNNING\tSTARTED\tLABEL")
+		for _, task := range s.Tasks {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n",
+				task.Running.Round(time.Millisecond), task.Started.Format(time.RFC3339), task.Label)
+		}
+	}
+
+	fmt.Fprintf(tw, "\npanics: %d\n", len(s.Panics))
+	if len(s.Panics) > 0 {
+		fmt.Fprintln(tw, "TIME\tTASK\tMESSAGE")
+		for _, p := range s.Panics {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Time.Format(time.RFC3339), p.Task, p.Message)
+		}
+	}
+	_ = tw.Flush()
+
+	// Stacks span several lines, so they are written after the tables
+	for _, p := range s.Panics {
+		if p.Stack != "" {
+			fmt.Fprintf(w, "\npanic: %s\n%s", p.Message, p.Stack)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package concdebug
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc"
+	"github.com/sourcegraph/conc/pool"
+)
+
+func TestHandler(t *testing.T) {
+	// Run a named pool with a labeled task, and report a panic
+	p := pool.New().WithMaxGoroutines(2).WithName("concdebug-test")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	p.Go(func() {
+		conc.Label("fetch example.com", func() {
+			close(started)
+			<-release
+		})
+	})
+	<-started
+	defer func() {
+		close(release)
+		p.Wait()
+	}()
+
+	var pc conc.PanicCatcher
+	conc.ReportPanic(pc.TryRecovered(func() {
+		conc.Label("parse", func() { panic("concdebug test panic") })
+	}))
+
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+
+	get := func(t *testing.T, query string) string {
+		resp, err := http.Get(srv.URL + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	t.Run("text", func(t *testing.T) {
+		body := get(t, "")
+		require.Regexp(t, `concdebug-test +1 +2 +0`, body)
+		require.Contains(t, body, "fetch example.com")
+		require.Regexp(t, `parse +concdebug test panic`, body)
+		require.NotContains(t, body, "panic: concdebug test panic\ngoroutine")
+	})
+
+	t.Run("stacks", func(t *testing.T) {
+		body := get(t, "?stacks=1")
+		require.Contains(t, body, "panic: concdebug test panic\ngoroutine")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var s Snapshot
+		require.NoError(t, json.Unmarshal([]byte(get(t, "?format=json")), &s))
+		require.Contains(t, s.Pools, pool.LivePool{Name: "concdebug-test", Goroutines: 1, MaxGoroutines: 2})
+
+		var labels []string
+		for _, task := range s.Tasks {
+			labels = append(labels, task.Label)
+		}
+		require.Contains(t, labels, "fetch example.com")
+		require.NotEmpty(t, s.Panics)
+		require.Equal(t, "concdebug test panic", s.Panics[0].Message)
+		require.Equal(t, "parse", s.Panics[0].Task)
+		require.Empty(t, s.Panics[0].Stack)
+	})
+}
+
+func TestDefaultServeMux(t *testing.T) {
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/conc", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "pools:")
+}
@@ -0,0 +1,330 @@
+// Package conctest provides helpers for testing concurrent code, such as code
+// built on conc.
+package conctest
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Options configures Stress. The zero value is safe to use with reasonable
+// defaults.
+type Options struct {
+	// Iterations is the number of times the scenario is run. Defaults to
+	// 100.
+	Iterations int
+
+	// Procs are the values of GOMAXPROCS that the iterations are spread
+	// over, in turn. Defaults to 1, 2, 4 and runtime.NumCPU().
+	Procs []int
+
+	// YieldProbability is the probability with which T.Yield gives up the
+	// processor. Defaults to 0.5, and a negative value disables yields.
+	YieldProbability float64
+
+	// Timeout, if set, is the time an iteration may take before it is
+	// reported as hung, which stops the stress test, since the goroutines of
+	// the hung iteration cannot be stopped.
+	Timeout time.Duration
+}
+
+// Stress runs scenario opts.Iterations times, one iteration after another,
+// changing GOMAXPROCS between iterations to shake out orderings that one
+// setting alone would not produce. Once all the iterations have run, it
+// reports a single failure to t that summarizes how many iterations failed
+// with each setting, with the log of the first one that failed, so that a
+// flaky scenario is reported once rather than once per failure.
+//
+// Each iteration gets its own T, which supports the usual failure
+// reporting methods, including with testify. Scenarios should call T.Yield
+// at interesting points and start their goroutines with T.Concurrently to
+// maximize the chances of an interleaving going wrong. Running the test with
+// -race makes the race detector check every iteration.
+//
+// Since GOMAXPROCS is global to the process, Stress must not be used in
+// parallel tests. GOMAXPROCS is restored when Stress returns.
+func Stress(t testing.TB, opts Options, scenario func(t *T)) {
+	t.Helper()
+	stress(t, opts, scenario)
+}
+
+// reporter is the part of testing.TB used by stress, so that it can be
+// tested.
+type reporter interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+func stress(r reporter, opts Options, scenario func(t *T)) {
+	r.Helper()
+
+	iterations := opts.Iterations
+	if iterations < 1 {
+		iterations = 100
+	}
+	procs := opts.Procs
+	if len(procs) == 0 {
+		procs = []int{1, 2, 4, runtime.NumCPU()}
+	}
+	yieldProbability := opts.YieldProbability
+	if yieldProbability == 0 {
+		yieldProbability = 0.5
+	}
+
+	previous := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(previous)
+
+	runs := make([]int, len(procs))
+	failures := make([]int, len(procs))
+	failed := 0
+	var first *T
+	for i := 0; i < iterations; i++ {
+		p := i % len(procs)
+		runtime.GOMAXPROCS(procs[p])
+
+		it := &T{
+			iteration:        i,
+			procs:            procs[p],
+			yieldProbability: yieldProbability,
+		}
+		runs[p]++
+		hung := !it.run(scenario, opts.Timeout)
+		if it.Failed() {
+			failures[p]++
+			failed++
+			if first == nil {
+				first = it
+			}
+		}
+		if hung {
+			iterations = i + 1
+			break
+		}
+	}
+
+	if failed == 0 {
+		return
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "conctest: %d of %d iterations failed\n", failed, iterations)
+	for p, n := range procs {
+		if runs[p] > 0 {
+			fmt.Fprintf(&sb, "\tGOMAXPROCS=%d: %d of %d failed\n", n, failures[p], runs[p])
+		}
+	}
+	fmt.Fprintf(&sb, "first failure (iteration %d, GOMAXPROCS=%d):\n%s", first.iteration, first.procs, first.output())
+	r.Errorf("%s", sb.String())
+}
+
+// T is passed to each iteration of a Stress scenario to report failures. It
+// implements the failure reporting methods of testing.T, and is safe to use
+// from the goroutines started by the scenario.
+type T struct {
+	iteration        int
+	procs            int
+	yieldProbability float64
+
+	mu       sync.Mutex
+	failed   bool
+	logs     []string
+	cleanups []func()
+}
+
+// run runs scenario with t, and returns false if it did not finish within
+// timeout.
+func (t *T) run(scenario func(t *T), timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer t.runCleanups()
+
+		completed := false
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("panic: %v\n\n%s", r, debug.Stack())
+			} else if !completed && !t.Failed() {
+				t.Errorf("runtime.Goexit called without a failure")
+			}
+		}()
+		scenario(t)
+		completed = true
+	}()
+
+	if timeout <= 0 {
+		<-done
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		t.Errorf("iteration did not finish within %v", timeout)
+		return false
+	}
+}
+
+func (t *T) runCleanups() {
+	t.mu.Lock()
+	cleanups := t.cleanups
+	t.cleanups = nil
+	t.mu.Unlock()
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
+func (t *T) output() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var sb strings.Builder
+	for _, line := range t.logs {
+		sb.WriteByte('\t')
+		sb.WriteString(strings.ReplaceAll(line, "\n", "\n\t"))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Iteration returns the index of the iteration, starting at 0.
+func (t *T) Iteration() int {
+	return t.iteration
+}
+
+// Procs returns the value of GOMAXPROCS for the iteration.
+func (t *T) Procs() int {
+	return t.procs
+}
+
+// Yield gives up the processor with the probability set in Options, letting
+// other goroutines run. Calls to Yield at the points where a scenario hands
+// off work between goroutines make unusual interleavings more likely.
+//
+// The decision to yield is taken without any synchronization between
+// goroutines, since synchronization would create happens-before edges that
+// hide data races from the race detector. For the same reason, the yields
+// cannot be reproduced from one run to the next.
+func (t *T) Yield() {
+	if t.yieldProbability < 0 {
+		return
+	}
+	// Mix the bits of the clock, which every goroutine can read
+	// independently, to get a cheap random number.
+	x := uint64(time.Now().UnixNano())
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	if float64(x>>11)/(1<<53) < t.yieldProbability {
+		runtime.Gosched()
+	}
+}
+
+// Concurrently calls f with 0 to n-1 from n goroutines, and returns once
+// they have all returned. The goroutines are all started before any of them
+// calls f, so that the calls overlap as much as possible. Panics in f are
+// reported as failures of the iteration.
+func (t *T) Concurrently(n int, f func(i int)) {
+	var start, ready, done sync.WaitGroup
+	start.Add(1)
+	ready.Add(n)
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			defer done.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("panic in goroutine %d: %v\n\n%s", i, r, debug.Stack())
+				}
+			}()
+			ready.Done()
+			start.Wait()
+			f(i)
+		}()
+	}
+	ready.Wait()
+	start.Done()
+	done.Wait()
+}
+
+// Cleanup registers a function to be called when the iteration, including
+// the goroutines it waits for, has finished. Cleanup functions are called in
+// last added, first called order.
+func (t *T) Cleanup(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cleanups = append(t.cleanups, f)
+}
+
+// Helper is a no-op, to satisfy the interfaces of assertion libraries.
+func (t *T) Helper() {}
+
+// Log records its arguments, formatted as by fmt.Sprintln, to be reported
+// if the iteration fails.
+func (t *T) Log(args ...any) {
+	t.log(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// Logf records its arguments, formatted as by fmt.Sprintf, to be reported if
+// the iteration fails.
+func (t *T) Logf(format string, args ...any) {
+	t.log(fmt.Sprintf(format, args...))
+}
+
+func (t *T) log(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logs = append(t.logs, line)
+}
+
+// Fail marks the iteration as failed, and continues running it.
+func (t *T) Fail() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failed = true
+}
+
+// Failed reports whether the iteration has failed.
+func (t *T) Failed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.failed
+}
+
+// FailNow marks the iteration as failed and stops the calling goroutine by
+// calling runtime.Goexit. As with testing.T, it must be called from the
+// goroutine running the scenario.
+func (t *T) FailNow() {
+	t.Fail()
+	runtime.Goexit()
+}
+
+// Error is equivalent to Log followed by Fail.
+func (t *T) Error(args ...any) {
+	t.Log(args...)
+	t.Fail()
+}
+
+// Errorf is equivalent to Logf followed by Fail.
+func (t *T) Errorf(format string, args ...any) {
+	t.Logf(format, args...)
+	t.Fail()
+}
+
+// Fatal is equivalent to Log followed by FailNow.
+func (t *T) Fatal(args ...any) {
+	t.Log(args...)
+	t.FailNow()
+}
+
+// Fatalf is equivalent to Logf followed by FailNow.
+func (t *T) Fatalf(format string, args ...any) {
+	t.Logf(format, args...)
+	t.FailNow()
+}
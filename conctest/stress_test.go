@@ -0,0 +1,124 @@
+package conctest
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReporter struct {
+	errors []string
+}
+
+func (r *fakeReporter) Helper() {}
+
+func (r *fakeReporter) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestStress(t *testing.T) {
+	t.Run("passing scenario", func(t *testing.T) {
+		var r fakeReporter
+		var runs atomic.Int64
+		procs := map[int]int{}
+		stress(&r, Options{Iterations: 12, Procs: []int{1, 3}}, func(t *T) {
+			runs.Add(1)
+			procs[t.Procs()]++
+		})
+		require.Empty(t, r.errors)
+		require.Equal(t, int64(12), runs.Load())
+		require.Equal(t, map[int]int{1: 6, 3: 6}, procs)
+	})
+
+	t.Run("restores GOMAXPROCS", func(t *testing.T) {
+		var r fakeReporter
+		before := runtime.GOMAXPROCS(0)
+		stress(&r, Options{Iterations: 2, Procs: []int{1}}, func(t *T) {})
+		require.Equal(t, before, runtime.GOMAXPROCS(0))
+	})
+
+	t.Run("summarizes failures", func(t *testing.T) {
+		var r fakeReporter
+		stress(&r, Options{Iterations: 10, Procs: []int{1, 2}}, func(t *T) {
+			if t.Iteration()%4 == 1 {
+				t.Logf("iteration %d", t.Iteration())
+				t.Fatalf("bad luck")
+			}
+		})
+		require.Len(t, r.errors, 1)
+		msg := r.errors[0]
+		require.Contains(t, msg, "3 of 10 iterations failed")
+		require.Contains(t, msg, "GOMAXPROCS=1: 0 of 5 failed")
+		require.Contains(t, msg, "GOMAXPROCS=2: 3 of 5 failed")
+		require.Contains(t, msg, "first failure (iteration 1, GOMAXPROCS=2)")
+		require.Contains(t, msg, "\titeration 1\n\tbad luck\n")
+	})
+
+	t.Run("works with require", func(t *testing.T) {
+		var r fakeReporter
+		stress(&r, Options{Iterations: 3}, func(t *T) {
+			require.Equal(t, 1, 2)
+		})
+		require.Len(t, r.errors, 1)
+		require.Contains(t, r.errors[0], "3 of 3 iterations failed")
+	})
+
+	t.Run("panics are failures", func(t *testing.T) {
+		var r fakeReporter
+		stress(&r, Options{Iterations: 2}, func(t *T) {
+			t.Concurrently(2, func(i int) {
+				if i == 1 {
+					panic("super bad thing happened")
+				}
+			})
+			if t.Iteration() == 1 {
+				panic("another bad thing happened")
+			}
+		})
+		require.Len(t, r.errors, 1)
+		require.Contains(t, r.errors[0], "2 of 2 iterations failed")
+		require.Contains(t, r.errors[0], "panic in goroutine 1: super bad thing happened")
+	})
+
+	t.Run("cleanups run in reverse order", func(t *testing.T) {
+		var r fakeReporter
+		var order []int
+		stress(&r, Options{Iterations: 1}, func(t *T) {
+			t.Cleanup(func() { order = append(order, 1) })
+			t.Cleanup(func() { order = append(order, 2) })
+			t.FailNow()
+		})
+		require.Equal(t, []int{2, 1}, order)
+	})
+
+	t.Run("hung iteration stops the test", func(t *testing.T) {
+		var r fakeReporter
+		var runs atomic.Int64
+		unblock := make(chan struct{})
+		defer close(unblock)
+		stress(&r, Options{Iterations: 5, Timeout: 10 * time.Millisecond}, func(t *T) {
+			if runs.Add(1) == 2 {
+				<-unblock
+			}
+		})
+		require.Equal(t, int64(2), runs.Load())
+		require.Len(t, r.errors, 1)
+		require.True(t, strings.Contains(r.errors[0], "1 of 2 iterations failed"), r.errors[0])
+		require.Contains(t, r.errors[0], "did not finish within 10ms")
+	})
+
+	t.Run("yields can be disabled", func(t *testing.T) {
+		var r fakeReporter
+		stress(&r, Options{Iterations: 1, YieldProbability: -1}, func(t *T) {
+			for i := 0; i < 100; i++ {
+				t.Yield()
+			}
+		})
+		require.Empty(t, r.errors)
+	})
+}
@@ -0,0 +1,167 @@
+package conctest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/conc/pool"
+)
+
+// TestingT is the part of testing.TB that a Trace reports failures to. It
+// is implemented by T, so that traces can be checked in Stress scenarios.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Traceable is implemented by the pools of the pool package, which can be
+// recorded by Record.
+type Traceable[P any] interface {
+	WithTracer(f func(pool.TraceEvent)) P
+}
+
+// Record configures p to record the events in the life of its tasks into a
+// Trace, on which tests can assert the order in which the tasks were queued,
+// started and finished, rather than hope for it:
+//
+//	p := pool.New().WithMaxGoroutines(1)
+//	trace := conctest.Record(t, p)
+//	p.GoLabeled("first", first)
+//	p.GoLabeled("second", second)
+//	p.Wait()
+//	trace.AssertHappensBefore(conctest.Finished("first"), conctest.Started("second"))
+//
+// Record must be called before any task is submitted to p, and replaces any
+// tracer p was configured with. Failed assertions are reported to t.
+func Record[P Traceable[P]](t TestingT, p P) *Trace {
+	tr := &Trace{t: t}
+	p.WithTracer(tr.add)
+	return tr
+}
+
+// Trace is the ordered sequence of events recorded from a pool by Record. An
+// event is recorded before any event that happens after it, so the order of
+// the events of different goroutines is one in which they could have
+// happened. A Trace is safe for concurrent use, and may be inspected while
+// the pool is running.
+type Trace struct {
+	t TestingT
+
+	mu     sync.Mutex
+	events []pool.TraceEvent
+}
+
+func (tr *Trace) add(e pool.TraceEvent) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.events = append(tr.events, e)
+}
+
+// Events returns the events recorded so far, in order.
+func (tr *Trace) Events() []pool.TraceEvent {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return append([]pool.TraceEvent(nil), tr.events...)
+}
+
+// Find returns the events recorded so far that match step, in order.
+func (tr *Trace) Find(step Step) []pool.TraceEvent {
+	var found []pool.TraceEvent
+	for _, e := range tr.Events() {
+		if step.matches(e) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// AssertHappensBefore checks that every event matching a was recorded before
+// any event matching b, and that there is at least one of each. Otherwise, it
+// reports a failure with the trace to the TestingT passed to Record, and
+// returns false.
+func (tr *Trace) AssertHappensBefore(a, b Step) bool {
+	tr.t.Helper()
+
+	events := tr.Events()
+	lastA, firstB := -1, -1
+	for i, e := range events {
+		if a.matches(e) {
+			lastA = i
+		}
+		if b.matches(e) && firstB < 0 {
+			firstB = i
+		}
+	}
+	switch {
+	case lastA < 0:
+		tr.t.Errorf("conctest: no event %v in trace:\n%s", a, formatEvents(events))
+	case firstB < 0:
+		tr.t.Errorf("conctest: no event %v in trace:\n%s", b, formatEvents(events))
+	case lastA > firstB:
+		tr.t.Errorf("conctest: expected %v to happen before %v in trace:\n%s", a, b, formatEvents(events))
+	default:
+		return true
+	}
+	return false
+}
+
+// String formats the trace with one event per line.
+func (tr *Trace) String() string {
+	return formatEvents(tr.Events())
+}
+
+func formatEvents(events []pool.TraceEvent) string {
+	var sb strings.Builder
+	for i, e := range events {
+		name := e.Name
+		if e.Pool != "" {
+			name = e.Pool + "/" + name
+		}
+		fmt.Fprintf(&sb, "\t%d: %s %q (task %d) on goroutine %d\n", i, e.Kind, name, e.Task, e.Goroutine)
+	}
+	return sb.String()
+}
+
+// Step matches the events of a kind of the tasks with a name, or of the
+// unnamed tasks if Name is empty. The names of tasks are their labels or
+// names, as passed to Pool.GoLabeled or ErrorPool.GoNamed.
+type Step struct {
+	Kind pool.TraceKind
+	Name string
+}
+
+func (s Step) matches(e pool.TraceEvent) bool {
+	return e.Kind == s.Kind && e.Name == s.Name
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s %q", s.Kind, s.Name)
+}
+
+// Submitted returns the Step of the submission of the tasks named name.
+func Submitted(name string) Step {
+	return Step{Kind: pool.TraceSubmitted, Name: name}
+}
+
+// Queued returns the Step of the tasks named name waiting for the pool to
+// accept them.
+func Queued(name string) Step {
+	return Step{Kind: pool.TraceQueued, Name: name}
+}
+
+// Started returns the Step of the start of the tasks named name.
+func Started(name string) Step {
+	return Step{Kind: pool.TraceStarted, Name: name}
+}
+
+// Finished returns the Step of the end of the tasks named name.
+func Finished(name string) Step {
+	return Step{Kind: pool.TraceFinished, Name: name}
+}
+
+// Canceled returns the Step of the cancellation of the tasks named name by
+// Pool.CancelWhere.
+func Canceled(name string) Step {
+	return Step{Kind: pool.TraceCanceled, Name: name}
+}
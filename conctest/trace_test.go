@@ -0,0 +1,76 @@
+package conctest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc/pool"
+)
+
+func TestRecord(t *testing.T) {
+	t.Run("asserts the order of tasks", func(t *testing.T) {
+		p := pool.New().WithMaxGoroutines(1)
+		trace := Record(t, p)
+		p.GoLabeled("first", func() {})
+		p.GoLabeled("second", func() {})
+		p.Wait()
+		require.True(t, trace.AssertHappensBefore(Submitted("first"), Started("first")))
+		require.True(t, trace.AssertHappensBefore(Finished("first"), Started("second")))
+
+		started := trace.Find(Started("first"))
+		require.Len(t, started, 1)
+		require.Equal(t, started[0].Goroutine, trace.Find(Started("second"))[0].Goroutine)
+	})
+
+	t.Run("reports tasks out of order", func(t *testing.T) {
+		var r fakeReporter
+		p := pool.New().WithMaxGoroutines(1)
+		trace := Record(&r, p)
+		p.GoLabeled("first", func() {})
+		p.GoLabeled("second", func() {})
+		p.Wait()
+		require.False(t, trace.AssertHappensBefore(Started("second"), Finished("first")))
+		require.Len(t, r.errors, 1)
+		require.Contains(t, r.errors[0], `expected started "second" to happen before finished "first"`)
+		require.Contains(t, r.errors[0], `started "first" (task 0)`)
+	})
+
+	t.Run("reports missing events", func(t *testing.T) {
+		var r fakeReporter
+		p := pool.New().WithName("named")
+		trace := Record(&r, p)
+		p.GoLabeled("ran", func() {})
+		p.Wait()
+		require.False(t, trace.AssertHappensBefore(Started("ran"), Canceled("ran")))
+		require.False(t, trace.AssertHappensBefore(Queued("missing"), Started("ran")))
+		require.Len(t, r.errors, 2)
+		require.Contains(t, r.errors[0], `no event canceled "ran"`)
+		require.Contains(t, r.errors[1], `no event queued "missing"`)
+		require.Contains(t, trace.String(), `finished "named/ran"`)
+	})
+
+	t.Run("records derived pools", func(t *testing.T) {
+		p := pool.NewWithResults[int]().WithContext(context.Background())
+		trace := Record(t, p)
+		p.GoNamed("task", func(context.Context) (int, error) { return 1, nil })
+		_, err := p.Wait()
+		require.NoError(t, err)
+		require.True(t, trace.AssertHappensBefore(Started("task"), Finished("task")))
+		require.Len(t, trace.Events(), 3)
+	})
+}
+
+func TestRecordStress(t *testing.T) {
+	Stress(t, Options{Iterations: 20}, func(t *T) {
+		p := pool.New().WithMaxGoroutines(1)
+		trace := Record(t, p)
+		for _, name := range []string{"a", "b", "c"} {
+			p.GoLabeled(name, t.Yield)
+		}
+		p.Wait()
+		trace.AssertHappensBefore(Finished("a"), Started("b"))
+		trace.AssertHappensBefore(Finished("b"), Started("c"))
+	})
+}
@@ -0,0 +1,52 @@
+package conc
+
+import (
+	"context"
+	"sync"
+)
+
+type drainingKey struct{}
+
+// drainSignal is the draining signal carried by a context.
+type drainSignal struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+// WithDraining returns a copy of ctx carrying a draining signal, and the
+// function that starts draining, which closes the channel returned by
+// Draining for the returned context and the contexts derived from it.
+// Starting to drain more than once has no effect. WithDraining is meant for
+// code that runs tasks on behalf of its callers, such as the pools in the
+// pool package, which start draining when they are shut down gracefully.
+func WithDraining(ctx context.Context) (context.Context, func()) {
+	s := &drainSignal{ch: make(chan struct{})}
+	drain := func() {
+		s.once.Do(func() { close(s.ch) })
+	}
+	return context.WithValue(ctx, drainingKey{}, s), drain
+}
+
+// Draining returns a channel that is closed once the task owning ctx is
+// asked to wind down, ahead of ctx being canceled, so that the task can stop
+// taking on new work and finish what it is doing cleanly, such as a
+// pool.ContextPool that is drained:
+//
+//	for {
+//		select {
+//		case <-conc.Draining(ctx):
+//			return flush(ctx)
+//		case msg := <-msgs:
+//			handle(ctx, msg)
+//		}
+//	}
+//
+// If ctx carries no draining signal, the returned channel is nil, so that it
+// is never ready. If ctx carries several, because pools are nested, the
+// signal of the innermost one is returned. See WithDraining.
+func Draining(ctx context.Context) <-chan struct{} {
+	if s, ok := ctx.Value(drainingKey{}).(*drainSignal); ok {
+		return s.ch
+	}
+	return nil
+}
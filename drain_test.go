@@ -0,0 +1,44 @@
+package conc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDraining(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no signal", func(t *testing.T) {
+		require.Nil(t, Draining(context.Background()))
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		ctx, drain := WithDraining(context.Background())
+		derived, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		select {
+		case <-Draining(derived):
+			t.Fatal("should not be draining")
+		default:
+		}
+		drain()
+		drain()
+		<-Draining(derived)
+		require.NoError(t, derived.Err())
+	})
+
+	t.Run("innermost signal", func(t *testing.T) {
+		outer, drainOuter := WithDraining(context.Background())
+		inner, _ := WithDraining(outer)
+		drainOuter()
+		<-Draining(outer)
+		select {
+		case <-Draining(inner):
+			t.Fatal("should not be draining")
+		default:
+		}
+	})
+}
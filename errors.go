@@ -0,0 +1,144 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrStop can be returned by a task to stop a group of tasks early without
+// failing. Pools and iterators that cancel the remaining tasks on the first
+// error also cancel them when a task returns ErrStop, but do not report it as
+// an error. This is useful when a task has found what the group was
+// searching for. ErrStop may be wrapped.
+var ErrStop = errors.New("conc: stop")
+
+// ErrGoexit is the value of the RecoveredPanic recorded by a PanicCatcher,
+// and so by a WaitGroup or pool, for a function that called runtime.Goexit,
+// for example with t.Fatal in a test, rather than returning or panicking.
+// runtime.Goexit cannot be stopped, so the goroutine still exits, but the
+// task is reported as failed instead of appearing to have succeeded. A call
+// to panic(nil) is reported as a panic rather than as ErrGoexit, with a
+// *runtime.PanicNilError as its value from Go 1.21.
+var ErrGoexit = errors.New("conc: task exited via runtime.Goexit")
+
+// Errors is a collection of errors, used to combine the errors returned by
+// concurrently executed tasks. It is the type of the combined error returned
+// by the pools in the pool package and the functions in the iter package, so
+// the individual errors can be inspected with errors.As:
+//
+//	var errs conc.Errors
+//	if errors.As(err, &errs) {
+//		for _, err := range errs.All() {
+//			...
+//		}
+//	}
+type Errors []error
+
+// Len returns the number of errors in the collection.
+func (e Errors) Len() int {
+	return len(e)
+}
+
+// All returns a copy of the errors in the collection, in the order they
+// were added.
+func (e Errors) All() []error {
+	return append([]error(nil), e...)
+}
+
+// Error formats the collection with the message of each error on its own
+// line, in the order the errors were added.
+func (e Errors) Error() string {
+	var sb strings.Builder
+	for i, err := range e {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns the errors in the collection, for compatibility with the
+// multi-error support of errors.Is and errors.As in go 1.20.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any error in the collection matches target.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error in the collection that matches target, and if
+// one is found, sets target to that error value and returns true.
+func (e Errors) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExternalCancel reports whether err is the error of a group of tasks that
+// were stopped by the cancellation of their context rather than by a failure
+// of their own: it is non-nil, and every error combined in it is, or wraps,
+// context.Canceled or context.DeadlineExceeded. This is the case when the
+// context passed to a pool or iterator is canceled or reaches its deadline,
+// and the tasks return ctx.Err(). Errors of the pool package that wrap a
+// context error, such as a pool.TaskTimeoutError, count as cancellations too.
+func IsExternalCancel(err error) bool {
+	if err == nil {
+		return false
+	}
+	external := true
+	eachError(err, func(err error) bool {
+		external = isContextError(err)
+		return external
+	})
+	return external
+}
+
+// FirstTaskError returns the first error combined in err that is not a
+// cancellation in the sense of IsExternalCancel, which is the error of the
+// first task that failed on its own when the other tasks were canceled as a
+// result. It returns nil if there is no such error.
+func FirstTaskError(err error) error {
+	var first error
+	eachError(err, func(err error) bool {
+		if !isContextError(err) {
+			first = err
+			return false
+		}
+		return true
+	})
+	return first
+}
+
+// eachError calls f with each error combined in err, in order, looking into
+// an Errors or any other error that unwraps to several errors, until f
+// returns false. It returns false if f did.
+func eachError(err error, f func(error) bool) bool {
+	if err == nil {
+		return true
+	}
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range multi.Unwrap() {
+			if !eachError(err, f) {
+				return false
+			}
+		}
+		return true
+	}
+	return f(err)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,99 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type codeError struct {
+	code int
+}
+
+func (e *codeError) Error() string {
+	return fmt.Sprintf("code %d", e.code)
+}
+
+func ExampleErrors() {
+	var err error = Errors{
+		errors.New("oh no!"),
+		errors.New("not again!"),
+	}
+
+	var errs Errors
+	if errors.As(err, &errs) {
+		fmt.Println(errs.Len())
+	}
+	fmt.Println(err)
+	// Output:
+	// 2
+	// oh no!
+	// not again!
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+
+	err1 := errors.New("err1")
+	err2 := &codeError{code: 2}
+	errs := Errors{err1, fmt.Errorf("wrapped: %w", err2)}
+
+	t.Run("is", func(t *testing.T) {
+		require.ErrorIs(t, errs, err1)
+		require.ErrorIs(t, errs, err2)
+		require.NotErrorIs(t, errs, errors.New("err1"))
+	})
+
+	t.Run("as", func(t *testing.T) {
+		var target *codeError
+		require.ErrorAs(t, errs, &target)
+		require.Equal(t, 2, target.code)
+	})
+
+	t.Run("accessors", func(t *testing.T) {
+		require.Equal(t, 2, errs.Len())
+		require.Len(t, errs.Unwrap(), 2)
+
+		all := errs.All()
+		require.Equal(t, []error(errs), all)
+		all[0] = nil
+		require.Equal(t, err1, errs[0], "All should return a copy")
+	})
+
+	t.Run("formatting", func(t *testing.T) {
+		require.Equal(t, "err1\nwrapped: code 2", errs.Error())
+		require.Equal(t, "err1", Errors{err1}.Error())
+	})
+}
+
+func TestIsExternalCancel(t *testing.T) {
+	t.Parallel()
+
+	err1 := errors.New("err1")
+	canceled := fmt.Errorf("wrapped: %w", context.Canceled)
+
+	require.False(t, IsExternalCancel(nil))
+	require.False(t, IsExternalCancel(err1))
+	require.False(t, IsExternalCancel(Errors{context.Canceled, err1}))
+	require.True(t, IsExternalCancel(context.Canceled))
+	require.True(t, IsExternalCancel(context.DeadlineExceeded))
+	require.True(t, IsExternalCancel(Errors{context.Canceled, canceled}))
+	require.True(t, IsExternalCancel(Errors{Errors{context.Canceled}, context.DeadlineExceeded}))
+}
+
+func TestFirstTaskError(t *testing.T) {
+	t.Parallel()
+
+	err1 := errors.New("err1")
+	err2 := fmt.Errorf("wrapped: %w", errors.New("err2"))
+
+	require.NoError(t, FirstTaskError(nil))
+	require.NoError(t, FirstTaskError(context.Canceled))
+	require.NoError(t, FirstTaskError(Errors{context.Canceled, context.DeadlineExceeded}))
+	require.Equal(t, err1, FirstTaskError(err1))
+	require.Equal(t, err1, FirstTaskError(Errors{context.Canceled, err1, err2}))
+	require.Equal(t, err2, FirstTaskError(Errors{Errors{context.Canceled, err2}, err1}))
+}
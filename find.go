@@ -0,0 +1,112 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Find evaluates predicate concurrently over items using up to workers
+// goroutines, and returns the first item for which it returns true, by order
+// of completion. As soon as a match is found, the context passed to the
+// outstanding calls to predicate is canceled and no new items are evaluated.
+// If workers < 1, it defaults to runtime.GOMAXPROCS(0).
+//
+// If predicate returns an error before a match is found, evaluation stops in
+// the same way and Find returns the error, unless it is ErrStop, in which
+// case Find returns no match and a nil error. If ctx is canceled before
+// every item has been evaluated, Find returns ctx.Err().
+func Find[T any](ctx context.Context, items []T, predicate func(context.Context, T) (bool, error), workers int) (T, bool, error) {
+	return find(ctx, items, predicate, workers, false)
+}
+
+// FindFirst is like Find, but returns the matching item with the lowest
+// index rather than the first to be found. Once a match is found, only the
+// evaluation of items after it is canceled, and FindFirst waits for the
+// items before it to be evaluated. Likewise, an error is only returned if no
+// item before it matched.
+func FindFirst[T any](ctx context.Context, items []T, predicate func(context.Context, T) (bool, error), workers int) (T, bool, error) {
+	return find(ctx, items, predicate, workers, true)
+}
+
+func find[T any](ctx context.Context, items []T, predicate func(context.Context, T) (bool, error), workers int, ordered bool) (T, bool, error) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	var (
+		next atomic.Int64
+
+		mu sync.Mutex
+		// best is the index of the item that decides the result, either by
+		// matching or by returning an error, or len(items) if there is none
+		best    = len(items)
+		bestErr error
+		// cancels holds the cancel functions of the items being evaluated
+		cancels   = make(map[int]context.CancelFunc)
+		evaluated int
+	)
+
+	task := func() {
+		for {
+			i := int(next.Add(1) - 1)
+			if i >= len(items) || ctx.Err() != nil {
+				return
+			}
+
+			mu.Lock()
+			// Indexes are handed out in increasing order, so once any item is
+			// decided, no later item can change the result.
+			if best < len(items) && (!ordered || best < i) {
+				mu.Unlock()
+				return
+			}
+			itemCtx, cancel := context.WithCancel(ctx)
+			cancels[i] = cancel
+			mu.Unlock()
+
+			ok, err := predicate(itemCtx, items[i])
+
+			mu.Lock()
+			delete(cancels, i)
+			cancel()
+			evaluated++
+			if (ok || err != nil) && i < best && (ordered || best == len(items)) {
+				best, bestErr = i, err
+				for j, cancel := range cancels {
+					if !ordered || j > i {
+						cancel()
+					}
+				}
+			}
+			mu.Unlock()
+		}
+	}
+
+	var wg WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Go(task)
+	}
+	wg.Wait()
+
+	var zero T
+	if best == len(items) {
+		if evaluated < len(items) {
+			// Items were skipped because ctx was canceled
+			return zero, false, ctx.Err()
+		}
+		return zero, false, nil
+	}
+	if bestErr != nil {
+		if errors.Is(bestErr, ErrStop) {
+			return zero, false, nil
+		}
+		return zero, false, bestErr
+	}
+	return items[best], true, nil
+}
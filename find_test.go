@@ -0,0 +1,96 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleFindFirst() {
+	items := []int{1, 3, 4, 5, 6}
+	even, found, err := FindFirst(context.Background(), items, func(ctx context.Context, i int) (bool, error) {
+		return i%2 == 0, nil
+	}, 2)
+	fmt.Println(even, found, err)
+	// Output:
+	// 4 true <nil>
+}
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+
+	isEven := func(ctx context.Context, i int) (bool, error) {
+		return i%2 == 0, nil
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		_, found, err := Find(context.Background(), []int{1, 3, 5}, isEven, 2)
+		require.NoError(t, err)
+		require.False(t, found)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, found, err := Find(context.Background(), []int{}, isEven, 0)
+		require.NoError(t, err)
+		require.False(t, found)
+	})
+
+	t.Run("match cancels outstanding work", func(t *testing.T) {
+		res, found, err := Find(context.Background(), []int{1, 2, 3}, func(ctx context.Context, i int) (bool, error) {
+			if i == 2 {
+				return true, nil
+			}
+			<-ctx.Done()
+			return false, ctx.Err()
+		}, 3)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, 2, res)
+	})
+
+	t.Run("first waits for earlier items", func(t *testing.T) {
+		res, found, err := FindFirst(context.Background(), []int{2, 4, 6}, func(ctx context.Context, i int) (bool, error) {
+			switch i {
+			case 2:
+				time.Sleep(10 * time.Millisecond)
+				return true, nil
+			case 4:
+				return true, nil
+			}
+			<-ctx.Done()
+			return false, ctx.Err()
+		}, 3)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, 2, res)
+	})
+
+	t.Run("error is returned", func(t *testing.T) {
+		err1 := errors.New("err1")
+		_, found, err := Find(context.Background(), []int{1, 3}, func(ctx context.Context, i int) (bool, error) {
+			return false, err1
+		}, 1)
+		require.ErrorIs(t, err, err1)
+		require.False(t, found)
+	})
+
+	t.Run("ErrStop is not an error", func(t *testing.T) {
+		_, found, err := Find(context.Background(), []int{1, 3}, func(ctx context.Context, i int) (bool, error) {
+			return false, ErrStop
+		}, 1)
+		require.NoError(t, err)
+		require.False(t, found)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, found, err := Find(ctx, []int{1, 2}, isEven, 1)
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, found)
+	})
+}
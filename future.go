@@ -0,0 +1,184 @@
+package conc
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+)
+
+// Future is the eventual result of an asynchronous computation. A Future is
+// created either with GoFuture, which runs a function in a WaitGroup or pool,
+// or with NewFuture, which returns a function to complete the Future by hand.
+// Continuations can be chained onto a Future with Then and Catch.
+type Future[T any] struct {
+	done     chan struct{}
+	resolved atomic.Bool
+	val      T
+	err      error
+}
+
+// Awaitable is implemented by every Future regardless of its value type, so
+// that futures of different types can be waited on together with Select.
+type Awaitable interface {
+	// Done returns a channel that is closed once the result is available.
+	Done() <-chan struct{}
+}
+
+// Runner is implemented by the types that can run a function in a new
+// goroutine they own, such as *WaitGroup and *pool.Pool.
+type Runner interface {
+	Go(func())
+}
+
+// NewFuture creates a Future along with the function that completes it. The
+// function must be called exactly once. Panics if it is called again.
+func NewFuture[T any]() (*Future[T], func(T, error)) {
+	f := &Future[T]{done: make(chan struct{})}
+	return f, f.resolve
+}
+
+// GoFuture runs f with r, for example in a new goroutine spawned in a
+// WaitGroup or in a pool, returning a Future for its result. If f panics, the
+// Future is completed with the *RecoveredPanic as its error, and the panic is
+// also propagated by r as usual.
+func GoFuture[T any](r Runner, f func() (T, error)) *Future[T] {
+	future, resolve := NewFuture[T]()
+	r.Go(func() {
+		completed := false
+		defer func() {
+			if !completed {
+				val := recover()
+				if val == nil {
+					if goexit, _ := unwinding(); goexit {
+						// f called runtime.Goexit, which carries on exiting
+						// the goroutine once this returns, so r sees it as
+						// well
+						recovered := NewRecoveredPanic(1, ErrGoexit)
+						var zero T
+						resolve(zero, &recovered)
+						return
+					}
+					// f called panic(nil). See PanicCatcher.Try.
+					val = nilPanicValue()
+				}
+				recovered := NewRecoveredPanic(1, val)
+				var zero T
+				resolve(zero, &recovered)
+				// Re-panic so that the panic is propagated by r
+				panic(val)
+			}
+		}()
+
+		val, err := f()
+		completed = true
+		resolve(val, err)
+	})
+	return future
+}
+
+func (f *Future[T]) resolve(val T, err error) {
+	if f.resolved.Swap(true) {
+		panic("future completed more than once")
+	}
+	f.val, f.err = val, err
+	close(f.done)
+}
+
+// Done returns a channel that is closed once the result of the Future is
+// available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the result of the Future is available and returns it.
+func (f *Future[T]) Wait() (T, error) {
+	<-f.done
+	return f.val, f.err
+}
+
+// WaitContext is like Wait, but returns early with ctx.Err() if ctx is done
+// before the result is available.
+func (f *Future[T]) WaitContext(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Then returns a Future for the result of calling fn with the value of f,
+// once f completes. fn is run with r, as with GoFuture. If f completes with an
+// error, including a panic, fn is not called and the returned Future
+// completes with the same error, so errors propagate along a chain of calls
+// to Then until they are handled with Catch.
+//
+// The continuation occupies one of r's goroutines while it waits for f, so if
+// r is a pool with a goroutine limit, f should already have been submitted to
+// it or be completed by something other than r.
+func Then[T, U any](f *Future[T], r Runner, fn func(T) (U, error)) *Future[U] {
+	return GoFuture(r, func() (U, error) {
+		val, err := f.Wait()
+		if err != nil {
+			var zero U
+			return zero, err
+		}
+		return fn(val)
+	})
+}
+
+// Catch returns a Future that completes with the result of f if it succeeds,
+// or otherwise with the result of calling fn with its error, which may be a
+// *RecoveredPanic. fn is run with r, as with Then.
+func (f *Future[T]) Catch(r Runner, fn func(error) (T, error)) *Future[T] {
+	return GoFuture(r, func() (T, error) {
+		val, err := f.Wait()
+		if err == nil {
+			return val, nil
+		}
+		return fn(err)
+	})
+}
+
+// Select blocks until any of the futures is complete, and returns its index
+// in futures. If more than one future is already complete, the lowest index
+// is returned. If ctx is done first, Select returns -1 and ctx.Err(). The
+// result of the selected future can then be read without blocking:
+//
+//	var user *Future[User]
+//	var orders *Future[[]Order]
+//	switch i, err := conc.Select(ctx, user, orders); {
+//	case err != nil:
+//		return err
+//	case i == 0:
+//		u, err := user.Wait()
+//		...
+//	}
+func Select(ctx context.Context, futures ...Awaitable) (int, error) {
+	for i, f := range futures {
+		select {
+		case <-f.Done():
+			return i, nil
+		default:
+		}
+	}
+
+	cases := make([]reflect.SelectCase, len(futures)+1)
+	for i, f := range futures {
+		cases[i] = reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(f.Done()),
+		}
+	}
+	cases[len(futures)] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(ctx.Done()),
+	}
+
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == len(futures) {
+		return -1, ctx.Err()
+	}
+	return chosen, nil
+}
@@ -0,0 +1,189 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleSelect() {
+	var wg WaitGroup
+	defer wg.Wait()
+
+	name := GoFuture(&wg, func() (string, error) {
+		return "gopher", nil
+	})
+	// Never completed
+	count, _ := NewFuture[int]()
+
+	i, _ := Select(context.Background(), count, name)
+	fmt.Println(i)
+	// Output:
+	// 1
+}
+
+func TestFuture(t *testing.T) {
+	t.Parallel()
+
+	t.Run("wait", func(t *testing.T) {
+		var wg WaitGroup
+		f := GoFuture(&wg, func() (int, error) { return 1, nil })
+		wg.Wait()
+		val, err := f.Wait()
+		require.NoError(t, err)
+		require.Equal(t, 1, val)
+	})
+
+	t.Run("wait context", func(t *testing.T) {
+		f, resolve := NewFuture[int]()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := f.WaitContext(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+
+		resolve(2, nil)
+		val, err := f.WaitContext(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 2, val)
+	})
+
+	t.Run("panic completes future", func(t *testing.T) {
+		var wg WaitGroup
+		f := GoFuture(&wg, func() (int, error) { panic("super bad thing") })
+		require.Panics(t, wg.Wait)
+
+		_, err := f.Wait()
+		var recovered *RecoveredPanic
+		require.ErrorAs(t, err, &recovered)
+		require.Equal(t, "super bad thing", recovered.Value)
+	})
+
+	t.Run("Goexit completes future", func(t *testing.T) {
+		var wg WaitGroup
+		f := GoFuture(&wg, func() (int, error) {
+			runtime.Goexit()
+			return 1, nil
+		})
+		require.Panics(t, wg.Wait)
+
+		_, err := f.Wait()
+		require.ErrorIs(t, err, ErrGoexit)
+	})
+
+	t.Run("panic(nil) completes future", func(t *testing.T) {
+		var wg WaitGroup
+		f := GoFuture(&wg, func() (int, error) {
+			panic(nil)
+		})
+		require.Panics(t, wg.Wait)
+
+		_, err := f.Wait()
+		var recovered *RecoveredPanic
+		require.ErrorAs(t, err, &recovered)
+		require.NotErrorIs(t, err, ErrGoexit)
+		require.IsType(t, nilPanicValue(), recovered.Value)
+	})
+
+	t.Run("panics on double resolve", func(t *testing.T) {
+		_, resolve := NewFuture[int]()
+		resolve(1, nil)
+		require.Panics(t, func() { resolve(1, nil) })
+	})
+}
+
+func TestThen(t *testing.T) {
+	t.Parallel()
+
+	t.Run("chains values", func(t *testing.T) {
+		var wg WaitGroup
+		f := GoFuture(&wg, func() (int, error) { return 21, nil })
+		doubled := Then(f, &wg, func(i int) (int, error) { return i * 2, nil })
+		formatted := Then(doubled, &wg, func(i int) (string, error) { return fmt.Sprint(i), nil })
+		wg.Wait()
+
+		val, err := formatted.Wait()
+		require.NoError(t, err)
+		require.Equal(t, "42", val)
+	})
+
+	t.Run("propagates errors to Catch", func(t *testing.T) {
+		var wg WaitGroup
+		errFailed := errors.New("failed")
+		f := GoFuture(&wg, func() (int, error) { return 0, errFailed })
+		called := false
+		next := Then(f, &wg, func(i int) (int, error) {
+			called = true
+			return i, nil
+		})
+		caught := next.Catch(&wg, func(err error) (int, error) {
+			require.ErrorIs(t, err, errFailed)
+			return -1, nil
+		})
+		wg.Wait()
+
+		require.False(t, called)
+		_, err := next.Wait()
+		require.ErrorIs(t, err, errFailed)
+		val, err := caught.Wait()
+		require.NoError(t, err)
+		require.Equal(t, -1, val)
+	})
+
+	t.Run("propagates panics", func(t *testing.T) {
+		var wg WaitGroup
+		f := GoFuture(&wg, func() (int, error) { return 1, nil })
+		next := Then(f, &wg, func(int) (int, error) { panic("super bad thing") })
+		caught := next.Catch(&wg, func(err error) (int, error) {
+			var recovered *RecoveredPanic
+			require.ErrorAs(t, err, &recovered)
+			return 0, nil
+		})
+		require.Panics(t, wg.Wait)
+
+		_, err := caught.Wait()
+		require.NoError(t, err)
+	})
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("first to complete", func(t *testing.T) {
+		a, _ := NewFuture[int]()
+		b, resolveB := NewFuture[string]()
+		go func() {
+			time.Sleep(time.Millisecond)
+			resolveB("", errors.New("failed"))
+		}()
+		i, err := Select(context.Background(), a, b)
+		require.NoError(t, err)
+		require.Equal(t, 1, i)
+
+		_, err = b.Wait()
+		require.Error(t, err)
+	})
+
+	t.Run("lowest index if several are complete", func(t *testing.T) {
+		a, resolveA := NewFuture[int]()
+		b, resolveB := NewFuture[struct{}]()
+		resolveB(struct{}{}, nil)
+		resolveA(1, nil)
+		i, err := Select(context.Background(), a, b)
+		require.NoError(t, err)
+		require.Equal(t, 0, i)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		a, _ := NewFuture[int]()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		i, err := Select(ctx, a)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, -1, i)
+	})
+}
@@ -0,0 +1,247 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// NewGroup creates a Group whose context is derived from ctx, so that the
+// group shuts down once ctx is done.
+func NewGroup(ctx context.Context) *Group {
+	g := &Group{
+		values:  valuesContext{ctx},
+		waiting: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-g.ctx.Done():
+		case <-g.waiting:
+		}
+		g.stop()
+		close(g.done)
+	}()
+	return g
+}
+
+// Group owns the whole concurrency tree of a service: the goroutines started
+// with Go, and the subsystems, such as pools and streams, started with Add.
+// Each member gets a context of its own, which carries the values of the
+// group's context, and which the group cancels when it stops the member:
+//
+//	g := conc.NewGroup(ctx)
+//	var p *pool.ContextPool
+//	g.Add(func(ctx context.Context) (wait func() error) {
+//		p = pool.New().WithContext(ctx)
+//		return p.Wait
+//	})
+//	g.Go(func(ctx context.Context) error {
+//		for range conc.Tick(ctx, time.Minute) {
+//			p.Go(refresh)
+//		}
+//		return nil
+//	})
+//	...
+//	err := g.Wait()
+//
+// The members are stopped in the reverse order they were added, as deferred
+// calls are, so that a member may use the members added before it: the
+// goroutine above, which submits tasks to the pool, is stopped before the
+// pool is waited for. Each member is waited for before the one added before
+// it is stopped.
+//
+// The group shuts down once Cancel is called, once its parent context is
+// done, or once a member fails with an error or a panic. It then stops its
+// members in order, canceling the context of each one before waiting for it.
+// A Group must be created with NewGroup, and Wait must be called to release
+// its resources.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// values is the parent context without its cancellation, from which the
+	// contexts of the members are derived, so that they can be canceled in
+	// order rather than all at once
+	values context.Context
+
+	// waiting is closed once Wait is called, and done once every member has
+	// stopped
+	waiting     chan struct{}
+	waitingOnce sync.Once
+	done        chan struct{}
+
+	pc PanicCatcher
+
+	mu sync.Mutex
+	// members are the members that have not been stopped yet, in the order
+	// they were added
+	members []*groupMember
+	// err is the first error returned by a member
+	err error
+	// stopped is set once every member has stopped
+	stopped bool
+}
+
+// groupMember is a member of a Group.
+type groupMember struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// wait blocks until the member has stopped
+	wait func()
+}
+
+// Go starts f in a new goroutine, as a member of the group, with a context
+// that is canceled once the group stops it. If f returns an error or panics,
+// the group shuts down. Panics if the group has stopped.
+func (g *Group) Go(f func(ctx context.Context) error) {
+	m := g.newMember()
+	done := make(chan struct{})
+	m.wait = func() { <-done }
+	g.add(m)
+	go func() {
+		defer close(done)
+		g.run(m, func() error { return f(m.ctx) })
+	}()
+}
+
+// Add starts a subsystem as a member of the group, by calling start with
+// the context of the member, which is canceled once the group stops it.
+// start returns the function that waits for the subsystem to stop, such as
+// the Wait method of a pool created with the context, which the group calls
+// when stopping the member. If wait returns an error or panics, the group
+// shuts down. Panics if the group has stopped.
+func (g *Group) Add(start func(ctx context.Context) (wait func() error)) {
+	m := g.newMember()
+	wait := start(m.ctx)
+	m.wait = func() { g.run(m, wait) }
+	g.add(m)
+}
+
+// Context returns the context of the group, which is canceled once the group
+// shuts down, and at the latest once Wait returns. It is meant for the code
+// that watches the group, rather than for its members, whose contexts are
+// canceled in order.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Cancel shuts the group down, stopping its members in the reverse order
+// they were added, and returns without waiting for them.
+func (g *Group) Cancel() {
+	g.cancel()
+}
+
+// Wait stops the members of the group in the reverse order they were added,
+// and returns once they have all stopped. Unless the group shuts down in the
+// meantime, the members are waited for without being canceled, so that the
+// goroutines started with Go can return on their own, and the pools started
+// with Add can finish their tasks. Members can still be added while Wait is
+// stopping the others; they are stopped before the members added before
+// them.
+//
+// Wait returns the first error returned by a member. The errors that match
+// context.Canceled, from members that the group canceled, are not reported.
+// A panic in a member is propagated once every member has stopped. Wait can
+// be called more than once.
+func (g *Group) Wait() error {
+	g.waitingOnce.Do(func() { close(g.waiting) })
+	<-g.done
+	g.cancel()
+
+	g.pc.Repanic()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+// newMember creates a member with a context derived from the values of the
+// group's context.
+func (g *Group) newMember() *groupMember {
+	m := &groupMember{}
+	m.ctx, m.cancel = context.WithCancel(g.values)
+	return m
+}
+
+// add adds m to the members of the group, panicking if the group has
+// stopped.
+func (g *Group) add(m *groupMember) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stopped {
+		m.cancel()
+		panic("conc: member added to a Group that has stopped")
+	}
+	g.members = append(g.members, m)
+}
+
+// run runs f for member m, shutting the group down if it fails.
+func (g *Group) run(m *groupMember, f func() error) {
+	recovered := g.pc.TryRecovered(func() { g.fail(m, f()) })
+	if recovered != nil {
+		g.cancel()
+	}
+	ReportPanic(recovered)
+}
+
+// fail records err, if it is the first error of the group, and shuts the
+// group down. The cancellation of the member by the group is not an error.
+func (g *Group) fail(m *groupMember, err error) {
+	if err == nil || (m.ctx.Err() != nil && errors.Is(err, context.Canceled)) {
+		return
+	}
+	g.mu.Lock()
+	if g.err == nil {
+		g.err = err
+	}
+	g.mu.Unlock()
+	g.cancel()
+}
+
+// stop stops the members of the group, from the last one added to the first.
+func (g *Group) stop() {
+	for {
+		g.mu.Lock()
+		if len(g.members) == 0 {
+			g.stopped = true
+			g.mu.Unlock()
+			return
+		}
+		m := g.members[len(g.members)-1]
+		g.members = g.members[:len(g.members)-1]
+		g.mu.Unlock()
+
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			m.wait()
+		}()
+		select {
+		case <-stopped:
+		case <-g.ctx.Done():
+			m.cancel()
+			<-stopped
+		}
+		m.cancel()
+	}
+}
+
+// valuesContext carries the values of a context, without its deadline and
+// cancellation.
+type valuesContext struct {
+	context.Context
+}
+
+func (valuesContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (valuesContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (valuesContext) Err() error {
+	return nil
+}
@@ -0,0 +1,207 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleGroup() {
+	g := NewGroup(context.Background())
+	results := make(chan int)
+	g.Add(func(ctx context.Context) func() error {
+		// A consumer, added first so that it is stopped last
+		var total int
+		var wg WaitGroup
+		wg.Go(func() {
+			for r := range results {
+				total += r
+			}
+		})
+		return func() error {
+			close(results)
+			wg.Wait()
+			fmt.Println(total)
+			return nil
+		}
+	})
+	g.Go(func(ctx context.Context) error {
+		for i := 1; i <= 3; i++ {
+			results <- i
+		}
+		return nil
+	})
+	fmt.Println(g.Wait())
+
+	// Output:
+	// 6
+	// <nil>
+}
+
+func TestGroup(t *testing.T) {
+	t.Parallel()
+
+	// stopper adds a member to g that records its name once its context is
+	// canceled.
+	stopper := func(g *Group, mu *sync.Mutex, stopped *[]string, name string) {
+		g.Add(func(ctx context.Context) func() error {
+			return func() error {
+				<-ctx.Done()
+				mu.Lock()
+				defer mu.Unlock()
+				*stopped = append(*stopped, name)
+				return ctx.Err()
+			}
+		})
+	}
+
+	t.Run("waits for members", func(t *testing.T) {
+		g := NewGroup(context.Background())
+		var completed atomic.Int64
+		for i := 0; i < 10; i++ {
+			g.Go(func(ctx context.Context) error {
+				time.Sleep(time.Millisecond)
+				completed.Add(1)
+				return ctx.Err()
+			})
+		}
+		require.NoError(t, g.Wait())
+		require.Equal(t, int64(10), completed.Load())
+		require.Error(t, g.Context().Err())
+	})
+
+	t.Run("stops members in reverse order", func(t *testing.T) {
+		g := NewGroup(context.Background())
+		var (
+			mu      sync.Mutex
+			stopped []string
+		)
+		for _, name := range []string{"a", "b", "c"} {
+			stopper(g, &mu, &stopped, name)
+		}
+		g.Cancel()
+		require.NoError(t, g.Wait())
+		require.Equal(t, []string{"c", "b", "a"}, stopped)
+	})
+
+	t.Run("members outlive the members added after them", func(t *testing.T) {
+		g := NewGroup(context.Background())
+		var first context.Context
+		g.Add(func(ctx context.Context) func() error {
+			first = ctx
+			return func() error {
+				<-ctx.Done()
+				return nil
+			}
+		})
+		g.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			if first.Err() != nil {
+				return errors.New("stopped out of order")
+			}
+			return nil
+		})
+		g.Cancel()
+		require.NoError(t, g.Wait())
+	})
+
+	t.Run("parent cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		g := NewGroup(ctx)
+		var (
+			mu      sync.Mutex
+			stopped []string
+		)
+		stopper(g, &mu, &stopped, "a")
+		stopper(g, &mu, &stopped, "b")
+		cancel()
+		require.NoError(t, g.Wait())
+		require.Equal(t, []string{"b", "a"}, stopped)
+	})
+
+	t.Run("error shuts the group down", func(t *testing.T) {
+		g := NewGroup(context.Background())
+		err := errors.New("failed")
+		var (
+			mu      sync.Mutex
+			stopped []string
+		)
+		stopper(g, &mu, &stopped, "a")
+		g.Go(func(context.Context) error { return err })
+		stopper(g, &mu, &stopped, "b")
+		require.ErrorIs(t, g.Wait(), err)
+		require.Equal(t, []string{"b", "a"}, stopped)
+	})
+
+	t.Run("shuts down before Wait", func(t *testing.T) {
+		g := NewGroup(context.Background())
+		stopped := make(chan struct{})
+		g.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			close(stopped)
+			return nil
+		})
+		g.Cancel()
+		<-stopped
+		require.NoError(t, g.Wait())
+	})
+
+	t.Run("members carry the values of the parent", func(t *testing.T) {
+		type key struct{}
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "value"), time.Hour)
+		defer cancel()
+		g := NewGroup(ctx)
+		g.Go(func(ctx context.Context) error {
+			require.Equal(t, "value", ctx.Value(key{}))
+			_, ok := ctx.Deadline()
+			require.False(t, ok)
+			return nil
+		})
+		require.NoError(t, g.Wait())
+	})
+
+	t.Run("members added while stopping", func(t *testing.T) {
+		g := NewGroup(context.Background())
+		var completed atomic.Int64
+		g.Go(func(ctx context.Context) error {
+			for i := 0; i < 3; i++ {
+				g.Go(func(ctx context.Context) error {
+					completed.Add(1)
+					return nil
+				})
+			}
+			return nil
+		})
+		require.NoError(t, g.Wait())
+		require.Equal(t, int64(3), completed.Load())
+	})
+
+	t.Run("propagates panics", func(t *testing.T) {
+		g := NewGroup(context.Background())
+		var stopped atomic.Bool
+		g.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped.Store(true)
+			return nil
+		})
+		g.Add(func(ctx context.Context) func() error {
+			return func() error { panic("super bad thing") }
+		})
+		require.Panics(t, func() { _ = g.Wait() })
+		require.True(t, stopped.Load())
+	})
+
+	t.Run("panics once stopped", func(t *testing.T) {
+		g := NewGroup(context.Background())
+		require.NoError(t, g.Wait())
+		require.NoError(t, g.Wait())
+		require.Panics(t, func() { g.Go(func(context.Context) error { return nil }) })
+	})
+}
@@ -0,0 +1,52 @@
+package conc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// HeartbeatMonitor records the heartbeats of a single task, so that tasks
+// that have stopped making progress can be detected. Pools that watch for
+// silent tasks attach a HeartbeatMonitor to the context of each task with
+// WithHeartbeatMonitor, and the task reports its progress with Heartbeat.
+type HeartbeatMonitor struct {
+	last atomic.Int64
+}
+
+// NewHeartbeatMonitor creates a HeartbeatMonitor. The time it is created
+// counts as the first heartbeat.
+func NewHeartbeatMonitor() *HeartbeatMonitor {
+	m := &HeartbeatMonitor{}
+	m.Beat()
+	return m
+}
+
+// Beat records a heartbeat.
+func (m *HeartbeatMonitor) Beat() {
+	m.last.Store(time.Now().UnixNano())
+}
+
+// Last returns the time of the most recent heartbeat.
+func (m *HeartbeatMonitor) Last() time.Time {
+	return time.Unix(0, m.last.Load())
+}
+
+type heartbeatKey struct{}
+
+// WithHeartbeatMonitor returns a copy of ctx that carries m, so that calls to
+// Heartbeat with the returned context are recorded by m.
+func WithHeartbeatMonitor(ctx context.Context, m *HeartbeatMonitor) context.Context {
+	return context.WithValue(ctx, heartbeatKey{}, m)
+}
+
+// Heartbeat reports that the task that was passed ctx is still making
+// progress. Long-running tasks should call it regularly, for example after
+// every response from an external system, so that the pool running them can
+// tell that they are not wedged. See pool.ContextPool.WithHeartbeatTimeout.
+// Heartbeat does nothing if ctx does not carry a HeartbeatMonitor.
+func Heartbeat(ctx context.Context) {
+	if m, ok := ctx.Value(heartbeatKey{}).(*HeartbeatMonitor); ok {
+		m.Beat()
+	}
+}
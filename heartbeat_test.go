@@ -0,0 +1,29 @@
+package conc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records heartbeats", func(t *testing.T) {
+		t.Parallel()
+		m := NewHeartbeatMonitor()
+		first := m.Last()
+		require.False(t, first.IsZero())
+
+		time.Sleep(time.Millisecond)
+		Heartbeat(WithHeartbeatMonitor(context.Background(), m))
+		require.True(t, m.Last().After(first))
+	})
+
+	t.Run("without a monitor", func(t *testing.T) {
+		t.Parallel()
+		require.NotPanics(t, func() { Heartbeat(context.Background()) })
+	})
+}
@@ -0,0 +1,225 @@
+package iter
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sourcegraph/conc"
+)
+
+// Result is a result sent by MapChan, with the index of the element of the
+// input it was mapped from.
+type Result[R any] struct {
+	// Index is the index of the element in the input.
+	Index int
+	// Value is the result of the element, or the zero value if it timed out.
+	Value R
+	// Err is the *TimeoutError of the element if it timed out, or nil
+	// otherwise.
+	Err error
+}
+
+// MapChan is like Map, but instead of returning the results once they have
+// all been computed, it sends each of them on the returned channel as soon as
+// it is computed, in the order they complete, so that a large output can be
+// consumed incrementally. The channel is closed once every element has been
+// sent.
+//
+// Once ctx is done, no new elements are started, the results that are not
+// yet sent are dropped and the channel is closed, so a caller that stops
+// reading before the channel is closed must cancel ctx to release the
+// goroutines. A panic in f is handled as by conc.Go, once the channel is
+// closed.
+//
+// MapChan always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Mapper.
+func MapChan[T, R any](ctx context.Context, input []T, f func(*T) R) <-chan Result[R] {
+	return Mapper[T, R]{}.MapChan(ctx, input, f)
+}
+
+// MapChan is like Map, but sends each result on the returned channel as soon
+// as it is computed. Elements that time out are sent with their error. See
+// the package-level MapChan.
+func (m Mapper[T, R]) MapChan(ctx context.Context, input []T, f func(*T) R) <-chan Result[R] {
+	out := make(chan Result[R])
+	r := Iterator[T](m).runner()
+	conc.Go(func() {
+		defer close(out)
+		r.run(len(input), func(i int) {
+			if ctx.Err() != nil {
+				r.stopped.Store(true)
+				return
+			}
+			select {
+			case out <- mapResult(r, input, f, i):
+			case <-ctx.Done():
+				r.stopped.Store(true)
+			}
+		})
+	})
+	return out
+}
+
+// MapChanOrdered is like MapChan, but sends the results in the order of the
+// input. Results that complete early are buffered until it is their turn, up
+// to window of them: an element is not started until the result window
+// elements before it has been sent, so that a slow element cannot make the
+// buffer grow without bound. A larger window keeps the goroutines busy for
+// longer while an element is slow. Panics if window < 1.
+//
+// MapChanOrdered always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Mapper.
+func MapChanOrdered[T, R any](ctx context.Context, input []T, f func(*T) R, window int) <-chan Result[R] {
+	return Mapper[T, R]{}.MapChanOrdered(ctx, input, f, window)
+}
+
+// MapChanOrdered is like MapChan, but sends the results in the order of the
+// input, buffering up to window of them. See the package-level
+// MapChanOrdered.
+func (m Mapper[T, R]) MapChanOrdered(ctx context.Context, input []T, f func(*T) R, window int) <-chan Result[R] {
+	if window < 1 {
+		panic("window must be greater than zero")
+	}
+	out := make(chan Result[R])
+	r := Iterator[T](m).runner()
+	ro := &reorderer[R]{
+		window: window,
+		buf:    make([]Result[R], window),
+		ready:  make([]bool, window),
+	}
+	ro.cond.L = &ro.mu
+	conc.Go(func() {
+		defer close(out)
+		var wg conc.WaitGroup
+		wg.Go(func() {
+			defer ro.finish()
+			r.run(len(input), func(i int) {
+				if ctx.Err() != nil {
+					r.stopped.Store(true)
+					ro.cancel()
+					return
+				}
+				if !ro.wait(i, r) {
+					return
+				}
+				completed := false
+				defer func() {
+					if !completed {
+						// f panicked, so the result will never be put, and
+						// the results after it cannot be sent
+						r.stopped.Store(true)
+						ro.cancel()
+					}
+				}()
+				ro.put(i, mapResult(r, input, f, i))
+				completed = true
+			})
+		})
+		ro.emit(ctx, out, len(input))
+		wg.Wait()
+	})
+	return out
+}
+
+// mapResult maps the element at index i with f.
+func mapResult[T, R any](r *runner, input []T, f func(*T) R, i int) Result[R] {
+	// The result is written to a local so that a callback that times out
+	// can never write into a result after it has been sent.
+	var val R
+	if err := r.call(i, func() { val = f(&input[i]) }); err != nil {
+		return Result[R]{Index: i, Err: err}
+	}
+	return Result[R]{Index: i, Value: val}
+}
+
+// reorderer buffers the results of MapChanOrdered until they can be sent in
+// order. The results waiting to be sent all have an index in
+// [next, next+window), so they are kept in a ring indexed by index%window.
+type reorderer[R any] struct {
+	window int
+
+	mu   sync.Mutex
+	cond sync.Cond
+	// next is the index of the next result to send
+	next  int
+	buf   []Result[R]
+	ready []bool
+	// canceled is set once no more results will be sent, and finished once
+	// no more results will be put
+	canceled bool
+	finished bool
+}
+
+// wait blocks until the result at index i fits in the window, returning
+// false if the iteration was stopped in the meantime.
+func (ro *reorderer[R]) wait(i int, r *runner) bool {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	for i >= ro.next+ro.window && !ro.canceled && !r.stopped.Load() {
+		ro.cond.Wait()
+	}
+	return !ro.canceled && !r.stopped.Load()
+}
+
+// put buffers the result at index i.
+func (ro *reorderer[R]) put(i int, res Result[R]) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	ro.buf[i%ro.window] = res
+	ro.ready[i%ro.window] = true
+	ro.cond.Broadcast()
+}
+
+// emit sends the buffered results on out in order, until n results have
+// been sent or none are left to send.
+func (ro *reorderer[R]) emit(ctx context.Context, out chan<- Result[R], n int) {
+	for {
+		ro.mu.Lock()
+		for ro.next < n && !ro.ready[ro.next%ro.window] && !ro.canceled {
+			if ro.finished {
+				// The element was never started, since the iteration
+				// stopped, so skip it.
+				ro.next++
+				continue
+			}
+			ro.cond.Wait()
+		}
+		if ro.next == n || ro.canceled {
+			ro.mu.Unlock()
+			return
+		}
+		res := ro.buf[ro.next%ro.window]
+		ro.buf[ro.next%ro.window] = Result[R]{}
+		ro.ready[ro.next%ro.window] = false
+		ro.next++
+		ro.cond.Broadcast()
+		ro.mu.Unlock()
+
+		select {
+		case out <- res:
+		case <-ctx.Done():
+			ro.cancel()
+			return
+		}
+	}
+}
+
+// cancel stops the emission of results.
+func (ro *reorderer[R]) cancel() {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	ro.canceled = true
+	ro.cond.Broadcast()
+}
+
+// finish records that no more results will be put.
+func (ro *reorderer[R]) finish() {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	ro.finished = true
+	ro.cond.Broadcast()
+}
@@ -0,0 +1,148 @@
+package iter
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapChan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sends every result", func(t *testing.T) {
+		ints := make([]int, 100)
+		for i := range ints {
+			ints[i] = i
+		}
+		var indexes []int
+		for res := range MapChan(context.Background(), ints, func(val *int) int { return *val * 2 }) {
+			require.NoError(t, res.Err)
+			require.Equal(t, res.Index*2, res.Value)
+			indexes = append(indexes, res.Index)
+		}
+		sort.Ints(indexes)
+		require.Equal(t, ints, indexes)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		for range MapChan(context.Background(), []int{}, func(*int) int { panic("this should never be called") }) {
+			t.Fatal("should not send")
+		}
+	})
+
+	t.Run("stops once canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls atomic.Int64
+		ints := make([]int, 1000)
+		results := Mapper[int, int]{MaxGoroutines: 2}.MapChan(ctx, ints, func(*int) int {
+			calls.Add(1)
+			return 0
+		})
+		<-results
+		cancel()
+		for range results {
+		}
+		require.Less(t, calls.Load(), int64(len(ints)))
+	})
+
+	t.Run("timed out elements are sent with their error", func(t *testing.T) {
+		m := Mapper[int, int]{Timeout: 10 * time.Millisecond}
+		for res := range m.MapChan(context.Background(), []int{0, 1}, func(val *int) int {
+			if *val == 1 {
+				time.Sleep(time.Second)
+			}
+			return 1
+		}) {
+			if res.Index == 1 {
+				var timeoutErr *TimeoutError
+				require.ErrorAs(t, res.Err, &timeoutErr)
+				require.Equal(t, 0, res.Value)
+			} else {
+				require.NoError(t, res.Err)
+				require.Equal(t, 1, res.Value)
+			}
+		}
+	})
+}
+
+func TestMapChanOrdered(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sends results in order", func(t *testing.T) {
+		ints := make([]int, 100)
+		for i := range ints {
+			ints[i] = i
+		}
+		m := Mapper[int, int]{MaxGoroutines: 10}
+		var values []int
+		for res := range m.MapChanOrdered(context.Background(), ints, func(val *int) int {
+			// Later elements complete first
+			time.Sleep(time.Duration(len(ints)-*val) * 10 * time.Microsecond)
+			return *val
+		}, 5) {
+			require.NoError(t, res.Err)
+			require.Equal(t, len(values), res.Index)
+			values = append(values, res.Value)
+		}
+		require.Equal(t, ints, values)
+	})
+
+	t.Run("window bounds the elements started", func(t *testing.T) {
+		release := make(chan struct{})
+		var started atomic.Int64
+		m := Mapper[int, int]{MaxGoroutines: 8}
+		results := m.MapChanOrdered(context.Background(), make([]int, 20), func(*int) int {
+			if started.Add(1) == 1 {
+				<-release
+			}
+			return 0
+		}, 3)
+
+		require.Eventually(t, func() bool { return started.Load() == 3 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, int64(3), started.Load())
+
+		close(release)
+		n := 0
+		for range results {
+			n++
+		}
+		require.Equal(t, 20, n)
+	})
+
+	t.Run("stops once canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		results := Mapper[int, int]{MaxGoroutines: 4}.MapChanOrdered(ctx, make([]int, 1000), func(*int) int { return 0 }, 2)
+		<-results
+		cancel()
+		for range results {
+		}
+	})
+
+	t.Run("fail on timeout sends the results so far", func(t *testing.T) {
+		m := Mapper[int, int]{MaxGoroutines: 1, Timeout: 10 * time.Millisecond, OnTimeout: Fail}
+		var results []Result[int]
+		for res := range m.MapChanOrdered(context.Background(), []int{0, 1, 2}, func(val *int) int {
+			if *val == 1 {
+				time.Sleep(time.Second)
+			}
+			return *val
+		}, 2) {
+			results = append(results, res)
+		}
+		require.Len(t, results, 2)
+		require.Equal(t, 0, results[0].Index)
+		require.NoError(t, results[0].Err)
+		require.Equal(t, 1, results[1].Index)
+		var timeoutErr *TimeoutError
+		require.ErrorAs(t, results[1].Err, &timeoutErr)
+	})
+
+	t.Run("panics on invalid window", func(t *testing.T) {
+		require.Panics(t, func() { MapChanOrdered(context.Background(), []int{1}, func(*int) int { return 0 }, 0) })
+	})
+}
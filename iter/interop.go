@@ -0,0 +1,60 @@
+package iter
+
+// Filter returns the elements of input for which keep returns true, in their
+// original order. keep is called in parallel, so it can do expensive work,
+// such as a lookup, to decide.
+//
+// Filter always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Iterator.
+func Filter[T any](input []T, keep func(*T) bool) []T {
+	res, _ := Iterator[T]{}.Filter(input, keep)
+	return res
+}
+
+// Filter returns the elements of input for which keep returns true, in their
+// original order. The returned error reports any elements that timed out,
+// which are left out of the result.
+func (iter Iterator[T]) Filter(input []T, keep func(*T) bool) ([]T, error) {
+	kept, err := Mapper[T, bool](iter).Map(input, keep)
+
+	n := 0
+	for _, k := range kept {
+		if k {
+			n++
+		}
+	}
+	res := make([]T, 0, n)
+	for i, k := range kept {
+		if k {
+			res = append(res, input[i])
+		}
+	}
+	return res, err
+}
+
+// Parallel adapts f, a function that maps a single value, such as the mappers
+// used with slice utility libraries like samber/lo, into a function that maps
+// a whole slice in parallel, so that a step of an existing pipeline can be
+// parallelized without rewriting f:
+//
+//	names = iter.Parallel(strings.ToUpper)(names)
+//
+// The returned function is Map with f. For a configurable goroutine limit,
+// use Mapper.Parallel.
+func Parallel[T, R any](f func(T) R) func(input []T) []R {
+	return func(input []T) []R {
+		res, _ := Mapper[T, R]{}.Parallel(f)(input)
+		return res
+	}
+}
+
+// Parallel adapts f into a function that maps a whole slice in parallel. The
+// returned function reports any elements that timed out, which are left as
+// the zero value in the result. See the package-level Parallel.
+func (m Mapper[T, R]) Parallel(f func(T) R) func(input []T) ([]R, error) {
+	return func(input []T) ([]R, error) {
+		return m.Map(input, func(t *T) R {
+			return f(*t)
+		})
+	}
+}
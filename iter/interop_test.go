@@ -0,0 +1,57 @@
+package iter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps order", func(t *testing.T) {
+		ints := make([]int, 100)
+		for i := range ints {
+			ints[i] = i
+		}
+		res := Filter(ints, func(i *int) bool { return *i%3 == 0 })
+		require.Len(t, res, 34)
+		for i, val := range res {
+			require.Equal(t, i*3, val)
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		require.Empty(t, Filter([]int{}, func(*int) bool { return true }))
+	})
+
+	t.Run("timed out elements are left out", func(t *testing.T) {
+		res, err := Iterator[int]{Timeout: 10 * time.Millisecond}.Filter([]int{1, 2, 3}, func(i *int) bool {
+			if *i == 2 {
+				time.Sleep(time.Second)
+			}
+			return true
+		})
+		var timeoutErr *TimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, []int{1, 3}, res)
+	})
+}
+
+func TestParallel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("maps values", func(t *testing.T) {
+		upper := Parallel(strings.ToUpper)
+		require.Equal(t, []string{"A", "B", "C"}, upper([]string{"a", "b", "c"}))
+	})
+
+	t.Run("mapper", func(t *testing.T) {
+		length := Mapper[string, int]{MaxGoroutines: 2}.Parallel(func(s string) int { return len(s) })
+		res, err := length([]string{"a", "bb", "ccc"})
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 2, 3}, res)
+	})
+}
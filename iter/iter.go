@@ -1,14 +1,179 @@
 package iter
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/sourcegraph/conc"
-	"github.com/sourcegraph/sourcegraph/lib/errors"
 )
 
+// Iterator can be used to configure the behaviour of ForEach and ForEachIdx.
+// The zero value is safe to use with reasonable defaults.
+//
+// Iterator is also safe for reuse and concurrent use.
+type Iterator[T any] struct {
+	// MaxGoroutines controls the maximum number of goroutines to use on this
+	// Iterator's methods. If unset, MaxGoroutines defaults to
+	// runtime.GOMAXPROCS(0).
+	MaxGoroutines int
+
+	// Timeout limits how long the callback may run for a single element. If
+	// unset, there is no timeout.
+	//
+	// A callback that times out is not interrupted. It keeps running in the
+	// background, and its panics go to the handler set with
+	// conc.SetDefaultPanicHandler rather than to the caller, while the
+	// goroutine that called it moves on to the next element. The element it
+	// was called with must not be accessed until the callback has returned.
+	Timeout time.Duration
+
+	// OnTimeout controls what happens after an element times out. Timed-out
+	// elements are always reported as a *TimeoutError in the returned error.
+	OnTimeout TimeoutPolicy
+
+	// StaticPartitioning, if set, splits the elements into one contiguous
+	// range per goroutine up front, instead of handing elements out as
+	// goroutines become free. This avoids the cost of sharing the work and
+	// keeps each goroutine on its own part of memory, which suits CPU-bound
+	// numeric loops where every element takes about as long, but lets the
+	// goroutines that finish early sit idle when they do not.
+	StaticPartitioning bool
+
+	// OnProgress, if set, is called after each element has been processed
+	// with the number of elements processed so far and the total number of
+	// elements. Calls are never concurrent, and done is strictly increasing.
+	OnProgress func(done, total int)
+
+	// Describe, if set, is called with each element that fails, to describe
+	// it in its *ElementError or *TimeoutError, for example with its ID or a
+	// short excerpt, so that the combined error pinpoints which inputs
+	// failed. For an element that timed out, it is called while the callback
+	// may still be running, so it must only read fields that the callback
+	// does not write.
+	Describe func(*T) string
+}
+
+// WithOptions returns a copy of iter with the settings of opts that are set
+// applied to its fields. The name of opts is ignored, since iterators have no
+// name. Panics if opts.MaxGoroutines < 0.
+func (iter Iterator[T]) WithOptions(opts conc.Options) Iterator[T] {
+	if opts.MaxGoroutines < 0 {
+		panic("max goroutines must not be negative")
+	}
+	if opts.MaxGoroutines != 0 {
+		iter.MaxGoroutines = opts.MaxGoroutines
+	}
+	if opts.OnProgress != nil {
+		iter.OnProgress = opts.OnProgress
+	}
+	return iter
+}
+
+// TimeoutPolicy controls the behaviour of an Iterator after an element has
+// timed out.
+type TimeoutPolicy int
+
+const (
+	// Skip continues processing the rest of the elements. This is the default.
+	Skip TimeoutPolicy = iota
+
+	// Fail stops processing any elements that have not yet started.
+	Fail
+)
+
+// TimeoutError is returned for each element whose callback exceeded the
+// Iterator's Timeout.
+type TimeoutError struct {
+	// Index is the index of the element that timed out.
+	Index int
+	// Item describes the element, if the Iterator has a Describe function.
+	Item string
+	// Timeout is the timeout that was exceeded.
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", elementName(e.Index, e.Item), e.Timeout)
+}
+
+// ElementError wraps an error returned by the callback for an element, in
+// the combined errors returned by MapErr and ForEachErr, with the index of
+// the element, so that the elements that failed can be told apart, and
+// retried with FailedIndices.
+type ElementError struct {
+	// Index is the index of the element that failed.
+	Index int
+	// Item describes the element, if the Iterator has a Describe function.
+	Item string
+	// Err is the error returned for the element.
+	Err error
+}
+
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("%s: %s", elementName(e.Index, e.Item), e.Err)
+}
+
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
+func elementName(index int, item string) string {
+	if item == "" {
+		return fmt.Sprintf("element %d", index)
+	}
+	return fmt.Sprintf("element %d (%s)", index, item)
+}
+
+// FailedIndices returns the indices of the elements reported in err, as an
+// *ElementError or a *TimeoutError, in increasing order and without
+// duplicates, so that only the elements that failed can be retried:
+//
+//	_, err := iter.MapErr(input, f)
+//	for _, i := range iter.FailedIndices(err) {
+//		retry(input[i])
+//	}
+//
+// Combined errors, such as conc.Errors, and wrapped errors are searched. It
+// returns nil if err reports no element.
+func FailedIndices(err error) []int {
+	seen := make(map[int]bool)
+	var indices []int
+	var walk func(err error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+			return
+		case *ElementError:
+			if !seen[e.Index] {
+				seen[e.Index] = true
+				indices = append(indices, e.Index)
+			}
+			return
+		case *TimeoutError:
+			if !seen[e.Index] {
+				seen[e.Index] = true
+				indices = append(indices, e.Index)
+			}
+			return
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+			return
+		}
+		walk(errors.Unwrap(err))
+	}
+	walk(err)
+	sort.Ints(indices)
+	return indices
+}
+
 // ForEach executes f in parallel over each element in input.
 //
 // It is safe to mutate the input parameter, which makes it
@@ -16,9 +181,20 @@ import (
 //
 // ForEach always uses at most runtime.GOMAXPROCS goroutines.
 // It takes roughly 2µs to start up the goroutines and adds
-// an overhead of roughly 50ns per element of input.
+// an overhead of roughly 50ns per element of input. For
+// a configurable goroutine limit, use a custom Iterator.
 func ForEach[T any](input []T, f func(*T)) {
-	ForEachIdx(input, func(_ int, t *T) {
+	_ = Iterator[T]{}.ForEach(input, f)
+}
+
+// ForEach executes f in parallel over each element in input, using up to the
+// Iterator's configured maximum number of goroutines. The returned error
+// reports any elements that timed out, in the order of the elements.
+//
+// It is safe to mutate the input parameter, which makes it
+// possible to map in place.
+func (iter Iterator[T]) ForEach(input []T, f func(*T)) error {
+	return iter.ForEachIdx(input, func(_ int, t *T) {
 		f(t)
 	})
 }
@@ -26,54 +202,540 @@ func ForEach[T any](input []T, f func(*T)) {
 // ForEachIdx is the same as ForEach except it also provides the
 // index of the element to the callback.
 func ForEachIdx[T any](input []T, f func(int, *T)) {
-	numTasks := runtime.GOMAXPROCS(0)
-	if numTasks > len(input) {
-		// No more tasks than the number of input items
-		numTasks = len(input)
+	_ = Iterator[T]{}.ForEachIdx(input, f)
+}
+
+// ForEachIdx is the same as ForEach except it also provides the
+// index of the element to the callback.
+func (iter Iterator[T]) ForEachIdx(input []T, f func(int, *T)) error {
+	r := iter.runner()
+	r.describe = iter.describer(input)
+	r.run(len(input), func(i int) {
+		r.addErr(i, r.call(i, func() { f(i, &input[i]) }))
+	})
+	return r.err()
+}
+
+// ForEachErr executes f in parallel over each element in input, stopping at
+// the first error. The context passed to f is derived from ctx, and is
+// canceled as soon as any call to f returns an error, so that the remaining
+// calls can exit early. No new elements are started once that context is
+// done. ForEachErr returns the first error returned by f, wrapped in an
+// *ElementError, or ctx.Err() if some elements were never started because
+// ctx was canceled.
+//
+// If f returns conc.ErrStop, the remaining elements are canceled in the same
+// way, but ForEachErr returns nil, so the iteration can be stopped early once
+// there is nothing left to do.
+//
+// ForEachErr always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Iterator.
+func ForEachErr[T any](ctx context.Context, input []T, f func(context.Context, *T) error) error {
+	return Iterator[T]{}.ForEachErr(ctx, input, f)
+}
+
+// ForEachErr executes f in parallel over each element in input, stopping at
+// the first error, including timeouts. See the package-level ForEachErr for
+// details.
+func (iter Iterator[T]) ForEachErr(ctx context.Context, input []T, f func(context.Context, *T) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		r        = iter.runner()
+		errOnce  sync.Once
+		firstErr error
+		skipped  atomic.Bool
+	)
+	fail := func(i int, err error) {
+		errOnce.Do(func() {
+			firstErr = r.elementError(i, err)
+			cancel()
+		})
 	}
+	r.describe = iter.describer(input)
+	r.run(len(input), func(i int) {
+		if ctx.Err() != nil {
+			skipped.Store(true)
+			return
+		}
 
-	var idx atomic.Int64
-	// create the task outside the loop to avoid extra closure allocations
-	task := func() {
-		i := int(idx.Add(1) - 1)
-		for ; i < len(input); i = int(idx.Add(1) - 1) {
-			f(i, &input[i])
+		// The error is written to a local that is only read if the callback
+		// returned, since one that times out keeps running and may still
+		// write it.
+		var err error
+		if timeoutErr := r.call(i, func() { err = f(ctx, &input[i]) }); timeoutErr != nil {
+			fail(i, timeoutErr)
+			return
 		}
+		if err != nil {
+			fail(i, err)
+		}
+	})
+
+	if firstErr != nil {
+		if errors.Is(firstErr, conc.ErrStop) {
+			return nil
+		}
+		return firstErr
+	}
+	if skipped.Load() {
+		return ctx.Err()
 	}
+	return nil
+}
 
-	var wg conc.WaitGroup
-	for i := 0; i < numTasks; i++ {
-		wg.Go(task)
+// ForEachN calls f in parallel with each index in [0, n), stopping at the
+// first error, without the need for a slice to iterate over. Indices are
+// handed out to the goroutines in contiguous chunks, so that the overhead per
+// index stays small even for millions of cheap calls.
+//
+// No new indices are started once f has returned an error or ctx is done.
+// ForEachN returns the first error returned by f, or ctx.Err() if some
+// indices were never started because ctx was done. If f returns
+// conc.ErrStop, the remaining indices are skipped in the same way, but
+// ForEachN returns nil.
+//
+// ForEachN always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Iterator.
+func ForEachN(ctx context.Context, n int, f func(i int) error) error {
+	return Iterator[int]{}.ForEachN(ctx, n, f)
+}
+
+// ForEachN calls f in parallel with each index in [0, n), stopping at the
+// first error, including timeouts. The element type of the Iterator is not
+// used. See the package-level ForEachN for details.
+func (iter Iterator[T]) ForEachN(ctx context.Context, n int, f func(i int) error) error {
+	var (
+		r        = iter.runner()
+		done     = ctx.Done()
+		errOnce  sync.Once
+		firstErr error
+		skipped  atomic.Bool
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			r.stopped.Store(true)
+		})
 	}
-	wg.Wait()
+	r.chunkSize = chunkSize(n, r.numTasks(n))
+	r.run(n, func(i int) {
+		select {
+		case <-done:
+			skipped.Store(true)
+			r.stopped.Store(true)
+			return
+		default:
+		}
+
+		// As in ForEachErr, err is only read if the callback returned.
+		var err error
+		if timeoutErr := r.call(i, func() { err = f(i) }); timeoutErr != nil {
+			fail(timeoutErr)
+			return
+		}
+		if err != nil {
+			fail(err)
+		}
+	})
+
+	if firstErr != nil {
+		if errors.Is(firstErr, conc.ErrStop) {
+			return nil
+		}
+		return firstErr
+	}
+	if skipped.Load() {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// chunkSize returns the number of consecutive indices that each of numTasks
+// goroutines claims at once to iterate over n indices. Each goroutine gets
+// several chunks, so that the goroutines still finish at about the same time
+// when some indices take longer than others.
+func chunkSize(n, numTasks int) int {
+	const chunksPerTask = 16
+	if numTasks < 1 {
+		return 1
+	}
+	if size := n / (numTasks * chunksPerTask); size > 1 {
+		return size
+	}
+	return 1
+}
+
+// Mapper is an Iterator with a result type R. It can be used to configure
+// the behaviour of Map and MapErr. The zero value is safe to use with
+// reasonable defaults.
+//
+// Mapper is also safe for reuse and concurrent use.
+type Mapper[T, R any] Iterator[T]
+
+// WithOptions returns a copy of m with the settings of opts that are set
+// applied to its fields. See Iterator.WithOptions.
+func (m Mapper[T, R]) WithOptions(opts conc.Options) Mapper[T, R] {
+	return Mapper[T, R](Iterator[T](m).WithOptions(opts))
 }
 
 // Map applies f to each element of input, returning the mapped result.
+//
+// Map always uses at most runtime.GOMAXPROCS goroutines. For a configurable
+// goroutine limit, use a custom Mapper.
 func Map[T, R any](input []T, f func(*T) R) []R {
-	res := make([]R, len(input))
-	ForEachIdx(input, func(i int, t *T) {
-		res[i] = f(t)
-	})
+	res, _ := Mapper[T, R]{}.Map(input, f)
 	return res
 }
 
+// Map applies f to each element of input, returning the mapped result. The
+// returned error reports any elements that timed out, which are left as the
+// zero value in the result.
+func (m Mapper[T, R]) Map(input []T, f func(*T) R) ([]R, error) {
+	return m.MapErr(input, func(t *T) (R, error) {
+		return f(t), nil
+	})
+}
+
 // MapErr applies f to each element of the input, returning the mapped result
-// and a combined error of all returned errors.
-func MapErr[T any, R any](input []T, f func(*T) (R, error)) ([]R, error) {
+// and a combined error of all returned errors. The combined error is a
+// conc.Errors of the errors wrapped in an *ElementError, in the order of the
+// elements they were returned for, so that the indices of the elements that
+// failed can be recovered with FailedIndices.
+//
+// MapErr always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Mapper.
+func MapErr[T, R any](input []T, f func(*T) (R, error)) ([]R, error) {
+	return Mapper[T, R]{}.MapErr(input, f)
+}
+
+// MapErr applies f to each element of the input, returning the mapped result
+// and a combined error of all returned errors, including any timeouts.
+func (m Mapper[T, R]) MapErr(input []T, f func(*T) (R, error)) ([]R, error) {
+	res := make([]R, len(input))
+	return res, m.mapInto(res, input, len(input), func(i int) int { return i }, f)
+}
+
+// RetryFailed calls f again for the elements of input that failed in a
+// previous call to MapErr, as reported by FailedIndices(err), and merges
+// their results into res, the results of that call, which it returns. The
+// returned error reports the elements that failed again, in the same way, so
+// that RetryFailed can be called in a loop until the error is nil or the
+// caller gives up:
+//
+//	res, err := iter.MapErr(input, f)
+//	for attempt := 0; err != nil && attempt < 3; attempt++ {
+//		res, err = iter.RetryFailed(input, res, err, f)
+//	}
+//
+// If err reports no element, nothing is retried, and res and err are
+// returned unchanged. Elements that timed out are retried too, but their
+// previous callback may still be running, so they must not be retried with
+// a callback that cannot run concurrently with it. Panics if res is not as
+// long as input.
+//
+// RetryFailed always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Mapper.
+func RetryFailed[T, R any](input []T, res []R, err error, f func(*T) (R, error)) ([]R, error) {
+	return Mapper[T, R]{}.RetryFailed(input, res, err, f)
+}
+
+// RetryFailed calls f again for the elements of input that failed in a
+// previous call to MapErr, merging their results into res. See the
+// package-level RetryFailed.
+func (m Mapper[T, R]) RetryFailed(input []T, res []R, err error, f func(*T) (R, error)) ([]R, error) {
+	if len(res) != len(input) {
+		panic("results must be as long as the input")
+	}
+	failed := FailedIndices(err)
+	if len(failed) == 0 {
+		return res, err
+	}
+	return res, m.mapInto(res, input, len(failed), func(j int) int { return failed[j] }, f)
+}
+
+// mapInto applies f to the n elements of input at index(0) to index(n-1),
+// storing their results in res, and returns the combined error of MapErr.
+func (m Mapper[T, R]) mapInto(res []R, input []T, n int, index func(int) int, f func(*T) (R, error)) error {
+	r := Iterator[T](m).runner()
+	r.describe = Iterator[T](m).describer(input)
+	r.run(n, func(j int) {
+		i := index(j)
+		// The result is written to a local so that a callback that times out
+		// can never write into the result slice after we have returned.
+		var (
+			val R
+			err error
+		)
+		if timeoutErr := r.call(i, func() { val, err = f(&input[i]) }); timeoutErr != nil {
+			r.addErr(i, timeoutErr)
+			return
+		}
+		res[i] = val
+		r.addErr(i, err)
+	})
+	return r.err()
+}
+
+// MapErrCtx applies f to each element of the input, returning the mapped
+// result and the error returned for each element. The context passed to f is
+// ctx. Errors do not stop other elements from being processed, but once ctx
+// is done, no new elements are started, so the results computed so far can
+// still be used. Elements that were never started have ctx.Err() as their
+// error.
+//
+// The returned error slice is nil if no element returned an error. Otherwise,
+// it has the same length as input, and an element's result should only be
+// used if its error is nil.
+//
+// MapErrCtx always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Mapper.
+func MapErrCtx[T, R any](ctx context.Context, input []T, f func(context.Context, *T) (R, error)) ([]R, []error) {
+	return Mapper[T, R]{}.MapErrCtx(ctx, input, f)
+}
+
+// MapErrCtx applies f to each element of the input, returning the mapped
+// result and the error returned for each element, including any timeouts.
+// See the package-level MapErrCtx for details.
+func (m Mapper[T, R]) MapErrCtx(ctx context.Context, input []T, f func(context.Context, *T) (R, error)) ([]R, []error) {
 	var (
-		res    = make([]R, len(input))
-		errMux sync.Mutex
-		errs   error
+		r         = Iterator[T](m).runner()
+		res       = make([]R, len(input))
+		errs      = make([]error, len(input))
+		hasErrors atomic.Bool
 	)
-	ForEachIdx(input, func(i int, t *T) {
-		var err error
-		res[i], err = f(t)
+	r.run(len(input), func(i int) {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			hasErrors.Store(true)
+			return
+		}
+
+		// As in mapInto, the result is written to locals that are only read
+		// if the callback returned, since one that times out keeps running.
+		var (
+			val R
+			err error
+		)
+		if timeoutErr := r.call(i, func() { val, err = f(ctx, &input[i]) }); timeoutErr != nil {
+			errs[i] = timeoutErr
+			hasErrors.Store(true)
+			return
+		}
+		res[i] = val
 		if err != nil {
-			errMux.Lock()
-			// TODO: use stdlib errors once multierrors land in go 1.20
-			errs = errors.Append(errs, err)
-			errMux.Unlock()
+			errs[i] = err
+			hasErrors.Store(true)
 		}
 	})
+	if !hasErrors.Load() {
+		return res, nil
+	}
 	return res, errs
 }
+
+// describer returns the function describing the element of input at an
+// index with iter.Describe, or nil if it is not set.
+func (iter Iterator[T]) describer(input []T) func(int) string {
+	if iter.Describe == nil {
+		return nil
+	}
+	return func(i int) string { return iter.Describe(&input[i]) }
+}
+
+func (iter Iterator[T]) runner() *runner {
+	return &runner{
+		maxGoroutines: iter.MaxGoroutines,
+		static:        iter.StaticPartitioning,
+		timeout:       iter.Timeout,
+		onTimeout:     iter.OnTimeout,
+		onProgress:    iter.OnProgress,
+	}
+}
+
+// runner implements the iteration shared by Iterator and Mapper.
+type runner struct {
+	maxGoroutines int
+	timeout       time.Duration
+	onTimeout     TimeoutPolicy
+	onProgress    func(done, total int)
+
+	// chunkSize is the number of consecutive indices claimed at once, or 0
+	// for one at a time
+	chunkSize int
+	// static is set to give each goroutine a fixed range of indices
+	static bool
+
+	// stopped is set once no more elements should be started
+	stopped atomic.Bool
+
+	progressMu sync.Mutex
+	done       int
+
+	// describe, if set, describes the element at an index in its errors
+	describe func(int) string
+
+	errMu sync.Mutex
+	errs  []indexedError
+}
+
+// indexedError is an error collected by a runner, with the index of the
+// element it was returned for.
+type indexedError struct {
+	index int
+	err   error
+}
+
+// numTasks returns the number of goroutines used to iterate over n indices.
+func (r *runner) numTasks(n int) int {
+	numTasks := r.maxGoroutines
+	if numTasks == 0 {
+		numTasks = runtime.GOMAXPROCS(0)
+	}
+	if numTasks > n {
+		// No more tasks than the number of input items
+		numTasks = n
+	}
+	return numTasks
+}
+
+// run calls f with each index in [0, n), in parallel.
+func (r *runner) run(n int, f func(int)) {
+	numTasks := r.numTasks(n)
+
+	if r.onProgress != nil {
+		inner := f
+		f = func(i int) {
+			inner(i)
+			r.progressMu.Lock()
+			r.done++
+			r.onProgress(r.done, n)
+			r.progressMu.Unlock()
+		}
+	}
+
+	var wg conc.WaitGroup
+	if r.static && numTasks > 0 {
+		// The first n%numTasks goroutines get one extra index each
+		size, extra := n/numTasks, n%numTasks
+		end := 0
+		for k := 0; k < numTasks; k++ {
+			lo := end
+			end += size
+			if k < extra {
+				end++
+			}
+			hi := end
+			wg.Go(func() {
+				for i := lo; i < hi && !r.stopped.Load(); i++ {
+					f(i)
+				}
+			})
+		}
+		wg.Wait()
+		return
+	}
+
+	var idx atomic.Int64
+	// create the task outside the loop to avoid extra closure allocations
+	task := func() {
+		i := int(idx.Add(1) - 1)
+		for ; i < n && !r.stopped.Load(); i = int(idx.Add(1) - 1) {
+			f(i)
+		}
+	}
+	if r.chunkSize > 1 {
+		size := int64(r.chunkSize)
+		task = func() {
+			start := int(idx.Add(size) - size)
+			for ; start < n && !r.stopped.Load(); start = int(idx.Add(size) - size) {
+				end := start + int(size)
+				if end > n || end < start {
+					end = n
+				}
+				for i := start; i < end && !r.stopped.Load(); i++ {
+					f(i)
+				}
+			}
+		}
+	}
+
+	for i := 0; i < numTasks; i++ {
+		wg.Go(task)
+	}
+	wg.Wait()
+}
+
+// call calls f for the element at index i, enforcing the timeout if one is
+// configured. It returns a *TimeoutError if f timed out.
+func (r *runner) call(i int, f func()) error {
+	if r.timeout <= 0 {
+		f()
+		return nil
+	}
+
+	var pc conc.PanicCatcher
+	done := make(chan struct{})
+	// This goroutine is intentionally not scoped: if it times out, it is
+	// abandoned so that it cannot hold up the rest of the iteration.
+	go func() {
+		defer close(done)
+		conc.ReportPanic(pc.TryRecovered(f))
+	}()
+
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		pc.Repanic()
+		return nil
+	case <-timer.C:
+		if r.onTimeout == Fail {
+			r.stopped.Store(true)
+		}
+		return &TimeoutError{Index: i, Item: r.describeElement(i), Timeout: r.timeout}
+	}
+}
+
+// describeElement describes the element at index i, or returns "" if the
+// runner has no describe function.
+func (r *runner) describeElement(i int) string {
+	if r.describe == nil {
+		return ""
+	}
+	return r.describe(i)
+}
+
+// elementError wraps err, returned for the element at index i, in an
+// *ElementError, unless it is a *TimeoutError, which has the index already.
+func (r *runner) elementError(i int, err error) error {
+	if _, ok := err.(*TimeoutError); ok {
+		return err
+	}
+	return &ElementError{Index: i, Item: r.describeElement(i), Err: err}
+}
+
+func (r *runner) addErr(i int, err error) {
+	if err != nil {
+		err = r.elementError(i, err)
+		r.errMu.Lock()
+		r.errs = append(r.errs, indexedError{index: i, err: err})
+		r.errMu.Unlock()
+	}
+}
+
+// err returns the collected errors, in the order of the elements they were
+// returned for, or nil if there were none.
+func (r *runner) err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(r.errs, func(i, j int) bool { return r.errs[i].index < r.errs[j].index })
+	errs := make(conc.Errors, len(r.errs))
+	for i, err := range r.errs {
+		errs[i] = err.err
+	}
+	return errs
+}
@@ -1,9 +1,16 @@
 package iter
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
 	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/sourcegraph/conc"
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 	"github.com/stretchr/testify/require"
 )
@@ -148,6 +155,20 @@ func TestMap(t *testing.T) {
 		}
 		require.Equal(t, expected, res)
 	})
+
+	t.Run("results are in input order", func(t *testing.T) {
+		ints := make([]int, 100)
+		for i := range ints {
+			ints[i] = i
+		}
+		res, err := Mapper[int, int]{MaxGoroutines: 10}.Map(ints, func(val *int) int {
+			// Later elements complete first
+			time.Sleep(time.Duration(len(ints)-*val) * 10 * time.Microsecond)
+			return *val
+		})
+		require.NoError(t, err)
+		require.Equal(t, ints, res)
+	})
 }
 
 func TestMapErr(t *testing.T) {
@@ -225,6 +246,32 @@ func TestMapErr(t *testing.T) {
 		require.Equal(t, []int{1, 2, 3, 4, 5}, ints)
 	})
 
+	t.Run("errors are indexed and ordered", func(t *testing.T) {
+		ints := []int{1, 2, 3, 4, 5}
+		_, err := Mapper[int, int]{
+			MaxGoroutines: 5,
+			Describe:      func(val *int) string { return "val " + strconv.Itoa(*val) },
+		}.MapErr(ints, func(val *int) (int, error) {
+			switch *val {
+			case 2:
+				// Fail last, so that the errors are not in completion order
+				time.Sleep(10 * time.Millisecond)
+				return 0, err1
+			case 4:
+				return 0, err2
+			}
+			return *val, nil
+		})
+		require.EqualError(t, err, "element 1 (val 2): "+err1.Error()+"\nelement 3 (val 4): "+err2.Error())
+		require.Equal(t, []int{1, 3}, FailedIndices(err))
+
+		var elemErr *ElementError
+		require.ErrorAs(t, err, &elemErr)
+		require.Equal(t, 1, elemErr.Index)
+		require.Equal(t, "val 2", elemErr.Item)
+		require.ErrorIs(t, elemErr, err1)
+	})
+
 	t.Run("huge inputs", func(t *testing.T) {
 		ints := make([]int, 10000)
 		res := Map(ints, func(val *int) int {
@@ -238,6 +285,520 @@ func TestMapErr(t *testing.T) {
 	})
 }
 
+func TestForEachErr(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no errors", func(t *testing.T) {
+		ints := []int{1, 2, 3, 4, 5}
+		err := ForEachErr(context.Background(), ints, func(ctx context.Context, val *int) error {
+			*val += 1
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{2, 3, 4, 5, 6}, ints)
+	})
+
+	t.Run("first error cancels siblings", func(t *testing.T) {
+		err1 := errors.New("error1")
+		ints := []int{1, 2, 3, 4, 5}
+		err := Iterator[int]{MaxGoroutines: 5}.ForEachErr(context.Background(), ints, func(ctx context.Context, val *int) error {
+			if *val == 3 {
+				return err1
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, err1)
+		require.NotErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("error is indexed", func(t *testing.T) {
+		err1 := errors.New("error1")
+		err := ForEachErr(context.Background(), []int{1, 2, 3}, func(ctx context.Context, val *int) error {
+			if *val == 2 {
+				return err1
+			}
+			return nil
+		})
+		require.EqualError(t, err, "element 1: error1")
+		require.Equal(t, []int{1}, FailedIndices(err))
+	})
+
+	t.Run("no new elements start after an error", func(t *testing.T) {
+		err1 := errors.New("error1")
+		var started atomic.Int64
+		ints := make([]int, 100)
+		err := Iterator[int]{MaxGoroutines: 1}.ForEachErr(context.Background(), ints, func(ctx context.Context, val *int) error {
+			started.Add(1)
+			return err1
+		})
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, int64(1), started.Load())
+	})
+
+	t.Run("ErrStop stops without error", func(t *testing.T) {
+		var started atomic.Int64
+		ints := make([]int, 100)
+		err := Iterator[int]{MaxGoroutines: 1}.ForEachErr(context.Background(), ints, func(ctx context.Context, val *int) error {
+			started.Add(1)
+			return conc.ErrStop
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), started.Load())
+	})
+
+	t.Run("parent cancellation is returned", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := ForEachErr(ctx, []int{1, 2, 3}, func(ctx context.Context, val *int) error {
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("timed-out callback keeps running", func(t *testing.T) {
+		release := releaseLater(200 * time.Millisecond)
+		err := Iterator[int]{Timeout: 10 * time.Millisecond}.ForEachErr(context.Background(), []int{1}, func(ctx context.Context, val *int) error {
+			<-release
+			return errors.New("late")
+		})
+		var timeoutErr *TimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.NotContains(t, err.Error(), "late")
+		awaitAbandoned(release)
+	})
+}
+
+// releaseLater returns a channel that is closed after d by a goroutine that
+// does not synchronize with the iteration, so that a callback that times out
+// while it waits on the channel returns concurrently with the caller, as the
+// race detector needs.
+func releaseLater(d time.Duration) <-chan struct{} {
+	release := make(chan struct{})
+	go func() {
+		time.Sleep(d)
+		close(release)
+	}()
+	return release
+}
+
+// awaitAbandoned waits for release to be closed, and for the callbacks
+// waiting on it to return, so that the race detector sees what they write.
+func awaitAbandoned(release <-chan struct{}) {
+	<-release
+	time.Sleep(20 * time.Millisecond)
+}
+
+func TestForEachN(t *testing.T) {
+	t.Parallel()
+
+	t.Run("visits every index once", func(t *testing.T) {
+		for _, n := range []int{0, 1, 7, 1000, 100000} {
+			seen := make([]int32, n)
+			err := ForEachN(context.Background(), n, func(i int) error {
+				atomic.AddInt32(&seen[i], 1)
+				return nil
+			})
+			require.NoError(t, err)
+			for i := range seen {
+				require.Equal(t, int32(1), seen[i], "index %d of %d", i, n)
+			}
+		}
+	})
+
+	t.Run("respects MaxGoroutines", func(t *testing.T) {
+		var current, peak atomic.Int64
+		err := Iterator[int]{MaxGoroutines: 3}.ForEachN(context.Background(), 300, func(i int) error {
+			cur := current.Add(1)
+			defer current.Add(-1)
+			for {
+				old := peak.Load()
+				if cur <= old || peak.CompareAndSwap(old, cur) {
+					break
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.LessOrEqual(t, peak.Load(), int64(3))
+	})
+
+	t.Run("no new indices start after an error", func(t *testing.T) {
+		err1 := errors.New("error1")
+		var started atomic.Int64
+		err := Iterator[int]{MaxGoroutines: 1}.ForEachN(context.Background(), 100000, func(i int) error {
+			started.Add(1)
+			return err1
+		})
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, int64(1), started.Load())
+	})
+
+	t.Run("ErrStop stops without error", func(t *testing.T) {
+		var started atomic.Int64
+		err := Iterator[int]{MaxGoroutines: 4}.ForEachN(context.Background(), 100000, func(i int) error {
+			if started.Add(1) == 10 {
+				return conc.ErrStop
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Less(t, started.Load(), int64(100000))
+	})
+
+	t.Run("cancellation stops the iteration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var started atomic.Int64
+		err := ForEachN(ctx, 100000, func(i int) error {
+			if started.Add(1) == 100 {
+				cancel()
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Less(t, started.Load(), int64(100000))
+	})
+
+	t.Run("static partitioning", func(t *testing.T) {
+		for _, n := range []int{0, 3, 10, 1001} {
+			// Each index records the goroutine that ran it
+			owners := make([]uint64, n)
+			it := Iterator[int]{MaxGoroutines: 4, StaticPartitioning: true}
+			err := it.ForEachN(context.Background(), n, func(i int) error {
+				owners[i] = goroutineID()
+				return nil
+			})
+			require.NoError(t, err)
+
+			// Every goroutine ran a single contiguous range of indices,
+			// and the ranges differ in size by at most one.
+			sizes := map[uint64]int{}
+			for i, owner := range owners {
+				require.NotZero(t, owner)
+				if i > 0 && owner != owners[i-1] {
+					require.NotContains(t, sizes, owner)
+				}
+				sizes[owner]++
+			}
+			if n >= 4 {
+				require.Len(t, sizes, 4)
+			}
+			for _, size := range sizes {
+				require.LessOrEqual(t, size, n/4+1)
+				require.GreaterOrEqual(t, size, n/4)
+			}
+		}
+	})
+
+	t.Run("static partitioning stops after an error", func(t *testing.T) {
+		err1 := errors.New("error1")
+		var started atomic.Int64
+		it := Iterator[int]{MaxGoroutines: 1, StaticPartitioning: true}
+		err := it.ForEachN(context.Background(), 100, func(i int) error {
+			started.Add(1)
+			return err1
+		})
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, int64(1), started.Load())
+	})
+
+	t.Run("canceled before start", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := ForEachN(ctx, 10, func(i int) error {
+			t.Error("should not be called")
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("timed-out callback keeps running", func(t *testing.T) {
+		release := releaseLater(200 * time.Millisecond)
+		err := Iterator[int]{Timeout: 10 * time.Millisecond}.ForEachN(context.Background(), 1, func(i int) error {
+			<-release
+			return errors.New("late")
+		})
+		require.ErrorAs(t, err, new(*TimeoutError))
+		awaitAbandoned(release)
+	})
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the
+// header of its stack trace.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	id, _ := strconv.ParseUint(string(b[:bytes.IndexByte(b, ' ')]), 10, 64)
+	return id
+}
+
+func TestChunkSize(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 1, chunkSize(0, 0))
+	require.Equal(t, 1, chunkSize(10, 4))
+	require.Equal(t, 1, chunkSize(64, 4))
+	require.Equal(t, 15, chunkSize(1000, 4))
+	require.Equal(t, 625, chunkSize(100000, 10))
+}
+
+func TestMapErrCtx(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no errors", func(t *testing.T) {
+		ints := []int{1, 2, 3}
+		res, errs := MapErrCtx(context.Background(), ints, func(ctx context.Context, val *int) (int, error) {
+			return *val + 1, nil
+		})
+		require.Nil(t, errs)
+		require.Equal(t, []int{2, 3, 4}, res)
+	})
+
+	t.Run("errors are reported per element", func(t *testing.T) {
+		err1 := errors.New("error1")
+		ints := []int{1, 2, 3}
+		res, errs := MapErrCtx(context.Background(), ints, func(ctx context.Context, val *int) (int, error) {
+			if *val == 2 {
+				return 0, err1
+			}
+			return *val + 1, nil
+		})
+		require.Equal(t, []error{nil, err1, nil}, errs)
+		require.Equal(t, []int{2, 0, 4}, res)
+	})
+
+	t.Run("cancellation returns partial results", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var processed atomic.Int64
+		ints := make([]int, 100)
+		res, errs := Mapper[int, int]{MaxGoroutines: 1}.MapErrCtx(ctx, ints, func(ctx context.Context, val *int) (int, error) {
+			if processed.Add(1) == 10 {
+				cancel()
+			}
+			return 1, nil
+		})
+		require.Len(t, errs, 100)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, errs[i])
+			require.Equal(t, 1, res[i])
+		}
+		for i := 10; i < 100; i++ {
+			require.ErrorIs(t, errs[i], context.Canceled)
+			require.Equal(t, 0, res[i])
+		}
+	})
+
+	t.Run("timed-out callback keeps running", func(t *testing.T) {
+		release := releaseLater(200 * time.Millisecond)
+		res, errs := Mapper[int, int]{Timeout: 10 * time.Millisecond}.MapErrCtx(context.Background(), []int{1}, func(ctx context.Context, val *int) (int, error) {
+			<-release
+			return 1, errors.New("late")
+		})
+		require.Len(t, errs, 1)
+		require.ErrorAs(t, errs[0], new(*TimeoutError))
+		require.Equal(t, []int{0}, res)
+		awaitAbandoned(release)
+	})
+}
+
+func TestIterator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("max goroutines", func(t *testing.T) {
+		var current, maxSeen atomic.Int64
+		ints := make([]int, 100)
+		err := Iterator[int]{MaxGoroutines: 3}.ForEach(ints, func(val *int) {
+			cur := current.Add(1)
+			for {
+				seen := maxSeen.Load()
+				if cur <= seen || maxSeen.CompareAndSwap(seen, cur) {
+					break
+				}
+			}
+			time.Sleep(100 * time.Microsecond)
+			current.Add(-1)
+		})
+		require.NoError(t, err)
+		require.LessOrEqual(t, maxSeen.Load(), int64(3))
+	})
+
+	t.Run("timeout skips element", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		ints := []int{1, 2, 3, 4, 5}
+		err := Iterator[int]{Timeout: 100 * time.Millisecond}.ForEachIdx(ints, func(i int, val *int) {
+			if i == 2 {
+				<-block
+				return
+			}
+			*val += 1
+		})
+		var timeoutErr *TimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, 2, timeoutErr.Index)
+		require.Equal(t, []int{2, 3, 5, 6}, []int{ints[0], ints[1], ints[3], ints[4]})
+	})
+
+	t.Run("timeout fails iteration", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		var started atomic.Int64
+		ints := make([]int, 100)
+		err := Iterator[int]{
+			MaxGoroutines: 1,
+			Timeout:       10 * time.Millisecond,
+			OnTimeout:     Fail,
+		}.ForEach(ints, func(val *int) {
+			if started.Add(1) == 1 {
+				<-block
+			}
+		})
+		var timeoutErr *TimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, 0, timeoutErr.Index)
+		require.Equal(t, int64(1), started.Load())
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		var calls []int
+		ints := make([]int, 50)
+		err := Iterator[int]{
+			OnProgress: func(done, total int) {
+				require.Equal(t, 50, total)
+				calls = append(calls, done)
+			},
+		}.ForEach(ints, func(val *int) {})
+		require.NoError(t, err)
+		require.Len(t, calls, 50)
+		for i, done := range calls {
+			require.Equal(t, i+1, done)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		var calls atomic.Int64
+		iter := Iterator[int]{MaxGoroutines: 2}.WithOptions(conc.Options{
+			Name:       "ignored",
+			OnProgress: func(done, total int) { calls.Add(1) },
+		})
+		require.Equal(t, 2, iter.MaxGoroutines)
+		require.NoError(t, iter.ForEach(make([]int, 10), func(*int) {}))
+		require.Equal(t, int64(10), calls.Load())
+
+		m := Mapper[int, int]{}.WithOptions(conc.Options{MaxGoroutines: 4})
+		require.Equal(t, 4, m.MaxGoroutines)
+		require.Panics(t, func() { Iterator[int]{}.WithOptions(conc.Options{MaxGoroutines: -1}) })
+	})
+
+	t.Run("panic is propagated with timeout", func(t *testing.T) {
+		f := func() {
+			ints := []int{1}
+			_ = Iterator[int]{Timeout: time.Second}.ForEach(ints, func(val *int) {
+				panic("super bad thing happened")
+			})
+		}
+		require.Panics(t, f)
+	})
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	reported := make(chan any, 1)
+	conc.SetDefaultPanicHandler(func(recovered *conc.RecoveredPanic) {
+		reported <- recovered.Value
+	})
+	defer conc.SetDefaultPanicHandler(nil)
+
+	block := make(chan struct{})
+	ints := []int{1}
+	err := Iterator[int]{Timeout: 10 * time.Millisecond}.ForEach(ints, func(val *int) {
+		<-block
+		panic("super bad thing happened")
+	})
+	require.ErrorAs(t, err, new(*TimeoutError))
+
+	close(block)
+	select {
+	case v := <-reported:
+		require.Equal(t, "super bad thing happened", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the panic of the timed-out callback was not reported")
+	}
+}
+
+func TestMapper(t *testing.T) {
+	t.Parallel()
+
+	t.Run("static partitioning", func(t *testing.T) {
+		ints := make([]int, 1000)
+		for i := range ints {
+			ints[i] = i
+		}
+		res, err := Mapper[int, int]{MaxGoroutines: 3, StaticPartitioning: true}.Map(ints, func(val *int) int {
+			return *val * 2
+		})
+		require.NoError(t, err)
+		for i, val := range res {
+			require.Equal(t, i*2, val)
+		}
+	})
+
+	t.Run("timeout leaves zero value", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		ints := []int{1, 2, 3}
+		res, err := Mapper[int, int]{Timeout: 100 * time.Millisecond}.Map(ints, func(val *int) int {
+			if *val == 2 {
+				<-block
+			}
+			return *val * 10
+		})
+		require.ErrorAs(t, err, new(*TimeoutError))
+		require.Equal(t, []int{10, 0, 30}, res)
+	})
+
+	t.Run("errors and timeouts are combined", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		// The timeout only has to fire for the element blocked on block, so
+		// it leaves the others plenty of time to return even under load.
+		err1 := errors.New("error1")
+		ints := []int{1, 2, 3}
+		_, err := Mapper[int, int]{Timeout: 500 * time.Millisecond}.MapErr(ints, func(val *int) (int, error) {
+			switch *val {
+			case 1:
+				return 0, err1
+			case 2:
+				<-block
+			}
+			return *val, nil
+		})
+		require.ErrorIs(t, err, err1)
+		require.ErrorAs(t, err, new(*TimeoutError))
+	})
+}
+
+func BenchmarkForEachN(b *testing.B) {
+	for _, count := range []int{0, 1, 8, 100, 1000, 10000, 100000} {
+		b.Run(strconv.Itoa(count), func(b *testing.B) {
+			ints := make([]int, count)
+			for i := 0; i < b.N; i++ {
+				_ = ForEachN(context.Background(), count, func(i int) error {
+					ints[i] = 0
+					return nil
+				})
+			}
+		})
+	}
+}
+
 func BenchmarkForEach(b *testing.B) {
 	for _, count := range []int{0, 1, 8, 100, 1000, 10000, 100000} {
 		b.Run(strconv.Itoa(count), func(b *testing.B) {
@@ -250,3 +811,90 @@ func BenchmarkForEach(b *testing.B) {
 		})
 	}
 }
+
+func TestFailedIndices(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no elements", func(t *testing.T) {
+		require.Nil(t, FailedIndices(nil))
+		require.Nil(t, FailedIndices(errors.New("not an element")))
+	})
+
+	t.Run("combined and wrapped errors", func(t *testing.T) {
+		failed := errors.New("failed")
+		err := conc.Errors{
+			&TimeoutError{Index: 5},
+			fmt.Errorf("wrapped: %w", &ElementError{Index: 2, Err: failed}),
+			&ElementError{Index: 5, Err: failed},
+			conc.Errors{&ElementError{Index: 0, Err: failed}},
+		}
+		require.Equal(t, []int{0, 2, 5}, FailedIndices(err))
+	})
+
+	t.Run("timeouts are described", func(t *testing.T) {
+		_, err := Mapper[int, int]{
+			Timeout:  10 * time.Millisecond,
+			Describe: func(val *int) string { return "slow" },
+		}.Map([]int{1, 2}, func(val *int) int {
+			if *val == 2 {
+				time.Sleep(time.Second)
+			}
+			return *val
+		})
+		require.EqualError(t, err, "element 1 (slow) timed out after 10ms")
+		require.Equal(t, []int{1}, FailedIndices(err))
+	})
+}
+
+func TestRetryFailed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retries only the failed elements", func(t *testing.T) {
+		failed := errors.New("failed")
+		var calls [5]atomic.Int64
+		f := func(val *int) (int, error) {
+			// Odd elements fail on their first attempt, and 3 on its
+			// second too
+			n := calls[*val].Add(1)
+			if *val%2 == 1 && n == 1 || *val == 3 && n == 2 {
+				return 0, failed
+			}
+			return *val * 10, nil
+		}
+		input := []int{0, 1, 2, 3, 4}
+		res, err := MapErr(input, f)
+		require.Equal(t, []int{1, 3}, FailedIndices(err))
+		require.Equal(t, []int{0, 0, 20, 0, 40}, res)
+
+		res, err = RetryFailed(input, res, err, f)
+		require.EqualError(t, err, "element 3: failed")
+		require.Equal(t, []int{0, 10, 20, 0, 40}, res)
+
+		res, err = RetryFailed(input, res, err, f)
+		require.NoError(t, err)
+		require.Equal(t, []int{0, 10, 20, 30, 40}, res)
+		for i, want := range []int64{1, 2, 1, 3, 1} {
+			require.Equal(t, want, calls[i].Load(), "calls of element %d", i)
+		}
+	})
+
+	t.Run("nothing to retry", func(t *testing.T) {
+		other := errors.New("not an element")
+		res, err := RetryFailed([]int{1}, []int{2}, other, func(*int) (int, error) {
+			t.Fatal("should not be called")
+			return 0, nil
+		})
+		require.Equal(t, other, err)
+		require.Equal(t, []int{2}, res)
+
+		res, err = RetryFailed([]int{1}, []int{2}, nil, func(*int) (int, error) { return 0, nil })
+		require.NoError(t, err)
+		require.Equal(t, []int{2}, res)
+	})
+
+	t.Run("panics on mismatched results", func(t *testing.T) {
+		require.Panics(t, func() {
+			_, _ = RetryFailed([]int{1, 2}, []int{1}, nil, func(*int) (int, error) { return 0, nil })
+		})
+	})
+}
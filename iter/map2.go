@@ -0,0 +1,72 @@
+package iter
+
+// result2 and result3 hold the results of f for an element in Map2 and Map3.
+type result2[A, B any] struct {
+	a A
+	b B
+}
+
+type result3[A, B, C any] struct {
+	a A
+	b B
+	c C
+}
+
+// Map2 is like Map, for an f with two results, such as a value and its
+// metadata, which are returned in two slices, so that they do not need a
+// struct of their own:
+//
+//	pages, headers := iter.Map2(urls, func(url *string) (*Page, http.Header) {
+//		return fetch(*url)
+//	})
+//
+// The results of f for an element are at the index of the element in both
+// slices.
+//
+// Map2 always uses at most runtime.GOMAXPROCS goroutines. For a configurable
+// goroutine limit, use a custom Mapper of a struct.
+func Map2[T, A, B any](input []T, f func(*T) (A, B)) ([]A, []B) {
+	as, bs, _ := MapErr2(input, func(t *T) (A, B, error) {
+		a, b := f(t)
+		return a, b, nil
+	})
+	return as, bs
+}
+
+// MapErr2 is like MapErr, for an f with two results and an error. See Map2.
+func MapErr2[T, A, B any](input []T, f func(*T) (A, B, error)) ([]A, []B, error) {
+	res, err := MapErr(input, func(t *T) (result2[A, B], error) {
+		a, b, err := f(t)
+		return result2[A, B]{a, b}, err
+	})
+	as := make([]A, len(res))
+	bs := make([]B, len(res))
+	for i, r := range res {
+		as[i], bs[i] = r.a, r.b
+	}
+	return as, bs, err
+}
+
+// Map3 is like Map2, for an f with three results.
+func Map3[T, A, B, C any](input []T, f func(*T) (A, B, C)) ([]A, []B, []C) {
+	as, bs, cs, _ := MapErr3(input, func(t *T) (A, B, C, error) {
+		a, b, c := f(t)
+		return a, b, c, nil
+	})
+	return as, bs, cs
+}
+
+// MapErr3 is like MapErr2, for an f with three results and an error.
+func MapErr3[T, A, B, C any](input []T, f func(*T) (A, B, C, error)) ([]A, []B, []C, error) {
+	res, err := MapErr(input, func(t *T) (result3[A, B, C], error) {
+		a, b, c, err := f(t)
+		return result3[A, B, C]{a, b, c}, err
+	})
+	as := make([]A, len(res))
+	bs := make([]B, len(res))
+	cs := make([]C, len(res))
+	for i, r := range res {
+		as[i], bs[i], cs[i] = r.a, r.b, r.c
+	}
+	return as, bs, cs, err
+}
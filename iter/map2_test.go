@@ -0,0 +1,84 @@
+package iter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func ExampleMap2() {
+	input := []string{"go", "conc", "iter"}
+	upper, lengths := Map2(input, func(s *string) (string, int) {
+		return strings.ToUpper(*s), len(*s)
+	})
+	fmt.Println(upper, lengths)
+	// Output:
+	// [GO CONC ITER] [2 4 4]
+}
+
+func TestMap2(t *testing.T) {
+	t.Parallel()
+
+	t.Run("results are in input order", func(t *testing.T) {
+		input := []int{1, 2, 3, 4, 5}
+		doubled, strs := Map2(input, func(i *int) (int, string) { return *i * 2, strconv.Itoa(*i) })
+		require.Equal(t, []int{2, 4, 6, 8, 10}, doubled)
+		require.Equal(t, []string{"1", "2", "3", "4", "5"}, strs)
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		as, bs := Map2([]int{}, func(i *int) (int, int) { return *i, *i })
+		require.Empty(t, as)
+		require.Empty(t, bs)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		err1 := errors.New("err1")
+		input := []int{1, 2, 3}
+		as, bs, err := MapErr2(input, func(i *int) (int, string, error) {
+			if *i == 2 {
+				return 0, "", err1
+			}
+			return *i, strconv.Itoa(*i), nil
+		})
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, []int{1}, FailedIndices(err))
+		require.Equal(t, []int{1, 0, 3}, as)
+		require.Equal(t, []string{"1", "", "3"}, bs)
+	})
+}
+
+func TestMap3(t *testing.T) {
+	t.Parallel()
+
+	t.Run("results are in input order", func(t *testing.T) {
+		input := []int{1, 2, 3}
+		as, bs, cs := Map3(input, func(i *int) (int, string, bool) {
+			return *i * 2, strconv.Itoa(*i), *i%2 == 0
+		})
+		require.Equal(t, []int{2, 4, 6}, as)
+		require.Equal(t, []string{"1", "2", "3"}, bs)
+		require.Equal(t, []bool{false, true, false}, cs)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		err1 := errors.New("err1")
+		input := []int{1, 2}
+		as, bs, cs, err := MapErr3(input, func(i *int) (int, string, bool, error) {
+			if *i == 1 {
+				return 0, "", false, err1
+			}
+			return *i, strconv.Itoa(*i), true, nil
+		})
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, []int{0}, FailedIndices(err))
+		require.Equal(t, []int{0, 2}, as)
+		require.Equal(t, []string{"", "2"}, bs)
+		require.Equal(t, []bool{false, true}, cs)
+	})
+}
@@ -0,0 +1,87 @@
+package iter
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Partition splits items into n chunks with roughly equal total cost, as
+// estimated by weight, so that the chunks can be processed concurrently
+// (for example with ForEach) without a few expensive items leaving most
+// goroutines idle at the end. Fewer than n chunks are returned if there are
+// fewer than n items. Items keep their relative order within each chunk.
+// Panics if n < 1.
+//
+// Partition uses the longest-processing-time-first heuristic: items are
+// assigned from most to least expensive, each to the chunk with the lowest
+// total cost so far. The most expensive chunk is guaranteed to cost at most
+// 4/3 of the optimum.
+func Partition[T any](items []T, weight func(T) int, n int) [][]T {
+	if n < 1 {
+		panic("number of partitions must be greater than zero")
+	}
+	if n > len(items) {
+		n = len(items)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	weights := make([]int, len(items))
+	order := make([]int, len(items))
+	for i, item := range items {
+		weights[i] = weight(item)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return weights[order[a]] > weights[order[b]]
+	})
+
+	bins := make(binHeap, n)
+	for i := range bins {
+		bins[i] = &bin{id: i}
+	}
+	for _, i := range order {
+		// The root of the heap is the bin with the lowest cost
+		bins[0].indexes = append(bins[0].indexes, i)
+		bins[0].cost += weights[i]
+		heap.Fix(&bins, 0)
+	}
+
+	chunks := make([][]T, n)
+	for _, b := range bins {
+		sort.Ints(b.indexes)
+		chunk := make([]T, len(b.indexes))
+		for j, i := range b.indexes {
+			chunk[j] = items[i]
+		}
+		chunks[b.id] = chunk
+	}
+	return chunks
+}
+
+type bin struct {
+	id      int
+	cost    int
+	indexes []int
+}
+
+// binHeap is a min-heap of bins by cost. Ties are broken by id so that the
+// partitioning is deterministic.
+type binHeap []*bin
+
+func (h binHeap) Len() int { return len(h) }
+func (h binHeap) Less(i, j int) bool {
+	if h[i].cost != h[j].cost {
+		return h[i].cost < h[j].cost
+	}
+	return h[i].id < h[j].id
+}
+func (h binHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *binHeap) Push(x any)   { *h = append(*h, x.(*bin)) }
+func (h *binHeap) Pop() any {
+	old := *h
+	b := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return b
+}
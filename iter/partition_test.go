@@ -0,0 +1,57 @@
+package iter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExamplePartition() {
+	costs := []int{7, 1, 1, 5, 2, 2}
+	chunks := Partition(costs, func(cost int) int { return cost }, 2)
+	fmt.Println(chunks)
+	// Output:
+	// [[7 2] [1 1 5 2]]
+}
+
+func TestPartition(t *testing.T) {
+	t.Parallel()
+
+	identity := func(i int) int { return i }
+
+	t.Run("balances skewed costs", func(t *testing.T) {
+		items := []int{1000, 1, 1, 1, 1, 500, 500, 1, 1, 1}
+		chunks := Partition(items, identity, 3)
+		require.Len(t, chunks, 3)
+
+		var total int
+		for _, chunk := range chunks {
+			for _, item := range chunk {
+				total += item
+			}
+		}
+		sum := func(chunk []int) (s int) {
+			for _, item := range chunk {
+				s += item
+			}
+			return s
+		}
+		require.Equal(t, 2007, total)
+		require.Equal(t, []int{1000}, chunks[0])
+		require.Equal(t, 504, sum(chunks[1]))
+		require.Equal(t, 503, sum(chunks[2]))
+	})
+
+	t.Run("fewer items than chunks", func(t *testing.T) {
+		require.Equal(t, [][]int{{2}, {1}}, Partition([]int{1, 2}, identity, 5))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		require.Nil(t, Partition([]int{}, identity, 2))
+	})
+
+	t.Run("panics on invalid n", func(t *testing.T) {
+		require.Panics(t, func() { Partition([]int{1}, identity, 0) })
+	})
+}
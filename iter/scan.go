@@ -0,0 +1,73 @@
+package iter
+
+// minScanChunk is the smallest number of elements that Scan gives a
+// goroutine of its own.
+const minScanChunk = 1 << 12
+
+// Scan returns the inclusive prefix scan of input under combine, that is,
+// the slice whose element i is the combination of input[0] through
+// input[i], such as the running totals of input for an addition:
+//
+//	totals := iter.Scan(amounts, func(a, b int) int { return a + b })
+//
+// combine must be associative, and is always called with the combination of
+// earlier elements as a, but it need not be commutative. The input is split
+// into one contiguous chunk per goroutine, each chunk is scanned
+// concurrently, and the combination of the chunks before it is then
+// combined into each element of a chunk, so combine is called about twice
+// per element. Inputs too small to be worth splitting are scanned by the
+// calling goroutine.
+//
+// Scan always uses at most runtime.GOMAXPROCS goroutines. For a configurable
+// goroutine limit, use a custom Iterator.
+func Scan[T any](input []T, combine func(a, b T) T) []T {
+	return Iterator[T]{}.Scan(input, combine)
+}
+
+// Scan returns the inclusive prefix scan of input under combine, using up to
+// the Iterator's configured maximum number of goroutines. The other settings
+// of the Iterator do not apply. See the package-level Scan.
+func (iter Iterator[T]) Scan(input []T, combine func(a, b T) T) []T {
+	res := make([]T, len(input))
+	r := &runner{maxGoroutines: iter.MaxGoroutines}
+	n := r.numTasks(len(input) / minScanChunk)
+	if n <= 1 {
+		scanChunk(res, input, combine)
+		return res
+	}
+
+	bounds := make([]int, n+1)
+	for i := range bounds {
+		bounds[i] = i * len(input) / n
+	}
+	r.run(n, func(c int) {
+		lo, hi := bounds[c], bounds[c+1]
+		scanChunk(res[lo:hi], input[lo:hi], combine)
+	})
+
+	// carries[c] is the combination of every element before chunk c
+	carries := make([]T, n)
+	carries[1] = res[bounds[1]-1]
+	for c := 2; c < n; c++ {
+		carries[c] = combine(carries[c-1], res[bounds[c]-1])
+	}
+	r.run(n-1, func(c int) {
+		c++
+		carry := carries[c]
+		for i := bounds[c]; i < bounds[c+1]; i++ {
+			res[i] = combine(carry, res[i])
+		}
+	})
+	return res
+}
+
+// scanChunk writes the inclusive prefix scan of input into res.
+func scanChunk[T any](res, input []T, combine func(a, b T) T) {
+	for i, v := range input {
+		if i == 0 {
+			res[i] = v
+			continue
+		}
+		res[i] = combine(res[i-1], v)
+	}
+}
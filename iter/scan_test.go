@@ -0,0 +1,61 @@
+package iter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	add := func(a, b int) int { return a + b }
+
+	for _, n := range []int{0, 1, 10, minScanChunk*3 + 5, 100000} {
+		n := n
+		t.Run(fmt.Sprintf("sums %d elements", n), func(t *testing.T) {
+			ints := make([]int, n)
+			expected := make([]int, n)
+			sum := 0
+			for i := range ints {
+				ints[i] = i % 7
+				sum += ints[i]
+				expected[i] = sum
+			}
+			require.Equal(t, expected, Scan(ints, add))
+		})
+	}
+
+	t.Run("combine is not assumed to be commutative", func(t *testing.T) {
+		input := make([]string, 3*minScanChunk)
+		for i := range input {
+			input[i] = string(rune('a' + i%26))
+		}
+		res := Iterator[string]{MaxGoroutines: 4}.Scan(input, func(a, b string) string {
+			// Keep the last 3 characters, which depend on the order
+			s := a + b
+			if len(s) > 3 {
+				s = s[len(s)-3:]
+			}
+			return s
+		})
+		require.Len(t, res, len(input))
+		for i := 2; i < len(input); i++ {
+			require.Equal(t, strings.Join(input[i-2:i+1], ""), res[i])
+		}
+	})
+
+	t.Run("does not modify the input", func(t *testing.T) {
+		ints := []int{1, 2, 3}
+		require.Equal(t, []int{1, 3, 6}, Scan(ints, add))
+		require.Equal(t, []int{1, 2, 3}, ints)
+	})
+
+	t.Run("panic is propagated", func(t *testing.T) {
+		require.Panics(t, func() {
+			Scan(make([]int, 100000), func(a, b int) int { panic("super bad thing happened") })
+		})
+	})
+}
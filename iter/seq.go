@@ -0,0 +1,49 @@
+//go:build go1.23
+
+package iter
+
+import (
+	"iter"
+	"slices"
+)
+
+// MapSeq is like Map, for the values of a sequence, such as one returned by
+// maps.Keys or by another step of a pipeline built on iter.Seq. It returns a
+// sequence of the results, in the order of the input, so that it can feed the
+// next step. Since the elements are handed out to several goroutines, the
+// input is collected into a slice first, and all of its elements are mapped
+// before the returned sequence yields the first result. The results are
+// yielded from the slice Map returns, without copying it.
+//
+// MapSeq always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Mapper.
+func MapSeq[T, R any](input iter.Seq[T], f func(*T) R) iter.Seq[R] {
+	res, _ := Mapper[T, R]{}.MapSeq(input, f)
+	return res
+}
+
+// MapSeq is like Map, for the values of a sequence. The returned error reports
+// any elements that timed out, which are yielded as the zero value. See the
+// package-level MapSeq.
+func (m Mapper[T, R]) MapSeq(input iter.Seq[T], f func(*T) R) (iter.Seq[R], error) {
+	res, err := m.Map(slices.Collect(input), f)
+	return slices.Values(res), err
+}
+
+// FilterSeq is like Filter, for the values of a sequence. Like MapSeq, it
+// collects the input before filtering it.
+//
+// FilterSeq always uses at most runtime.GOMAXPROCS goroutines. For a
+// configurable goroutine limit, use a custom Iterator.
+func FilterSeq[T any](input iter.Seq[T], keep func(*T) bool) iter.Seq[T] {
+	res, _ := Iterator[T]{}.FilterSeq(input, keep)
+	return res
+}
+
+// FilterSeq is like Filter, for the values of a sequence. The returned error
+// reports any elements that timed out, which are left out of the result. See
+// the package-level FilterSeq.
+func (iter Iterator[T]) FilterSeq(input iter.Seq[T], keep func(*T) bool) (iter.Seq[T], error) {
+	res, err := iter.Filter(slices.Collect(input), keep)
+	return slices.Values(res), err
+}
@@ -0,0 +1,31 @@
+//go:build go1.23
+
+package iter
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeq(t *testing.T) {
+	t.Parallel()
+
+	t.Run("MapSeq", func(t *testing.T) {
+		res := MapSeq(slices.Values([]int{1, 2, 3}), func(i *int) int { return *i * 2 })
+		require.Equal(t, []int{2, 4, 6}, slices.Collect(res))
+	})
+
+	t.Run("FilterSeq", func(t *testing.T) {
+		res := FilterSeq(slices.Values([]int{1, 2, 3, 4}), func(i *int) bool { return *i%2 == 0 })
+		require.Equal(t, []int{2, 4}, slices.Collect(res))
+	})
+
+	t.Run("pipeline", func(t *testing.T) {
+		evens := FilterSeq(slices.Values([]int{1, 2, 3, 4, 5, 6}), func(i *int) bool { return *i%2 == 0 })
+		squares, err := Mapper[int, int]{MaxGoroutines: 2}.MapSeq(evens, func(i *int) int { return *i * *i })
+		require.NoError(t, err)
+		require.Equal(t, []int{4, 16, 36}, slices.Collect(squares))
+	})
+}
@@ -0,0 +1,121 @@
+package conc
+
+import (
+	"context"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LabelKey is the pprof label key set by Label, so labeled tasks can be
+// found in goroutine profiles.
+const LabelKey = "conc.task"
+
+var runningLabels struct {
+	mu     sync.Mutex
+	nextID uint64
+	labels map[uint64]RunningTask
+}
+
+// RunningTask is a call to Label that has not yet returned. See
+// RunningTasks.
+type RunningTask struct {
+	// Label is the label passed to Label.
+	Label string
+	// Started is when Label was called.
+	Started time.Time
+}
+
+// Label runs f with the given label attached to the current goroutine. For
+// the duration of f, the label is reported by RunningLabels and is set as a
+// pprof label with the key LabelKey. Label is intended to be called at the
+// start of a task submitted to a pool or WaitGroup, so that when a pool
+// hangs, it is possible to find out which logical tasks are still running:
+//
+//	p.Go(func() {
+//		conc.Label("fetch "+url, func() {
+//			fetch(url)
+//		})
+//	})
+//
+// If f panics, the label is recorded in the RecoveredPanic as its Task.
+//
+// Labels should be short, since they are stored for as long as f runs.
+func Label(label string, f func()) {
+	gid := currentGoroutineID()
+	id := addLabel(label, gid)
+	completed := false
+	defer func() {
+		if !completed {
+			recordPanic(gid, func(info *panicInfo) {
+				// Keep the label of the innermost call
+				if info.label == "" {
+					info.label = label
+				}
+				info.labels = append([]string{label}, info.labels...)
+			})
+		}
+		removeLabel(id)
+	}()
+
+	pprof.Do(context.Background(), pprof.Labels(LabelKey, label), func(context.Context) {
+		f()
+	})
+	completed = true
+}
+
+// RunningLabels returns the labels of all calls to Label that have not yet
+// returned, in the order they started.
+func RunningLabels() []string {
+	tasks := RunningTasks()
+	labels := make([]string, len(tasks))
+	for i, task := range tasks {
+		labels[i] = task.Label
+	}
+	return labels
+}
+
+// RunningTasks is like RunningLabels, but also returns when each call to
+// Label started, so that tasks that have been running for too long can be
+// found.
+func RunningTasks() []RunningTask {
+	runningLabels.mu.Lock()
+	defer runningLabels.mu.Unlock()
+
+	ids := make([]uint64, 0, len(runningLabels.labels))
+	for id := range runningLabels.labels {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	tasks := make([]RunningTask, len(ids))
+	for i, id := range ids {
+		tasks[i] = runningLabels.labels[id]
+	}
+	return tasks
+}
+
+func addLabel(label string, gid uint64) uint64 {
+	// A label left behind by a panic that was recovered without
+	// NewRecoveredPanic must not be attributed to a later panic.
+	takePanicInfo(gid)
+
+	runningLabels.mu.Lock()
+	defer runningLabels.mu.Unlock()
+
+	if runningLabels.labels == nil {
+		runningLabels.labels = make(map[uint64]RunningTask)
+	}
+	id := runningLabels.nextID
+	runningLabels.nextID++
+	runningLabels.labels[id] = RunningTask{Label: label, Started: time.Now()}
+	return id
+}
+
+func removeLabel(id uint64) {
+	runningLabels.mu.Lock()
+	defer runningLabels.mu.Unlock()
+
+	delete(runningLabels.labels, id)
+}
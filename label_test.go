@@ -0,0 +1,66 @@
+package conc
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabel(t *testing.T) {
+	t.Run("running labels", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		var wg WaitGroup
+		wg.Go(func() {
+			Label("outer", func() {
+				Label("inner", func() {
+					close(started)
+					<-release
+				})
+			})
+		})
+
+		<-started
+		require.Subset(t, RunningLabels(), []string{"outer", "inner"})
+		close(release)
+		wg.Wait()
+		require.NotContains(t, RunningLabels(), "outer")
+		require.NotContains(t, RunningLabels(), "inner")
+	})
+
+	t.Run("running tasks", func(t *testing.T) {
+		before := time.Now()
+		Label("timed", func() {
+			var found bool
+			for _, task := range RunningTasks() {
+				if task.Label == "timed" {
+					found = true
+					require.False(t, task.Started.Before(before))
+					require.False(t, task.Started.After(time.Now()))
+				}
+			}
+			require.True(t, found)
+		})
+	})
+
+	t.Run("sets pprof label", func(t *testing.T) {
+		Label("profiled", func() {
+			var buf bytes.Buffer
+			require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+			require.Contains(t, buf.String(), `"conc.task":"profiled"`)
+		})
+	})
+
+	t.Run("removed on panic", func(t *testing.T) {
+		var pc PanicCatcher
+		pc.Try(func() {
+			Label("panicking", func() { panic("oh no") })
+		})
+		require.NotNil(t, pc.Recovered())
+		require.NotContains(t, RunningLabels(), "panicking")
+	})
+}
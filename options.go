@@ -0,0 +1,49 @@
+package conc
+
+// Options is a bundle of settings shared by the pools of the pool package, the
+// streams of the stream package, and the iterators of the iter package, so
+// that one set of defaults can be defined once and applied to each of them
+// with their WithOptions method:
+//
+//	var defaults = conc.Options{MaxGoroutines: 16}
+//
+//	p := pool.New().WithOptions(defaults)
+//	s := stream.New().WithOptions(defaults.Merge(conc.Options{Name: "ingest"}))
+//	it := iter.Iterator[int]{}.WithOptions(defaults)
+//
+// The zero value of each field leaves the corresponding setting unchanged, so
+// that options can be layered with Merge, and settings can still be changed
+// after the options are applied. WaitGroup has no settings, since it starts a
+// goroutine for every function it is given: use a pool to apply Options.
+type Options struct {
+	// MaxGoroutines limits the number of goroutines. See
+	// pool.Pool.WithMaxGoroutines.
+	MaxGoroutines int
+
+	// Name is the name of pools and streams, which identifies them in panics
+	// and debugging tools. It is ignored by iterators. See
+	// pool.Pool.WithName.
+	Name string
+
+	// OnProgress is called each time a task completes, with the number of
+	// tasks completed and the number of tasks submitted so far. Calls are
+	// never concurrent. For a stream, a task completes once it has run,
+	// before its callback is called, and for an iterator, once the callback
+	// has been called with an element. See pool.Pool.WithProgress.
+	OnProgress func(done, total int)
+}
+
+// Merge returns o with the fields that are set in other replaced by their
+// values in other, so that a bundle of defaults can be specialized.
+func (o Options) Merge(other Options) Options {
+	if other.MaxGoroutines != 0 {
+		o.MaxGoroutines = other.MaxGoroutines
+	}
+	if other.Name != "" {
+		o.Name = other.Name
+	}
+	if other.OnProgress != nil {
+		o.OnProgress = other.OnProgress
+	}
+	return o
+}
@@ -0,0 +1,29 @@
+package conc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("merge overrides set fields", func(t *testing.T) {
+		var called string
+		defaults := Options{MaxGoroutines: 8, Name: "default", OnProgress: func(int, int) { called = "default" }}
+		merged := defaults.Merge(Options{Name: "ingest"})
+		require.Equal(t, 8, merged.MaxGoroutines)
+		require.Equal(t, "ingest", merged.Name)
+		merged.OnProgress(1, 1)
+		require.Equal(t, "default", called)
+
+		merged = merged.Merge(Options{MaxGoroutines: 2, OnProgress: func(int, int) { called = "override" }})
+		require.Equal(t, 2, merged.MaxGoroutines)
+		merged.OnProgress(1, 1)
+		require.Equal(t, "override", called)
+
+		// The receiver is unchanged
+		require.Equal(t, "default", defaults.Name)
+	})
+}
@@ -1,10 +1,13 @@
 package conc
 
 import (
+	"bytes"
 	"fmt"
 	"runtime"
 	"runtime/debug"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // PanicCatcher is used to catch panics. You can execute a function with Try,
@@ -14,20 +17,144 @@ import (
 // propagate the panic (re-panic) with Propagate()
 type PanicCatcher struct {
 	recovered atomic.Pointer[RecoveredPanic]
+	last      atomic.Pointer[RecoveredPanic]
+	count     atomic.Int64
 }
 
 // Try executes f, catching any panic it might spawn. It is safe
-// to call from multiple goroutines simultaneously.
+// to call from multiple goroutines simultaneously. If f exits its goroutine
+// with runtime.Goexit, which cannot be recovered from, Try records a panic
+// with the value ErrGoexit before the goroutine exits.
 func (p *PanicCatcher) Try(f func()) {
-	defer p.tryRecover()
+	var (
+		recovered *RecoveredPanic
+		completed bool
+	)
+	defer p.tryRecover(&recovered, &completed)
 	f()
+	completed = true
 }
 
-func (p *PanicCatcher) tryRecover() {
-	if val := recover(); val != nil {
-		rp := NewRecoveredPanic(1, val)
+// TryRecovered is like Try, but also returns the panic caught from this call
+// to f, or nil if it did not panic.
+func (p *PanicCatcher) TryRecovered(f func()) (recovered *RecoveredPanic) {
+	var completed bool
+	defer p.tryRecover(&recovered, &completed)
+	f()
+	completed = true
+	return nil
+}
+
+func (p *PanicCatcher) tryRecover(recovered **RecoveredPanic, completed *bool) {
+	val := recover()
+	if val == nil && !*completed {
+		// f neither returned nor panicked with a value. go.mod declares Go
+		// 1.19, so recover returns nil for panic(nil), as with
+		// GODEBUG=panicnil=1, and the stack tells it apart from
+		// runtime.Goexit.
+		goexit, depth := unwinding()
+		if !goexit {
+			val = nilPanicValue()
+		} else if !recordGoexit(currentGoroutineID(), depth) {
+			// Unlike a panic, runtime.Goexit cannot be stopped, and it
+			// unwinds through every enclosing call to Try on this
+			// goroutine, so only the innermost one records it.
+			return
+		} else {
+			val = ErrGoexit
+		}
+	}
+	if val != nil {
+		rp := recoverPanic(val)
 		p.recovered.CompareAndSwap(nil, &rp)
+		p.last.Store(&rp)
+		p.count.Add(1)
+		*recovered = &rp
+	}
+}
+
+// goexits maps the IDs of goroutines exiting with runtime.Goexit, whose exit
+// has been recorded by the innermost PanicCatcher, to the number of enclosing
+// calls to Try that have yet to see it. An entry is removed once the
+// outermost one has, so that goroutines that have exited leave none.
+var goexits struct {
+	mu  sync.Mutex
+	ids map[uint64]int
+}
+
+// recordGoexit reports whether the exit of goroutine gid must be recorded,
+// which is only the case for the innermost of the depth calls to Try it is
+// unwinding through.
+func recordGoexit(gid uint64, depth int) bool {
+	goexits.mu.Lock()
+	defer goexits.mu.Unlock()
+
+	if remaining, ok := goexits.ids[gid]; ok {
+		if remaining <= 1 {
+			delete(goexits.ids, gid)
+		} else {
+			goexits.ids[gid] = remaining - 1
+		}
+		return false
+	}
+	if depth > 1 {
+		if goexits.ids == nil {
+			goexits.ids = make(map[uint64]int)
+		}
+		goexits.ids[gid] = depth - 1
 	}
+	return true
+}
+
+// unwinding reports whether the deferred call that calls it is run by
+// runtime.Goexit rather than by a panic, and the number of calls to Try on
+// the stack. While runtime.Goexit runs deferred calls, the frames of every
+// call it unwinds through are still on the stack.
+func unwinding() (goexit bool, depth int) {
+	pcs := make([]uintptr, 256)
+	n := runtime.Callers(3, pcs)
+	for n == len(pcs) {
+		pcs = make([]uintptr, 2*len(pcs))
+		n = runtime.Callers(3, pcs)
+	}
+	frames := runtime.CallersFrames(pcs[:n])
+	found := false
+	for {
+		frame, more := frames.Next()
+		switch frame.Function {
+		case "runtime.Goexit":
+			if !found {
+				goexit, found = true, true
+			}
+		case "runtime.gopanic":
+			found = true
+		case "github.com/sourcegraph/conc.(*PanicCatcher).Try",
+			"github.com/sourcegraph/conc.(*PanicCatcher).TryRecovered":
+			depth++
+		}
+		if !more {
+			return goexit, depth
+		}
+	}
+}
+
+// recoverPanic returns NewRecoveredPanic for a value recovered by tryRecover.
+// If collecting the panic information panics in turn, the secondary panic is
+// discarded and the original value is kept with whatever could be collected,
+// so that it is not replaced by a confusing panic from the recovery itself.
+func recoverPanic(val any) (rp RecoveredPanic) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			rp = RecoveredPanic{
+				Value: sanitizePanicValue(val),
+				Stack: debug.Stack(),
+				Time:  time.Now(),
+			}
+		}
+	}()
+	// Skip recoverPanic so that the callers start at tryRecover, as they do
+	// when tryRecover calls NewRecoveredPanic directly.
+	return NewRecoveredPanic(2, val)
 }
 
 // Repanic panics if any calls to Try caught a panic. It will panic with the
@@ -35,7 +162,7 @@ func (p *PanicCatcher) tryRecover() {
 // information.
 func (p *PanicCatcher) Repanic() {
 	if val := p.Recovered(); val != nil {
-		panic(val)
+		val.propagate(1)
 	}
 }
 
@@ -45,6 +172,99 @@ func (p *PanicCatcher) Recovered() *RecoveredPanic {
 	return p.recovered.Load()
 }
 
+// Count returns the number of panics caught by Try so far.
+func (p *PanicCatcher) Count() int64 {
+	return p.count.Load()
+}
+
+// Last returns the most recent panic caught by Try, or nil if no calls to
+// Try panicked. Unlike Recovered, which always returns the first panic, Last
+// is useful for long-lived catchers that keep running after a panic.
+func (p *PanicCatcher) Last() *RecoveredPanic {
+	return p.last.Load()
+}
+
+var defaultPanicHandler atomic.Pointer[func(*RecoveredPanic)]
+
+// SetDefaultPanicHandler sets the process-wide handler for caught panics, so
+// that they can be reported in one place, for example to a crash reporting
+// service. The handler is called with every panic caught in a goroutine
+// spawned with Go or GoCtx, or by a WaitGroup, pool, stream or iterator,
+// unless the panic is handled by the instance that caught it, for example
+// with pool.ErrorPool.WithPanicsAsErrors or stream.WithCallbackPanicHandler.
+// Panics caught by a WaitGroup or pool are still propagated by Wait as usual.
+// The handler may be called concurrently from multiple goroutines. Passing nil
+// removes the handler.
+func SetDefaultPanicHandler(handler func(*RecoveredPanic)) {
+	if handler == nil {
+		defaultPanicHandler.Store(nil)
+		return
+	}
+	defaultPanicHandler.Store(&handler)
+}
+
+// DefaultPanicHandler returns the handler set with SetDefaultPanicHandler, or
+// nil if there is none.
+func DefaultPanicHandler() func(*RecoveredPanic) {
+	if handler := defaultPanicHandler.Load(); handler != nil {
+		return *handler
+	}
+	return nil
+}
+
+// ReportPanic passes recovered to the handler set with SetDefaultPanicHandler,
+// if there is one. It is meant to be called by code that catches panics with a
+// PanicCatcher, as WaitGroup does. A panic whose value is a *RecoveredPanic has
+// already been caught and reported once, so it is not reported again when it
+// is propagated to another WaitGroup or pool.
+func ReportPanic(recovered *RecoveredPanic) {
+	if recovered == nil {
+		return
+	}
+	if _, ok := recovered.Value.(*RecoveredPanic); ok {
+		return
+	}
+	recordRecentPanic(recovered)
+	if handler := DefaultPanicHandler(); handler != nil {
+		handler(recovered)
+	}
+}
+
+// recentPanicsSize is the number of panics kept for RecentPanics.
+const recentPanicsSize = 16
+
+var recentPanics struct {
+	mu     sync.Mutex
+	panics []*RecoveredPanic
+}
+
+// RecentPanics returns the most recent panics passed to ReportPanic, up to
+// 16 of them, starting with the most recent. Since ReportPanic is called with
+// every panic that would be passed to the default panic handler, they are
+// available for debugging even when no handler is set. See
+// SetDefaultPanicHandler.
+func RecentPanics() []*RecoveredPanic {
+	recentPanics.mu.Lock()
+	defer recentPanics.mu.Unlock()
+
+	panics := make([]*RecoveredPanic, len(recentPanics.panics))
+	for i, recovered := range recentPanics.panics {
+		panics[len(panics)-1-i] = recovered
+	}
+	return panics
+}
+
+func recordRecentPanic(recovered *RecoveredPanic) {
+	recentPanics.mu.Lock()
+	defer recentPanics.mu.Unlock()
+
+	if len(recentPanics.panics) == recentPanicsSize {
+		copy(recentPanics.panics, recentPanics.panics[1:])
+		recentPanics.panics = recentPanics.panics[:recentPanicsSize-1]
+	}
+	recentPanics.panics = append(recentPanics.panics, recovered)
+}
+
 // NewRecoveredPanic creates a RecoveredPanic from a panic value and a
 // collected stacktrace. The skip parameter allows the caller to skip stack
 // frames when collecting the stacktrace. Calling with a skip of 0 means
@@ -53,10 +273,17 @@ func NewRecoveredPanic(skip int, value any) RecoveredPanic {
 	// 64 frames should be plenty
 	var callers [64]uintptr
 	n := runtime.Callers(skip+1, callers[:])
+	stack := trimStack(debug.Stack())
+	info := takePanicInfo(stackGoroutineID(stack))
 	return RecoveredPanic{
-		Value:   value,
-		Callers: callers[:n],
-		Stack:   debug.Stack(),
+		Value:     sanitizePanicValue(value),
+		Callers:   callers[:n],
+		Stack:     stack,
+		Time:      time.Now(),
+		Task:      info.label,
+		TaskInfo:  info.task,
+		Goroutine: stackHeader(stack),
+		Snapshot:  takeRuntimeSnapshot(info),
 	}
 }
 
@@ -69,12 +296,210 @@ type RecoveredPanic struct {
 	// runtime.CallersFrames.
 	Callers []uintptr
 	// The formatted stacktrace from the goroutine where the panic was recovered.
-	// Easier to use than Callers.
+	// Easier to use than Callers. The frames of the recovery itself are
+	// removed, so that the first frame is the function that panicked.
 	Stack []byte
+	// The time at which the panic was recovered.
+	Time time.Time
+	// The label of the innermost call to Label that the panic unwound
+	// through, or "" if there is none.
+	Task string
+	// The pool task that raised the panic, if the panic was raised by a task
+	// run by a pool or stream, or nil otherwise. See RunTask.
+	TaskInfo *TaskInfo
+	// The header line of the stacktrace, which identifies the goroutine, e.g.
+	// "goroutine 42 [running]:".
+	Goroutine string
+	// The formatted stacktrace of the goroutine that propagated the panic
+	// with Propagate, if the panic format is PanicFormatPropagated, or nil
+	// otherwise. See SetPanicFormat.
+	PropagatedStack []byte
+	// The state of the runtime when the panic was recovered, if runtime
+	// snapshots are enabled, or nil otherwise. See SetPanicSnapshots.
+	Snapshot *RuntimeSnapshot
+}
+
+// TrimmedStack returns Stack without its first skip frames. This is useful
+// for panics raised by helpers such as assertion functions, so that the first
+// frame is the caller of the helper rather than the helper itself. The frame
+// that started the goroutine is never removed.
+func (c *RecoveredPanic) TrimmedStack(skip int) []byte {
+	return skipFrames(c.Stack, skip)
+}
+
+// trimStack removes the frames of the recovery machinery from a stack
+// formatted by debug.Stack, which are all the frames up to and including the
+// call to panic. The stack is returned unchanged if it has no call to panic.
+func trimStack(stack []byte) []byte {
+	lines := bytes.Split(stack, []byte("\n"))
+	// The first line is the goroutine header, followed by two lines per
+	// frame: the function, then its file and line.
+	for i := 1; i+1 < len(lines); i += 2 {
+		if bytes.HasPrefix(lines[i], []byte("panic(")) {
+			return joinStack(lines[0], lines[i+2:])
+		}
+	}
+	return stack
+}
+
+// skipFrames removes the first n frames from a stack formatted by
+// debug.Stack, stopping at the line that records where the goroutine was
+// created.
+func skipFrames(stack []byte, n int) []byte {
+	lines := bytes.Split(stack, []byte("\n"))
+	i := 1
+	for ; n > 0 && i+1 < len(lines) && !bytes.HasPrefix(lines[i], []byte("created by ")); n-- {
+		i += 2
+	}
+	return joinStack(lines[0], lines[i:])
+}
+
+// stackHeader returns the first line of a stack formatted by debug.Stack.
+func stackHeader(stack []byte) string {
+	if i := bytes.IndexByte(stack, '\n'); i >= 0 {
+		return string(stack[:i])
+	}
+	return string(stack)
+}
+
+func joinStack(header []byte, frames [][]byte) []byte {
+	return bytes.Join(append([][]byte{header}, frames...), []byte("\n"))
+}
+
+// Propagate panics with the RecoveredPanic, as PanicCatcher.Repanic does, to
+// propagate it to another goroutine, usually the one waiting for the
+// goroutine that panicked. If the panic format is PanicFormatPropagated, the
+// stacktrace of the current goroutine is recorded in PropagatedStack of a
+// copy of the RecoveredPanic, which is panicked with instead.
+func (c *RecoveredPanic) Propagate() {
+	c.propagate(1)
+}
+
+// propagate is the implementation of Propagate, skipping skip frames of its
+// callers in the recorded stacktrace.
+func (c *RecoveredPanic) propagate(skip int) {
+	if PanicFormat(panicFormat.Load()) != PanicFormatPropagated {
+		panic(c)
+	}
+	propagated := *c
+	// Skip debug.Stack and propagate itself
+	propagated.PropagatedStack = skipFrames(debug.Stack(), skip+2)
+	panic(&propagated)
+}
+
+// PanicFormat is a way of formatting a RecoveredPanic as an error, which is
+// also how the runtime prints it when it is propagated and not recovered
+// again. See SetPanicFormat.
+type PanicFormat int32
+
+const (
+	// PanicFormatDefault formats the panic value followed by the
+	// stacktrace of the goroutine that panicked.
+	PanicFormatDefault PanicFormat = iota
+	// PanicFormatPropagated formats the panic value, then the stacktrace of
+	// the goroutine that panicked, introduced by "panic originally occurred
+	// at:", then the stacktrace of the goroutine that propagated it, such as
+	// the caller of WaitGroup.Wait, introduced by "panic propagated at:".
+	PanicFormatPropagated
+)
+
+var panicFormat atomic.Int32
+
+// SetPanicFormat sets the process-wide format of RecoveredPanic errors.
+// PanicFormatPropagated has a cost, since the stacktrace of the propagating
+// goroutine is collected every time a panic is propagated, so it is not the
+// default.
+func SetPanicFormat(format PanicFormat) {
+	panicFormat.Store(int32(format))
+}
+
+var panicValueSanitizer atomic.Pointer[func(any) any]
+
+// SetPanicValueSanitizer sets the process-wide function applied to the value
+// of every panic when it is recovered, before it is stored in the
+// RecoveredPanic, so that panic values carrying secrets, such as request
+// bodies or tokens, can be redacted before they are formatted by Error and
+// end up in logs. The sanitized value replaces the original one, which is not
+// kept, so errors.Is and errors.As only see what the sanitizer returns. Panics
+// that are propagated and recovered again, whose value is a *RecoveredPanic,
+// and ErrGoexit are not passed to the sanitizer. If the sanitizer panics, the
+// value is replaced by a placeholder. Passing nil removes the sanitizer.
+func SetPanicValueSanitizer(sanitize func(value any) any) {
+	if sanitize == nil {
+		panicValueSanitizer.Store(nil)
+		return
+	}
+	panicValueSanitizer.Store(&sanitize)
+}
+
+// redactedPanicValue replaces a panic value that the sanitizer failed on.
+const redactedPanicValue = "conc: panic value redacted after the sanitizer panicked"
+
+// sanitizePanicValue applies the sanitizer set with SetPanicValueSanitizer
+// to value.
+func sanitizePanicValue(value any) (sanitized any) {
+	sanitize := panicValueSanitizer.Load()
+	if sanitize == nil || value == ErrGoexit {
+		return value
+	}
+	if _, ok := value.(*RecoveredPanic); ok {
+		return value
+	}
+	defer func() {
+		if recover() != nil {
+			sanitized = redactedPanicValue
+		}
+	}()
+	return (*sanitize)(value)
+}
+
+// RuntimeSnapshot is the state of the runtime when a panic was recovered,
+// which helps tell a panic caused by an overloaded program, for example one
+// with far more goroutines than usual, from a plain bug. See
+// SetPanicSnapshots.
+type RuntimeSnapshot struct {
+	// NumGoroutine is the number of goroutines, as returned by
+	// runtime.NumGoroutine.
+	NumGoroutine int
+	// GOMAXPROCS is the value of runtime.GOMAXPROCS.
+	GOMAXPROCS int
+	// Labels are the labels of every call to Label that the panic unwound
+	// through, which are the values of the pprof label LabelKey of the
+	// goroutine that panicked, outermost first. The last one is the Task of
+	// the RecoveredPanic.
+	Labels []string
+}
+
+var panicSnapshots atomic.Bool
+
+// SetPanicSnapshots sets whether a RuntimeSnapshot is taken, process-wide,
+// every time a panic is recovered, and recorded in the Snapshot of the
+// RecoveredPanic. Snapshots are disabled by default, to keep the cost of
+// recovering a panic down.
+func SetPanicSnapshots(enabled bool) {
+	panicSnapshots.Store(enabled)
 }
 
+func takeRuntimeSnapshot(info panicInfo) *RuntimeSnapshot {
+	if !panicSnapshots.Load() {
+		return nil
+	}
+	return &RuntimeSnapshot{
+		NumGoroutine: runtime.NumGoroutine(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		Labels:       info.labels,
+	}
+}
+
+// Error formats the panic value with FormatPanicValue, followed by the
+// stacktraces, as set with SetPanicFormat. It never panics, whatever the
+// value.
 func (c *RecoveredPanic) Error() string {
-	return fmt.Sprintf("panic: %v\nstacktrace:\n%s\n", c.Value, c.Stack)
+	value := FormatPanicValue(c.Value)
+	if c.PropagatedStack != nil {
+		return fmt.Sprintf("panic: %s\n\npanic originally occurred at:\n%s\n\npanic propagated at:\n%s\n", value, c.Stack, c.PropagatedStack)
+	}
+	return fmt.Sprintf("panic: %s\nstacktrace:\n%s\n", value, c.Stack)
 }
 
 func (c *RecoveredPanic) Unwrap() error {
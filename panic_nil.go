@@ -0,0 +1,12 @@
+//go:build go1.21
+
+package conc
+
+import "runtime"
+
+// nilPanicValue returns the value recorded for panic(nil), which is the
+// *runtime.PanicNilError that recover returns in modules that declare Go 1.21
+// or later.
+func nilPanicValue() any {
+	return new(runtime.PanicNilError)
+}
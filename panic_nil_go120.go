@@ -0,0 +1,14 @@
+//go:build !go1.21
+
+package conc
+
+import "errors"
+
+// nilPanicValue returns the value recorded for panic(nil). Go 1.21 added
+// runtime.PanicNilError for it, so earlier versions record an error of their
+// own.
+func nilPanicValue() any {
+	return errPanicNil
+}
+
+var errPanicNil = errors.New("panic called with nil argument")
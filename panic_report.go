@@ -0,0 +1,97 @@
+package conc
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// PanicReport describes a RecoveredPanic in a form that maps directly onto
+// the events of error tracking services such as Sentry, without having to
+// parse the formatted stack trace.
+type PanicReport struct {
+	// Type is the Go type of the panic value, e.g. "*errors.errorString" or
+	// "string".
+	Type string
+	// Message is the panic value formatted with FormatPanicValue.
+	Message string
+	// Frames is the stack of the goroutine at the point of the panic,
+	// starting with the function that panicked and ending with the function
+	// that started the goroutine. Sentry expects its frames in the reverse
+	// order.
+	Frames []PanicFrame
+	// GoroutineID is the ID of the goroutine that panicked, as shown in stack
+	// traces, or 0 if it is unknown.
+	GoroutineID uint64
+	// Task is the label of the task that panicked. See RecoveredPanic.Task.
+	Task string
+	// TaskInfo identifies the pool task that panicked, if any. See
+	// RecoveredPanic.TaskInfo.
+	TaskInfo *TaskInfo
+}
+
+// PanicFrame is a single frame of a PanicReport.
+type PanicFrame struct {
+	// Function is the package path-qualified function name, e.g.
+	// "github.com/sourcegraph/conc.(*WaitGroup).Go.func1".
+	Function string
+	File     string
+	Line     int
+}
+
+// Report returns a PanicReport for the panic.
+func (p *RecoveredPanic) Report() PanicReport {
+	return PanicReport{
+		Type:        fmt.Sprintf("%T", p.Value),
+		Message:     FormatPanicValue(p.Value),
+		Frames:      panicFrames(p.Callers),
+		GoroutineID: stackGoroutineID(p.Stack),
+		Task:        p.Task,
+		TaskInfo:    p.TaskInfo,
+	}
+}
+
+// panicFrames converts callers to frames, skipping the frames of the
+// recovery itself, which come before the call to runtime.gopanic.
+func panicFrames(callers []uintptr) []PanicFrame {
+	var frames []PanicFrame
+	iter := runtime.CallersFrames(callers)
+	for {
+		frame, more := iter.Next()
+		if frame.Function == "runtime.gopanic" {
+			frames = frames[:0]
+		} else if frame.Function != "" {
+			frames = append(frames, PanicFrame{
+				Function: frame.Function,
+				File:     frame.File,
+				Line:     frame.Line,
+			})
+		}
+		if !more {
+			return frames
+		}
+	}
+}
+
+// stackGoroutineID returns the ID of the goroutine in a stack trace formatted
+// by runtime.Stack, which starts with "goroutine <id> [", or 0 if there is
+// none.
+func stackGoroutineID(stack []byte) uint64 {
+	if i := bytes.IndexByte(stack, '['); i >= 0 {
+		stack = stack[:i]
+	}
+	fields := bytes.Fields(stack)
+	if len(fields) < 2 || string(fields[0]) != "goroutine" {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+	return id
+}
+
+// currentGoroutineID returns the ID of the current goroutine.
+func currentGoroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	return stackGoroutineID(buf[:n])
+}
@@ -0,0 +1,62 @@
+package conc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func panicWith(val any) {
+	panic(val)
+}
+
+func TestRecoveredPanicReport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("error value", func(t *testing.T) {
+		t.Parallel()
+		var pc PanicCatcher
+		pc.Try(func() { panicWith(errors.New("SOS")) })
+		report := pc.Recovered().Report()
+		require.Equal(t, "*errors.errorString", report.Type)
+		require.Equal(t, "SOS", report.Message)
+		require.NotZero(t, report.GoroutineID)
+	})
+
+	t.Run("frames start where the panic happened", func(t *testing.T) {
+		t.Parallel()
+		var pc PanicCatcher
+		pc.Try(func() { panicWith("super bad thing") })
+		report := pc.Recovered().Report()
+		require.Equal(t, "string", report.Type)
+		require.Equal(t, "super bad thing", report.Message)
+		require.NotEmpty(t, report.Frames)
+		require.Equal(t, "github.com/sourcegraph/conc.panicWith", report.Frames[0].Function)
+		require.True(t, strings.HasSuffix(report.Frames[0].File, "panic_report_test.go"))
+		require.NotZero(t, report.Frames[0].Line)
+	})
+
+	t.Run("task label", func(t *testing.T) {
+		t.Parallel()
+		var pc PanicCatcher
+		pc.Try(func() {
+			Label("outer", func() {
+				Label("inner", func() { panicWith("super bad thing") })
+			})
+		})
+		require.Equal(t, "inner", pc.Recovered().Report().Task)
+
+		// The label is not attributed to later panics
+		pc.Try(func() { panicWith("super bad thing") })
+		require.Equal(t, "", pc.Last().Report().Task)
+	})
+}
+
+func TestStackGoroutineID(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, uint64(42), stackGoroutineID([]byte("goroutine 42 [running]:\nmain.main()")))
+	require.Equal(t, uint64(0), stackGoroutineID(nil))
+	require.NotZero(t, currentGoroutineID())
+}
@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -115,4 +118,328 @@ func TestPanicCatcher(t *testing.T) {
 		wg.Wait()
 		require.Equal(t, "50", pc.Recovered().Value)
 	})
+
+	t.Run("counts panics and keeps the last", func(t *testing.T) {
+		var pc PanicCatcher
+		require.Equal(t, int64(0), pc.Count())
+		require.Nil(t, pc.Last())
+
+		pc.Try(func() { panic("first") })
+		pc.Try(func() {})
+		pc.Try(func() { panic("second") })
+		require.Equal(t, int64(2), pc.Count())
+		require.Equal(t, "first", pc.Recovered().Value)
+		require.Equal(t, "second", pc.Last().Value)
+		require.False(t, pc.Last().Time.Before(pc.Recovered().Time))
+	})
+
+	t.Run("stack starts at the panicking function", func(t *testing.T) {
+		var pc PanicCatcher
+		pc.Try(func() { panicWith("super bad thing") })
+		stack := string(pc.Recovered().Stack)
+		require.True(t, strings.HasPrefix(stack, "goroutine "))
+		frames := strings.Split(stack, "\n")
+		require.Equal(t, "github.com/sourcegraph/conc.panicWith(...)", frames[1])
+		require.NotContains(t, stack, "tryRecover")
+		require.NotContains(t, stack, "runtime/debug.Stack")
+
+		trimmed := strings.Split(string(pc.Recovered().TrimmedStack(1)), "\n")
+		require.Equal(t, frames[0], trimmed[0])
+		require.Equal(t, frames[3:], trimmed[1:])
+
+		// The frame that created the goroutine is kept
+		all := string(pc.Recovered().TrimmedStack(1000))
+		require.Contains(t, all, "created by ")
+	})
+
+	t.Run("stack is kept without a panic", func(t *testing.T) {
+		stack := []byte("goroutine 1 [running]:\nmain.main()\n\t/main.go:1 +0x1\n")
+		require.Equal(t, stack, trimStack(stack))
+	})
+
+	t.Run("TryRecovered returns the panic from the call", func(t *testing.T) {
+		var pc PanicCatcher
+		require.Nil(t, pc.TryRecovered(func() {}))
+		require.Equal(t, "first", pc.TryRecovered(func() { panic("first") }).Value)
+		require.Equal(t, "second", pc.TryRecovered(func() { panic("second") }).Value)
+		require.Equal(t, "first", pc.Recovered().Value)
+	})
+
+	t.Run("Goexit", func(t *testing.T) {
+		var pc PanicCatcher
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			pc.Try(runtime.Goexit)
+			t.Error("Try returned after Goexit")
+		}()
+		<-done
+		recovered := pc.Recovered()
+		require.NotNil(t, recovered)
+		require.ErrorIs(t, recovered, ErrGoexit)
+		require.Equal(t, int64(1), pc.Count())
+	})
+
+	t.Run("nested Goexit is recorded once", func(t *testing.T) {
+		var inner, outer PanicCatcher
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			outer.Try(func() {
+				outer.Try(func() {
+					inner.Try(runtime.Goexit)
+				})
+			})
+		}()
+		<-done
+		require.ErrorIs(t, inner.Recovered(), ErrGoexit)
+		require.Nil(t, outer.Recovered())
+
+		goexits.mu.Lock()
+		defer goexits.mu.Unlock()
+		require.Empty(t, goexits.ids)
+	})
+
+	t.Run("panic(nil) is not a Goexit", func(t *testing.T) {
+		var pc PanicCatcher
+		recovered := pc.TryRecovered(func() { panic(nil) })
+		require.NotNil(t, recovered)
+		require.NotErrorIs(t, recovered, ErrGoexit)
+		require.IsType(t, nilPanicValue(), recovered.Value)
+		require.Equal(t, int64(1), pc.Count())
+
+		goexits.mu.Lock()
+		defer goexits.mu.Unlock()
+		require.Empty(t, goexits.ids)
+	})
+
+	t.Run("value that panics when formatted", func(t *testing.T) {
+		var pc PanicCatcher
+		pc.Try(func() { panic(brokenValue{}) })
+		recovered := pc.Recovered()
+		require.NotNil(t, recovered)
+		require.Equal(t, brokenValue{}, recovered.Value)
+		require.NotEmpty(t, recovered.Stack)
+		require.NotEmpty(t, recovered.Callers)
+		require.Contains(t, recovered.Error(), "PANIC=")
+		require.Contains(t, recovered.Report().Message, "PANIC=")
+		require.Panics(t, pc.Repanic)
+	})
+
+	t.Run("recoverPanic keeps the value", func(t *testing.T) {
+		rp := recoverPanic(brokenValue{})
+		require.Equal(t, brokenValue{}, rp.Value)
+		require.NotEmpty(t, rp.Stack)
+	})
+}
+
+// brokenValue is a panic value whose methods panic when it is formatted.
+type brokenValue struct{}
+
+func (brokenValue) Error() string  { panic("Error panicked") }
+func (brokenValue) String() string { panic("String panicked") }
+
+func TestPanicFormat(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		var wg WaitGroup
+		wg.Go(func() { panicWith("super bad thing") })
+		defer func() {
+			recovered := recover().(*RecoveredPanic)
+			require.Nil(t, recovered.PropagatedStack)
+			require.NotContains(t, recovered.Error(), "panic propagated at")
+		}()
+		wg.Wait()
+	})
+
+	t.Run("propagated", func(t *testing.T) {
+		SetPanicFormat(PanicFormatPropagated)
+		defer SetPanicFormat(PanicFormatDefault)
+
+		var wg WaitGroup
+		wg.Go(func() { panicWith("super bad thing") })
+		defer func() {
+			recovered := recover().(*RecoveredPanic)
+			msg := recovered.Error()
+			original := strings.Index(msg, "panic originally occurred at:\n")
+			propagated := strings.Index(msg, "panic propagated at:\n")
+			require.Greater(t, original, 0, msg)
+			require.Greater(t, propagated, original, msg)
+			require.Contains(t, msg[original:propagated], "conc.panicWith")
+			require.Contains(t, msg[propagated:], "conc.(*WaitGroup).Wait")
+			require.NotContains(t, msg[propagated:], "runtime/debug.Stack")
+			require.NotContains(t, msg[propagated:], "(*RecoveredPanic).propagate")
+		}()
+		wg.Wait()
+	})
+}
+
+func TestPanicSnapshots(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		var pc PanicCatcher
+		pc.Try(func() { panic("super bad thing") })
+		require.Nil(t, pc.Recovered().Snapshot)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		SetPanicSnapshots(true)
+		defer SetPanicSnapshots(false)
+
+		var pc PanicCatcher
+		pc.Try(func() {
+			Label("outer", func() {
+				Label("inner", func() { panic("super bad thing") })
+			})
+		})
+		snapshot := pc.Recovered().Snapshot
+		require.NotNil(t, snapshot)
+		require.GreaterOrEqual(t, snapshot.NumGoroutine, 1)
+		require.Equal(t, runtime.GOMAXPROCS(0), snapshot.GOMAXPROCS)
+		require.Equal(t, []string{"outer", "inner"}, snapshot.Labels)
+		require.Equal(t, "inner", pc.Recovered().Task)
+	})
+
+	t.Run("labels are not kept across panics", func(t *testing.T) {
+		SetPanicSnapshots(true)
+		defer SetPanicSnapshots(false)
+
+		var pc PanicCatcher
+		pc.Try(func() { Label("first", func() { panic("first") }) })
+		pc.Try(func() { panic("second") })
+		require.Empty(t, pc.Last().Snapshot.Labels)
+	})
+}
+
+func TestPanicValueSanitizer(t *testing.T) {
+	redact := func(value any) any {
+		if s, ok := value.(string); ok {
+			return strings.ReplaceAll(s, "hunter2", "[redacted]")
+		}
+		return value
+	}
+
+	t.Run("redacts values", func(t *testing.T) {
+		SetPanicValueSanitizer(redact)
+		defer SetPanicValueSanitizer(nil)
+
+		var pc PanicCatcher
+		pc.Try(func() { panic("password is hunter2") })
+		recovered := pc.Recovered()
+		require.Equal(t, "password is [redacted]", recovered.Value)
+		require.NotContains(t, recovered.Error(), "hunter2")
+	})
+
+	t.Run("propagated panics are sanitized once", func(t *testing.T) {
+		var calls atomic.Int64
+		SetPanicValueSanitizer(func(value any) any {
+			calls.Add(1)
+			return redact(value)
+		})
+		defer SetPanicValueSanitizer(nil)
+
+		var wg WaitGroup
+		wg.Go(func() { panic("password is hunter2") })
+		var pc PanicCatcher
+		pc.Try(wg.Wait)
+		require.Equal(t, "password is [redacted]", pc.Recovered().Value.(*RecoveredPanic).Value)
+		require.Equal(t, int64(1), calls.Load())
+	})
+
+	t.Run("sanitizer panics", func(t *testing.T) {
+		SetPanicValueSanitizer(func(any) any { panic("broken sanitizer") })
+		defer SetPanicValueSanitizer(nil)
+
+		var pc PanicCatcher
+		pc.Try(func() { panic("password is hunter2") })
+		require.NotContains(t, pc.Recovered().Error(), "hunter2")
+		require.NotContains(t, pc.Recovered().Error(), "broken sanitizer")
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		SetPanicValueSanitizer(redact)
+		SetPanicValueSanitizer(nil)
+
+		var pc PanicCatcher
+		pc.Try(func() { panic("password is hunter2") })
+		require.Equal(t, "password is hunter2", pc.Recovered().Value)
+	})
+}
+
+// collectPanics sets a default panic handler that records the value of every
+// panic it is called with, until the test ends.
+func collectPanics(t *testing.T) func() []any {
+	var (
+		mu     sync.Mutex
+		values []any
+	)
+	SetDefaultPanicHandler(func(recovered *RecoveredPanic) {
+		mu.Lock()
+		defer mu.Unlock()
+		values = append(values, recovered.Value)
+	})
+	t.Cleanup(func() { SetDefaultPanicHandler(nil) })
+	return func() []any {
+		mu.Lock()
+		defer mu.Unlock()
+		return values
+	}
+}
+
+func TestReportPanic(t *testing.T) {
+	t.Run("without a handler", func(t *testing.T) {
+		var pc PanicCatcher
+		require.NotPanics(t, func() {
+			ReportPanic(pc.TryRecovered(func() { panic("super bad thing") }))
+		})
+	})
+
+	t.Run("WaitGroup reports panics", func(t *testing.T) {
+		panics := collectPanics(t)
+		var wg WaitGroup
+		wg.Go(func() { panic("super bad thing") })
+		wg.Go(func() {})
+		require.Panics(t, wg.Wait)
+		require.Equal(t, []any{"super bad thing"}, panics())
+	})
+
+	t.Run("propagated panics are reported once", func(t *testing.T) {
+		panics := collectPanics(t)
+		var outer WaitGroup
+		outer.Go(func() {
+			var inner WaitGroup
+			inner.Go(func() { panic("super bad thing") })
+			inner.Wait()
+		})
+		require.Panics(t, outer.Wait)
+		require.Equal(t, []any{"super bad thing"}, panics())
+	})
+
+	t.Run("Go reports propagated panics once", func(t *testing.T) {
+		panics := collectPanics(t)
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			var wg WaitGroup
+			wg.Go(func() { panic("super bad thing") })
+			wg.Wait()
+		})
+		<-done
+		require.Eventually(t, func() bool { return len(panics()) > 0 }, time.Second, time.Millisecond)
+		require.Equal(t, []any{"super bad thing"}, panics())
+	})
+
+	t.Run("recent panics", func(t *testing.T) {
+		var pc PanicCatcher
+		for i := 0; i < recentPanicsSize+2; i++ {
+			i := i
+			ReportPanic(pc.TryRecovered(func() { panic(fmt.Sprintf("recent %d", i)) }))
+		}
+		recent := RecentPanics()
+		require.Len(t, recent, recentPanicsSize)
+		var values []any
+		for _, recovered := range recent {
+			values = append(values, recovered.Value)
+		}
+		require.Equal(t, fmt.Sprintf("recent %d", recentPanicsSize+1), values[0])
+		require.NotContains(t, values, "recent 1")
+	})
 }
@@ -0,0 +1,201 @@
+package conc
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// DefaultPanicValueMaxSize is the default maximum size of a formatted panic
+// value. See SetPanicValueMaxSize.
+const DefaultPanicValueMaxSize = 64 << 10
+
+var panicValueMaxSize atomic.Int64
+
+// SetPanicValueMaxSize sets the process-wide maximum size in bytes of a
+// panic value formatted with FormatPanicValue, and so by RecoveredPanic.Error
+// and RecoveredPanic.Report, beyond which the value is truncated. Defaults to
+// DefaultPanicValueMaxSize. Panics if n < 1.
+func SetPanicValueMaxSize(n int) {
+	if n < 1 {
+		panic("max panic value size must be greater than zero")
+	}
+	panicValueMaxSize.Store(int64(n))
+}
+
+func maxPanicValueSize() int {
+	if n := panicValueMaxSize.Load(); n > 0 {
+		return int(n)
+	}
+	return DefaultPanicValueMaxSize
+}
+
+const (
+	// maxPanicValueDepth is the deepest nesting of a panic value that is
+	// formatted in full.
+	maxPanicValueDepth = 32
+	// maxPanicValueNodes is the largest number of nested values, such as
+	// the elements of slices of interfaces, that a panic value formatted in
+	// full may have.
+	maxPanicValueNodes = 1 << 14
+)
+
+// FormatPanicValue formats a panic value as fmt.Sprint does, but never
+// panics, and never produces more than the size set with
+// SetPanicValueMaxSize, whatever the value. Errors and fmt.Stringers are
+// formatted with their methods; if formatting panics, the panic is reported
+// in place of the value, as fmt does, e.g. "%!v(PANIC=Error method: boom)",
+// or "<nil>" for a nil pointer. Values that are cyclic, such as a slice that
+// contains itself, or that are too deeply nested or too large to be formatted
+// in a bounded time, are replaced by a placeholder naming their type. Output
+// beyond the maximum size is cut at a UTF-8 boundary and marked as truncated.
+func FormatPanicValue(value any) string {
+	return truncatePanicValue(formatPanicValue(value, false), maxPanicValueSize())
+}
+
+// formatPanicValue formats value. If nested is set, value is the panic
+// raised while formatting another value, and a panic while formatting it is
+// reported without its value, so that formatting always terminates.
+func formatPanicValue(value any, nested bool) (formatted string) {
+	if value == nil {
+		return "<nil>"
+	}
+	method := "fmt.Sprint"
+	defer func() {
+		if r := recover(); r != nil {
+			formatted = panickedPlaceholder(value, method, r, nested)
+		}
+	}()
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		method = "Error method"
+		return v.Error()
+	case fmt.Stringer:
+		method = "String method"
+		return v.String()
+	}
+
+	w := panicValueWalker{visiting: make(map[visit]bool)}
+	if !w.formattable(reflect.ValueOf(value), 0) {
+		return fmt.Sprintf("<%T: too large, deep or cyclic to format>", value)
+	}
+	return fmt.Sprint(value)
+}
+
+// panickedPlaceholder replaces value, whose formatting panicked in method
+// with r.
+func panickedPlaceholder(value any, method string, r any, nested bool) string {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer && v.IsNil() {
+		return "<nil>"
+	}
+	if nested {
+		return fmt.Sprintf("%%!v(PANIC=%s)", method)
+	}
+	return fmt.Sprintf("%%!v(PANIC=%s: %s)", method, formatPanicValue(r, true))
+}
+
+// truncatePanicValue cuts s to at most n bytes, marker included, at a UTF-8
+// boundary.
+func truncatePanicValue(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	const marker = "... [truncated]"
+	if n <= len(marker) {
+		return marker[:n]
+	}
+	cut := n - len(marker)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
+
+// visit identifies a slice or map that a panicValueWalker is inside of.
+type visit struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// panicValueWalker walks a value the way fmt does when formatting it, to
+// check that formatting terminates in a bounded time.
+type panicValueWalker struct {
+	nodes    int
+	visiting map[visit]bool
+}
+
+// formattable reports whether v, at the given depth, is acyclic and small
+// enough to be formatted in full.
+func (w *panicValueWalker) formattable(v reflect.Value, depth int) bool {
+	w.nodes++
+	if depth > maxPanicValueDepth || w.nodes > maxPanicValueNodes {
+		return false
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		return v.IsNil() || w.formattable(v.Elem(), depth+1)
+	case reflect.Pointer:
+		// Like fmt, only follow a pointer at the top level. Nested pointers
+		// are formatted as addresses.
+		if depth > 0 || v.IsNil() {
+			return true
+		}
+		switch v.Elem().Kind() {
+		case reflect.Array, reflect.Slice, reflect.Struct, reflect.Map:
+			return w.formattable(v.Elem(), depth+1)
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !w.formattable(v.Field(i), depth+1) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return true
+		}
+		key := visit{typ: v.Type(), ptr: v.Pointer(), len: v.Len()}
+		if w.visiting[key] {
+			return false
+		}
+		w.visiting[key] = true
+		defer delete(w.visiting, key)
+		if v.Kind() == reflect.Map {
+			iter := v.MapRange()
+			for iter.Next() {
+				if !w.formattable(iter.Key(), depth+1) || !w.formattable(iter.Value(), depth+1) {
+					return false
+				}
+			}
+			return true
+		}
+		return w.formattableElems(v, depth)
+	case reflect.Array:
+		return w.formattableElems(v, depth)
+	}
+	return true
+}
+
+// formattableElems reports whether the elements of the slice or array v are
+// formattable. The elements are only walked if they can nest further.
+func (w *panicValueWalker) formattableElems(v reflect.Value, depth int) bool {
+	switch v.Type().Elem().Kind() {
+	case reflect.Interface, reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		return true
+	}
+	for i := 0; i < v.Len(); i++ {
+		if !w.formattable(v.Index(i), depth+1) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package conc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+type panickingError struct{}
+
+func (panickingError) Error() string { panic("Error panicked") }
+
+type panickingStringer struct{ s *string }
+
+func (p *panickingStringer) String() string { return *p.s }
+
+// doublyPanickingError panics with a value whose own Error method panics.
+type doublyPanickingError struct{}
+
+func (doublyPanickingError) Error() string { panic(panickingError{}) }
+
+func TestFormatPanicValue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("plain values", func(t *testing.T) {
+		require.Equal(t, "<nil>", FormatPanicValue(nil))
+		require.Equal(t, "boom", FormatPanicValue("boom"))
+		require.Equal(t, "boom", FormatPanicValue(errors.New("boom")))
+		require.Equal(t, "42", FormatPanicValue(42))
+		require.Equal(t, "[1 2 3]", FormatPanicValue([]int{1, 2, 3}))
+		require.Equal(t, "map[a:1]", FormatPanicValue(map[string]int{"a": 1}))
+		require.Equal(t, "&{1 [2]}", FormatPanicValue(&struct {
+			A int
+			B []any
+		}{1, []any{2}}))
+	})
+
+	t.Run("panicking methods", func(t *testing.T) {
+		require.Equal(t, "%!v(PANIC=Error method: Error panicked)", FormatPanicValue(panickingError{}))
+		require.Equal(t, "%!v(PANIC=Error method: %!v(PANIC=Error method))", FormatPanicValue(doublyPanickingError{}))
+		require.Contains(t, FormatPanicValue(&panickingStringer{}), "%!v(PANIC=String method: runtime error: invalid memory address")
+		require.Equal(t, "<nil>", FormatPanicValue((*panickingStringer)(nil)))
+	})
+
+	t.Run("cyclic values", func(t *testing.T) {
+		s := []any{1, nil}
+		s[1] = s
+		require.Equal(t, "<[]interface {}: too large, deep or cyclic to format>", FormatPanicValue(s))
+
+		m := map[string]any{}
+		m["self"] = m
+		require.Equal(t, "<map[string]interface {}: too large, deep or cyclic to format>", FormatPanicValue(m))
+
+		// Pointers are formatted as addresses, so they cannot cycle
+		type node struct{ Next *node }
+		n := &node{}
+		n.Next = n
+		require.True(t, strings.HasPrefix(FormatPanicValue(n), "&{0x"))
+	})
+
+	t.Run("shared values are not cyclic", func(t *testing.T) {
+		shared := []any{1}
+		require.Equal(t, "[[1] [1]]", FormatPanicValue([]any{shared, shared}))
+	})
+
+	t.Run("deep values", func(t *testing.T) {
+		var v any = 1
+		for i := 0; i < 100; i++ {
+			v = []any{v}
+		}
+		require.Equal(t, "<[]interface {}: too large, deep or cyclic to format>", FormatPanicValue(v))
+	})
+
+	t.Run("unhashable values", func(t *testing.T) {
+		p := NewRecoveredPanic(0, []int{1})
+		require.Contains(t, p.Error(), "panic: [1]")
+		require.Equal(t, "[1]", p.Report().Message)
+	})
+
+	t.Run("large values are truncated", func(t *testing.T) {
+		formatted := FormatPanicValue(strings.Repeat("é", DefaultPanicValueMaxSize))
+		require.Len(t, formatted, DefaultPanicValueMaxSize-1)
+		require.True(t, utf8.ValidString(formatted))
+		require.True(t, strings.HasSuffix(formatted, "... [truncated]"))
+	})
+}
+
+func TestSetPanicValueMaxSize(t *testing.T) {
+	defer SetPanicValueMaxSize(DefaultPanicValueMaxSize)
+
+	SetPanicValueMaxSize(20)
+	require.Equal(t, "short", FormatPanicValue("short"))
+	require.Equal(t, "01234... [truncated]", FormatPanicValue(strings.Repeat("0123456789", 10)))
+
+	p := NewRecoveredPanic(0, strings.Repeat("x", 100))
+	require.Contains(t, p.Error(), "panic: xxxxx... [truncated]\n")
+
+	SetPanicValueMaxSize(3)
+	require.Equal(t, "...", FormatPanicValue("long value"))
+
+	require.Panics(t, func() { SetPanicValueMaxSize(0) })
+}
+
+func FuzzFormatPanicValue(f *testing.F) {
+	f.Add("boom", 0, uint8(0), false)
+	f.Add("é", 10, uint8(3), true)
+	f.Add(strings.Repeat("x", 1000), 1, uint8(40), false)
+	f.Fuzz(func(t *testing.T, s string, n int, depth uint8, cyclic bool) {
+		var v any = s
+		switch n % 3 {
+		case 1:
+			v = errors.New(s)
+		case 2:
+			v = map[string]any{s: n}
+		}
+		outer := []any{v}
+		inner := outer
+		for i := 0; i < int(depth); i++ {
+			next := []any{s, nil}
+			inner[0], inner = next, next[1:]
+		}
+		if cyclic {
+			inner[0] = outer
+		}
+
+		formatted := FormatPanicValue(outer)
+		require.LessOrEqual(t, len(formatted), DefaultPanicValueMaxSize)
+		if utf8.ValidString(s) {
+			require.True(t, utf8.ValidString(formatted))
+		}
+	})
+}
@@ -0,0 +1,10 @@
+//go:build go1.21
+
+package pool
+
+import "context"
+
+// afterFunc is context.AfterFunc, which needs Go 1.21.
+func afterFunc(ctx context.Context, f func()) (stop func() bool) {
+	return context.AfterFunc(ctx, f)
+}
@@ -0,0 +1,32 @@
+//go:build !go1.21
+
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// afterFunc is like context.AfterFunc, which needs Go 1.21. A goroutine
+// waits for ctx to be done until stop is called.
+func afterFunc(ctx context.Context, f func()) (stop func() bool) {
+	// state is 0 until either f is started (1) or stop is called (2)
+	var state atomic.Int32
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if state.CompareAndSwap(0, 1) {
+				f()
+			}
+		case <-stopped:
+		}
+	}()
+	return func() bool {
+		if state.CompareAndSwap(0, 2) {
+			close(stopped)
+			return true
+		}
+		return false
+	}
+}
@@ -0,0 +1,117 @@
+package pool
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sourcegraph/conc"
+)
+
+// NewWithArgs creates a new ArgPool for tasks that take an argument of type
+// T.
+func NewWithArgs[T any]() *ArgPool[T] {
+	return &ArgPool[T]{}
+}
+
+// ArgPool is a pool for tasks that are a function and an argument to call it
+// with. Passing the argument separately means that a task does not need a
+// closure to capture it, and the pool hands each function and argument to a
+// worker without allocating, so submitting a task to an ArgPool does not
+// allocate at all when f is not a closure, which matters when there are
+// millions of short tasks:
+//
+//	p := pool.NewWithArgs[*Item]()
+//	for _, item := range items {
+//		p.Go(process, item)
+//	}
+//	p.Wait()
+//
+// ArgPool supports only a limit on the number of goroutines, since the other
+// options of Pool have a cost for every task. Panics are propagated by Wait,
+// as for Pool.
+type ArgPool[T any] struct {
+	handle   conc.WaitGroup
+	limiter  limiter
+	tasks    chan argTask[T]
+	initOnce sync.Once
+	waited   atomic.Bool
+}
+
+type argTask[T any] struct {
+	f   func(T)
+	arg T
+}
+
+// Go submits a task that calls f with arg to the pool.
+func (p *ArgPool[T]) Go(f func(T), arg T) {
+	p.init()
+	if p.waited.Load() {
+		panic("pool: Go called after Wait")
+	}
+
+	t := argTask[T]{f: f, arg: arg}
+	select {
+	case p.limiter <- struct{}{}:
+		// If we are below our limit, spawn a new worker rather than
+		// waiting for one to become available.
+		p.handle.Go(p.worker)
+		p.tasks <- t
+	case p.tasks <- t:
+		// A worker is available and has accepted the task
+	}
+}
+
+// Wait cleans up spawned goroutines, propagating any panics that were raised
+// by a task.
+func (p *ArgPool[T]) Wait() {
+	p.init()
+	if p.waited.Swap(true) {
+		panic("pool: Wait called more than once")
+	}
+	close(p.tasks)
+	p.handle.Wait()
+}
+
+// MaxGoroutines returns the maximum size of the pool.
+func (p *ArgPool[T]) MaxGoroutines() int {
+	p.init()
+	return p.limiter.limit()
+}
+
+// WithMaxGoroutines limits the number of goroutines in a pool.
+// Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
+func (p *ArgPool[T]) WithMaxGoroutines(n int) *ArgPool[T] {
+	if n < 1 {
+		panic("max goroutines in a pool must be greater than zero")
+	}
+	p.limiter = make(limiter, n)
+	return p
+}
+
+// WithUnlimitedGoroutines removes the limit on the number of goroutines in a
+// pool. See Pool.WithUnlimitedGoroutines.
+func (p *ArgPool[T]) WithUnlimitedGoroutines() *ArgPool[T] {
+	p.limiter = make(limiter, math.MaxInt)
+	return p
+}
+
+func (p *ArgPool[T]) init() {
+	p.initOnce.Do(func() {
+		if p.limiter == nil {
+			p.limiter = make(limiter, runtime.GOMAXPROCS(0))
+		}
+		p.tasks = make(chan argTask[T])
+	})
+}
+
+func (p *ArgPool[T]) worker() {
+	// If a task panics, this worker exits, so release its slot to allow a
+	// new worker to be spawned in its place.
+	defer p.limiter.release()
+
+	for t := range p.tasks {
+		t.f(t.arg)
+	}
+}
@@ -0,0 +1,101 @@
+package pool
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleArgPool() {
+	var sum atomic.Int64
+	add := func(i int) { sum.Add(int64(i)) }
+
+	p := NewWithArgs[int]()
+	for i := 0; i < 10; i++ {
+		p.Go(add, i)
+	}
+	p.Wait()
+	fmt.Println(sum.Load())
+
+	// Output:
+	// 45
+}
+
+var argPoolSum atomic.Int64
+
+func addToArgPoolSum(i int) {
+	argPoolSum.Add(int64(i))
+}
+
+func TestArgPoolAllocs(t *testing.T) {
+	p := NewWithArgs[int]().WithMaxGoroutines(1)
+	// Start the worker first, since spawning it allocates
+	p.Go(addToArgPoolSum, 0)
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Go(addToArgPoolSum, 1)
+	})
+	p.Wait()
+	require.Zero(t, allocs)
+}
+
+func TestArgPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("basic", func(t *testing.T) {
+		t.Parallel()
+		var count atomic.Int64
+		p := NewWithArgs[int64]().WithMaxGoroutines(3)
+		for i := 0; i < 100; i++ {
+			p.Go(func(n int64) { count.Add(n) }, 2)
+		}
+		p.Wait()
+		require.Equal(t, int64(200), count.Load())
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		t.Parallel()
+		var current, peak atomic.Int64
+		p := NewWithArgs[int]().WithMaxGoroutines(2)
+		require.Equal(t, 2, p.MaxGoroutines())
+		for i := 0; i < 100; i++ {
+			p.Go(func(int) {
+				n := current.Add(1)
+				for {
+					old := peak.Load()
+					if n <= old || peak.CompareAndSwap(old, n) {
+						break
+					}
+				}
+				current.Add(-1)
+			}, i)
+		}
+		p.Wait()
+		require.LessOrEqual(t, peak.Load(), int64(2))
+	})
+
+	t.Run("propagates panics", func(t *testing.T) {
+		t.Parallel()
+		var count atomic.Int64
+		p := NewWithArgs[int]().WithMaxGoroutines(1)
+		p.Go(func(int) { panic("super bad thing") }, 0)
+		for i := 0; i < 10; i++ {
+			p.Go(func(int) { count.Add(1) }, i)
+		}
+		require.Panics(t, p.Wait)
+		require.Equal(t, int64(10), count.Load())
+	})
+
+	t.Run("panics on Go after Wait", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithArgs[int]()
+		p.Wait()
+		require.Panics(t, func() { p.Go(addToArgPoolSum, 1) })
+	})
+
+	t.Run("panics on invalid WithMaxGoroutines", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { NewWithArgs[int]().WithMaxGoroutines(0) })
+	})
+}
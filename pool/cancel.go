@@ -0,0 +1,134 @@
+package pool
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// The states of a labeled task.
+const (
+	labeledQueued int32 = iota
+	labeledStarted
+	labeledCanceled
+)
+
+// labeledTask tracks a task submitted with a label until it starts or is
+// canceled by CancelWhere.
+type labeledTask struct {
+	label string
+	seq   uint64
+	trace *taskTrace
+	state atomic.Int32
+	// canceled is closed when the task is canceled, to unblock the call to
+	// Go that is waiting for a worker to accept it.
+	canceled chan struct{}
+}
+
+// canceledChan returns a channel that is closed when t is canceled, or nil
+// if t is nil, for a task without a label.
+func (t *labeledTask) canceledChan() <-chan struct{} {
+	if t == nil {
+		return nil
+	}
+	return t.canceled
+}
+
+func (t *labeledTask) isCanceled() bool {
+	return t != nil && t.state.Load() == labeledCanceled
+}
+
+// GoLabeled submits a task to the pool with a label, so that it can be
+// canceled by CancelWhere until it starts. The label is also the name of the
+// task in a recovered panic, as with ErrorPool.GoNamed. A task with an empty
+// label cannot be canceled.
+func (p *Pool) GoLabeled(label string, f func()) {
+	p.submit(p.wrapNamed(label, f), f, nil, unitCost, label)
+}
+
+// CancelWhere cancels the tasks submitted with a label that have not started
+// yet and whose label matches the predicate, so that they never run, and
+// returns how many were canceled. It suits servers multiplexing the requests
+// of several clients on a pool, which need to drop the queued work of a
+// client that disconnects without waiting for it to run.
+//
+// Tasks are labeled by GoLabeled, and by GoNamed on the pools that have it,
+// with the name of the task. A call to Go that is blocked on a canceled task
+// returns. Canceled tasks are counted as completed by WithProgress, but are
+// not seen by interceptors nor observers, and return no error nor result.
+func (p *Pool) CancelWhere(pred func(label string) bool) int {
+	canceled := 0
+	for _, t := range p.queuedLabeled() {
+		if !pred(t.label) || !t.state.CompareAndSwap(labeledQueued, labeledCanceled) {
+			continue
+		}
+		p.mu.Lock()
+		delete(p.labeled, t)
+		p.mu.Unlock()
+		t.trace.emit(TraceCanceled)
+		close(t.canceled)
+		canceled++
+	}
+	return canceled
+}
+
+// QueuedLabels returns the labels of the tasks submitted with a label that
+// have not started yet, in the order they were submitted. See CancelWhere.
+func (p *Pool) QueuedLabels() []string {
+	tasks := p.queuedLabeled()
+	labels := make([]string, len(tasks))
+	for i, t := range tasks {
+		labels[i] = t.label
+	}
+	return labels
+}
+
+// queuedLabeled returns the labeled tasks that have not started or been
+// canceled yet, in the order they were submitted.
+func (p *Pool) queuedLabeled() []*labeledTask {
+	p.mu.Lock()
+	tasks := make([]*labeledTask, 0, len(p.labeled))
+	for t := range p.labeled {
+		tasks = append(tasks, t)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].seq < tasks[j].seq })
+	return tasks
+}
+
+// addLabeled registers a task submitted with label.
+func (p *Pool) addLabeled(label string, trace *taskTrace) *labeledTask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.labeled == nil {
+		p.labeled = make(map[*labeledTask]struct{})
+	}
+	p.labeledSeq++
+	t := &labeledTask{label: label, seq: p.labeledSeq, trace: trace, canceled: make(chan struct{})}
+	p.labeled[t] = struct{}{}
+	return t
+}
+
+// startLabeled wraps f so that it only runs if t has not been canceled, in
+// which case t can no longer be.
+func (p *Pool) startLabeled(t *labeledTask, f func()) func() {
+	return func() {
+		if !t.state.CompareAndSwap(labeledQueued, labeledStarted) {
+			return
+		}
+		p.mu.Lock()
+		delete(p.labeled, t)
+		p.mu.Unlock()
+		f()
+	}
+}
+
+// dropCanceled completes a task that was canceled before a worker ran it,
+// which only leaves its bookkeeping to run.
+func (p *Pool) dropCanceled(t queuedTask) {
+	t.f()
+	if p.reusable || p.reentrant {
+		p.active.Done()
+	}
+}
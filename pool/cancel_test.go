@@ -0,0 +1,197 @@
+package pool
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func TestCancelWhere(t *testing.T) {
+	t.Parallel()
+
+	// recorder records the labels of the tasks that ran.
+	type recorder struct {
+		mu  sync.Mutex
+		ran []string
+	}
+	record := func(r *recorder, label string) func() {
+		return func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.ran = append(r.ran, label)
+		}
+	}
+
+	t.Run("cancels tasks blocked on a saturated pool", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(1)
+		unblock := make(chan struct{})
+		p.Go(func() { <-unblock })
+
+		var r recorder
+		var submitters sync.WaitGroup
+		labels := []string{"client-1", "client-2", "client-1"}
+		for i, label := range labels {
+			label := label
+			submitters.Add(1)
+			go func() {
+				defer submitters.Done()
+				p.GoLabeled(label, record(&r, label))
+			}()
+			require.Eventually(t, func() bool { return len(p.QueuedLabels()) == i+1 }, time.Second, time.Millisecond)
+		}
+		require.Equal(t, labels, p.QueuedLabels())
+
+		require.Equal(t, 2, p.CancelWhere(func(label string) bool { return label == "client-1" }))
+		require.Equal(t, []string{"client-2"}, p.QueuedLabels())
+		require.Equal(t, 0, p.CancelWhere(func(label string) bool { return label == "client-1" }))
+
+		// The calls to Go that were blocked on canceled tasks return.
+		require.Eventually(t, func() bool { return len(p.QueuedLabels()) == 1 && p.queued.Load() == 1 }, time.Second, time.Millisecond)
+		close(unblock)
+		submitters.Wait()
+		p.Wait()
+		require.Equal(t, []string{"client-2"}, r.ran)
+	})
+
+	t.Run("cancels tasks held by a paused pool", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(2)
+		p.Pause()
+		var r recorder
+		p.GoLabeled("keep", record(&r, "keep"))
+		p.GoLabeled("drop", record(&r, "drop"))
+		require.Equal(t, 1, p.CancelWhere(func(label string) bool { return label == "drop" }))
+		p.Resume()
+		p.Wait()
+		require.Equal(t, []string{"keep"}, r.ran)
+	})
+
+	t.Run("started tasks are not canceled", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(1)
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		p.GoLabeled("running", func() {
+			close(started)
+			<-unblock
+		})
+		<-started
+		require.Empty(t, p.QueuedLabels())
+		require.Equal(t, 0, p.CancelWhere(func(string) bool { return true }))
+		close(unblock)
+		p.Wait()
+	})
+
+	t.Run("unlabeled tasks are not canceled", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(2)
+		p.Pause()
+		var r recorder
+		p.Go(record(&r, "go"))
+		p.GoLabeled("", record(&r, "empty"))
+		require.Empty(t, p.QueuedLabels())
+		require.Equal(t, 0, p.CancelWhere(func(string) bool { return true }))
+		p.Resume()
+		p.Wait()
+		require.ElementsMatch(t, []string{"go", "empty"}, r.ran)
+	})
+
+	t.Run("canceled tasks count as completed", func(t *testing.T) {
+		t.Parallel()
+
+		var done, total int
+		p := New().WithMaxGoroutines(3).WithProgress(func(d, tot int) {
+			done, total = d, tot
+		})
+		p.Pause()
+		for i := 0; i < 3; i++ {
+			p.GoLabeled("task", func() {})
+		}
+		require.Equal(t, 3, p.CancelWhere(func(string) bool { return true }))
+		p.Resume()
+		p.Wait()
+		require.Equal(t, 3, done)
+		require.Equal(t, 3, total)
+	})
+
+	t.Run("reusable pool", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(2).WithReuse()
+		defer p.Close()
+		for i := 0; i < 2; i++ {
+			p.Pause()
+			var r recorder
+			p.GoLabeled("keep", record(&r, "keep"))
+			p.GoLabeled("drop", record(&r, "drop"))
+			require.Equal(t, 1, p.CancelWhere(func(label string) bool { return label == "drop" }))
+			p.Resume()
+			p.Wait()
+			require.Equal(t, []string{"keep"}, r.ran)
+		}
+	})
+
+	t.Run("named tasks of an error pool", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(2).WithErrors()
+		p.Pause()
+		p.GoNamed("client-1/a", func() error { return errors.New("a") })
+		p.GoNamed("client-2/b", func() error { return errors.New("b") })
+		require.Equal(t, []string{"client-1/a", "client-2/b"}, p.QueuedLabels())
+		require.Equal(t, 1, p.CancelWhere(func(label string) bool {
+			return strings.HasPrefix(label, "client-1/")
+		}))
+		p.Resume()
+		err := p.Wait()
+		require.ErrorContains(t, err, "b")
+		require.NotContains(t, err.Error(), "client-1")
+	})
+
+	t.Run("named tasks of a context pool", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(1).WithContext(context.Background())
+		p.Pause()
+		p.GoNamed("client-1", func(context.Context) error { return errors.New("canceled task ran") })
+		require.Equal(t, 1, p.CancelWhere(func(string) bool { return true }))
+		p.Resume()
+		require.NoError(t, p.Wait())
+	})
+
+	t.Run("result pools leave canceled tasks out", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewWithResults[int]().WithMaxGoroutines(3)
+		p.Pause()
+		for i := 0; i < 3; i++ {
+			i := i
+			p.GoLabeled(string(rune('a'+i)), func() int { return i })
+		}
+		require.Equal(t, []string{"a", "b", "c"}, p.QueuedLabels())
+		require.Equal(t, 1, p.CancelWhere(func(label string) bool { return label == "b" }))
+		p.Resume()
+		require.ElementsMatch(t, []int{0, 2}, p.Wait())
+
+		rp := NewWithResults[int]().WithMaxGoroutines(1).WithContext(context.Background())
+		rp.Pause()
+		rp.GoNamed("drop", func(context.Context) (int, error) { return 1, nil })
+		require.Equal(t, []string{"drop"}, rp.QueuedLabels())
+		require.Equal(t, 1, rp.CancelWhere(func(string) bool { return true }))
+		rp.Resume()
+		res, err := rp.Wait()
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+}
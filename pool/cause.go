@@ -0,0 +1,11 @@
+//go:build go1.20
+
+package pool
+
+import "context"
+
+// withCancelCause is context.WithCancelCause, which needs Go 1.20.
+func withCancelCause(ctx context.Context) (context.Context, func(cause error)) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return ctx, cancel
+}
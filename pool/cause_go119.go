@@ -0,0 +1,12 @@
+//go:build !go1.20
+
+package pool
+
+import "context"
+
+// withCancelCause is like context.WithCancelCause, which needs Go 1.20. The
+// cause is dropped, since earlier versions of Go cannot record it.
+func withCancelCause(ctx context.Context) (context.Context, func(cause error)) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, func(error) { cancel() }
+}
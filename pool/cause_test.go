@@ -0,0 +1,96 @@
+//go:build go1.20
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc"
+)
+
+func TestContextPoolCause(t *testing.T) {
+	t.Parallel()
+
+	// causeOf runs a task that waits for the pool to be canceled alongside
+	// the tasks in fs, and returns the cause it saw.
+	causeOf := func(p *ContextPool, fs ...func(ctx context.Context) error) error {
+		var cause error
+		p.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			cause = context.Cause(ctx)
+			return ctx.Err()
+		})
+		for _, f := range fs {
+			p.Go(f)
+		}
+		_ = p.Wait()
+		return cause
+	}
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		err1 := errors.New("err1")
+		p := New().WithMaxGoroutines(2).WithContext(context.Background())
+		cause := causeOf(p, func(ctx context.Context) error { return err1 })
+		require.Equal(t, err1, cause)
+	})
+
+	t.Run("panic as error", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(2).WithContext(context.Background()).WithPanicsAsErrors()
+		cause := causeOf(p, func(ctx context.Context) error { panic("poison") })
+		var recovered *conc.RecoveredPanic
+		require.ErrorAs(t, cause, &recovered)
+	})
+
+	t.Run("ErrStop", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(2).WithContext(context.Background())
+		cause := causeOf(p, func(ctx context.Context) error { return conc.ErrStop })
+		require.ErrorIs(t, cause, conc.ErrStop)
+	})
+
+	t.Run("success threshold", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(2).WithContext(context.Background()).WithFirstSuccess()
+		cause := causeOf(p, func(ctx context.Context) error { return nil })
+		require.Equal(t, context.Canceled, cause)
+	})
+
+	t.Run("WaitContext", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithContext(context.Background())
+		var cause atomic.Pointer[error]
+		done := make(chan struct{})
+		p.Go(func(ctx context.Context) error {
+			defer close(done)
+			<-ctx.Done()
+			err := context.Cause(ctx)
+			cause.Store(&err)
+			return ctx.Err()
+		})
+		err := p.WaitTimeout(10 * time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		<-done
+		require.ErrorIs(t, *cause.Load(), context.DeadlineExceeded)
+	})
+
+	t.Run("grace period expired", func(t *testing.T) {
+		p := New().WithContext(context.Background())
+		var cause error
+		p.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			cause = context.Cause(ctx)
+			return nil
+		})
+		p.Drain(time.Millisecond)
+		require.NoError(t, p.Wait())
+		require.ErrorIs(t, cause, ErrGracePeriodExpired)
+	})
+}
@@ -2,6 +2,13 @@ package pool
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/sourcegraph/conc"
 )
 
 // ContextPool is a pool that runs tasks that take a context.
@@ -9,37 +16,440 @@ import (
 // of the tasks return an error, which makes its functionality
 // different than just capturing a context with the task closure.
 //
+// A task can also stop the pool early without failing by returning
+// conc.ErrStop. The context passed to the other tasks is canceled, but
+// neither ErrStop nor the context.Canceled errors returned by the other tasks
+// as a result are reported by Wait().
+//
+// Tasks can tell why their context was canceled with context.Cause, which
+// returns the error of the task that failed, including the
+// *conc.RecoveredPanic of a panic with WithPanicsAsErrors, conc.ErrStop, or
+// the error of WaitContext if it stopped waiting. Tasks should still return
+// ctx.Err() when they stop because of the cancellation, since returning the
+// cause would report it twice. Causes need Go 1.20: with earlier versions,
+// context.Cause is not available.
+//
 // A new ContextPool should be created with `New().WithContext(ctx)`.
 type ContextPool struct {
 	errorPool ErrorPool
 
-	ctx    context.Context
-	cancel context.CancelFunc
+	ctx context.Context
+	// cancel cancels ctx with the given cause, which tasks can get with
+	// context.Cause
+	cancel func(cause error)
+	// parent is the context passed to WithContext, from which ctx derives
+	parent context.Context
+	// drain starts draining the tasks, and graceTimer is set by Drain
+	drain      func()
+	graceTimer atomic.Pointer[time.Timer]
+
+	// canceledPolicy is set by WithCanceledPolicy
+	canceledPolicy CanceledPolicy
+
+	propagatedKeys []any
+
+	// successThreshold is the number of successful tasks after which the
+	// context is canceled, or 0 to cancel on the first error
+	successThreshold int64
+	succeeded        atomic.Int64
+
+	// stopped is set when a task returns conc.ErrStop
+	stopped atomic.Bool
+
+	taskTimeout       time.Duration
+	timeoutFromSubmit bool
+
+	// onRejected is set by WithRejectionHandler
+	onRejected func(*TaskTimeoutError)
+
+	// ioLimiter is set by WithIOLane
+	ioLimiter limiter
+
+	// heartbeats is set by WithHeartbeatTimeout
+	heartbeats   *heartbeatTracker
+	cancelSilent bool
 }
 
 // Go submits a task. If it returns an error, the error will be
 // collected and returned by Wait() and the context passed to other
 // tasks will be canceled.
 func (g *ContextPool) Go(f func(ctx context.Context) error) {
-	g.errorPool.Go(func() error {
-		err := f(g.ctx)
+	g.goWithContext(g.ctx, f)
+}
+
+// TryGo is like Go, but if the pool is configured with
+// WithCanceledPolicy(RejectCanceled) and the parent context is done, the task
+// is not submitted, and TryGo returns the error of the parent context.
+// Otherwise, it submits the task as Go does and returns nil.
+func (g *ContextPool) TryGo(f func(ctx context.Context) error) error {
+	if g.canceledPolicy == RejectCanceled {
+		if err := g.parent.Err(); err != nil {
+			return err
+		}
+	}
+	g.Go(f)
+	return nil
+}
+
+// GoContext is like Go, but the values of any keys configured with
+// WithContextPropagation are carried over from ctx into the context passed
+// to the task. Cancellation of the task is still governed by the pool's
+// context, not by ctx, unless the pool is configured with
+// WithRejectionHandler, in which case the task also has the deadline of ctx.
+func (g *ContextPool) GoContext(ctx context.Context, f func(ctx context.Context) error) {
+	if deadline, ok := ctx.Deadline(); ok && g.onRejected != nil {
+		f = g.withDeadline(deadline, f)
+	}
+
+	taskCtx := g.ctx
+	if len(g.propagatedKeys) > 0 {
+		taskCtx = propagatedContext{
+			Context: g.ctx,
+			values:  ctx,
+			keys:    g.propagatedKeys,
+		}
+	}
+	g.goWithContext(taskCtx, f)
+}
+
+// GoNamed submits a task. If the task returns an error, it is wrapped in a
+// *TaskError with the given name and the index of the task, and until it
+// starts it can be canceled by its name with CancelWhere. See
+// ErrorPool.GoNamed.
+func (g *ContextPool) GoNamed(name string, f func(ctx context.Context) error) {
+	f = g.skipIfCanceled(f)
+	index := g.errorPool.nextIndex()
+	f = g.withTimeout(g.withHeartbeat(name, index, g.asTask(name, index, f)))
+	g.submit(g.ctx, func(ctx context.Context) error {
+		return newTaskError(name, index, f(ctx))
+	}, unitCost, name)
+}
+
+// GoBlocking submits a task that spends most of its time blocked, on a
+// goroutine that does not count toward the pool's limit. EnterIO and ExitIO
+// are no-ops in such a task, since it holds no CPU slot. See
+// Pool.GoBlocking.
+func (g *ContextPool) GoBlocking(f func(ctx context.Context) error) {
+	f = g.skipIfCanceled(f)
+	index := g.errorPool.nextIndex()
+	f = g.withTimeout(g.withHeartbeat("", index, g.asTask("", index, f)))
+	g.errorPool.pool.goErrBlocking(g.task(g.ctx, f, nil, true))
+}
+
+// GoWeighted submits a task that counts as weight tasks against the pool's
+// limit. See Pool.GoWeighted.
+func (g *ContextPool) GoWeighted(weight int, f func(ctx context.Context) error) {
+	if weight < 1 {
+		panic("task weight must be greater than zero")
+	}
+	f = g.skipIfCanceled(f)
+	index := g.errorPool.nextIndex()
+	g.submit(g.ctx, g.withTimeout(g.withHeartbeat("", index, g.asTask("", index, f))), taskCost{weight: weight}, "")
+}
+
+// GoSized submits a task that is estimated to use bytes of memory while it
+// runs. See Pool.GoSized.
+func (g *ContextPool) GoSized(bytes int64, f func(ctx context.Context) error) {
+	if bytes < 0 {
+		panic("task size must not be negative")
+	}
+	f = g.skipIfCanceled(f)
+	index := g.errorPool.nextIndex()
+	g.submit(g.ctx, g.withTimeout(g.withHeartbeat("", index, g.asTask("", index, f))), taskCost{weight: 1, bytes: bytes}, "")
+}
+
+func (g *ContextPool) goWithContext(ctx context.Context, f func(ctx context.Context) error) {
+	f = g.skipIfCanceled(f)
+	index := g.errorPool.nextIndex()
+	g.submit(ctx, g.withTimeout(g.withHeartbeat("", index, g.asTask("", index, f))), unitCost, "")
+}
+
+// skipIfCanceled returns a task that fails with the error of the parent
+// context in place of f, if the parent is done and the pool is not configured
+// to run such tasks. See WithCanceledPolicy.
+func (g *ContextPool) skipIfCanceled(f func(ctx context.Context) error) func(ctx context.Context) error {
+	if g.canceledPolicy == RunCanceled {
+		return f
+	}
+	err := g.parent.Err()
+	if err == nil {
+		return f
+	}
+	return func(context.Context) error { return err }
+}
+
+// asTask wraps f so that if it panics, the recovered panic identifies the
+// task. See Pool.asTask.
+func (g *ContextPool) asTask(name string, index int, f func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return g.errorPool.pool.asTaskErr(name, index, func() error { return f(ctx) })()
+	}
+}
+
+// withTimeout wraps f to enforce the pool's task timeout, if any. It must be
+// called when the task is submitted so the submit time can be recorded.
+func (g *ContextPool) withTimeout(f func(ctx context.Context) error) func(ctx context.Context) error {
+	if g.taskTimeout <= 0 {
+		return f
+	}
+	submitted := time.Now()
+	return func(ctx context.Context) error {
+		start := time.Now()
+		deadline := start.Add(g.taskTimeout)
+		if g.timeoutFromSubmit {
+			deadline = submitted.Add(g.taskTimeout)
+			if !start.Before(deadline) {
+				return g.reject(&TaskTimeoutError{
+					Timeout:   g.taskTimeout,
+					QueueWait: start.Sub(submitted),
+				})
+			}
+		}
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		return f(ctx)
+	}
+}
+
+// withDeadline wraps f so that it is rejected if it has not started by the
+// deadline, and otherwise runs with the deadline. It must be called when the
+// task is submitted.
+func (g *ContextPool) withDeadline(deadline time.Time, f func(ctx context.Context) error) func(ctx context.Context) error {
+	submitted := time.Now()
+	return func(ctx context.Context) error {
+		start := time.Now()
+		if !start.Before(deadline) {
+			return g.reject(&TaskTimeoutError{
+				Timeout:   deadline.Sub(submitted),
+				QueueWait: start.Sub(submitted),
+			})
+		}
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		return f(ctx)
+	}
+}
+
+// reject returns the error for a task that was not started because its
+// deadline passed. If the pool has a rejection handler, the error is marked
+// so that submit hands it to the handler rather than collecting it.
+func (g *ContextPool) reject(err *TaskTimeoutError) error {
+	if g.onRejected != nil {
+		return rejectedError{err}
+	}
+	return err
+}
+
+func (g *ContextPool) submit(ctx context.Context, f func(ctx context.Context) error, cost taskCost, label string) {
+	var state *any
+	if g.errorPool.pool.workerInit != nil {
+		state = new(any)
+	}
+	g.errorPool.pool.goErr(g.task(ctx, f, state, false), state, cost, label)
+}
+
+// task prepares f to be run by the pool with ctx, applying the options of
+// the pool and handling the error it returns. state is the pointer to the
+// worker state passed to goErr, if any, and blocking is set for tasks
+// submitted with GoBlocking.
+func (g *ContextPool) task(ctx context.Context, f func(ctx context.Context) error, state *any, blocking bool) func() error {
+	// Tasks yield in conc.Checkpoint while other tasks wait for a goroutine
+	ctx = conc.WithPressure(ctx, g.errorPool.pool.hasQueued)
+	if len(g.errorPool.pool.interceptors) > 0 {
+		f = g.errorPool.pool.intercept(f)
+	}
+	if g.errorPool.panicsAsErrors {
+		inner := f
+		f = func(ctx context.Context) error {
+			return g.errorPool.catchPanics(func() error { return inner(ctx) })()
+		}
+	}
+	return func() error {
+		if g.errorPool.reachedMaxErrors() {
+			return nil
+		}
+		err := g.run(ctx, f, state, blocking)
+		var rejected rejectedError
+		if errors.As(err, &rejected) {
+			g.onRejected(rejected.err)
+			return rejected.err
+		}
+		if errors.Is(err, conc.ErrStop) {
+			// Set stopped before canceling so that the errors caused by the
+			// cancellation are recognized as such.
+			g.stopped.Store(true)
+			g.cancel(err)
+			return err
+		}
+		if g.stopped.Load() && errors.Is(err, context.Canceled) {
+			return err
+		}
+		if g.successThreshold > 0 {
+			if err == nil {
+				if g.succeeded.Add(1) == g.successThreshold {
+					g.cancel(nil)
+				}
+			} else {
+				g.errorPool.addErr(err)
+				if g.errorPool.reachedMaxErrors() {
+					g.cancel(err)
+				}
+			}
+			return err
+		}
 		if err != nil {
 			// Leaky abstraction warning: We add the error directly because
 			// otherwise, canceling could cause another goroutine to exit and
 			// return an error before this error was added, which breaks the
 			// expectations of WithFirstError().
 			g.errorPool.addErr(err)
-			g.cancel()
-			return nil
+			if g.errorPool.maxErrors == 0 || g.errorPool.reachedMaxErrors() {
+				g.cancel(err)
+			}
 		}
 		return err
-	})
+	}
+}
+
+// run calls f with ctx, after adding the task's worker state and lane to ctx.
+// It fails without calling f if the worker could not be initialized. Tasks
+// submitted with GoBlocking have no lane, since they hold no CPU slot.
+func (g *ContextPool) run(ctx context.Context, f func(ctx context.Context) error, state *any, blocking bool) error {
+	if state != nil {
+		if initErr, ok := (*state).(workerInitError); ok {
+			return initErr.err
+		}
+		ctx = context.WithValue(ctx, workerStateKey{}, *state)
+	}
+	if g.ioLimiter != nil && !blocking {
+		l := &lane{pool: g}
+		ctx = context.WithValue(ctx, laneKey{}, l)
+		// Return to the CPU lane even if the task returns or panics without
+		// calling ExitIO, since the worker owns the CPU slot.
+		defer l.reset()
+	}
+	return f(ctx)
+}
+
+// OnCancel registers f to be run in a goroutine of its own once the context
+// passed to the tasks is canceled, whether by the first error, by the parent
+// context or by WaitContext. This lets a task that is blocked in a call that
+// does not take a context, such as a read from a connection, be unblocked
+// promptly:
+//
+//	p.Go(func(ctx context.Context) error {
+//		conn := dial()
+//		defer p.OnCancel(func() { conn.Close() })()
+//		return read(conn)
+//	})
+//
+// The returned stop function unregisters f, and reports whether it did so
+// before f was started, as for context.AfterFunc. Tasks should call it when
+// they return, since f is kept until the context is canceled otherwise. A
+// panic in f is handled as by conc.Go. If the context is already canceled, f
+// is run right away.
+func (p *ContextPool) OnCancel(f func()) (stop func() bool) {
+	return afterFunc(p.ctx, func() { conc.Go(f) })
+}
+
+// ErrGracePeriodExpired is the cause of the cancellation of the context passed
+// to the tasks of a ContextPool that was drained, if the tasks did not all
+// finish within the grace period. See ContextPool.Drain.
+var ErrGracePeriodExpired = errors.New("pool: grace period expired")
+
+// Drain shuts the tasks of the pool down gracefully, in two phases, as
+// Kubernetes terminates pods: it first closes the channel returned by
+// conc.Draining for the context passed to the tasks, so that they can stop
+// taking on new work and finish cleanly, then, if they have not all finished
+// once grace has passed, cancels the context, with ErrGracePeriodExpired as
+// its cause. Drain does not wait for the tasks, so it is usually followed by
+// Wait, which ends the grace period once the tasks have finished. Only the
+// grace period of the first call to Drain applies.
+func (p *ContextPool) Drain(grace time.Duration) {
+	p.drain()
+	timer := time.AfterFunc(grace, func() { p.cancel(ErrGracePeriodExpired) })
+	if !p.graceTimer.CompareAndSwap(nil, timer) {
+		timer.Stop()
+	}
 }
 
 // Wait cleans up all spawned goroutines, propagates any panics, and
 // returns an error if any of the tasks errored.
 func (p *ContextPool) Wait() error {
-	return p.errorPool.Wait()
+	err := p.errorPool.Wait()
+	if timer := p.graceTimer.Load(); timer != nil {
+		timer.Stop()
+	}
+	if p.successThreshold > 0 && (p.succeeded.Load() >= p.successThreshold || p.stopped.Load()) {
+		return nil
+	}
+	return err
+}
+
+// WaitContext is like Wait, but stops waiting when ctx is done. In that case,
+// it cancels the context passed to the remaining tasks, so that they can stop
+// in the background, and returns ctx.Err(). See Pool.WaitContext.
+func (p *ContextPool) WaitContext(ctx context.Context) error {
+	var err error
+	if ctxErr := waitContext(ctx, func() { err = p.Wait() }); ctxErr != nil {
+		p.cancel(ctxErr)
+		return ctxErr
+	}
+	return err
+}
+
+// WaitTimeout is like WaitContext, but stops waiting after d.
+func (p *ContextPool) WaitTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return p.WaitContext(ctx)
+}
+
+// Pause stops the pool from starting any new tasks until Resume is called.
+// See Pool.Pause for details.
+func (p *ContextPool) Pause() {
+	p.errorPool.Pause()
+}
+
+// Resume undoes a call to Pause, allowing held and queued tasks to start.
+func (p *ContextPool) Resume() {
+	p.errorPool.Resume()
+}
+
+// CancelWhere cancels the labeled tasks that have not started yet and whose
+// label matches the predicate, and returns how many were canceled. See
+// Pool.CancelWhere.
+func (p *ContextPool) CancelWhere(pred func(label string) bool) int {
+	return p.errorPool.CancelWhere(pred)
+}
+
+// QueuedLabels returns the labels of the labeled tasks that have not started
+// yet. See Pool.QueuedLabels.
+func (p *ContextPool) QueuedLabels() []string {
+	return p.errorPool.QueuedLabels()
+}
+
+// Name returns the name of the pool qualified by the names of its parents.
+// See Pool.Name for details.
+func (p *ContextPool) Name() string {
+	return p.errorPool.Name()
+}
+
+// Goroutines returns the number of goroutines the pool is running. See
+// Pool.Goroutines.
+func (p *ContextPool) Goroutines() int {
+	return p.errorPool.Goroutines()
+}
+
+// Prewarm starts up to n workers ahead of the first tasks. See Pool.Prewarm.
+func (p *ContextPool) Prewarm(n int) {
+	p.errorPool.Prewarm(n)
+}
+
+// SampledOutcomes returns the outcomes of tasks sampled by the pool, starting
+// with the most recent. See Pool.SampledOutcomes.
+func (p *ContextPool) SampledOutcomes() []TaskStats {
+	return p.errorPool.SampledOutcomes()
 }
 
 // WithFirstError configures the pool to only return the first error
@@ -51,9 +461,357 @@ func (p *ContextPool) WithFirstError() *ContextPool {
 	return p
 }
 
+// WithFirstSuccess configures the pool to cancel the context passed to tasks
+// as soon as any task succeeds, rather than when a task fails. Wait() only
+// returns an error if no task succeeded, in which case the error combines the
+// errors returned by all of the tasks. This is useful for sending the same
+// request to several replicas and taking the first good answer.
+//
+// Wait() still waits for all tasks to return, so tasks should exit promptly
+// once their context is canceled.
+func (p *ContextPool) WithFirstSuccess() *ContextPool {
+	return p.WithSuccessThreshold(1)
+}
+
+// WithMaxErrors configures the pool to let tasks fail without canceling the
+// context passed to the other tasks, until n tasks have failed. The nth
+// failure cancels the context, and the tasks that have not started by then
+// are skipped. With WithSuccessThreshold, the context is canceled by
+// whichever of the two limits is reached first. See ErrorPool.WithMaxErrors.
+func (p *ContextPool) WithMaxErrors(n int) *ContextPool {
+	p.errorPool.WithMaxErrors(n)
+	return p
+}
+
+// WithPanicsAsErrors configures the pool to treat a panic in a task as the
+// task's error, which cancels the context passed to the other tasks like any
+// other error. See ErrorPool.WithPanicsAsErrors.
+func (p *ContextPool) WithPanicsAsErrors() *ContextPool {
+	p.errorPool.WithPanicsAsErrors()
+	return p
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *ContextPool) WithName(name string) *ContextPool {
+	p.errorPool.WithName(name)
+	return p
+}
+
+// WithOptions applies the settings of opts that are set to the pool. See
+// Pool.WithOptions.
+func (p *ContextPool) WithOptions(opts conc.Options) *ContextPool {
+	p.errorPool.WithOptions(opts)
+	return p
+}
+
+// WithParent sets the parent of the pool in the naming hierarchy. See
+// Pool.WithParent.
+func (p *ContextPool) WithParent(parent *Pool) *ContextPool {
+	p.errorPool.WithParent(parent)
+	return p
+}
+
+// WithContextPropagation configures the pool to carry the values of the given
+// keys from the context passed to GoContext into the task's context, so
+// request-scoped values like trace IDs don't need to be copied by hand at
+// every call site. Panics if a key is not comparable.
+func (p *ContextPool) WithContextPropagation(keys ...any) *ContextPool {
+	for _, key := range keys {
+		if key == nil || !reflect.TypeOf(key).Comparable() {
+			panic("context propagation key must be non-nil and comparable")
+		}
+	}
+	p.propagatedKeys = append(p.propagatedKeys, keys...)
+	return p
+}
+
+// WithBaggage configures the pool to give every task a context carrying
+// value for key, as context.WithValue does, so that the values shared by all
+// tasks, such as a logger or the name of a tenant, need not be added at every
+// call site. Unlike the values carried over by WithContextPropagation, which
+// come from each submitter, the value is set once for the pool. If the key is
+// also propagated, the value from the context passed to GoContext, if it has
+// one, takes precedence. Panics if key is not comparable.
+func (p *ContextPool) WithBaggage(key, value any) *ContextPool {
+	if key == nil || !reflect.TypeOf(key).Comparable() {
+		panic("baggage key must be non-nil and comparable")
+	}
+	p.ctx = context.WithValue(p.ctx, key, value)
+	return p
+}
+
+// WithInterceptor adds an interceptor that wraps every task submitted to the
+// pool. See Pool.WithInterceptor.
+func (p *ContextPool) WithInterceptor(i Interceptor) *ContextPool {
+	p.errorPool.WithInterceptor(i)
+	return p
+}
+
+// WithWorkerInit configures the pool to call init in each of its goroutines
+// before it runs its first task. See Pool.WithWorkerInit.
+func (p *ContextPool) WithWorkerInit(init func() (state any, err error)) *ContextPool {
+	p.errorPool.WithWorkerInit(init)
+	return p
+}
+
+// WithWorkerTeardown configures the pool to call teardown with the state of
+// each of its goroutines when it exits. See Pool.WithWorkerTeardown.
+func (p *ContextPool) WithWorkerTeardown(teardown func(state any)) *ContextPool {
+	p.errorPool.WithWorkerTeardown(teardown)
+	return p
+}
+
+// WithMisuseDetection configures the pool to panic when it is misused in a
+// way that would otherwise deadlock. See Pool.WithMisuseDetection.
+func (p *ContextPool) WithMisuseDetection() *ContextPool {
+	p.errorPool.WithMisuseDetection()
+	return p
+}
+
+// WithReentrancy configures the pool so that its tasks can submit more tasks
+// to it without deadlocking. See Pool.WithReentrancy.
+func (p *ContextPool) WithReentrancy() *ContextPool {
+	p.errorPool.WithReentrancy()
+	return p
+}
+
+// WithLockOSThread configures each goroutine of the pool to lock itself to
+// its OS thread for as long as it runs. See Pool.WithLockOSThread.
+func (p *ContextPool) WithLockOSThread() *ContextPool {
+	p.errorPool.WithLockOSThread()
+	return p
+}
+
+// WithIdleShutdown configures each worker of the pool to exit once it has
+// been idle for d. See Pool.WithIdleShutdown.
+func (p *ContextPool) WithIdleShutdown(d time.Duration) *ContextPool {
+	p.errorPool.WithIdleShutdown(d)
+	return p
+}
+
+// WithMemoryBudget limits the estimated memory of the tasks submitted with
+// GoSized that run at once. See Pool.WithMemoryBudget.
+func (p *ContextPool) WithMemoryBudget(bytes int64) *ContextPool {
+	p.errorPool.WithMemoryBudget(bytes)
+	return p
+}
+
+// WithMaxBlocking limits the number of tasks submitted with GoBlocking that
+// run outside the pool's limit at once. See Pool.WithMaxBlocking.
+func (p *ContextPool) WithMaxBlocking(n int) *ContextPool {
+	p.errorPool.WithMaxBlocking(n)
+	return p
+}
+
+// WithScheduler registers the pool with s, which shares its limit fairly
+// between pools according to their weights. See Pool.WithScheduler.
+func (p *ContextPool) WithScheduler(s *Scheduler, weight int) *ContextPool {
+	p.errorPool.WithScheduler(s, weight)
+	return p
+}
+
+// WithParentLimiter configures the pool to borrow its goroutines from the
+// concurrency budget of parent. See Pool.WithParentLimiter.
+func (p *ContextPool) WithParentLimiter(parent *Pool) *ContextPool {
+	p.errorPool.WithParentLimiter(parent)
+	return p
+}
+
+// WithProgress configures the pool to call f every time a task completes.
+// See Pool.WithProgress.
+func (p *ContextPool) WithProgress(f func(done, total int)) *ContextPool {
+	p.errorPool.WithProgress(f)
+	return p
+}
+
+// WithTaskObserver configures the pool to call f with the timings and
+// outcome of every task once it finishes. See Pool.WithTaskObserver.
+func (p *ContextPool) WithTaskObserver(f func(TaskStats)) *ContextPool {
+	p.errorPool.WithTaskObserver(f)
+	return p
+}
+
+// WithOutcomeSampling configures the pool to keep every failure and one in
+// every successes successful tasks among its last size outcomes. See
+// Pool.WithOutcomeSampling.
+func (p *ContextPool) WithOutcomeSampling(successes, size int) *ContextPool {
+	p.errorPool.WithOutcomeSampling(successes, size)
+	return p
+}
+
+// WithTracer configures the pool to call f with every event in the life of
+// its tasks. See Pool.WithTracer.
+func (p *ContextPool) WithTracer(f func(TraceEvent)) *ContextPool {
+	p.errorPool.WithTracer(f)
+	return p
+}
+
+// WithSuccessThreshold configures the pool to cancel the context passed to
+// tasks as soon as n tasks have succeeded, rather than when a task fails.
+// Wait() only returns an error if fewer than n tasks succeeded, in which case
+// the error combines the errors returned by the tasks that failed. This is
+// useful for quorum reads and writes across replicas. Panics if n < 1.
+//
+// WithFirstSuccess is the same as WithSuccessThreshold(1).
+func (p *ContextPool) WithSuccessThreshold(n int) *ContextPool {
+	if n < 1 {
+		panic("success threshold must be greater than zero")
+	}
+	p.successThreshold = int64(n)
+	return p
+}
+
+// WithIOLane gives the pool a second lane of n goroutines for tasks that are
+// blocked on IO, separate from the limit set by WithMaxGoroutines, which then
+// only applies to tasks doing CPU-bound work. A task moves to the IO lane by
+// calling EnterIO with its context, which frees its slot for another task,
+// and moves back with ExitIO. Panics if n < 1.
+func (p *ContextPool) WithIOLane(n int) *ContextPool {
+	if n < 1 {
+		panic("IO lane size must be greater than zero")
+	}
+	p.ioLimiter = make(limiter, n)
+	return p
+}
+
+// WithRejectionHandler configures the pool to fail fast on tasks whose
+// deadline passes while they wait to start, so that no goroutine is wasted on
+// doomed work. Instead of running such a task, the pool calls f with a
+// *TaskTimeoutError describing it. Rejected tasks do not cancel the other
+// tasks and are not reported by Wait().
+//
+// A task's deadline is the deadline of the context passed to GoContext, if it
+// has one, or the timeout configured with WithTaskTimeoutFromSubmit. f is
+// called from the goroutine that would have run the task, so it may be called
+// concurrently.
+func (p *ContextPool) WithRejectionHandler(f func(*TaskTimeoutError)) *ContextPool {
+	p.onRejected = f
+	return p
+}
+
+// CanceledPolicy controls what a ContextPool does with the tasks submitted
+// once its parent context, the context passed to WithContext, is done. See
+// WithCanceledPolicy.
+type CanceledPolicy int
+
+const (
+	// RunCanceled runs the tasks as usual, with a canceled context, so it is
+	// up to them to notice it. This is the default.
+	RunCanceled CanceledPolicy = iota
+
+	// SkipCanceled does not run the tasks, and records the error of the
+	// parent context as their error instead, which Wait reports.
+	SkipCanceled
+
+	// RejectCanceled does not submit the tasks that are submitted with
+	// TryGo, which returns the error of the parent context instead, so that
+	// the caller can stop submitting. Tasks submitted in any other way are
+	// skipped, as with SkipCanceled.
+	RejectCanceled
+)
+
+// WithCanceledPolicy configures what the pool does with the tasks submitted
+// once its parent context is done. The parent is checked when a task is
+// submitted, so a task submitted before the parent is done still runs, with
+// a canceled context; likewise, tasks submitted once the pool's context is
+// canceled by one of its own tasks failing are not affected. See
+// CanceledPolicy.
+func (p *ContextPool) WithCanceledPolicy(policy CanceledPolicy) *ContextPool {
+	p.canceledPolicy = policy
+	return p
+}
+
+// WithTaskTimeout configures the pool to give each task a context that
+// expires d after the task starts. Use WithTaskTimeoutFromSubmit to measure
+// the timeout from when the task is submitted instead.
+func (p *ContextPool) WithTaskTimeout(d time.Duration) *ContextPool {
+	p.taskTimeout = d
+	return p
+}
+
+// WithTaskTimeoutFromSubmit configures the timeout set by WithTaskTimeout to
+// be measured from when each task is submitted with Go(), so time spent
+// waiting for a free goroutine counts towards the timeout. A task whose
+// timeout expires before it starts is not run, and fails with a
+// *TaskTimeoutError reporting how long it waited.
+func (p *ContextPool) WithTaskTimeoutFromSubmit() *ContextPool {
+	p.timeoutFromSubmit = true
+	return p
+}
+
 // WithMaxGoroutines limits the number of goroutines in a pool.
 // Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
 func (p *ContextPool) WithMaxGoroutines(n int) *ContextPool {
 	p.errorPool.WithMaxGoroutines(n)
 	return p
 }
+
+// WithUnlimitedGoroutines removes the limit on the number of goroutines in a
+// pool. See Pool.WithUnlimitedGoroutines.
+func (p *ContextPool) WithUnlimitedGoroutines() *ContextPool {
+	p.errorPool.WithUnlimitedGoroutines()
+	return p
+}
+
+// TaskTimeoutError is the error of a task whose timeout or deadline expired
+// before the task started. See ContextPool.WithTaskTimeoutFromSubmit and
+// ContextPool.WithRejectionHandler.
+type TaskTimeoutError struct {
+	// Timeout is the timeout that was exceeded.
+	Timeout time.Duration
+	// QueueWait is how long the task waited before it would have started.
+	QueueWait time.Duration
+}
+
+func (e *TaskTimeoutError) Error() string {
+	return fmt.Sprintf("task timed out after %s waiting to start (timeout %s)", e.QueueWait, e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded, so errors.Is can be used to check
+// for timeouts regardless of whether the task started.
+func (e *TaskTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// rejectedError marks the error of a task that should be passed to the
+// rejection handler.
+type rejectedError struct {
+	err *TaskTimeoutError
+}
+
+func (e rejectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e rejectedError) Unwrap() error {
+	return e.err
+}
+
+// propagatedContext is a context that looks up a fixed set of keys in a
+// different context from the one providing cancellation.
+type propagatedContext struct {
+	context.Context
+	values context.Context
+	keys   []any
+}
+
+func (c propagatedContext) Value(key any) any {
+	for _, k := range c.keys {
+		if k == key {
+			if val := c.values.Value(key); val != nil {
+				return val
+			}
+			break
+		}
+	}
+	return c.Context.Value(key)
+}
+
+type workerStateKey struct{}
+
+// WorkerState returns the state of the goroutine running the context pool
+// task that was passed ctx, as returned by the function passed to
+// WithWorkerInit, or nil if the pool has no worker state.
+func WorkerState(ctx context.Context) any {
+	return ctx.Value(workerStateKey{})
+}
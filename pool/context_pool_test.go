@@ -10,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/sourcegraph/conc"
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 )
 
@@ -100,6 +101,43 @@ func TestContextPool(t *testing.T) {
 		require.ErrorIs(t, err, err1)
 	})
 
+	t.Run("WithMaxErrors", func(t *testing.T) {
+		t.Run("cancels after n failures", func(t *testing.T) {
+			p := New().WithMaxGoroutines(1).WithContext(bgctx).WithMaxErrors(2)
+			var ran atomic.Int64
+			var canceledAt []int
+			for i := 0; i < 5; i++ {
+				i := i
+				p.Go(func(ctx context.Context) error {
+					ran.Add(1)
+					if ctx.Err() != nil {
+						canceledAt = append(canceledAt, i)
+					}
+					return err1
+				})
+			}
+			var errs conc.Errors
+			require.ErrorAs(t, p.Wait(), &errs)
+			require.Equal(t, 2, errs.Len())
+			require.Equal(t, int64(2), ran.Load())
+			// The first failure did not cancel the second task
+			require.Empty(t, canceledAt)
+		})
+
+		t.Run("with success threshold", func(t *testing.T) {
+			p := New().WithMaxGoroutines(1).WithContext(bgctx).WithSuccessThreshold(3).WithMaxErrors(1)
+			var ran atomic.Int64
+			for i := 0; i < 5; i++ {
+				p.Go(func(ctx context.Context) error {
+					ran.Add(1)
+					return err1
+				})
+			}
+			require.ErrorIs(t, p.Wait(), err1)
+			require.Equal(t, int64(1), ran.Load())
+		})
+	})
+
 	t.Run("WithFirstError", func(t *testing.T) {
 		p := New().WithContext(bgctx).WithFirstError()
 		p.Go(func(ctx context.Context) error {
@@ -114,6 +152,535 @@ func TestContextPool(t *testing.T) {
 		require.NotErrorIs(t, err, context.Canceled)
 	})
 
+	t.Run("WithFirstSuccess", func(t *testing.T) {
+		t.Run("success cancels the rest", func(t *testing.T) {
+			p := New().WithContext(bgctx).WithFirstSuccess().WithMaxGoroutines(3)
+			p.Go(func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			p.Go(func(ctx context.Context) error {
+				return err1
+			})
+			p.Go(func(ctx context.Context) error {
+				return nil
+			})
+			require.NoError(t, p.Wait())
+		})
+
+		t.Run("all failed", func(t *testing.T) {
+			p := New().WithContext(bgctx).WithFirstSuccess()
+			p.Go(func(ctx context.Context) error {
+				return err1
+			})
+			p.Go(func(ctx context.Context) error {
+				return err2
+			})
+			err := p.Wait()
+			require.ErrorIs(t, err, err1)
+			require.ErrorIs(t, err, err2)
+		})
+	})
+
+	t.Run("WithSuccessThreshold", func(t *testing.T) {
+		t.Run("quorum cancels the rest", func(t *testing.T) {
+			p := New().WithContext(bgctx).WithSuccessThreshold(2).WithMaxGoroutines(4)
+			var canceled atomic.Int64
+			for i := 0; i < 2; i++ {
+				p.Go(func(ctx context.Context) error {
+					<-ctx.Done()
+					canceled.Add(1)
+					return ctx.Err()
+				})
+			}
+			for i := 0; i < 2; i++ {
+				p.Go(func(ctx context.Context) error {
+					return nil
+				})
+			}
+			require.NoError(t, p.Wait())
+			require.Equal(t, int64(2), canceled.Load())
+		})
+
+		t.Run("quorum not reached", func(t *testing.T) {
+			p := New().WithContext(bgctx).WithSuccessThreshold(2)
+			p.Go(func(ctx context.Context) error {
+				return nil
+			})
+			p.Go(func(ctx context.Context) error {
+				return err1
+			})
+			require.ErrorIs(t, p.Wait(), err1)
+		})
+
+		t.Run("panics on invalid threshold", func(t *testing.T) {
+			require.Panics(t, func() { New().WithContext(bgctx).WithSuccessThreshold(0) })
+		})
+	})
+
+	t.Run("WithTaskTimeout", func(t *testing.T) {
+		t.Run("measured from start", func(t *testing.T) {
+			const timeout = 10 * time.Millisecond
+			p := New().WithContext(bgctx).WithTaskTimeout(timeout).WithMaxGoroutines(1)
+
+			// The first task holds the only worker well past the timeout,
+			// and the second can only start once it has returned.
+			release := make(chan struct{})
+			var firstReturned time.Time
+			p.Go(func(ctx context.Context) error {
+				<-release
+				firstReturned = time.Now()
+				return nil
+			})
+			go func() {
+				time.Sleep(5 * timeout)
+				close(release)
+			}()
+			var deadline time.Time
+			var hasDeadline bool
+			p.Go(func(ctx context.Context) error {
+				deadline, hasDeadline = ctx.Deadline()
+				return nil
+			})
+			require.NoError(t, p.Wait())
+			require.True(t, hasDeadline)
+			require.False(t, deadline.Before(firstReturned.Add(timeout)))
+		})
+
+		t.Run("measured from submit", func(t *testing.T) {
+			p := New().WithContext(bgctx).
+				WithTaskTimeout(10 * time.Millisecond).
+				WithTaskTimeoutFromSubmit().
+				WithMaxGoroutines(1)
+			p.Go(func(ctx context.Context) error {
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			})
+			var ran atomic.Bool
+			p.Go(func(ctx context.Context) error {
+				ran.Store(true)
+				return nil
+			})
+			err := p.Wait()
+			require.False(t, ran.Load())
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+
+			var timeoutErr *TaskTimeoutError
+			require.ErrorAs(t, err, &timeoutErr)
+			require.GreaterOrEqual(t, timeoutErr.QueueWait, 10*time.Millisecond)
+		})
+	})
+
+	t.Run("panics as errors cancel other tasks", func(t *testing.T) {
+		p := New().WithContext(bgctx).WithPanicsAsErrors().WithMaxGoroutines(2)
+		p.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		p.Go(func(ctx context.Context) error { panic("poison") })
+
+		err := p.Wait()
+		require.ErrorIs(t, err, context.Canceled)
+		var recovered *conc.RecoveredPanic
+		require.ErrorAs(t, err, &recovered)
+	})
+
+	t.Run("worker state", func(t *testing.T) {
+		t.Run("owned by each worker", func(t *testing.T) {
+			var workers, tasks atomic.Int64
+			p := New().WithMaxGoroutines(2).WithContext(bgctx).
+				WithWorkerInit(func() (any, error) {
+					return new(atomic.Int64), nil
+				}).
+				WithWorkerTeardown(func(state any) {
+					workers.Add(1)
+					tasks.Add(state.(*atomic.Int64).Load())
+				})
+			for i := 0; i < 10; i++ {
+				p.Go(func(ctx context.Context) error {
+					WorkerState(ctx).(*atomic.Int64).Add(1)
+					return nil
+				})
+			}
+			require.NoError(t, p.Wait())
+			require.LessOrEqual(t, workers.Load(), int64(2))
+			require.Equal(t, int64(10), tasks.Load())
+		})
+
+		t.Run("init failure fails the task", func(t *testing.T) {
+			var attempts atomic.Int64
+			p := New().WithMaxGoroutines(1).WithContext(bgctx).
+				WithWorkerInit(func() (any, error) {
+					if attempts.Add(1) == 1 {
+						return nil, err1
+					}
+					return "conn", nil
+				})
+			var ran atomic.Int64
+			for i := 0; i < 3; i++ {
+				p.Go(func(ctx context.Context) error {
+					require.Equal(t, "conn", WorkerState(ctx))
+					ran.Add(1)
+					return nil
+				})
+			}
+			require.ErrorIs(t, p.Wait(), err1)
+			require.Equal(t, int64(2), attempts.Load())
+			require.LessOrEqual(t, ran.Load(), int64(2))
+		})
+
+		t.Run("nil without init", func(t *testing.T) {
+			p := New().WithContext(bgctx)
+			p.Go(func(ctx context.Context) error {
+				require.Nil(t, WorkerState(ctx))
+				return nil
+			})
+			require.NoError(t, p.Wait())
+		})
+	})
+
+	t.Run("interceptor errors are task errors", func(t *testing.T) {
+		p := New().WithContext(bgctx).WithInterceptor(func(next TaskFunc) TaskFunc {
+			return func(ctx context.Context) error {
+				if ctx.Value("user") == nil {
+					return err1
+				}
+				return next(ctx)
+			}
+		}).WithContextPropagation("user")
+		var ran atomic.Bool
+		p.Go(func(ctx context.Context) error {
+			ran.Store(true)
+			return nil
+		})
+		err := p.Wait()
+		require.ErrorIs(t, err, err1)
+		require.False(t, ran.Load())
+	})
+
+	t.Run("WithRejectionHandler", func(t *testing.T) {
+		var rejected atomic.Int64
+		p := New().WithContext(bgctx).
+			WithRejectionHandler(func(err *TaskTimeoutError) {
+				require.GreaterOrEqual(t, err.QueueWait, err.Timeout)
+				rejected.Add(1)
+			}).
+			WithMaxGoroutines(1)
+		p.Go(func(ctx context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+
+		reqCtx, cancel := context.WithTimeout(bgctx, 10*time.Millisecond)
+		defer cancel()
+		var ran atomic.Bool
+		p.GoContext(reqCtx, func(ctx context.Context) error {
+			ran.Store(true)
+			return nil
+		})
+		var hasDeadline atomic.Bool
+		p.GoContext(bgctx, func(ctx context.Context) error {
+			_, ok := ctx.Deadline()
+			hasDeadline.Store(ok)
+			return nil
+		})
+
+		require.NoError(t, p.Wait())
+		require.False(t, ran.Load())
+		require.False(t, hasDeadline.Load())
+		require.Equal(t, int64(1), rejected.Load())
+	})
+
+	t.Run("ErrStop", func(t *testing.T) {
+		t.Run("cancels without error", func(t *testing.T) {
+			p := New().WithContext(bgctx).WithMaxGoroutines(3)
+			for i := 0; i < 2; i++ {
+				p.Go(func(ctx context.Context) error {
+					<-ctx.Done()
+					return fmt.Errorf("waiting: %w", ctx.Err())
+				})
+			}
+			p.Go(func(ctx context.Context) error {
+				return fmt.Errorf("found it: %w", conc.ErrStop)
+			})
+			require.NoError(t, p.Wait())
+		})
+
+		t.Run("keeps real errors", func(t *testing.T) {
+			p := New().WithContext(bgctx).WithMaxGoroutines(1)
+			p.Go(func(ctx context.Context) error {
+				return err1
+			})
+			p.Go(func(ctx context.Context) error {
+				return conc.ErrStop
+			})
+			err := p.Wait()
+			require.ErrorIs(t, err, err1)
+			require.NotErrorIs(t, err, conc.ErrStop)
+		})
+	})
+
+	t.Run("GoNamed wraps errors with task info", func(t *testing.T) {
+		p := New().WithContext(bgctx)
+		p.GoNamed("waiter", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		p.GoNamed("failer", func(ctx context.Context) error {
+			return err1
+		})
+		err := p.Wait()
+		require.ErrorIs(t, err, err1)
+		require.ErrorIs(t, err, context.Canceled)
+
+		var taskErr *TaskError
+		require.ErrorAs(t, err, &taskErr)
+		require.Contains(t, err.Error(), `task "failer" (#1): err1`)
+		require.Contains(t, err.Error(), `task "waiter" (#0): context canceled`)
+	})
+
+	t.Run("WithContextPropagation", func(t *testing.T) {
+		type key string
+		submitCtx := context.WithValue(bgctx, key("trace"), "abc")
+		submitCtx = context.WithValue(submitCtx, key("ignored"), "xyz")
+		poolCtx := context.WithValue(bgctx, key("service"), "ingest")
+
+		p := New().WithContext(poolCtx).WithContextPropagation(key("trace"))
+		p.GoContext(submitCtx, func(ctx context.Context) error {
+			require.Equal(t, "abc", ctx.Value(key("trace")))
+			require.Equal(t, "ingest", ctx.Value(key("service")))
+			require.Nil(t, ctx.Value(key("ignored")))
+			return nil
+		})
+		p.Go(func(ctx context.Context) error {
+			require.Nil(t, ctx.Value(key("trace")))
+			return nil
+		})
+		require.NoError(t, p.Wait())
+	})
+
+	t.Run("GoContext is canceled by the pool", func(t *testing.T) {
+		submitCtx, cancel := context.WithCancel(bgctx)
+		defer cancel()
+
+		p := New().WithContext(bgctx).WithContextPropagation("key")
+		p.GoContext(submitCtx, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		p.Go(func(ctx context.Context) error {
+			return err1
+		})
+		err := p.Wait()
+		require.ErrorIs(t, err, err1)
+		require.ErrorIs(t, err, context.Canceled)
+		require.NoError(t, submitCtx.Err())
+	})
+
+	t.Run("Checkpoint stops long tasks", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(bgctx)
+		p := New().WithMaxGoroutines(1).WithContext(ctx)
+		started := make(chan struct{})
+		p.Go(func(ctx context.Context) error {
+			close(started)
+			for {
+				if err := conc.Checkpoint(ctx); err != nil {
+					return err
+				}
+			}
+		})
+		<-started
+		cancel()
+		require.ErrorIs(t, p.Wait(), context.Canceled)
+	})
+
+	t.Run("OnCancel unblocks tasks", func(t *testing.T) {
+		p := New().WithContext(bgctx)
+		p.Go(func(ctx context.Context) error {
+			blocked := make(chan struct{})
+			defer p.OnCancel(func() { close(blocked) })()
+			<-blocked
+			return nil
+		})
+		p.Go(func(ctx context.Context) error {
+			return err1
+		})
+		require.ErrorIs(t, p.Wait(), err1)
+	})
+
+	t.Run("OnCancel stop", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(bgctx)
+		p := New().WithContext(ctx)
+		var called atomic.Bool
+		stop := p.OnCancel(func() { called.Store(true) })
+		require.True(t, stop())
+		require.False(t, stop())
+		cancel()
+		require.NoError(t, p.Wait())
+		time.Sleep(10 * time.Millisecond)
+		require.False(t, called.Load())
+	})
+
+	t.Run("OnCancel after cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(bgctx)
+		cancel()
+		p := NewWithResults[int]().WithContext(ctx)
+		done := make(chan struct{})
+		stop := p.OnCancel(func() { close(done) })
+		<-done
+		require.False(t, stop())
+		_, err := p.Wait()
+		require.NoError(t, err)
+	})
+
+	t.Run("canceled policy", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(bgctx)
+		cancel()
+
+		t.Run("runs by default", func(t *testing.T) {
+			p := New().WithContext(canceled)
+			var ran atomic.Bool
+			p.Go(func(ctx context.Context) error {
+				ran.Store(true)
+				return ctx.Err()
+			})
+			require.ErrorIs(t, p.Wait(), context.Canceled)
+			require.True(t, ran.Load())
+		})
+
+		t.Run("skip", func(t *testing.T) {
+			p := New().WithContext(canceled).WithCanceledPolicy(SkipCanceled)
+			var ran atomic.Int64
+			p.Go(func(ctx context.Context) error { ran.Add(1); return nil })
+			p.GoNamed("named", func(ctx context.Context) error { ran.Add(1); return nil })
+			p.GoBlocking(func(ctx context.Context) error { ran.Add(1); return nil })
+			p.GoWeighted(1, func(ctx context.Context) error { ran.Add(1); return nil })
+			p.GoSized(1, func(ctx context.Context) error { ran.Add(1); return nil })
+			err := p.Wait()
+			require.ErrorIs(t, err, context.Canceled)
+			require.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 5)
+			var taskErr *TaskError
+			require.ErrorAs(t, err, &taskErr)
+			require.Equal(t, "named", taskErr.Name)
+			require.Equal(t, int64(0), ran.Load())
+		})
+
+		t.Run("reject", func(t *testing.T) {
+			p := New().WithContext(canceled).WithCanceledPolicy(RejectCanceled)
+			var ran atomic.Bool
+			err := p.TryGo(func(ctx context.Context) error { ran.Store(true); return nil })
+			require.ErrorIs(t, err, context.Canceled)
+			require.NoError(t, p.Wait())
+			require.False(t, ran.Load())
+
+			p = New().WithContext(canceled).WithCanceledPolicy(RejectCanceled)
+			p.Go(func(ctx context.Context) error { ran.Store(true); return nil })
+			require.ErrorIs(t, p.Wait(), context.Canceled)
+			require.False(t, ran.Load())
+		})
+
+		t.Run("parent is checked at submission", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(bgctx)
+			p := New().WithContext(ctx).WithCanceledPolicy(SkipCanceled)
+			require.NoError(t, p.TryGo(func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			}))
+			cancel()
+			require.NoError(t, p.Wait())
+		})
+
+		t.Run("errors of the pool's tasks do not skip tasks", func(t *testing.T) {
+			p := New().WithMaxGoroutines(1).WithContext(bgctx).WithCanceledPolicy(SkipCanceled)
+			p.Go(func(ctx context.Context) error { return err1 })
+			var ran atomic.Bool
+			p.Go(func(ctx context.Context) error { ran.Store(true); return nil })
+			require.ErrorIs(t, p.Wait(), err1)
+			require.True(t, ran.Load())
+		})
+
+		t.Run("result pool", func(t *testing.T) {
+			p := NewWithResults[int]().WithContext(canceled).WithCanceledPolicy(RejectCanceled)
+			require.ErrorIs(t, p.TryGo(func(ctx context.Context) (int, error) { return 1, nil }), context.Canceled)
+			res, err := p.Wait()
+			require.NoError(t, err)
+			require.Empty(t, res)
+		})
+	})
+
+	t.Run("Drain lets tasks finish", func(t *testing.T) {
+		p := New().WithContext(bgctx)
+		started := make(chan struct{})
+		p.Go(func(ctx context.Context) error {
+			close(started)
+			<-conc.Draining(ctx)
+			return ctx.Err()
+		})
+		<-started
+		p.Drain(time.Hour)
+		require.NoError(t, p.Wait())
+	})
+
+	t.Run("Drain cancels once the grace period expires", func(t *testing.T) {
+		p := NewWithResults[int]().WithContext(bgctx)
+		drained := make(chan struct{})
+		p.Go(func(ctx context.Context) (int, error) {
+			<-conc.Draining(ctx)
+			close(drained)
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		p.Drain(10 * time.Millisecond)
+		<-drained
+		_, err := p.Wait()
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("WithBaggage", func(t *testing.T) {
+		type tenantKey struct{}
+		type loggerKey struct{}
+		p := New().WithContext(bgctx).
+			WithBaggage(tenantKey{}, "acme").
+			WithBaggage(loggerKey{}, "logger").
+			WithContextPropagation(tenantKey{})
+		var acme, other atomic.Int64
+		record := func(ctx context.Context) error {
+			require.Equal(t, "logger", ctx.Value(loggerKey{}))
+			switch ctx.Value(tenantKey{}) {
+			case "acme":
+				acme.Add(1)
+			case "other":
+				other.Add(1)
+			}
+			return nil
+		}
+		p.Go(record)
+		p.GoContext(bgctx, record)
+		p.GoContext(context.WithValue(bgctx, tenantKey{}, "other"), record)
+		require.NoError(t, p.Wait())
+		require.Equal(t, int64(2), acme.Load())
+		require.Equal(t, int64(1), other.Load())
+	})
+
+	t.Run("WithBaggage result pool", func(t *testing.T) {
+		type key struct{}
+		p := NewWithResults[any]().WithContext(bgctx).WithBaggage(key{}, "value")
+		p.Go(func(ctx context.Context) (any, error) { return ctx.Value(key{}), nil })
+		res, err := p.Wait()
+		require.NoError(t, err)
+		require.Equal(t, []any{"value"}, res)
+	})
+
+	t.Run("panics on invalid baggage key", func(t *testing.T) {
+		require.Panics(t, func() { New().WithContext(bgctx).WithBaggage(nil, 1) })
+		require.Panics(t, func() { New().WithContext(bgctx).WithBaggage([]int{}, 1) })
+	})
+
+	t.Run("panics on invalid propagation key", func(t *testing.T) {
+		require.Panics(t, func() { New().WithContext(bgctx).WithContextPropagation([]int{}) })
+		require.Panics(t, func() { New().WithContext(bgctx).WithContextPropagation(nil) })
+	})
+
 	t.Run("limit", func(t *testing.T) {
 		t.Parallel()
 		for _, maxConcurrent := range []int{1, 10, 100} {
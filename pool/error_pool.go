@@ -2,9 +2,12 @@ package pool
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"github.com/sourcegraph/conc"
 )
 
 // ErrorPool is a pool that runs tasks that may return an error.
@@ -15,33 +18,194 @@ type ErrorPool struct {
 	pool Pool
 
 	onlyFirstError bool
+	panicsAsErrors bool
+
+	// maxErrors is set by WithMaxErrors, and failed is the number of errors
+	// collected, which is only counted if it is set
+	maxErrors int64
+	failed    atomic.Int64
+
+	// submitted is the number of tasks submitted, used to index tasks
+	submitted atomic.Int64
 
 	mu   sync.Mutex
-	errs error
+	errs conc.Errors
 }
 
 // Go submits a task to the pool.
 func (p *ErrorPool) Go(f func() error) {
-	p.pool.Go(func() {
-		p.addErr(f())
+	p.goWithState(f, nil, unitCost)
+}
+
+// GoWithWorkerState submits a task that is called with the state of the
+// goroutine that runs it. If initializing the goroutine fails, the task fails
+// with the error instead of running. See Pool.GoWithWorkerState.
+func (p *ErrorPool) GoWithWorkerState(f func(state any) error) {
+	state := new(any)
+	p.goWithState(func() error { return f(*state) }, state, unitCost)
+}
+
+// GoWeighted submits a task that counts as weight tasks against the pool's
+// limit. See Pool.GoWeighted.
+func (p *ErrorPool) GoWeighted(weight int, f func() error) {
+	if weight < 1 {
+		panic("task weight must be greater than zero")
+	}
+	p.goWithState(f, nil, taskCost{weight: weight})
+}
+
+// GoSized submits a task that is estimated to use bytes of memory while it
+// runs. See Pool.GoSized.
+func (p *ErrorPool) GoSized(bytes int64, f func() error) {
+	if bytes < 0 {
+		panic("task size must not be negative")
+	}
+	p.goWithState(f, nil, taskCost{weight: 1, bytes: bytes})
+}
+
+// GoBlocking submits a task that spends most of its time blocked, on a
+// goroutine that does not count toward the pool's limit. See
+// Pool.GoBlocking.
+func (p *ErrorPool) GoBlocking(f func() error) {
+	f = p.pool.asTaskErr("", p.nextIndex(), f)
+	f = p.catchPanics(p.pool.interceptErr(f))
+	p.pool.goErrBlocking(func() error {
+		if p.reachedMaxErrors() {
+			return nil
+		}
+		err := f()
+		p.addErr(err)
+		return err
 	})
 }
 
+func (p *ErrorPool) goWithState(f func() error, state *any, cost taskCost) {
+	f = p.pool.asTaskErr("", p.nextIndex(), f)
+	f, state = p.pool.withInitCheck(p.catchPanics(p.pool.interceptErr(f)), state)
+	p.pool.goErr(func() error {
+		if p.reachedMaxErrors() {
+			return nil
+		}
+		err := f()
+		p.addErr(err)
+		return err
+	}, state, cost, "")
+}
+
+// GoNamed submits a task to the pool. If the task returns an error, it is
+// wrapped in a *TaskError with the given name and the index of the task, so
+// the source of each error can be identified in the error returned by Wait().
+// Until it starts, the task can be canceled by its name with CancelWhere.
+func (p *ErrorPool) GoNamed(name string, f func() error) {
+	index := p.nextIndex()
+	f = p.pool.asTaskErr(name, index, f)
+	f, state := p.pool.withInitCheck(p.catchPanics(p.pool.interceptErr(f)), nil)
+	p.pool.goErr(func() error {
+		if p.reachedMaxErrors() {
+			return nil
+		}
+		err := newTaskError(name, index, f())
+		p.addErr(err)
+		return err
+	}, state, unitCost, name)
+}
+
 // Wait cleans up any spawned goroutines, propagating any panics and
-// returning any errors from tasks.
+// returning any errors from tasks. Unless WithFirstError is used, the
+// returned error is a conc.Errors containing every error returned by a task.
 func (p *ErrorPool) Wait() error {
+	if p.pool.reusable {
+		defer p.reset()
+	}
 	p.pool.Wait()
-	return p.errs
+	return p.err()
+}
+
+// WaitContext is like Wait, but stops waiting and returns ctx.Err() when ctx
+// is done. See Pool.WaitContext.
+func (p *ErrorPool) WaitContext(ctx context.Context) error {
+	var err error
+	if ctxErr := waitContext(ctx, func() { err = p.Wait() }); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// WaitTimeout is like WaitContext, but stops waiting after d.
+func (p *ErrorPool) WaitTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return p.WaitContext(ctx)
+}
+
+// Close stops the goroutines of a pool configured with WithReuse. See
+// Pool.Close.
+func (p *ErrorPool) Close() {
+	p.pool.Close()
+}
+
+// Pause stops the pool from starting any new tasks until Resume is called.
+// See Pool.Pause for details.
+func (p *ErrorPool) Pause() {
+	p.pool.Pause()
+}
+
+// Resume undoes a call to Pause, allowing held and queued tasks to start.
+func (p *ErrorPool) Resume() {
+	p.pool.Resume()
+}
+
+// CancelWhere cancels the labeled tasks that have not started yet and whose
+// label matches the predicate, and returns how many were canceled. See
+// Pool.CancelWhere.
+func (p *ErrorPool) CancelWhere(pred func(label string) bool) int {
+	return p.pool.CancelWhere(pred)
+}
+
+// QueuedLabels returns the labels of the labeled tasks that have not started
+// yet. See Pool.QueuedLabels.
+func (p *ErrorPool) QueuedLabels() []string {
+	return p.pool.QueuedLabels()
+}
+
+// Name returns the name of the pool qualified by the names of its parents.
+// See Pool.Name for details.
+func (p *ErrorPool) Name() string {
+	return p.pool.Name()
+}
+
+// Goroutines returns the number of goroutines the pool is running. See
+// Pool.Goroutines.
+func (p *ErrorPool) Goroutines() int {
+	return p.pool.Goroutines()
+}
+
+// Prewarm starts up to n workers ahead of the first tasks. See Pool.Prewarm.
+func (p *ErrorPool) Prewarm(n int) {
+	p.pool.Prewarm(n)
+}
+
+// SampledOutcomes returns the outcomes of tasks sampled by the pool, starting
+// with the most recent. See Pool.SampledOutcomes.
+func (p *ErrorPool) SampledOutcomes() []TaskStats {
+	return p.pool.SampledOutcomes()
 }
 
 // WithContext converts the pool to a ContextPool for tasks that should
 // be canceled on first error.
 func (p *ErrorPool) WithContext(ctx context.Context) *ContextPool {
-	ctx, cancel := context.WithCancel(ctx)
+	if p.pool.reusable {
+		panic("context pools cannot be reused")
+	}
+	parent := ctx
+	ctx, drain := conc.WithDraining(ctx)
+	ctx, cancel := withCancelCause(ctx)
 	return &ContextPool{
 		errorPool: *p,
 		ctx:       ctx,
 		cancel:    cancel,
+		parent:    parent,
+		drain:     drain,
 	}
 }
 
@@ -52,6 +216,163 @@ func (p *ErrorPool) WithFirstError() *ErrorPool {
 	return p
 }
 
+// WithMaxErrors configures the pool to stop running tasks once n of them have
+// failed, for batch jobs that should give up after too many failures rather
+// than run to the end. The tasks that have not started by then are skipped, as
+// are the tasks submitted afterwards, and Wait() returns the errors collected,
+// which include the errors of the tasks that were already running and failed
+// as well. Panics if n < 1.
+func (p *ErrorPool) WithMaxErrors(n int) *ErrorPool {
+	if n < 1 {
+		panic("max errors must be greater than zero")
+	}
+	p.maxErrors = int64(n)
+	return p
+}
+
+// WithPanicsAsErrors configures the pool to treat a panic in a task as the
+// task's error, rather than propagating it from Wait(). The error is the
+// *conc.RecoveredPanic, which is combined with the errors of the other tasks
+// as usual, so that one bad input does not crash a whole batch.
+func (p *ErrorPool) WithPanicsAsErrors() *ErrorPool {
+	p.panicsAsErrors = true
+	return p
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *ErrorPool) WithName(name string) *ErrorPool {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithOptions applies the settings of opts that are set to the pool. See
+// Pool.WithOptions.
+func (p *ErrorPool) WithOptions(opts conc.Options) *ErrorPool {
+	p.pool.WithOptions(opts)
+	return p
+}
+
+// WithParent sets the parent of the pool in the naming hierarchy. See
+// Pool.WithParent.
+func (p *ErrorPool) WithParent(parent *Pool) *ErrorPool {
+	p.pool.WithParent(parent)
+	return p
+}
+
+// WithReuse configures the pool so that Wait can be called more than once,
+// each time returning the errors of the tasks submitted since the previous
+// call. See Pool.WithReuse.
+func (p *ErrorPool) WithReuse() *ErrorPool {
+	p.pool.WithReuse()
+	return p
+}
+
+// WithInterceptor adds an interceptor that wraps every task submitted to the
+// pool. See Pool.WithInterceptor.
+func (p *ErrorPool) WithInterceptor(i Interceptor) *ErrorPool {
+	p.pool.WithInterceptor(i)
+	return p
+}
+
+// WithWorkerInit configures the pool to call init in each of its goroutines
+// before it runs its first task. See Pool.WithWorkerInit.
+func (p *ErrorPool) WithWorkerInit(init func() (state any, err error)) *ErrorPool {
+	p.pool.WithWorkerInit(init)
+	return p
+}
+
+// WithWorkerTeardown configures the pool to call teardown with the state of
+// each of its goroutines when it exits. See Pool.WithWorkerTeardown.
+func (p *ErrorPool) WithWorkerTeardown(teardown func(state any)) *ErrorPool {
+	p.pool.WithWorkerTeardown(teardown)
+	return p
+}
+
+// WithMisuseDetection configures the pool to panic when it is misused in a
+// way that would otherwise deadlock. See Pool.WithMisuseDetection.
+func (p *ErrorPool) WithMisuseDetection() *ErrorPool {
+	p.pool.WithMisuseDetection()
+	return p
+}
+
+// WithReentrancy configures the pool so that its tasks can submit more tasks
+// to it without deadlocking. See Pool.WithReentrancy.
+func (p *ErrorPool) WithReentrancy() *ErrorPool {
+	p.pool.WithReentrancy()
+	return p
+}
+
+// WithLockOSThread configures each goroutine of the pool to lock itself to
+// its OS thread for as long as it runs. See Pool.WithLockOSThread.
+func (p *ErrorPool) WithLockOSThread() *ErrorPool {
+	p.pool.WithLockOSThread()
+	return p
+}
+
+// WithIdleShutdown configures each worker of the pool to exit once it has
+// been idle for d. See Pool.WithIdleShutdown.
+func (p *ErrorPool) WithIdleShutdown(d time.Duration) *ErrorPool {
+	p.pool.WithIdleShutdown(d)
+	return p
+}
+
+// WithMemoryBudget limits the estimated memory of the tasks submitted with
+// GoSized that run at once. See Pool.WithMemoryBudget.
+func (p *ErrorPool) WithMemoryBudget(bytes int64) *ErrorPool {
+	p.pool.WithMemoryBudget(bytes)
+	return p
+}
+
+// WithMaxBlocking limits the number of tasks submitted with GoBlocking that
+// run outside the pool's limit at once. See Pool.WithMaxBlocking.
+func (p *ErrorPool) WithMaxBlocking(n int) *ErrorPool {
+	p.pool.WithMaxBlocking(n)
+	return p
+}
+
+// WithScheduler registers the pool with s, which shares its limit fairly
+// between pools according to their weights. See Pool.WithScheduler.
+func (p *ErrorPool) WithScheduler(s *Scheduler, weight int) *ErrorPool {
+	p.pool.WithScheduler(s, weight)
+	return p
+}
+
+// WithParentLimiter configures the pool to borrow its goroutines from the
+// concurrency budget of parent. See Pool.WithParentLimiter.
+func (p *ErrorPool) WithParentLimiter(parent *Pool) *ErrorPool {
+	p.pool.WithParentLimiter(parent)
+	return p
+}
+
+// WithProgress configures the pool to call f every time a task completes.
+// See Pool.WithProgress.
+func (p *ErrorPool) WithProgress(f func(done, total int)) *ErrorPool {
+	p.pool.WithProgress(f)
+	return p
+}
+
+// WithTaskObserver configures the pool to call f with the timings and
+// outcome of every task once it finishes. See Pool.WithTaskObserver.
+func (p *ErrorPool) WithTaskObserver(f func(TaskStats)) *ErrorPool {
+	p.pool.WithTaskObserver(f)
+	return p
+}
+
+// WithOutcomeSampling configures the pool to keep every failure and one in
+// every successes successful tasks among its last size outcomes. See
+// Pool.WithOutcomeSampling.
+func (p *ErrorPool) WithOutcomeSampling(successes, size int) *ErrorPool {
+	p.pool.WithOutcomeSampling(successes, size)
+	return p
+}
+
+// WithTracer configures the pool to call f with every event in the life of
+// its tasks. See Pool.WithTracer.
+func (p *ErrorPool) WithTracer(f func(TraceEvent)) *ErrorPool {
+	p.pool.WithTracer(f)
+	return p
+}
+
 // WithMaxGoroutines limits the number of goroutines in a pool.
 // Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
 func (p *ErrorPool) WithMaxGoroutines(n int) *ErrorPool {
@@ -59,16 +380,103 @@ func (p *ErrorPool) WithMaxGoroutines(n int) *ErrorPool {
 	return p
 }
 
+// WithUnlimitedGoroutines removes the limit on the number of goroutines in a
+// pool. See Pool.WithUnlimitedGoroutines.
+func (p *ErrorPool) WithUnlimitedGoroutines() *ErrorPool {
+	p.pool.WithUnlimitedGoroutines()
+	return p
+}
+
+// catchPanics wraps f so that a panic is returned as its error if the pool
+// is configured with WithPanicsAsErrors.
+func (p *ErrorPool) catchPanics(f func() error) func() error {
+	if !p.panicsAsErrors {
+		return f
+	}
+	return func() error {
+		var (
+			pc  conc.PanicCatcher
+			err error
+		)
+		pc.Try(func() { err = f() })
+		if recovered := pc.Recovered(); recovered != nil {
+			return recovered
+		}
+		return err
+	}
+}
+
+// nextIndex returns the index of a newly submitted task.
+func (p *ErrorPool) nextIndex() int {
+	return int(p.submitted.Add(1) - 1)
+}
+
 func (p *ErrorPool) addErr(err error) {
 	if err != nil {
+		if p.maxErrors > 0 {
+			p.failed.Add(1)
+		}
 		p.mu.Lock()
-		if p.onlyFirstError {
-			if p.errs == nil {
-				p.errs = err
-			}
-		} else {
-			p.errs = errors.Append(p.errs, err)
+		if !p.onlyFirstError || len(p.errs) == 0 {
+			p.errs = append(p.errs, err)
 		}
 		p.mu.Unlock()
 	}
 }
+
+// reset clears the collected errors so that the pool can be reused.
+func (p *ErrorPool) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.errs = nil
+	p.submitted.Store(0)
+	p.failed.Store(0)
+}
+
+// reachedMaxErrors reports whether enough tasks have failed that the pool
+// should stop running tasks. See WithMaxErrors.
+func (p *ErrorPool) reachedMaxErrors() bool {
+	return p.maxErrors > 0 && p.failed.Load() >= p.maxErrors
+}
+
+// err returns the collected errors, or nil if there were none.
+func (p *ErrorPool) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.errs) == 0 {
+		return nil
+	}
+	if p.onlyFirstError {
+		return p.errs[0]
+	}
+	return p.errs
+}
+
+// TaskError is an error returned by a task submitted with GoNamed, annotated
+// with information about the task.
+type TaskError struct {
+	// Name is the name the task was submitted with.
+	Name string
+	// Index is the position of the task in the order tasks were submitted to
+	// the pool, starting at zero.
+	Index int
+	// Err is the error returned by the task.
+	Err error
+}
+
+func newTaskError(name string, index int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TaskError{Name: name, Index: index, Err: err}
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("task %q (#%d): %s", e.Name, e.Index, e.Err)
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
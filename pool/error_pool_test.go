@@ -3,12 +3,14 @@ package pool
 import (
 	"fmt"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/sourcegraph/conc"
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 )
 
@@ -41,6 +43,54 @@ func TestErrorPool(t *testing.T) {
 		require.NoError(t, g.Wait())
 	})
 
+	t.Run("reuse returns errors per batch", func(t *testing.T) {
+		g := New().WithErrors().WithReuse()
+		defer g.Close()
+
+		g.Go(func() error { return err1 })
+		require.ErrorIs(t, g.Wait(), err1)
+
+		g.Go(func() error { return nil })
+		require.NoError(t, g.Wait())
+	})
+
+	t.Run("tasks receive worker state", func(t *testing.T) {
+		g := New().WithErrors().WithWorkerInit(func() (any, error) { return "conn", nil })
+		g.GoWithWorkerState(func(state any) error {
+			if state != "conn" {
+				return err1
+			}
+			return nil
+		})
+		require.NoError(t, g.Wait())
+	})
+
+	t.Run("worker init failure fails the task", func(t *testing.T) {
+		g := New().WithErrors().WithWorkerInit(func() (any, error) { return nil, err1 })
+		var ran atomic.Bool
+		g.Go(func() error {
+			ran.Store(true)
+			return nil
+		})
+		require.ErrorIs(t, g.Wait(), err1)
+		require.False(t, ran.Load())
+	})
+
+	t.Run("panics as errors", func(t *testing.T) {
+		g := New().WithErrors().WithPanicsAsErrors()
+		g.Go(func() error { panic("poison") })
+		g.Go(func() error { return err1 })
+		g.Go(func() error { return nil })
+
+		var err error
+		require.NotPanics(t, func() { err = g.Wait() })
+		require.ErrorIs(t, err, err1)
+
+		var recovered *conc.RecoveredPanic
+		require.ErrorAs(t, err, &recovered)
+		require.Equal(t, "poison", recovered.Value)
+	})
+
 	t.Run("wait error if func returns error", func(t *testing.T) {
 		g := New().WithErrors()
 		g.Go(func() error { return err1 })
@@ -57,6 +107,89 @@ func TestErrorPool(t *testing.T) {
 		require.ErrorIs(t, err, err2)
 	})
 
+	t.Run("WithMaxErrors", func(t *testing.T) {
+		t.Run("stops after n failures", func(t *testing.T) {
+			g := New().WithErrors().WithMaxGoroutines(1).WithMaxErrors(3)
+			var ran atomic.Int64
+			for i := 0; i < 10; i++ {
+				g.Go(func() error {
+					ran.Add(1)
+					return err1
+				})
+			}
+			var errs conc.Errors
+			require.ErrorAs(t, g.Wait(), &errs)
+			require.Equal(t, 3, errs.Len())
+			require.Equal(t, int64(3), ran.Load())
+		})
+
+		t.Run("successes do not count", func(t *testing.T) {
+			g := New().WithErrors().WithMaxGoroutines(1).WithMaxErrors(2)
+			var ran atomic.Int64
+			for i := 0; i < 10; i++ {
+				i := i
+				g.Go(func() error {
+					ran.Add(1)
+					if i == 4 {
+						return err1
+					}
+					return nil
+				})
+			}
+			require.ErrorIs(t, g.Wait(), err1)
+			require.Equal(t, int64(10), ran.Load())
+		})
+
+		t.Run("reset on reuse", func(t *testing.T) {
+			g := New().WithErrors().WithMaxGoroutines(1).WithMaxErrors(1).WithReuse()
+			defer g.Close()
+			g.Go(func() error { return err1 })
+			require.ErrorIs(t, g.Wait(), err1)
+
+			var ran atomic.Bool
+			g.Go(func() error {
+				ran.Store(true)
+				return nil
+			})
+			require.NoError(t, g.Wait())
+			require.True(t, ran.Load())
+		})
+
+		t.Run("panics on invalid limit", func(t *testing.T) {
+			require.Panics(t, func() { New().WithErrors().WithMaxErrors(0) })
+		})
+	})
+
+	t.Run("GoNamed wraps errors with task info", func(t *testing.T) {
+		g := New().WithErrors()
+		g.Go(func() error { return nil })
+		g.GoNamed("fetch", func() error { return err1 })
+		g.GoNamed("store", func() error { return nil })
+		err := g.Wait()
+		require.ErrorIs(t, err, err1)
+
+		var taskErr *TaskError
+		require.ErrorAs(t, err, &taskErr)
+		require.Equal(t, "fetch", taskErr.Name)
+		require.Equal(t, 1, taskErr.Index)
+		require.Equal(t, err1, taskErr.Err)
+		require.Contains(t, err.Error(), `task "fetch" (#1): err1`)
+	})
+
+	t.Run("task observer reports errors", func(t *testing.T) {
+		var mu sync.Mutex
+		var errs []error
+		g := New().WithErrors().WithTaskObserver(func(stats TaskStats) {
+			mu.Lock()
+			errs = append(errs, stats.Err)
+			mu.Unlock()
+		}).WithMaxGoroutines(1)
+		g.Go(func() error { return nil })
+		g.Go(func() error { return err1 })
+		require.ErrorIs(t, g.Wait(), err1)
+		require.Equal(t, []error{nil, err1}, errs)
+	})
+
 	t.Run("limit", func(t *testing.T) {
 		t.Parallel()
 		for _, maxGoroutines := range []int{1, 10, 100} {
@@ -0,0 +1,293 @@
+package pool
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/sourcegraph/conc"
+)
+
+// NewFrontier creates a new Frontier for items of type T, which are
+// deduplicated by the key returned by key.
+func NewFrontier[T any, K comparable](key func(T) K) *Frontier[T, K] {
+	return &Frontier[T, K]{key: key}
+}
+
+// Frontier processes a set of work items that grows as it is processed, such
+// as the pages found by a web crawler. Each item is visited at most once: an
+// item whose key has been seen before is dropped. Items can be split into
+// groups, such as the host of a URL, and the number of items of a group
+// being visited at once and the rate at which they are started can be
+// limited, so that no group is overloaded while items of other groups
+// proceed:
+//
+//	f := pool.NewFrontier(func(u *url.URL) string { return u.String() }).
+//		WithMaxGoroutines(32).
+//		WithGroups(func(u *url.URL) string { return u.Host }).
+//		WithMaxPerGroup(2).
+//		WithGroupInterval(100 * time.Millisecond)
+//	err := f.Run(ctx, crawl, seeds...)
+//
+// The groups that have items ready are served in turn, so a group with many
+// items does not hold up the others. Within a group, items are visited in
+// the order they were found.
+//
+// A Frontier must not be reconfigured or run again while Run is in progress.
+type Frontier[T any, K comparable] struct {
+	key           func(T) K
+	group         func(T) string
+	maxGoroutines int
+	maxPerGroup   int
+	interval      time.Duration
+}
+
+// WithMaxGoroutines limits the number of items visited at once.
+// Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
+func (f *Frontier[T, K]) WithMaxGoroutines(n int) *Frontier[T, K] {
+	if n < 1 {
+		panic("max goroutines in a pool must be greater than zero")
+	}
+	f.maxGoroutines = n
+	return f
+}
+
+// WithGroups assigns each item to the group returned by group, to which the
+// limits set with WithMaxPerGroup and WithGroupInterval apply. Without it,
+// all items are in a single group.
+func (f *Frontier[T, K]) WithGroups(group func(T) string) *Frontier[T, K] {
+	f.group = group
+	return f
+}
+
+// WithMaxPerGroup limits the number of items of the same group that are
+// visited at once. By default, only the limit set with WithMaxGoroutines
+// applies. Panics if n < 1.
+func (f *Frontier[T, K]) WithMaxPerGroup(n int) *Frontier[T, K] {
+	if n < 1 {
+		panic("max goroutines per group must be greater than zero")
+	}
+	f.maxPerGroup = n
+	return f
+}
+
+// WithGroupInterval sets the minimum time between the starts of two items of
+// the same group. The time is measured with the conc.Clock of the context
+// passed to Run. Panics if d < 0.
+func (f *Frontier[T, K]) WithGroupInterval(d time.Duration) *Frontier[T, K] {
+	if d < 0 {
+		panic("group interval must not be negative")
+	}
+	f.interval = d
+	return f
+}
+
+// Run visits seeds and every item emitted while visiting them, concurrently
+// within the configured limits, and returns once there are no items left.
+// visit is called with a context derived from ctx, the item, and a function
+// that adds new items to the frontier. emit is safe to call concurrently,
+// but must not be called after visit has returned.
+//
+// The errors returned by visit do not stop the other items, and are
+// returned together as a conc.Errors. If ctx is done before the frontier is
+// exhausted, Run starts no new items and returns ctx.Err() once the calls to
+// visit in progress have returned. Panics in visit are propagated by Run in
+// the same way, after the context passed to the other calls is canceled.
+func (f *Frontier[T, K]) Run(ctx context.Context, visit func(ctx context.Context, item T, emit func(T)) error, seeds ...T) error {
+	workers := f.maxGoroutines
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	clock := conc.ClockFromContext(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		seen   = make(map[K]struct{})
+		groups = make(map[string]*frontierGroup[T])
+		// ready holds the groups that have items waiting, in the order in
+		// which they are served
+		ready   []*frontierGroup[T]
+		cursor  int
+		running int
+		stopped bool
+		errs    conc.Errors
+		found   = make(chan T)
+		done    = make(chan frontierDone)
+	)
+
+	add := func(item T) {
+		k := f.key(item)
+		if _, ok := seen[k]; ok {
+			return
+		}
+		seen[k] = struct{}{}
+
+		var name string
+		if f.group != nil {
+			name = f.group(item)
+		}
+		g, ok := groups[name]
+		if !ok {
+			g = &frontierGroup[T]{name: name}
+			groups[name] = g
+		}
+		if len(g.queue) == 0 {
+			ready = append(ready, g)
+		}
+		g.queue = append(g.queue, item)
+	}
+
+	emit := func(item T) {
+		found <- item
+	}
+
+	var wg conc.WaitGroup
+	start := func(g *frontierGroup[T], item T, now time.Time) {
+		running++
+		g.running++
+		g.next = now.Add(f.interval)
+		wg.Go(func() {
+			var err error
+			completed := false
+			defer func() {
+				done <- frontierDone{group: g.name, err: err, panicked: !completed}
+			}()
+			err = visit(ctx, item, emit)
+			completed = true
+		})
+	}
+
+	// schedule starts as many waiting items as the limits allow, and returns
+	// the earliest time at which a group held back by its interval may start
+	// another item.
+	schedule := func() (wake time.Time) {
+		for len(ready) > 0 && running < workers {
+			now := clock.Now()
+			wake = time.Time{}
+			started := false
+			for i := 0; i < len(ready); i++ {
+				idx := (cursor + i) % len(ready)
+				g := ready[idx]
+				if f.maxPerGroup > 0 && g.running >= f.maxPerGroup {
+					continue
+				}
+				if now.Before(g.next) {
+					if wake.IsZero() || g.next.Before(wake) {
+						wake = g.next
+					}
+					continue
+				}
+
+				item := g.queue[0]
+				var zero T
+				g.queue[0] = zero
+				g.queue = g.queue[1:]
+				if len(g.queue) == 0 {
+					ready = append(ready[:idx], ready[idx+1:]...)
+					cursor = idx
+				} else {
+					cursor = idx + 1
+				}
+				if len(ready) > 0 {
+					cursor %= len(ready)
+				}
+				start(g, item, now)
+				started = true
+				break
+			}
+			if !started {
+				return wake
+			}
+		}
+		return time.Time{}
+	}
+
+	// stop drops the waiting items, so that Run returns once the items in
+	// progress are done.
+	stop := func() {
+		stopped = true
+		ready = nil
+		for _, g := range groups {
+			g.queue = nil
+		}
+	}
+
+	for _, item := range seeds {
+		add(item)
+	}
+
+	var ctxErr error
+	for {
+		if !stopped && ctx.Err() != nil {
+			ctxErr = ctx.Err()
+			stop()
+		}
+		var wake time.Time
+		if !stopped {
+			wake = schedule()
+		}
+		if running == 0 && len(ready) == 0 {
+			break
+		}
+
+		var timer conc.Timer
+		var timerC <-chan time.Time
+		if !wake.IsZero() {
+			timer = clock.NewTimer(wake.Sub(clock.Now()))
+			timerC = timer.C()
+		}
+		var ctxDone <-chan struct{}
+		if !stopped {
+			ctxDone = ctx.Done()
+		}
+
+		select {
+		case item := <-found:
+			if !stopped {
+				add(item)
+			}
+		case d := <-done:
+			if d.err != nil {
+				errs = append(errs, d.err)
+			}
+			running--
+			groups[d.group].running--
+			if d.panicked && !stopped {
+				stop()
+				cancel()
+			}
+		case <-timerC:
+		case <-ctxDone:
+			ctxErr = ctx.Err()
+			stop()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+
+	wg.Wait()
+	if ctxErr != nil {
+		return ctxErr
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type frontierGroup[T any] struct {
+	name    string
+	queue   []T
+	running int
+	// next is the earliest time at which the next item may start
+	next time.Time
+}
+
+// frontierDone reports that a call to visit is over.
+type frontierDone struct {
+	group    string
+	err      error
+	panicked bool
+}
@@ -0,0 +1,243 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc"
+)
+
+func ExampleFrontier() {
+	// Visit the numbers below 20 reachable from 1 by doubling or adding 3
+	var mu sync.Mutex
+	var visited []int
+	f := NewFrontier(func(i int) int { return i })
+	err := f.Run(context.Background(), func(ctx context.Context, i int, emit func(int)) error {
+		mu.Lock()
+		visited = append(visited, i)
+		mu.Unlock()
+		for _, next := range []int{i * 2, i + 3} {
+			if next < 20 {
+				emit(next)
+			}
+		}
+		return nil
+	}, 1)
+	sort.Ints(visited)
+	fmt.Println(visited, err)
+
+	// Output:
+	// [1 2 4 5 7 8 10 11 13 14 16 17 19] <nil>
+}
+
+func TestFrontier(t *testing.T) {
+	t.Parallel()
+
+	identity := func(i int) int { return i }
+
+	t.Run("visits each key once", func(t *testing.T) {
+		t.Parallel()
+		var visits sync.Map
+		var count atomic.Int64
+		f := NewFrontier(identity).WithMaxGoroutines(4)
+		err := f.Run(context.Background(), func(ctx context.Context, i int, emit func(int)) error {
+			_, loaded := visits.LoadOrStore(i, struct{}{})
+			require.False(t, loaded)
+			count.Add(1)
+			// Every node links to the next ten, so most are emitted many times
+			for j := i + 1; j <= i+10 && j < 100; j++ {
+				emit(j)
+			}
+			return nil
+		}, 0, 0, 1)
+		require.NoError(t, err)
+		require.Equal(t, int64(100), count.Load())
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		t.Parallel()
+		var mu sync.Mutex
+		running := map[int]int{}
+		var total, peak, groupPeak int
+		f := NewFrontier(identity).
+			WithMaxGoroutines(5).
+			WithGroups(func(i int) string { return fmt.Sprint(i % 3) }).
+			WithMaxPerGroup(2)
+		seeds := make([]int, 60)
+		for i := range seeds {
+			seeds[i] = i
+		}
+		err := f.Run(context.Background(), func(ctx context.Context, i int, emit func(int)) error {
+			mu.Lock()
+			total++
+			running[i%3]++
+			if total > peak {
+				peak = total
+			}
+			if running[i%3] > groupPeak {
+				groupPeak = running[i%3]
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			total--
+			running[i%3]--
+			mu.Unlock()
+			return nil
+		}, seeds...)
+		require.NoError(t, err)
+		require.LessOrEqual(t, peak, 5)
+		require.Equal(t, 2, groupPeak)
+	})
+
+	t.Run("groups take turns", func(t *testing.T) {
+		t.Parallel()
+		var order []string
+		f := NewFrontier(identity).
+			WithMaxGoroutines(1).
+			WithGroups(func(i int) string {
+				if i < 10 {
+					return "a"
+				}
+				return "b"
+			})
+		err := f.Run(context.Background(), func(ctx context.Context, i int, emit func(int)) error {
+			if i < 10 {
+				order = append(order, "a")
+			} else {
+				order = append(order, "b")
+			}
+			return nil
+		}, 0, 1, 2, 3, 10, 11)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b", "a", "b", "a", "a"}, order)
+	})
+
+	t.Run("group interval", func(t *testing.T) {
+		t.Parallel()
+		const interval = 20 * time.Millisecond
+		var mu sync.Mutex
+		starts := map[string][]time.Time{}
+		group := func(i int) string { return fmt.Sprint(i % 2) }
+		f := NewFrontier(identity).
+			WithMaxGoroutines(4).
+			WithGroups(group).
+			WithGroupInterval(interval)
+		begin := time.Now()
+		err := f.Run(context.Background(), func(ctx context.Context, i int, emit func(int)) error {
+			mu.Lock()
+			starts[group(i)] = append(starts[group(i)], time.Now())
+			mu.Unlock()
+			return nil
+		}, 0, 1, 2, 3, 4, 5)
+		require.NoError(t, err)
+		require.Len(t, starts, 2)
+		for _, times := range starts {
+			// The items of a group are started an interval apart, so the
+			// last one cannot start before two intervals have passed
+			require.Len(t, times, 3)
+			require.GreaterOrEqual(t, times[2].Sub(begin), 2*interval)
+		}
+		// The groups do not wait for each other
+		require.Less(t, starts["1"][0].Sub(starts["0"][0]), interval)
+	})
+
+	t.Run("errors are collected", func(t *testing.T) {
+		t.Parallel()
+		err1 := errors.New("err1")
+		err2 := errors.New("err2")
+		var count atomic.Int64
+		f := NewFrontier(identity)
+		err := f.Run(context.Background(), func(ctx context.Context, i int, emit func(int)) error {
+			count.Add(1)
+			switch i {
+			case 1:
+				emit(2)
+				return err1
+			case 2:
+				emit(3)
+				return err2
+			}
+			return nil
+		}, 1)
+		require.ErrorIs(t, err, err1)
+		require.ErrorIs(t, err, err2)
+		var errs conc.Errors
+		require.ErrorAs(t, err, &errs)
+		require.Equal(t, 2, errs.Len())
+		require.Equal(t, int64(3), count.Load())
+	})
+
+	t.Run("cancellation drains", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var active, started atomic.Int64
+		f := NewFrontier(identity).WithMaxGoroutines(3)
+		err := f.Run(ctx, func(ctx context.Context, i int, emit func(int)) error {
+			started.Add(1)
+			active.Add(1)
+			defer active.Add(-1)
+			if i == 5 {
+				cancel()
+				<-ctx.Done()
+				// Items emitted after cancellation are dropped
+				emit(i + 100)
+				return ctx.Err()
+			}
+			emit(i + 1)
+			return nil
+		}, 0)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Zero(t, active.Load())
+		require.Equal(t, int64(6), started.Load())
+	})
+
+	t.Run("canceled before start", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var count atomic.Int64
+		f := NewFrontier(identity)
+		err := f.Run(ctx, func(ctx context.Context, i int, emit func(int)) error {
+			count.Add(1)
+			return nil
+		}, 1, 2, 3)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Zero(t, count.Load())
+	})
+
+	t.Run("panics are propagated", func(t *testing.T) {
+		t.Parallel()
+		f := NewFrontier(identity).WithMaxGoroutines(2)
+		var canceled atomic.Bool
+		require.Panics(t, func() {
+			_ = f.Run(context.Background(), func(ctx context.Context, i int, emit func(int)) error {
+				if i == 1 {
+					panic("super bad thing happened")
+				}
+				<-ctx.Done()
+				canceled.Store(true)
+				return nil
+			}, 0, 1)
+		})
+		require.True(t, canceled.Load())
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { NewFrontier(identity).WithMaxGoroutines(0) })
+		require.Panics(t, func() { NewFrontier(identity).WithMaxPerGroup(0) })
+		require.Panics(t, func() { NewFrontier(identity).WithGroupInterval(-time.Second) })
+	})
+}
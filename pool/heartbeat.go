@@ -0,0 +1,197 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sourcegraph/conc"
+)
+
+// SilentTask describes a running task that has not called conc.Heartbeat for
+// longer than the heartbeat timeout. See ContextPool.SilentTasks.
+type SilentTask struct {
+	// Name is the name passed to GoNamed, or "" if the task was submitted
+	// with Go.
+	Name string
+	// Index is the index of the task in the order tasks were submitted.
+	Index int
+	// Started is when the task started running.
+	Started time.Time
+	// LastHeartbeat is when the task last called conc.Heartbeat, or when it
+	// started if it never has.
+	LastHeartbeat time.Time
+}
+
+// SilentTaskError is the error of a task that was canceled because it did not
+// call conc.Heartbeat within the heartbeat timeout. See
+// ContextPool.WithSilentTaskCancellation.
+type SilentTaskError struct {
+	// Timeout is the heartbeat timeout that was exceeded.
+	Timeout time.Duration
+	// LastHeartbeat is when the task last called conc.Heartbeat, or when it
+	// started if it never has.
+	LastHeartbeat time.Time
+}
+
+func (e *SilentTaskError) Error() string {
+	return fmt.Sprintf("task canceled after no heartbeat for %s", e.Timeout)
+}
+
+// Unwrap returns context.Canceled, since the task was stopped by canceling
+// its context.
+func (e *SilentTaskError) Unwrap() error {
+	return context.Canceled
+}
+
+// heartbeatTracker keeps track of the heartbeats of the running tasks of a
+// ContextPool configured with WithHeartbeatTimeout.
+type heartbeatTracker struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	running map[*runningTask]struct{}
+}
+
+type runningTask struct {
+	name    string
+	index   int
+	started time.Time
+	monitor *conc.HeartbeatMonitor
+}
+
+func (h *heartbeatTracker) add(t *runningTask) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.running == nil {
+		h.running = make(map[*runningTask]struct{})
+	}
+	h.running[t] = struct{}{}
+}
+
+func (h *heartbeatTracker) remove(t *runningTask) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.running, t)
+}
+
+func (h *heartbeatTracker) silent() []SilentTask {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var silent []SilentTask
+	for t := range h.running {
+		last := t.monitor.Last()
+		if time.Since(last) >= h.timeout {
+			silent = append(silent, SilentTask{
+				Name:          t.name,
+				Index:         t.index,
+				Started:       t.started,
+				LastHeartbeat: last,
+			})
+		}
+	}
+	sort.Slice(silent, func(i, j int) bool { return silent[i].Index < silent[j].Index })
+	return silent
+}
+
+// withHeartbeat wraps f so that its heartbeats are tracked, if the pool is
+// configured with WithHeartbeatTimeout.
+func (g *ContextPool) withHeartbeat(name string, index int, f func(ctx context.Context) error) func(ctx context.Context) error {
+	h := g.heartbeats
+	if h == nil {
+		return f
+	}
+	return func(ctx context.Context) error {
+		t := &runningTask{
+			name:    name,
+			index:   index,
+			started: time.Now(),
+			monitor: conc.NewHeartbeatMonitor(),
+		}
+		h.add(t)
+		defer h.remove(t)
+
+		ctx = conc.WithHeartbeatMonitor(ctx, t.monitor)
+		if !g.cancelSilent {
+			return f(ctx)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// The watchdog checks for a heartbeat every time the timeout could
+		// have expired, so no goroutine is needed while the task runs.
+		var (
+			mu       sync.Mutex
+			watchdog *time.Timer
+			silenced atomic.Bool
+		)
+		mu.Lock()
+		watchdog = time.AfterFunc(h.timeout, func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if silence := time.Since(t.monitor.Last()); silence < h.timeout {
+				watchdog.Reset(h.timeout - silence)
+				return
+			}
+			silenced.Store(true)
+			cancel()
+		})
+		mu.Unlock()
+
+		err := f(ctx)
+
+		mu.Lock()
+		watchdog.Stop()
+		mu.Unlock()
+		if silenced.Load() {
+			return &SilentTaskError{
+				Timeout:       h.timeout,
+				LastHeartbeat: t.monitor.Last(),
+			}
+		}
+		return err
+	}
+}
+
+// SilentTasks returns the running tasks that have not called conc.Heartbeat
+// for longer than the timeout configured with WithHeartbeatTimeout, ordered
+// by index. Returns nil if the pool is not configured with
+// WithHeartbeatTimeout.
+func (p *ContextPool) SilentTasks() []SilentTask {
+	if p.heartbeats == nil {
+		return nil
+	}
+	return p.heartbeats.silent()
+}
+
+// WithHeartbeatTimeout configures the pool to track the heartbeats that tasks
+// report by calling conc.Heartbeat with their context. A running task that
+// has gone d without a heartbeat, counting from when it started, is reported
+// by SilentTasks, which helps find tasks that are wedged talking to an
+// external system. Use WithSilentTaskCancellation to also cancel such tasks.
+// Panics if d <= 0.
+func (p *ContextPool) WithHeartbeatTimeout(d time.Duration) *ContextPool {
+	if d <= 0 {
+		panic("heartbeat timeout must be greater than zero")
+	}
+	p.heartbeats = &heartbeatTracker{timeout: d}
+	return p
+}
+
+// WithSilentTaskCancellation configures the pool to cancel the context of
+// each task that goes longer than the timeout configured with
+// WithHeartbeatTimeout without a heartbeat. The task fails with a
+// *SilentTaskError, which cancels the other tasks like any other error.
+// Has no effect without WithHeartbeatTimeout.
+func (p *ContextPool) WithSilentTaskCancellation() *ContextPool {
+	p.cancelSilent = true
+	return p
+}
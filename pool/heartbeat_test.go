@@ -0,0 +1,85 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc"
+)
+
+func TestHeartbeatTimeout(t *testing.T) {
+	t.Parallel()
+
+	bgctx := context.Background()
+
+	t.Run("reports silent tasks", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithContext(bgctx).WithHeartbeatTimeout(20 * time.Millisecond)
+		stop := make(chan struct{})
+		p.Go(func(ctx context.Context) error {
+			for {
+				select {
+				case <-stop:
+					return nil
+				case <-time.After(time.Millisecond):
+					conc.Heartbeat(ctx)
+				}
+			}
+		})
+		p.GoNamed("wedged", func(ctx context.Context) error {
+			<-stop
+			return nil
+		})
+
+		require.Eventually(t, func() bool { return len(p.SilentTasks()) > 0 }, time.Second, time.Millisecond)
+		silent := p.SilentTasks()
+		require.Len(t, silent, 1)
+		require.Equal(t, "wedged", silent[0].Name)
+		require.Equal(t, 1, silent[0].Index)
+
+		close(stop)
+		require.NoError(t, p.Wait())
+		require.Empty(t, p.SilentTasks())
+	})
+
+	t.Run("cancels silent tasks", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithContext(bgctx).WithHeartbeatTimeout(20 * time.Millisecond).WithSilentTaskCancellation()
+		p.Go(func(ctx context.Context) error {
+			for i := 0; i < 10; i++ {
+				time.Sleep(5 * time.Millisecond)
+				conc.Heartbeat(ctx)
+			}
+			return ctx.Err()
+		})
+		p.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		err := p.Wait()
+		var silentErr *SilentTaskError
+		require.True(t, errors.As(err, &silentErr))
+		require.Equal(t, 20*time.Millisecond, silentErr.Timeout)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("without a timeout", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithContext(bgctx)
+		p.Go(func(ctx context.Context) error {
+			conc.Heartbeat(ctx)
+			return nil
+		})
+		require.NoError(t, p.Wait())
+		require.Nil(t, p.SilentTasks())
+		require.Panics(t, func() { New().WithContext(bgctx).WithHeartbeatTimeout(0) })
+	})
+}
@@ -0,0 +1,83 @@
+package pool
+
+import (
+	"context"
+)
+
+type laneKey struct{}
+
+// lane tracks which lane a task of a pool configured with WithIOLane is
+// running in. It is only accessed by the goroutine running the task.
+type lane struct {
+	pool *ContextPool
+	// depth is the number of calls to EnterIO without a matching ExitIO
+	depth int
+}
+
+// EnterIO moves the task that was passed ctx from the CPU lane of its pool to
+// the IO lane, so that another task can use its CPU slot while it is blocked
+// on IO. It blocks until there is room in the IO lane. Every call to EnterIO
+// must be followed by a call to ExitIO before the task returns:
+//
+//	p.Go(func(ctx context.Context) error {
+//		pool.EnterIO(ctx)
+//		resp, err := http.Get(url)
+//		pool.ExitIO(ctx)
+//		...
+//	})
+//
+// Nested calls are allowed, and only the outermost pair switches lanes.
+// EnterIO is a no-op if ctx does not belong to a task of a pool configured
+// with ContextPool.WithIOLane.
+func EnterIO(ctx context.Context) {
+	l, ok := ctx.Value(laneKey{}).(*lane)
+	if !ok {
+		return
+	}
+	l.depth++
+	if l.depth > 1 {
+		return
+	}
+	// Release the CPU slot first so that it is not held while waiting for
+	// room in the IO lane. The slot is also given up in the total weight of
+	// the running tasks, for the task that takes it over to be able to run.
+	p := &l.pool.errorPool.pool
+	p.limiter.release()
+	if p.weights != nil {
+		p.weights.release(1)
+	}
+	l.pool.ioLimiter.acquire()
+}
+
+// ExitIO moves the task that was passed ctx back from the IO lane of its pool
+// to the CPU lane, blocking until a CPU slot is free. See EnterIO.
+func ExitIO(ctx context.Context) {
+	l, ok := ctx.Value(laneKey{}).(*lane)
+	if !ok {
+		return
+	}
+	if l.depth == 0 {
+		panic("ExitIO called without a matching EnterIO")
+	}
+	l.depth--
+	if l.depth == 0 {
+		l.exit()
+	}
+}
+
+// reset returns the task to the CPU lane if it is still in the IO lane.
+func (l *lane) reset() {
+	if l.depth > 0 {
+		l.depth = 0
+		l.exit()
+	}
+}
+
+func (l *lane) exit() {
+	l.pool.ioLimiter.release()
+	p := &l.pool.errorPool.pool
+	p.limiter.acquire()
+	if p.weights != nil {
+		p.weights.acquire(1)
+	}
+}
@@ -0,0 +1,93 @@
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIOLane(t *testing.T) {
+	t.Parallel()
+
+	bgctx := context.Background()
+
+	// trackMax records the maximum value reached by cur.
+	trackMax := func(cur int64, max *atomic.Int64) {
+		for {
+			seen := max.Load()
+			if cur <= seen || max.CompareAndSwap(seen, cur) {
+				return
+			}
+		}
+	}
+
+	t.Run("IO does not hold CPU slots", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(1).WithContext(bgctx).WithIOLane(10)
+		var cpu, io, maxCPU, maxIO atomic.Int64
+		for i := 0; i < 10; i++ {
+			p.Go(func(ctx context.Context) error {
+				trackMax(cpu.Add(1), &maxCPU)
+				cpu.Add(-1)
+
+				EnterIO(ctx)
+				trackMax(io.Add(1), &maxIO)
+				time.Sleep(10 * time.Millisecond)
+				io.Add(-1)
+				ExitIO(ctx)
+
+				trackMax(cpu.Add(1), &maxCPU)
+				cpu.Add(-1)
+				return nil
+			})
+		}
+		require.NoError(t, p.Wait())
+		require.Equal(t, int64(1), maxCPU.Load())
+		require.Greater(t, maxIO.Load(), int64(1))
+	})
+
+	t.Run("IO lane is limited", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(4).WithContext(bgctx).WithIOLane(2)
+		var io, maxIO atomic.Int64
+		for i := 0; i < 8; i++ {
+			p.Go(func(ctx context.Context) error {
+				EnterIO(ctx)
+				defer ExitIO(ctx)
+				trackMax(io.Add(1), &maxIO)
+				time.Sleep(time.Millisecond)
+				io.Add(-1)
+				return nil
+			})
+		}
+		require.NoError(t, p.Wait())
+		require.Equal(t, int64(2), maxIO.Load())
+	})
+
+	t.Run("task returning in IO lane", func(t *testing.T) {
+		p := New().WithMaxGoroutines(1).WithContext(bgctx).WithIOLane(1)
+		for i := 0; i < 3; i++ {
+			p.Go(func(ctx context.Context) error {
+				EnterIO(ctx)
+				EnterIO(ctx)
+				ExitIO(ctx)
+				return nil
+			})
+		}
+		require.NoError(t, p.Wait())
+	})
+
+	t.Run("no-op outside IO lane pools", func(t *testing.T) {
+		EnterIO(bgctx)
+		ExitIO(bgctx)
+	})
+
+	t.Run("panics on invalid WithIOLane", func(t *testing.T) {
+		require.Panics(t, func() { New().WithContext(bgctx).WithIOLane(0) })
+	})
+}
@@ -0,0 +1,80 @@
+package pool
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockOSThread(t *testing.T) {
+	t.Parallel()
+
+	// stays reports whether the calling goroutine is still on the same
+	// thread after giving the scheduler chances to move it.
+	stays := func() bool {
+		tid := syscall.Gettid()
+		for i := 0; i < 5; i++ {
+			time.Sleep(100 * time.Microsecond)
+			if syscall.Gettid() != tid {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("tasks run on the thread of their worker", func(t *testing.T) {
+		t.Parallel()
+		p := New().
+			WithMaxGoroutines(2).
+			WithLockOSThread().
+			WithWorkerInit(func() (any, error) {
+				return syscall.Gettid(), nil
+			})
+		var mismatches int64
+		results := make(chan bool, 20)
+		for i := 0; i < 20; i++ {
+			p.GoWithWorkerState(func(state any) {
+				results <- state.(int) == syscall.Gettid() && stays()
+			})
+		}
+		p.Wait()
+		close(results)
+		for ok := range results {
+			if !ok {
+				mismatches++
+			}
+		}
+		require.Zero(t, mismatches)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines().WithLockOSThread()
+		results := make(chan bool, 10)
+		for i := 0; i < 10; i++ {
+			p.Go(func() {
+				results <- stays()
+			})
+		}
+		p.Wait()
+		close(results)
+		for ok := range results {
+			require.True(t, ok)
+		}
+	})
+
+	t.Run("context pool", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithContext(context.Background()).WithLockOSThread()
+		var ok bool
+		p.Go(func(ctx context.Context) error {
+			ok = stays()
+			return nil
+		})
+		require.NoError(t, p.Wait())
+		require.True(t, ok)
+	})
+}
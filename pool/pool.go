@@ -1,9 +1,14 @@
 package pool
 
 import (
+	"bytes"
 	"context"
+	"math"
 	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sourcegraph/conc"
 )
@@ -28,37 +33,907 @@ func New() *Pool {
 type Pool struct {
 	handle   conc.WaitGroup
 	limiter  limiter
-	tasks    chan func()
+	tasks    chan queuedTask
 	initOnce sync.Once
+
+	name   string
+	parent *Pool
+
+	// budgetParent is set by WithParentLimiter. Once the pool is
+	// initialized, budget is the limiter shared by the whole tree of pools,
+	// and freeSlot allows one worker to run without borrowing from it.
+	budgetParent *Pool
+	budget       limiter
+	freeSlot     limiter
+
+	// scheduled is set by WithScheduler
+	scheduled *schedulerMember
+
+	// indexed is the number of tasks submitted with Go, used to index tasks
+	indexed atomic.Int64
+	// goroutines is the number of goroutines running. See Goroutines.
+	goroutines atomic.Int64
+	// queued is the number of calls to Go waiting for the pool to accept
+	// their task. See LivePool.
+	queued atomic.Int64
+
+	onProgress func(done, total int)
+	progressMu sync.Mutex
+	submitted  int
+	completed  int
+
+	observer     func(TaskStats)
+	interceptors []Interceptor
+	// tracer is set by WithTracer, and traced numbers the traced tasks
+	tracer func(TraceEvent)
+	traced atomic.Int64
+	// sampler is set by WithOutcomeSampling
+	sampler *outcomeSampler
+
+	workerInit     func() (any, error)
+	workerTeardown func(any)
+
+	// waited is set once Wait or DrainContext is called, or Close if the
+	// pool is reusable
+	waited atomic.Bool
+
+	// reusable is set by WithReuse. Reusable pools count the active tasks so
+	// that Wait does not need to wait for the workers to exit, and catch the
+	// panics of each batch separately.
+	reusable    bool
+	active      sync.WaitGroup
+	batchPanics conc.PanicCatcher
+	// detectMisuse is set by WithMisuseDetection. When set, submitting counts
+	// the calls to Go in progress, and workers register their goroutine IDs.
+	detectMisuse bool
+	submitting   atomic.Int64
+
+	// reentrant is set by WithReentrancy. When set, running is the number
+	// of tasks running on workers, and blocked is the number of calls to Go
+	// waiting for a worker. See runInlineIfStuck.
+	reentrant bool
+	running   atomic.Int64
+	blocked   atomic.Int64
+
+	// lockOSThread is set by WithLockOSThread
+	lockOSThread bool
+	// idleTimeout is set by WithIdleShutdown
+	idleTimeout time.Duration
+
+	// blockingLimiter is set by WithMaxBlocking, to limit the number of
+	// tasks submitted with GoBlocking that run outside the pool's limit
+	blockingLimiter limiter
+
+	// weights limits the total weight of the running tasks to the pool's
+	// limit, for GoWeighted. It is nil for unlimited pools.
+	weights *weightedLimiter
+	// memory is set by WithMemoryBudget
+	memory *weightedLimiter
+
+	// unlimited is set by WithUnlimitedGoroutines
+	unlimited bool
+	// paused is set once Pause is called, so that tasks are no longer
+	// started directly by Go, even though the pool may have been resumed
+	paused atomic.Bool
+
+	mu sync.Mutex
+	// resumed is non-nil while the pool is paused. It is closed to wake any
+	// paused workers when the pool is resumed or starts draining.
+	resumed chan struct{}
+	// drained is set by DrainContext and is closed when its deadline passes.
+	drained   <-chan struct{}
+	unstarted []Task
+	// labeled holds the tasks submitted with a label that have not started
+	// or been canceled yet. See CancelWhere.
+	labeled    map[*labeledTask]struct{}
+	labeledSeq uint64
+	// workerIDs maps the goroutine IDs of running workers to their worker
+	// state if reentrant or detectMisuse is set
+	workerIDs map[uint64]*any
+}
+
+// Task is a task submitted to a Pool with Go.
+type Task func()
+
+// TaskFunc is the common form of the tasks of every kind of pool, as seen by
+// an Interceptor. Tasks that do not take a context are passed
+// context.Background(), and tasks that do not return an error return nil.
+type TaskFunc func(ctx context.Context) error
+
+// Interceptor wraps every task submitted to a pool. It is called with the
+// task when the task is submitted, and the TaskFunc it returns is run in
+// place of the task. See WithInterceptor.
+type Interceptor func(next TaskFunc) TaskFunc
+
+// queuedTask is a task waiting to be picked up by a worker. If state is
+// non-nil, the worker stores its worker state there before running f. See
+// WithWorkerInit.
+type queuedTask struct {
+	f func()
+	// task is the function submitted by the caller, which f runs along with
+	// the bookkeeping of the pool. See DrainContext.
+	task  Task
+	state *any
+	cost  taskCost
+	// labeled is set for a task submitted with a label. See CancelWhere.
+	labeled *labeledTask
+	// trace is set if the pool is traced. See WithTracer.
+	trace *taskTrace
+}
+
+// taskCost is what a task counts for against the limits of its pool.
+type taskCost struct {
+	// weight is the number of slots of the pool's limit the task takes. See
+	// GoWeighted.
+	weight int
+	// bytes is the estimated memory used by the task. See GoSized.
+	bytes int64
+}
+
+// unitCost is the cost of a task submitted with Go.
+var unitCost = taskCost{weight: 1}
+
+// workerInitError is stored as the worker state of a task if initializing the
+// worker failed, so that the task fails instead of running.
+type workerInitError struct {
+	err error
+}
+
+// TaskStats describes the execution of a single task. See WithTaskObserver.
+type TaskStats struct {
+	// Enqueued is when the task was submitted.
+	Enqueued time.Time
+	// Started is when the task started running.
+	Started time.Time
+	// Finished is when the task returned or panicked.
+	Finished time.Time
+	// Err is the error returned by the task, if any. It is always nil for
+	// tasks submitted to a Pool.
+	Err error
+	// Panicked is true if the task panicked.
+	Panicked bool
+}
+
+// QueueWait returns how long the task waited to start.
+func (s TaskStats) QueueWait() time.Duration {
+	return s.Started.Sub(s.Enqueued)
+}
+
+// Duration returns how long the task ran for.
+func (s TaskStats) Duration() time.Duration {
+	return s.Finished.Sub(s.Started)
 }
 
 // Go submits a task to be run in the pool.
 func (p *Pool) Go(f func()) {
+	if p.canGoDirect() {
+		p.goDirect(f)
+		return
+	}
+	p.goWithState(f, nil, unitCost)
+}
+
+// canGoDirect reports whether tasks can skip the queue and the bookkeeping
+// of submit, because the pool has no limit and none of the options that
+// need them.
+func (p *Pool) canGoDirect() bool {
+	return p.unlimited &&
+		len(p.interceptors) == 0 &&
+		!p.observed() &&
+		p.tracer == nil &&
+		p.onProgress == nil &&
+		p.budgetParent == nil &&
+		p.scheduled == nil &&
+		p.workerInit == nil &&
+		!p.lockOSThread &&
+		p.memory == nil &&
+		!p.reusable &&
+		!p.reentrant &&
+		!p.detectMisuse &&
+		!p.paused.Load()
+}
+
+// goDirect runs f in a new goroutine of its own, which costs little more
+// than starting the goroutine by hand with a sync.WaitGroup, since it needs
+// neither the pool to be initialized nor a closure of its own. The goroutine
+// is not counted by Goroutines, and the task is only numbered for its panics
+// if the pool has a name to report them with.
+func (p *Pool) goDirect(f func()) {
+	if p.waited.Load() {
+		panic("pool: Go called after Wait")
+	}
+	if p.name != "" || p.parent != nil {
+		f = p.asTask("", int(p.indexed.Add(1)-1), f)
+	}
+	p.handle.Go(f)
+}
+
+// GoWithWorkerState submits a task that is called with the state of the
+// goroutine that runs it, as returned by the function passed to
+// WithWorkerInit. The state is owned by that goroutine, so the task can use
+// it without synchronization, which makes the pool a bounded set of workers
+// each holding a resource, such as a session to a remote host. The state is
+// nil if the pool has no worker state. If initializing the goroutine fails,
+// the task panics with the error instead of running.
+func (p *Pool) GoWithWorkerState(f func(state any)) {
+	state := new(any)
+	p.goWithState(func() {
+		if initErr, ok := (*state).(workerInitError); ok {
+			panic(initErr.err)
+		}
+		f(*state)
+	}, state, unitCost)
+}
+
+// GoBlocking submits a task that spends most of its time blocked rather
+// than using the CPU, such as a call into a C library or a blocking system
+// call. Like the Go runtime does for a goroutine in a system call, the pool
+// runs such a task on a goroutine of its own that does not count toward the
+// pool's limit, nor toward the limits it shares with other pools, so that a
+// few blocked tasks do not stall a pool sized to the number of CPUs. The
+// number of these goroutines can be limited with WithMaxBlocking.
+//
+// Tasks submitted with GoBlocking are otherwise treated like other tasks:
+// they are waited for by Wait, their panics are propagated, and they are
+// counted by WithProgress and passed to interceptors and task observers.
+// They do not have a worker state.
+func (p *Pool) GoBlocking(f func()) {
+	p.submitBlocking(p.wrap(f), f)
+}
+
+// GoWeighted submits a task that counts as weight tasks against the pool's
+// limit, like an acquisition from a weighted semaphore, for tasks whose cost
+// varies, such as the memory they use when the limit is really a memory
+// budget. The task waits until the total weight of the running tasks leaves
+// room for its own, or until no other task is running if weight is larger
+// than the limit. Tasks waiting for room are started in the order they were
+// picked up by the pool's goroutines, so that heavy tasks are not starved by
+// lighter ones. Other tasks have a weight of 1.
+//
+// The weight only applies to the pool's own limit, not to the limits it
+// shares with other pools with WithParentLimiter or WithScheduler, and it is
+// ignored by pools without a limit. A task in the IO lane of a ContextPool
+// gives up a single slot of its weight. Panics if weight < 1.
+func (p *Pool) GoWeighted(weight int, f func()) {
+	if weight < 1 {
+		panic("task weight must be greater than zero")
+	}
+	p.goWithState(f, nil, taskCost{weight: weight})
+}
+
+// GoSized submits a task that is estimated to use bytes of memory while it
+// runs, which counts against the budget set with WithMemoryBudget. The task
+// waits until the estimated memory of the running tasks leaves room for its
+// own, or until no other sized task is running if bytes is larger than the
+// budget, so that a pool limited to avoid running out of memory admits tasks
+// by their footprint rather than by their number. Like the tasks waiting for
+// room for their weight, the tasks waiting for memory are started in order.
+//
+// Tasks submitted with Go, or with a pool without a budget, use no memory of
+// the budget. Panics if bytes < 0.
+func (p *Pool) GoSized(bytes int64, f func()) {
+	if bytes < 0 {
+		panic("task size must not be negative")
+	}
+	p.goWithState(f, nil, taskCost{weight: 1, bytes: bytes})
+}
+
+// goWithState is the implementation of Go. If state is non-nil, it is set to
+// the worker state before f is run.
+func (p *Pool) goWithState(f func(), state *any, cost taskCost) {
+	p.submit(p.wrap(f), f, state, cost, "")
+}
+
+// wrap prepares a task submitted with Go to be run, by applying the
+// interceptors and the task observer of the pool.
+func (p *Pool) wrap(f func()) func() {
+	return p.wrapNamed("", f)
+}
+
+// wrapNamed is like wrap, for a task with the given name.
+func (p *Pool) wrapNamed(name string, f func()) func() {
+	f = p.asTask(name, int(p.indexed.Add(1)-1), f)
+	if len(p.interceptors) > 0 {
+		task := f
+		intercepted := p.intercept(func(context.Context) error {
+			task()
+			return nil
+		})
+		f = func() { _ = intercepted(context.Background()) }
+	}
+	if p.observed() {
+		task := f
+		return p.withObserver(func() error {
+			task()
+			return nil
+		})
+	}
+	return f
+}
+
+// asTask wraps f with conc.RunTask, so that if f panics, the recovered panic
+// identifies the pool and the task by its name and index.
+func (p *Pool) asTask(name string, index int, f func()) func() {
+	return func() {
+		conc.RunTask(func() conc.TaskInfo {
+			return conc.TaskInfo{Pool: p.Name(), Name: name, Index: index}
+		}, f)
+	}
+}
+
+// asTaskErr is like asTask, for tasks that return an error.
+func (p *Pool) asTaskErr(name string, index int, f func() error) func() error {
+	return func() (err error) {
+		p.asTask(name, index, func() { err = f() })()
+		return err
+	}
+}
+
+// intercept wraps f with the pool's interceptors, so that the first
+// interceptor is the outermost.
+func (p *Pool) intercept(f TaskFunc) TaskFunc {
+	for i := len(p.interceptors) - 1; i >= 0; i-- {
+		f = p.interceptors[i](f)
+	}
+	return f
+}
+
+// interceptErr is like intercept, for tasks that do not take a context.
+func (p *Pool) interceptErr(f func() error) func() error {
+	if len(p.interceptors) == 0 {
+		return f
+	}
+	intercepted := p.intercept(func(context.Context) error {
+		return f()
+	})
+	return func() error {
+		return intercepted(context.Background())
+	}
+}
+
+// goErr is like Go, but the error returned by the task is reported to the
+// task observer. If state is non-nil, it is set to the worker state before f
+// is run. If label is non-empty, the task can be canceled by CancelWhere
+// until it starts.
+func (p *Pool) goErr(f func() error, state *any, cost taskCost, label string) {
+	if p.observed() {
+		p.submit(p.withObserver(f), nil, state, cost, label)
+		return
+	}
+	p.submit(func() { _ = f() }, nil, state, cost, label)
+}
+
+// goErrBlocking is like goErr, for a task submitted with GoBlocking.
+func (p *Pool) goErrBlocking(f func() error) {
+	if p.observed() {
+		p.submitBlocking(p.withObserver(f), nil)
+		return
+	}
+	p.submitBlocking(func() { _ = f() }, nil)
+}
+
+// withInitCheck wraps f so that it fails if the worker that picks it up could
+// not be initialized, returning the state pointer to pass to goErr along
+// with it, which is allocated if state is nil. It is a no-op if the pool has
+// no worker state.
+func (p *Pool) withInitCheck(f func() error, state *any) (func() error, *any) {
+	if p.workerInit == nil {
+		return f, state
+	}
+	if state == nil {
+		state = new(any)
+	}
+	return func() error {
+		if initErr, ok := (*state).(workerInitError); ok {
+			return initErr.err
+		}
+		return f()
+	}, state
+}
+
+// submit submits f, which runs task, to be run by a worker. task is what
+// DrainContext returns if f never starts, and is nil for the tasks of an
+// ErrorPool, which cannot be drained. If label is non-empty, the task can be
+// canceled by CancelWhere until it starts.
+func (p *Pool) submit(f func(), task Task, state *any, cost taskCost, label string) {
 	p.init()
 
+	if p.detectMisuse {
+		p.submitting.Add(1)
+		defer p.submitting.Add(-1)
+	}
+	if p.waited.Load() {
+		panic("pool: Go called after Wait")
+	}
+
+	if p.reusable || p.reentrant {
+		p.active.Add(1)
+	}
+
+	trace := p.newTrace(label)
+	f = trace.wrap(f)
+	var labeled *labeledTask
+	if label != "" {
+		labeled = p.addLabeled(label, trace)
+		f = p.startLabeled(labeled, f)
+	}
+	if p.onProgress != nil {
+		f = p.withProgress(f)
+	}
+
+	t := queuedTask{f: f, task: task, state: state, cost: cost, labeled: labeled, trace: trace}
+	if p.budget != nil {
+		p.submitBudgeted(t)
+		return
+	}
+
 	select {
 	case p.limiter <- struct{}{}:
-		// If we are below our limit, spawn a new worker rather
-		// than waiting for one to become available.
-		p.handle.Go(p.worker)
-
-		// We know there is a least one worker running, so wait
-		// for it to become available. This ensures we never spawn
-		// more workers than the number of tasks.
-		p.tasks <- f
-	case p.tasks <- f:
+		p.spawnWorker(t)
+		return
+	case p.tasks <- t:
 		// A worker is available and has accepted the task
 		return
+	default:
+	}
+
+	// The pool is saturated, so we have to wait for a worker, unless this is
+	// one of its own tasks and waiting might deadlock.
+	if p.runInlineIfStuck(t) {
+		return
+	}
+	defer p.doneWaiting()
+
+	p.queued.Add(1)
+	defer p.queued.Add(-1)
+	t.trace.emit(TraceQueued)
+	select {
+	case p.limiter <- struct{}{}:
+		p.spawnWorker(t)
+	case p.tasks <- t:
+		// A worker is available and has accepted the task
+	case <-t.labeled.canceledChan():
+		p.dropCanceled(t)
+	}
+}
+
+// hasQueued reports whether calls to Go are waiting for the pool to accept
+// their task.
+func (p *Pool) hasQueued() bool {
+	return p.queued.Load() > 0
+}
+
+// submitBlocking is like submit, for a task submitted with GoBlocking. The
+// task is run by a goroutine of its own, outside the limiter, unless the
+// limit set by WithMaxBlocking has been reached, in which case it is
+// submitted like any other task.
+func (p *Pool) submitBlocking(f func(), task Task) {
+	p.init()
+
+	if p.detectMisuse {
+		p.submitting.Add(1)
+		defer p.submitting.Add(-1)
+	}
+
+	if p.blockingLimiter != nil {
+		select {
+		case p.blockingLimiter <- struct{}{}:
+			inner := f
+			f = func() {
+				defer p.blockingLimiter.release()
+				inner()
+			}
+		default:
+			p.submit(f, task, nil, unitCost, "")
+			return
+		}
+	}
+
+	if p.waited.Load() {
+		panic("pool: Go called after Wait")
+	}
+	if p.reusable || p.reentrant {
+		p.active.Add(1)
+	}
+	f = p.newTrace("").wrap(f)
+	if p.onProgress != nil {
+		f = p.withProgress(f)
+	}
+
+	p.spawn(func() {
+		if !p.waitReady() {
+			p.addUnstarted(task)
+			return
+		}
+		// The task does not take a slot of the scheduler either, since it
+		// is not using the CPU it would share.
+		if p.reusable {
+			p.runBatchTask(f)
+			return
+		}
+		defer p.doneReentrant()
+		f()
+	})
+}
+
+// spawnWorker starts a new worker and hands it t. The caller must have
+// acquired a slot in the limiter for it.
+func (p *Pool) spawnWorker(t queuedTask) {
+	// If we are below our limit, spawn a new worker rather
+	// than waiting for one to become available. The task is
+	// handed to the worker as it starts, which ensures we never
+	// spawn more workers than the number of tasks, and that a
+	// worker configured to exit when idle cannot exit before
+	// receiving it.
+	p.spawn(func() { p.runWorker(&t) })
+}
+
+// Prewarm starts up to n workers ahead of the first tasks, so that a burst of
+// submissions does not pay for starting them. Like the workers started by
+// Go, they keep running until Wait is called, or until they have been idle
+// for the duration set by WithIdleShutdown. If the pool is configured with
+// WithWorkerInit, the workers are also initialized right away, rather than
+// when they receive their first task; a worker whose initialization fails
+// exits, and is replaced once a task needs it. Fewer than n workers are
+// started if the pool has fewer free slots, or, for a pool configured with
+// WithParentLimiter, if the budget has run out. Panics if n < 0, or if Wait
+// has been called.
+func (p *Pool) Prewarm(n int) {
+	if n < 0 {
+		panic("number of workers must not be negative")
+	}
+	p.init()
+	if p.waited.Load() {
+		panic("pool: Prewarm called after Wait")
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case p.limiter <- struct{}{}:
+		default:
+			return
+		}
+		if p.budget == nil {
+			p.spawn(p.prewarmedWorker)
+			continue
+		}
+		select {
+		case p.freeSlot <- struct{}{}:
+			p.spawn(p.budgetedWorker(p.freeSlot, nil))
+		case p.budget <- struct{}{}:
+			p.spawn(p.budgetedWorker(p.budget, nil))
+		default:
+			p.limiter.release()
+			return
+		}
+	}
+}
+
+// runInlineIfStuck is called before waiting for a worker to accept t. If
+// every running task of a pool configured with WithReentrancy is waiting to
+// submit a task, none of them will ever finish to accept the tasks. To
+// prevent that, when every worker is running a task, there are at least as
+// many callers waiting as running tasks, and the caller is one of the
+// workers, it runs t itself, with its own worker state, and
+// runInlineIfStuck returns true. Otherwise, the caller is counted as waiting
+// until it calls doneWaiting.
+func (p *Pool) runInlineIfStuck(t queuedTask) bool {
+	if !p.reentrant {
+		return false
+	}
+
+	blocked := p.blocked.Add(1)
+	running := p.running.Load()
+	if running < int64(len(p.limiter)) || blocked < running {
+		// A worker is about to look for a task, or at least one running
+		// task is not waiting, so it will finish
+		return false
+	}
+
+	id := goroutineID()
+	p.mu.Lock()
+	state, ok := p.workerIDs[id]
+	p.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	p.blocked.Add(-1)
+	if t.state != nil {
+		*t.state = *state
+	}
+	// The worker already holds a slot of the scheduler, if any, so the
+	// task must not wait for another one.
+	if p.reusable {
+		p.runBatchTask(t.f)
+	} else {
+		defer p.doneReentrant()
+		t.f()
+	}
+	return true
+}
+
+// doneWaiting stops counting the caller of runInlineIfStuck as waiting.
+func (p *Pool) doneWaiting() {
+	if p.reentrant {
+		p.blocked.Add(-1)
+	}
+}
+
+// submitBudgeted is like submit, but a new worker is only spawned if it can
+// also get a slot from the shared budget. Otherwise, the task is handed to a
+// worker that is already running.
+func (p *Pool) submitBudgeted(t queuedTask) {
+	select {
+	case p.limiter <- struct{}{}:
+	case p.tasks <- t:
+		return
+	default:
+		// The pool is saturated, so run the task in place if waiting might
+		// deadlock, as in submit.
+		if p.runInlineIfStuck(t) {
+			return
+		}
+		p.queued.Add(1)
+		t.trace.emit(TraceQueued)
+		select {
+		case p.limiter <- struct{}{}:
+			p.queued.Add(-1)
+			p.doneWaiting()
+		case p.tasks <- t:
+			p.queued.Add(-1)
+			p.doneWaiting()
+			return
+		case <-t.labeled.canceledChan():
+			p.queued.Add(-1)
+			p.doneWaiting()
+			p.dropCanceled(t)
+			return
+		}
+	}
+
+	// We are below our own limit, so now try to borrow from the budget.
+	// Selecting on p.tasks as well means that we never wait for the budget
+	// while one of our workers is available.
+	select {
+	case p.freeSlot <- struct{}{}:
+		p.spawn(p.budgetedWorker(p.freeSlot, &t))
+		return
+	case p.budget <- struct{}{}:
+		p.spawn(p.budgetedWorker(p.budget, &t))
+		return
+	case p.tasks <- t:
+		p.limiter.release()
+		return
+	default:
+	}
+
+	// The budget is exhausted, and the workers of this pool may be waiting
+	// for it too if they are submitting tasks themselves.
+	if p.runInlineIfStuck(t) {
+		p.limiter.release()
+		return
+	}
+	defer p.doneWaiting()
+
+	p.queued.Add(1)
+	defer p.queued.Add(-1)
+	t.trace.emit(TraceQueued)
+	select {
+	case p.freeSlot <- struct{}{}:
+		p.spawn(p.budgetedWorker(p.freeSlot, &t))
+	case p.budget <- struct{}{}:
+		p.spawn(p.budgetedWorker(p.budget, &t))
+	case p.tasks <- t:
+		p.limiter.release()
+	case <-t.labeled.canceledChan():
+		p.limiter.release()
+		p.dropCanceled(t)
 	}
 }
 
 // Wait cleans up spawned goroutines, propagating any panics that were
-// raised by a tasks.
+// raised by a tasks. Everything done by the tasks happens before Wait
+// returns, in the sense of the Go memory model, so tasks can write their
+// results to variables or to distinct elements of a slice without locking,
+// and the caller can read them once Wait has returned. This is not the case
+// for WaitContext and WaitTimeout when they return early.
 func (p *Pool) Wait() {
 	p.init()
+	if p.reusable {
+		p.waitBatch()
+		return
+	}
+	if p.reentrant {
+		// Wait for the tasks before closing the pool, since they may still
+		// submit more tasks.
+		p.checkNotInTask()
+		p.active.Wait()
+	}
+	p.beginWait()
+
+	defer p.unregister()
+	close(p.tasks)
+	p.handle.Wait()
+}
+
+// WaitContext is like Wait, but stops waiting when ctx is done, so that a hung
+// task cannot block the caller forever. It returns ctx.Err() if ctx is done
+// before all tasks have finished, in which case the remaining tasks keep
+// running in the background, and any panic they raise is passed to the
+// default panic handler rather than propagated. See
+// conc.SetDefaultPanicHandler. Like Wait, WaitContext closes the pool to new
+// tasks.
+func (p *Pool) WaitContext(ctx context.Context) error {
+	return waitContext(ctx, p.Wait)
+}
+
+// WaitTimeout is like WaitContext, but stops waiting after d.
+func (p *Pool) WaitTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return p.WaitContext(ctx)
+}
+
+// waitContext calls wait and blocks until it returns or ctx is done. If wait
+// returns first, any panic from it is propagated.
+func waitContext(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	var panicVal any
+	// This goroutine is intentionally not scoped: if ctx is done first, it is
+	// abandoned so that the caller can stop waiting.
+	go func() {
+		defer close(done)
+		defer func() { panicVal = recover() }()
+		wait()
+	}()
+
+	select {
+	case <-done:
+		if panicVal != nil {
+			panic(panicVal)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DrainContext is an alternative to Wait that gives up on tasks which have
+// not started by the time ctx is done. Like Wait, it closes the pool to new
+// tasks and propagates any panics. Tasks that are already running when ctx is
+// done are allowed to finish, but tasks that have not yet started will never
+// run and are returned so the caller can persist or resubmit them. The
+// returned tasks are the functions passed to Go, not bound to the pool, so
+// running them elsewhere does not report them to its observers or tracer.
+//
+// If any tasks were left unstarted, the returned error is ctx.Err().
+func (p *Pool) DrainContext(ctx context.Context) (unstarted []Task, err error) {
+	p.init()
+	p.beginWait()
+
+	p.mu.Lock()
+	p.drained = ctx.Done()
+	if p.resumed != nil {
+		// Wake paused workers so they notice the deadline
+		close(p.resumed)
+		p.resumed = make(chan struct{})
+	}
+	p.mu.Unlock()
 
+	defer p.unregister()
 	close(p.tasks)
 	p.handle.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.unstarted) > 0 {
+		return p.unstarted, ctx.Err()
+	}
+	return nil, nil
+}
+
+// addUnstarted records task as left unstarted by DrainContext.
+func (p *Pool) addUnstarted(task Task) {
+	if task == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unstarted = append(p.unstarted, task)
+}
+
+// beginWait marks the pool as closed to new tasks, panicking if that is a
+// misuse of the pool.
+func (p *Pool) beginWait() {
+	p.checkNotInTask()
+	if p.waited.Swap(true) {
+		panic("pool: Wait called more than once")
+	}
+	if !p.detectMisuse {
+		return
+	}
+	// Any call to Go that started after waited was set panics by itself, so
+	// this only catches calls that are still in progress.
+	if p.submitting.Load() > 0 {
+		panic("pool: Go called concurrently with Wait")
+	}
+}
+
+// checkNotInTask panics if misuse detection is enabled and the caller is one
+// of the pool's tasks.
+func (p *Pool) checkNotInTask() {
+	if !p.detectMisuse {
+		return
+	}
+	p.mu.Lock()
+	_, inTask := p.workerIDs[goroutineID()]
+	p.mu.Unlock()
+	if inTask {
+		panic("pool: Wait called from a task in the same pool, which would deadlock")
+	}
+}
+
+// waitBatch is the implementation of Wait for pools configured with
+// WithReuse. It waits for the submitted tasks rather than the workers.
+func (p *Pool) waitBatch() {
+	p.checkNotInTask()
+	if p.waited.Load() {
+		panic("pool: Wait called after Close")
+	}
+
+	p.active.Wait()
+
+	// No tasks are running, so it is safe to reset the panic catcher for
+	// the next batch.
+	recovered := p.batchPanics.Recovered()
+	p.batchPanics = conc.PanicCatcher{}
+	p.indexed.Store(0)
+	if recovered != nil {
+		recovered.Propagate()
+	}
+}
+
+// Close stops the goroutines of a pool configured with WithReuse, after
+// waiting for any submitted tasks to finish, and propagates their panics. The
+// pool cannot be used after calling Close. For other pools, Close is the same
+// as Wait.
+func (p *Pool) Close() {
+	p.init()
+	p.beginWait()
+
+	defer p.unregister()
+	close(p.tasks)
+	p.handle.Wait()
+	if p.reusable {
+		p.batchPanics.Repanic()
+	}
+}
+
+// Pause stops the pool from starting any new tasks until Resume is called.
+// Tasks that are already running are unaffected. While paused, Go will
+// still accept tasks until every worker is holding one, then block as it
+// would for a saturated pool. Wait will not return while the pool is paused.
+func (p *Pool) Pause() {
+	p.paused.Store(true)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+	}
+}
+
+// Resume undoes a call to Pause, allowing held and queued tasks to start.
+// Calling Resume on a pool that is not paused is a no-op.
+func (p *Pool) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+	}
 }
 
 // MaxGoroutines returns the maximum size of the pool.
@@ -66,6 +941,255 @@ func (p *Pool) MaxGoroutines() int {
 	return p.limiter.limit()
 }
 
+// Name returns the name of the pool qualified by the names of its parents,
+// separated by slashes (e.g. "root/ingest"). Unnamed pools are skipped.
+func (p *Pool) Name() string {
+	if p.parent == nil {
+		return p.name
+	}
+	parentName := p.parent.Name()
+	if parentName == "" {
+		return p.name
+	}
+	if p.name == "" {
+		return parentName
+	}
+	return parentName + "/" + p.name
+}
+
+// WithName sets the name of the pool. Names are used to organize pools
+// hierarchically in logging and metrics. See WithParent.
+func (p *Pool) WithName(name string) *Pool {
+	p.name = name
+	return p
+}
+
+// WithOptions applies the settings of opts that are set to the pool, as with
+// WithMaxGoroutines, WithName and WithProgress. Settings applied afterwards
+// override them. Panics if opts.MaxGoroutines < 0.
+func (p *Pool) WithOptions(opts conc.Options) *Pool {
+	if opts.MaxGoroutines != 0 {
+		p.WithMaxGoroutines(opts.MaxGoroutines)
+	}
+	if opts.Name != "" {
+		p.WithName(opts.Name)
+	}
+	if opts.OnProgress != nil {
+		p.WithProgress(opts.OnProgress)
+	}
+	return p
+}
+
+// WithParent sets the parent of the pool in the naming hierarchy. Panics if
+// doing so would introduce a cycle.
+func (p *Pool) WithParent(parent *Pool) *Pool {
+	for ancestor := parent; ancestor != nil; ancestor = ancestor.parent {
+		if ancestor == p {
+			panic("pool cannot be its own ancestor")
+		}
+	}
+	p.parent = parent
+	return p
+}
+
+// WithParentLimiter configures the pool to borrow its goroutines from the
+// concurrency budget of parent, so that a whole tree of pools, such as a pool
+// whose tasks each create an inner pool, respects one global limit. The
+// budget is the limit set by WithMaxGoroutines on the root of the tree. Each
+// pool still never runs more goroutines than its own limit.
+//
+// To avoid deadlocks when every slot in the budget is held by a task waiting
+// on an inner pool, each pool may always run one goroutine without borrowing,
+// on behalf of the task that created it. Tasks in the inner pool are run by
+// that goroutine as long as the budget is exhausted.
+func (p *Pool) WithParentLimiter(parent *Pool) *Pool {
+	p.budgetParent = parent
+	return p
+}
+
+// WithScheduler registers the pool with s, so that its tasks only start when
+// s has a free slot, and share the limit of s fairly with the other pools
+// registered with it in proportion to their weights. The pool's own limit set
+// by WithMaxGoroutines still applies. Tasks should not wait for tasks in other
+// pools registered with the same Scheduler, which could deadlock once every
+// slot is taken by a waiting task. Panics if weight < 1.
+func (p *Pool) WithScheduler(s *Scheduler, weight int) *Pool {
+	if weight < 1 {
+		panic("scheduler weight must be greater than zero")
+	}
+	p.scheduled = &schedulerMember{scheduler: s, weight: weight}
+	return p
+}
+
+// WithReuse configures the pool so that Wait can be called more than once.
+// Each call to Wait waits for the tasks submitted since the previous call,
+// propagating any of their panics, but keeps the pool's goroutines running so
+// that the next batch of tasks can reuse them. Close must be called once the
+// pool is no longer needed to clean up its goroutines.
+//
+// Go must not be called concurrently with Wait. Context pools cannot be
+// reused, since their context cannot be reset once canceled.
+func (p *Pool) WithReuse() *Pool {
+	p.reusable = true
+	return p
+}
+
+// WithInterceptor adds an interceptor that wraps every task submitted to the
+// pool, so that cross-cutting concerns such as logging, metrics, or recovery
+// policies can be applied once rather than at every call site:
+//
+//	p := pool.New().WithErrors().WithInterceptor(func(next pool.TaskFunc) pool.TaskFunc {
+//		return func(ctx context.Context) error {
+//			start := time.Now()
+//			err := next(ctx)
+//			log.Printf("task took %s: %v", time.Since(start), err)
+//			return err
+//		}
+//	})
+//
+// Interceptors apply in the order they are added, with the first being the
+// outermost. The error returned by an interceptor is treated as the error of
+// the task, except in a Pool, whose tasks cannot fail, where it is ignored.
+func (p *Pool) WithInterceptor(i Interceptor) *Pool {
+	p.interceptors = append(p.interceptors, i)
+	return p
+}
+
+// WithWorkerInit configures the pool to call init in each of its goroutines
+// before it runs its first task, so that each goroutine can own an expensive
+// resource, like a database connection, for its whole lifetime rather than
+// acquiring one for every task. The state returned by init is passed to tasks
+// submitted with GoWithWorkerState, and to context pool tasks with their
+// context, from which it can be retrieved with WorkerState. See also
+// WithWorkerTeardown.
+//
+// If init returns an error, the task that the goroutine was about to run
+// fails with that error instead of running, or panics with it if it is a task
+// that cannot fail, and the goroutine exits. The next task is then given to a
+// new goroutine, which calls init again.
+func (p *Pool) WithWorkerInit(init func() (state any, err error)) *Pool {
+	p.workerInit = init
+	return p
+}
+
+// WithWorkerTeardown configures the pool to call teardown with the state
+// returned by the function passed to WithWorkerInit when each goroutine of
+// the pool exits, including after a task panics. It is not called for
+// goroutines whose initialization failed.
+func (p *Pool) WithWorkerTeardown(teardown func(state any)) *Pool {
+	p.workerTeardown = teardown
+	return p
+}
+
+// WithMisuseDetection configures the pool to panic with a descriptive message
+// when it is misused in a way that would otherwise deadlock or fail
+// nondeterministically: calling Wait from inside one of the pool's own tasks,
+// or calling Go concurrently with Wait. Detection adds overhead to every
+// task, so it is intended for tests and debugging.
+//
+// Calling Go after Wait, or calling Wait more than once, always panics,
+// regardless of this option.
+func (p *Pool) WithMisuseDetection() *Pool {
+	p.detectMisuse = true
+	return p
+}
+
+// WithReentrancy configures the pool so that its tasks can submit more tasks
+// to it, for example to traverse a tree in parallel, without deadlocking.
+// Otherwise, a task that calls Go on its own pool waits for a worker like
+// any other caller, which deadlocks once every worker is doing the same.
+//
+// With this option, a task that would have to wait while every worker
+// is running a task that is also waiting runs the new task itself instead,
+// before Go returns, with the worker state of its own goroutine, if any.
+// Tasks are still never run by more than the pool's maximum number of
+// goroutines. Only calls to Go from the goroutine running a task are
+// recognized as coming from the pool, not calls from goroutines the task
+// spawns. Wait also waits for the tasks submitted by tasks while it is
+// waiting. This adds a small overhead to every task.
+func (p *Pool) WithReentrancy() *Pool {
+	p.reentrant = true
+	return p
+}
+
+// WithLockOSThread configures each goroutine of the pool to lock itself to
+// its OS thread with runtime.LockOSThread for as long as it runs, so that
+// all the tasks it runs, as well as the functions passed to WithWorkerInit
+// and WithWorkerTeardown, run on the same thread. This is needed by tasks
+// that call C libraries with thread-local state, such as OpenGL contexts.
+// Combined with WithWorkerInit, each thread can set up its own state and
+// reuse it for every task it runs. A task that must run on a particular
+// thread should not be submitted to a pool, since tasks are run by
+// whichever goroutine is free.
+//
+// The pool never runs tasks on other goroutines with this option, even
+// with WithUnlimitedGoroutines. Tasks that spawn goroutines of their own
+// must lock them separately.
+func (p *Pool) WithLockOSThread() *Pool {
+	p.lockOSThread = true
+	return p
+}
+
+// WithIdleShutdown configures each worker of the pool to exit once it has
+// waited for a task for d, so that a pool that is idle for long stops
+// holding goroutines. Workers are started again as tasks are submitted, as
+// they are for a new pool; if the pool is configured with WithWorkerInit,
+// each new worker initializes again, and the state of an exiting worker is
+// passed to the function set with WithWorkerTeardown. Without this option,
+// workers keep running until Wait is called, which only matters for pools
+// that are used for long, such as the ones configured with WithReuse.
+// Panics if d <= 0.
+func (p *Pool) WithIdleShutdown(d time.Duration) *Pool {
+	if d <= 0 {
+		panic("idle timeout must be greater than zero")
+	}
+	p.idleTimeout = d
+	return p
+}
+
+// WithMemoryBudget limits the estimated memory of the tasks submitted with
+// GoSized that run at once to bytes, in addition to the limit on the number
+// of goroutines, which can be removed with WithUnlimitedGoroutines when the
+// budget is the only limit that matters. Panics if bytes < 1.
+func (p *Pool) WithMemoryBudget(bytes int64) *Pool {
+	if bytes < 1 {
+		panic("memory budget must be greater than zero")
+	}
+	p.memory = &weightedLimiter{size: bytes}
+	return p
+}
+
+// WithMaxBlocking limits the number of tasks submitted with GoBlocking that
+// run outside the pool's limit at once. Once the limit is reached, the
+// tasks submitted with GoBlocking are run by the pool's goroutines like any
+// other task until one of the running ones finishes. By default, there is
+// no limit. Panics if n < 1.
+func (p *Pool) WithMaxBlocking(n int) *Pool {
+	if n < 1 {
+		panic("max blocking tasks in a pool must be greater than zero")
+	}
+	p.blockingLimiter = make(limiter, n)
+	return p
+}
+
+// WithProgress configures the pool to call f every time a task completes,
+// with the number of completed tasks and the number of tasks submitted so
+// far. Calls are never concurrent, and done is strictly increasing. Tasks
+// that panic are counted as completed.
+func (p *Pool) WithProgress(f func(done, total int)) *Pool {
+	p.onProgress = f
+	return p
+}
+
+// WithTaskObserver configures the pool to call f with the timings and
+// outcome of every task once it finishes, including tasks that panic. f is
+// called from the goroutine that ran the task, so it may be called
+// concurrently. Tasks that never start are not reported.
+func (p *Pool) WithTaskObserver(f func(TaskStats)) *Pool {
+	p.observer = f
+	return p
+}
+
 // WithMaxGoroutines limits the number of goroutines in a pool.
 // Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
 func (p *Pool) WithMaxGoroutines(n int) *Pool {
@@ -73,6 +1197,22 @@ func (p *Pool) WithMaxGoroutines(n int) *Pool {
 		panic("max goroutines in a pool must be greater than zero")
 	}
 	p.limiter = make(limiter, n)
+	p.unlimited = false
+	return p
+}
+
+// WithUnlimitedGoroutines removes the limit on the number of goroutines in a
+// pool, so that every task starts as soon as it is submitted. MaxGoroutines
+// then returns math.MaxInt. When no other options that change how tasks are
+// run are set, such as WithTaskObserver or WithReuse, Pool.Go starts each
+// task in a goroutine of its own directly, which makes pools cheap enough
+// even for very short tasks. Such goroutines are not counted by Goroutines,
+// and the panics of their tasks only carry a conc.TaskInfo if the pool has a
+// name.
+func (p *Pool) WithUnlimitedGoroutines() *Pool {
+	// A channel of empty structs needs no buffer, whatever its capacity
+	p.limiter = make(limiter, math.MaxInt)
+	p.unlimited = true
 	return p
 }
 
@@ -85,7 +1225,21 @@ func (p *Pool) init() {
 			p.limiter = make(limiter, runtime.GOMAXPROCS(0))
 		}
 
-		p.tasks = make(chan func())
+		if p.budgetParent != nil {
+			p.budgetParent.init()
+			p.budget = p.budgetParent.budget
+			if p.budget == nil {
+				p.budget = p.budgetParent.limiter
+			}
+			p.freeSlot = make(limiter, 1)
+		}
+
+		if !p.unlimited {
+			p.weights = &weightedLimiter{size: int64(p.limiter.limit())}
+		}
+
+		p.tasks = make(chan queuedTask)
+		p.register()
 	})
 }
 
@@ -100,24 +1254,294 @@ func (p *Pool) WithErrors() *ErrorPool {
 // WithContext converts the pool to a ContextPool for tasks that should
 // be canceled on first error.
 func (p *Pool) WithContext(ctx context.Context) *ContextPool {
-	ctx, cancel := context.WithCancel(ctx)
+	if p.reusable {
+		panic("context pools cannot be reused")
+	}
+	parent := ctx
+	ctx, drain := conc.WithDraining(ctx)
+	ctx, cancel := withCancelCause(ctx)
 	return &ContextPool{
 		errorPool: *p.WithErrors(),
 		ctx:       ctx,
 		cancel:    cancel,
+		parent:    parent,
+		drain:     drain,
+	}
+}
+
+// withProgress wraps f so that completing it reports progress.
+func (p *Pool) withProgress(f func()) func() {
+	p.progressMu.Lock()
+	p.submitted++
+	p.progressMu.Unlock()
+
+	return func() {
+		defer func() {
+			p.progressMu.Lock()
+			p.completed++
+			p.onProgress(p.completed, p.submitted)
+			p.progressMu.Unlock()
+		}()
+		f()
+	}
+}
+
+// withObserver wraps f so that its execution is reported to the task
+// observer. It must be called when the task is submitted.
+func (p *Pool) withObserver(f func() error) func() {
+	enqueued := time.Now()
+	return func() {
+		stats := TaskStats{
+			Enqueued: enqueued,
+			Started:  time.Now(),
+			Panicked: true,
+		}
+		defer func() {
+			stats.Finished = time.Now()
+			p.observe(stats)
+		}()
+		stats.Err = f()
+		stats.Panicked = false
 	}
 }
 
-func (p *Pool) worker() {
+// prewarmedWorker is a worker started by Prewarm, which initializes itself
+// before receiving its first task.
+func (p *Pool) prewarmedWorker() {
+	p.runWorker(nil)
+}
+
+// runWorker runs first, then the tasks handed to a worker until the pool is
+// closed, or until the worker has been idle for the duration set by
+// WithIdleShutdown. If first is nil, the worker was started ahead of the
+// tasks by Prewarm, and is initialized before receiving one.
+func (p *Pool) runWorker(first *queuedTask) {
 	// The only time this matters is if the task panics.
 	// This makes it possible to spin up new workers in that case.
 	defer p.limiter.release()
 
-	for f := range p.tasks {
-		f()
+	if p.lockOSThread {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	}
+
+	var (
+		state       any
+		initialized bool
+	)
+
+	if p.reentrant || p.detectMisuse {
+		// Register the worker so that its tasks can be recognized when
+		// they use the pool themselves.
+		id := goroutineID()
+		p.mu.Lock()
+		if p.workerIDs == nil {
+			p.workerIDs = make(map[uint64]*any)
+		}
+		p.workerIDs[id] = &state
+		p.mu.Unlock()
+		defer func() {
+			p.mu.Lock()
+			delete(p.workerIDs, id)
+			p.mu.Unlock()
+		}()
+	}
+	if first == nil && p.workerInit != nil {
+		var err error
+		state, err = p.workerInit()
+		if err != nil {
+			// No task is waiting for the worker to fail it, so exit and
+			// leave the slot to a new worker, which initializes once a
+			// task needs it.
+			return
+		}
+		if p.workerTeardown != nil {
+			defer p.workerTeardown(state)
+		}
+		initialized = true
+	}
+	for {
+		t, ok := p.nextTask(first)
+		if !ok {
+			return
+		}
+		first = nil
+		if !p.waitReady() {
+			if !t.labeled.isCanceled() {
+				p.addUnstarted(t.task)
+			}
+			continue
+		}
+
+		// Workers are initialized when they receive their first task, so
+		// that the task can fail if initialization does.
+		if !initialized && p.workerInit != nil {
+			var err error
+			state, err = p.workerInit()
+			if err != nil {
+				// Fail the task, then exit so that the next task is given
+				// to a new worker, which gets a new chance to initialize.
+				p.runCounted(p.failedTask(t, err))
+				return
+			}
+			if p.workerTeardown != nil {
+				defer p.workerTeardown(state)
+			}
+		}
+		initialized = true
+
+		if t.state != nil {
+			*t.state = state
+		}
+		p.runAdmitted(t)
+	}
+}
+
+// nextTask returns first if it is non-nil, or else receives the next task
+// for a worker. It returns false once the pool is closed, or once the worker
+// has been idle for the duration set by WithIdleShutdown, in which case the
+// worker exits and releases its slot.
+func (p *Pool) nextTask(first *queuedTask) (queuedTask, bool) {
+	if first != nil {
+		return *first, true
+	}
+	if p.idleTimeout == 0 {
+		t, ok := <-p.tasks
+		return t, ok
+	}
+	idle := time.NewTimer(p.idleTimeout)
+	defer idle.Stop()
+	select {
+	case t, ok := <-p.tasks:
+		return t, ok
+	case <-idle.C:
+		return queuedTask{}, false
+	}
+}
+
+// runAdmitted runs t once the total weight and the total memory of the
+// running tasks leave room for it. See GoWeighted and GoSized.
+func (p *Pool) runAdmitted(t queuedTask) {
+	if t.labeled.isCanceled() {
+		p.dropCanceled(t)
+		return
+	}
+	// Wait for memory first, so that the task does not hold slots of the
+	// limit while it cannot run.
+	if p.memory != nil && t.cost.bytes > 0 {
+		bytes := p.memory.fit(t.cost.bytes)
+		p.memory.acquire(bytes)
+		defer p.memory.release(bytes)
+	}
+	if p.weights != nil {
+		weight := p.weights.fit(int64(t.cost.weight))
+		p.weights.acquire(weight)
+		defer p.weights.release(weight)
+	}
+	p.runCounted(t.f)
+}
+
+// runCounted runs f, counting it as running for runInlineIfStuck.
+func (p *Pool) runCounted(f func()) {
+	if !p.reentrant {
+		p.run(f)
+		return
+	}
+	p.running.Add(1)
+	defer p.running.Add(-1)
+	defer p.doneReentrant()
+	p.run(f)
+}
+
+// doneReentrant marks a task of a pool configured with WithReentrancy as
+// done, unless the pool is reusable, in which case runBatchTask does.
+func (p *Pool) doneReentrant() {
+	if p.reentrant && !p.reusable {
+		p.active.Done()
+	}
+}
+
+// run runs a task in the current worker.
+func (p *Pool) run(f func()) {
+	if p.scheduled != nil {
+		p.scheduled.acquire()
+		defer p.scheduled.release()
+	}
+	if p.reusable {
+		p.runBatchTask(f)
+		return
+	}
+	f()
+}
+
+// failedTask returns the function to run in place of t if initializing the
+// worker failed with err. Tasks with a state pointer fail with err, and other
+// tasks panic with it.
+func (p *Pool) failedTask(t queuedTask, err error) func() {
+	if t.state == nil {
+		return func() { panic(err) }
+	}
+	*t.state = workerInitError{err}
+	return t.f
+}
+
+// runBatchTask runs a task of a reusable pool. Its panic is caught, rather
+// than ending the worker, so that it can be propagated by the call to Wait
+// for its batch.
+func (p *Pool) runBatchTask(f func()) {
+	defer p.active.Done()
+	conc.ReportPanic(p.batchPanics.TryRecovered(f))
+}
+
+// budgetedWorker returns a worker that runs first, as for runWorker, and
+// releases slot, which it borrowed from the shared budget, when it exits.
+func (p *Pool) budgetedWorker(slot limiter, first *queuedTask) func() {
+	return func() {
+		defer slot.release()
+		p.runWorker(first)
+	}
+}
+
+// waitReady blocks until the pool is not paused. It returns false if the
+// deadline passed to DrainContext has passed, in which case the task
+// should not be started.
+func (p *Pool) waitReady() bool {
+	for {
+		p.mu.Lock()
+		resumed, drained := p.resumed, p.drained
+		p.mu.Unlock()
+
+		select {
+		case <-drained:
+			return false
+		default:
+		}
+
+		if resumed == nil {
+			return true
+		}
+
+		select {
+		case <-resumed:
+		case <-drained:
+		}
 	}
 }
 
+// goroutineID returns the ID of the current goroutine, as reported in stack
+// traces. It is slow, so it is only used for misuse detection.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	// The stack starts with "goroutine <id> ["
+	fields := bytes.Fields(buf[:n])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+	return id
+}
+
 type limiter chan struct{}
 
 func (l limiter) limit() int {
@@ -131,3 +1555,62 @@ func (l limiter) acquire() {
 func (l limiter) release() {
 	<-l
 }
+
+// weightedLimiter limits the total weight of the tasks running at once.
+// Unlike limiter, it is acquired with a weight, and the callers waiting for
+// room are served in order.
+type weightedLimiter struct {
+	mu   sync.Mutex
+	size int64
+	used int64
+	// waiting holds the callers of acquire that are waiting for room, in
+	// the order they called it
+	waiting []weightedWaiter
+}
+
+type weightedWaiter struct {
+	weight int64
+	ready  chan struct{}
+}
+
+// fit returns weight, or the size of the limiter if weight is larger, so that
+// a task heavier than the limiter can run once it has the limiter to itself.
+func (l *weightedLimiter) fit(weight int64) int64 {
+	if weight > l.size {
+		return l.size
+	}
+	return weight
+}
+
+// acquire blocks until there is room for weight, which must not be larger
+// than the size of the limiter.
+func (l *weightedLimiter) acquire(weight int64) {
+	l.mu.Lock()
+	if len(l.waiting) == 0 && l.used+weight <= l.size {
+		l.used += weight
+		l.mu.Unlock()
+		return
+	}
+	ready := make(chan struct{})
+	l.waiting = append(l.waiting, weightedWaiter{weight: weight, ready: ready})
+	l.mu.Unlock()
+	<-ready
+}
+
+// release gives back weight, and wakes the waiting callers of acquire that
+// fit, in order.
+func (l *weightedLimiter) release(weight int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used -= weight
+	for len(l.waiting) > 0 {
+		w := l.waiting[0]
+		if l.used+w.weight > l.size {
+			break
+		}
+		l.used += w.weight
+		l.waiting[0] = weightedWaiter{}
+		l.waiting = l.waiting[1:]
+		close(w.ready)
+	}
+}
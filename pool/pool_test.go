@@ -1,13 +1,21 @@
 package pool
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"runtime"
+	"sort"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc"
 )
 
 func ExamplePool() {
@@ -44,6 +52,41 @@ func TestPool(t *testing.T) {
 		require.Equal(t, completed.Load(), int64(100))
 	})
 
+	t.Run("writes happen before Wait returns", func(t *testing.T) {
+		t.Parallel()
+
+		// Run with -race: the plain writes and reads below are only free of
+		// data races because of the ordering guaranteed by Wait.
+		for name, p := range map[string]*Pool{
+			"limited":   New().WithMaxGoroutines(3),
+			"unlimited": New().WithUnlimitedGoroutines(),
+			"reusable":  New().WithMaxGoroutines(3).WithReuse(),
+		} {
+			p := p
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				for batch := 0; batch < 3; batch++ {
+					results := make([]int, 50)
+					total := 0
+					for i := range results {
+						i := i
+						p.Go(func() {
+							results[i] = i + batch
+						})
+					}
+					p.Wait()
+					for _, res := range results {
+						total += res
+					}
+					require.Equal(t, 50*49/2+50*batch, total)
+					if !p.reusable {
+						break
+					}
+				}
+			})
+		}
+	})
+
 	t.Run("limit", func(t *testing.T) {
 		t.Parallel()
 		for _, maxConcurrent := range []int{1, 10, 100} {
@@ -97,10 +140,443 @@ func TestPool(t *testing.T) {
 		require.Panics(t, func() { New().WithMaxGoroutines(0) })
 	})
 
+	t.Run("pause and resume", func(t *testing.T) {
+		t.Parallel()
+
+		g := New().WithMaxGoroutines(3)
+		g.Pause()
+
+		var completed atomic.Int64
+		for i := 0; i < 3; i++ {
+			g.Go(func() {
+				completed.Add(1)
+			})
+		}
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, int64(0), completed.Load())
+
+		g.Resume()
+		g.Wait()
+		require.Equal(t, int64(3), completed.Load())
+	})
+
+	t.Run("pause does not interrupt running tasks", func(t *testing.T) {
+		t.Parallel()
+
+		g := New().WithMaxGoroutines(1)
+		started := make(chan struct{})
+		finished := make(chan struct{})
+		g.Go(func() {
+			close(started)
+			time.Sleep(10 * time.Millisecond)
+			close(finished)
+		})
+		<-started
+		g.Pause()
+		<-finished
+		g.Resume()
+		g.Wait()
+	})
+
+	t.Run("drain runs all tasks before deadline", func(t *testing.T) {
+		t.Parallel()
+
+		g := New().WithMaxGoroutines(2)
+		var completed atomic.Int64
+		for i := 0; i < 10; i++ {
+			g.Go(func() {
+				completed.Add(1)
+			})
+		}
+		unstarted, err := g.DrainContext(context.Background())
+		require.NoError(t, err)
+		require.Len(t, unstarted, 0)
+		require.Equal(t, int64(10), completed.Load())
+	})
+
+	t.Run("drain returns unstarted tasks", func(t *testing.T) {
+		t.Parallel()
+
+		g := New().WithMaxGoroutines(2)
+		g.Pause()
+		var completed atomic.Int64
+		for i := 0; i < 2; i++ {
+			g.Go(func() {
+				completed.Add(1)
+			})
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		unstarted, err := g.DrainContext(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Len(t, unstarted, 2)
+		require.Equal(t, int64(0), completed.Load())
+
+		// Unstarted tasks can be replayed in another pool
+		g = New()
+		for _, task := range unstarted {
+			g.Go(task)
+		}
+		g.Wait()
+		require.Equal(t, int64(2), completed.Load())
+	})
+
+	t.Run("unstarted tasks are not bound to the drained pool", func(t *testing.T) {
+		t.Parallel()
+
+		var progress, observed, traced atomic.Int64
+		g := New().WithMaxGoroutines(3).
+			WithProgress(func(int, int) { progress.Add(1) }).
+			WithTaskObserver(func(TaskStats) { observed.Add(1) }).
+			WithTracer(func(TraceEvent) { traced.Add(1) })
+		g.Pause()
+		var completed atomic.Int64
+		g.Go(func() { completed.Add(1) })
+		g.GoLabeled("labeled", func() { completed.Add(1) })
+		g.GoBlocking(func() { completed.Add(1) })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		unstarted, err := g.DrainContext(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Len(t, unstarted, 3)
+
+		progressBefore, tracedBefore := progress.Load(), traced.Load()
+		for _, task := range unstarted {
+			task()
+		}
+		require.Equal(t, int64(3), completed.Load())
+		require.Equal(t, progressBefore, progress.Load())
+		require.Equal(t, int64(0), observed.Load())
+		require.Equal(t, tracedBefore, traced.Load())
+	})
+
+	t.Run("names are hierarchical", func(t *testing.T) {
+		root := New().WithName("root")
+		ingest := New().WithName("ingest").WithParent(root)
+		anonymous := New().WithParent(ingest)
+		fetch := NewWithResults[int]().WithName("fetch").WithParent(anonymous).WithErrors()
+
+		require.Equal(t, "root", root.Name())
+		require.Equal(t, "root/ingest", ingest.Name())
+		require.Equal(t, "root/ingest", anonymous.Name())
+		require.Equal(t, "root/ingest/fetch", fetch.Name())
+		require.Equal(t, "", New().Name())
+	})
+
+	t.Run("options", func(t *testing.T) {
+		var calls atomic.Int64
+		defaults := conc.Options{MaxGoroutines: 3, OnProgress: func(done, total int) { calls.Add(1) }}
+		p := New().WithOptions(defaults.Merge(conc.Options{Name: "ingest"}))
+		require.Equal(t, 3, p.MaxGoroutines())
+		require.Equal(t, "ingest", p.Name())
+		p.Go(func() {})
+		p.Wait()
+		require.Equal(t, int64(1), calls.Load())
+
+		// Settings applied later win, and unset options change nothing
+		g := NewWithResults[int]().WithOptions(defaults).WithMaxGoroutines(5).WithOptions(conc.Options{})
+		require.Equal(t, 5, g.MaxGoroutines())
+
+		require.Panics(t, func() { New().WithOptions(conc.Options{MaxGoroutines: -1}) })
+	})
+
+	t.Run("panics on parent cycle", func(t *testing.T) {
+		a := New()
+		b := New().WithParent(a)
+		require.Panics(t, func() { a.WithParent(b) })
+		require.Panics(t, func() { a.WithParent(a) })
+	})
+
+	t.Run("nested pools share parent limiter", func(t *testing.T) {
+		t.Parallel()
+
+		outer := New().WithMaxGoroutines(2)
+		var current, maxSeen, completed atomic.Int64
+		for i := 0; i < 4; i++ {
+			outer.Go(func() {
+				inner := New().WithMaxGoroutines(10).WithParentLimiter(outer)
+				for j := 0; j < 10; j++ {
+					inner.Go(func() {
+						cur := current.Add(1)
+						for {
+							seen := maxSeen.Load()
+							if cur <= seen || maxSeen.CompareAndSwap(seen, cur) {
+								break
+							}
+						}
+						time.Sleep(time.Millisecond)
+						current.Add(-1)
+						completed.Add(1)
+					})
+				}
+				inner.Wait()
+			})
+		}
+		outer.Wait()
+		require.Equal(t, int64(40), completed.Load())
+		require.LessOrEqual(t, maxSeen.Load(), int64(2))
+	})
+
+	t.Run("panics on Go after Wait", func(t *testing.T) {
+		p := New()
+		p.Go(func() {})
+		p.Wait()
+		require.PanicsWithValue(t, "pool: Go called after Wait", func() { p.Go(func() {}) })
+		require.PanicsWithValue(t, "pool: Wait called more than once", p.Wait)
+	})
+
+	t.Run("reuse across batches", func(t *testing.T) {
+		t.Parallel()
+
+		p := New().WithMaxGoroutines(4).WithReuse()
+		defer p.Close()
+
+		for batch := 0; batch < 3; batch++ {
+			var completed atomic.Int64
+			for i := 0; i < 10; i++ {
+				p.Go(func() {
+					time.Sleep(time.Millisecond)
+					completed.Add(1)
+				})
+			}
+			p.Wait()
+			require.Equal(t, int64(10), completed.Load())
+		}
+	})
+
+	t.Run("reuse propagates panics per batch", func(t *testing.T) {
+		p := New().WithMaxGoroutines(1).WithReuse()
+		p.Go(func() { panic("super bad thing") })
+		require.Panics(t, p.Wait)
+
+		// The panic does not carry over to the next batch
+		var completed atomic.Int64
+		p.Go(func() { completed.Add(1) })
+		require.NotPanics(t, p.Wait)
+		require.Equal(t, int64(1), completed.Load())
+
+		p.Close()
+		require.PanicsWithValue(t, "pool: Go called after Wait", func() { p.Go(func() {}) })
+	})
+
+	t.Run("panics on reusable context pool", func(t *testing.T) {
+		require.Panics(t, func() { New().WithReuse().WithContext(context.Background()) })
+	})
+
+	t.Run("misuse detection catches Wait inside task", func(t *testing.T) {
+		p := New().WithMisuseDetection()
+		p.Go(func() {
+			p.Wait()
+		})
+		defer func() {
+			val := recover()
+			require.NotNil(t, val)
+			require.Contains(t, fmt.Sprint(val), "Wait called from a task in the same pool")
+		}()
+		p.Wait()
+	})
+
+	t.Run("misuse detection catches Go concurrent with Wait", func(t *testing.T) {
+		p := New().WithMaxGoroutines(1).WithMisuseDetection()
+		release := make(chan struct{})
+		p.Go(func() { <-release })
+
+		submitted := make(chan struct{})
+		go func() {
+			defer close(submitted)
+			// Blocks until the first task finishes
+			p.Go(func() {})
+		}()
+		require.Eventually(t, func() bool { return p.submitting.Load() == 1 }, time.Second, time.Millisecond)
+
+		require.PanicsWithValue(t, "pool: Go called concurrently with Wait", p.Wait)
+
+		// Clean up the pool by hand, since Wait refused to
+		close(release)
+		<-submitted
+		close(p.tasks)
+		p.handle.Wait()
+	})
+
+	t.Run("runs futures", func(t *testing.T) {
+		p := New().WithMaxGoroutines(1)
+		f := conc.GoFuture(p, func() (int, error) { return 1, nil })
+		next := conc.Then(f, p, func(i int) (int, error) { return i + 1, nil })
+		p.Wait()
+
+		val, err := next.Wait()
+		require.NoError(t, err)
+		require.Equal(t, 2, val)
+	})
+
+	t.Run("tasks use worker state exclusively", func(t *testing.T) {
+		t.Parallel()
+
+		type session struct {
+			inUse atomic.Bool
+			used  int
+		}
+		var sessions atomic.Int64
+		p := New().WithMaxGoroutines(3).WithWorkerInit(func() (any, error) {
+			sessions.Add(1)
+			return &session{}, nil
+		})
+		var conflicts atomic.Int64
+		for i := 0; i < 30; i++ {
+			p.GoWithWorkerState(func(state any) {
+				s := state.(*session)
+				if !s.inUse.CompareAndSwap(false, true) {
+					conflicts.Add(1)
+				}
+				s.used++
+				time.Sleep(time.Millisecond)
+				s.inUse.Store(false)
+			})
+		}
+		p.Wait()
+		require.Equal(t, int64(0), conflicts.Load())
+		require.LessOrEqual(t, sessions.Load(), int64(3))
+	})
+
+	t.Run("worker state is nil without init", func(t *testing.T) {
+		p := New()
+		p.GoWithWorkerState(func(state any) {
+			require.Nil(t, state)
+		})
+		p.Wait()
+	})
+
+	t.Run("worker init failure panics", func(t *testing.T) {
+		p := New().WithWorkerInit(func() (any, error) { return nil, fmt.Errorf("no connection") })
+		p.Go(func() {})
+		require.Panics(t, p.Wait)
+	})
+
+	t.Run("interceptors wrap tasks in order", func(t *testing.T) {
+		var mu sync.Mutex
+		var calls []string
+		record := func(name string) Interceptor {
+			return func(next TaskFunc) TaskFunc {
+				return func(ctx context.Context) error {
+					mu.Lock()
+					calls = append(calls, name)
+					mu.Unlock()
+					return next(ctx)
+				}
+			}
+		}
+		p := New().WithInterceptor(record("outer")).WithInterceptor(record("inner"))
+		p.Go(func() {
+			mu.Lock()
+			calls = append(calls, "task")
+			mu.Unlock()
+		})
+		p.Wait()
+		require.Equal(t, []string{"outer", "inner", "task"}, calls)
+	})
+
+	t.Run("task observer reports panics", func(t *testing.T) {
+		var panicked atomic.Int64
+		p := New().WithTaskObserver(func(stats TaskStats) {
+			if stats.Panicked {
+				panicked.Add(1)
+			}
+			require.False(t, stats.Finished.Before(stats.Started))
+			require.GreaterOrEqual(t, stats.QueueWait(), time.Duration(0))
+		})
+		p.Go(func() {})
+		p.Go(func() { panic("super bad thing") })
+		require.Panics(t, p.Wait)
+		require.Equal(t, int64(1), panicked.Load())
+	})
+
 	t.Run("returns correct MaxGoroutines", func(t *testing.T) {
 		p := New().WithMaxGoroutines(42)
 		require.Equal(t, 42, p.MaxGoroutines())
 	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines()
+		require.Equal(t, math.MaxInt, p.MaxGoroutines())
+
+		// Every task must be running at once for any of them to finish
+		const n = 100
+		var started sync.WaitGroup
+		started.Add(n)
+		var count atomic.Int64
+		for i := 0; i < n; i++ {
+			p.Go(func() {
+				started.Done()
+				started.Wait()
+				count.Add(1)
+			})
+		}
+		p.Wait()
+		require.Equal(t, int64(n), count.Load())
+	})
+
+	t.Run("unlimited propagates panics", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines().WithName("fetch")
+		p.Go(func() {})
+		p.Go(func() { panic("super bad thing") })
+		defer func() {
+			recovered, ok := recover().(*conc.RecoveredPanic)
+			require.True(t, ok)
+			require.Equal(t, "super bad thing", recovered.Value)
+			require.Equal(t, &conc.TaskInfo{Pool: "fetch", Index: 1}, recovered.TaskInfo)
+		}()
+		p.Wait()
+	})
+
+	t.Run("unlimited skips the bookkeeping of unnamed pools", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines()
+		p.Go(func() {})
+		p.Go(func() { panic("super bad thing") })
+		require.Nil(t, p.tasks)
+		require.Zero(t, p.indexed.Load())
+		defer func() {
+			recovered, ok := recover().(*conc.RecoveredPanic)
+			require.True(t, ok)
+			require.Equal(t, "super bad thing", recovered.Value)
+			require.Nil(t, recovered.TaskInfo)
+		}()
+		p.Wait()
+	})
+
+	t.Run("unlimited panics on Go after Wait", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines()
+		p.Wait()
+		require.Panics(t, func() { p.Go(func() {}) })
+	})
+
+	t.Run("unlimited can be paused", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines()
+		p.Pause()
+		var ran atomic.Bool
+		p.Go(func() { ran.Store(true) })
+		time.Sleep(10 * time.Millisecond)
+		require.False(t, ran.Load())
+		p.Resume()
+		p.Wait()
+		require.True(t, ran.Load())
+	})
+
+	t.Run("unlimited error pool", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines().WithErrors()
+		for i := 0; i < 100; i++ {
+			p.Go(func() error { return nil })
+		}
+		p.Go(func() error { return errors.New("failed") })
+		require.Error(t, p.Wait())
+	})
 }
 
 func BenchmarkPool(b *testing.B) {
@@ -120,4 +596,834 @@ func BenchmarkPool(b *testing.B) {
 		}
 		p.Wait()
 	})
+
+	b.Run("per task unlimited", func(b *testing.B) {
+		p := New().WithUnlimitedGoroutines()
+		f := func() {}
+		for i := 0; i < b.N; i++ {
+			p.Go(f)
+		}
+		p.Wait()
+	})
+
+	b.Run("per task sync.WaitGroup", func(b *testing.B) {
+		var wg sync.WaitGroup
+		f := func() {}
+		for i := 0; i < b.N; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f()
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestDefaultPanicHandler(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		values []any
+	)
+	conc.SetDefaultPanicHandler(func(recovered *conc.RecoveredPanic) {
+		mu.Lock()
+		defer mu.Unlock()
+		values = append(values, recovered.Value)
+	})
+	defer conc.SetDefaultPanicHandler(nil)
+
+	t.Run("reports task panics", func(t *testing.T) {
+		values = nil
+		p := New().WithMaxGoroutines(2)
+		p.Go(func() { panic("super bad thing") })
+		p.Go(func() {})
+		require.Panics(t, p.Wait)
+		require.Equal(t, []any{"super bad thing"}, values)
+	})
+
+	t.Run("reports task panics in reusable pools", func(t *testing.T) {
+		values = nil
+		p := New().WithReuse()
+		defer p.Close()
+		p.Go(func() { panic("super bad thing") })
+		require.Panics(t, p.Wait)
+		require.Equal(t, []any{"super bad thing"}, values)
+	})
+
+	t.Run("does not report panics returned as errors", func(t *testing.T) {
+		values = nil
+		p := New().WithErrors().WithPanicsAsErrors()
+		p.Go(func() error { panic("super bad thing") })
+		require.Error(t, p.Wait())
+		require.Empty(t, values)
+	})
+}
+
+func TestPanicTaskInfo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("pool", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithName("fetch")
+		p.Go(func() {})
+		p.Go(func() { panic("super bad thing") })
+		defer func() {
+			recovered, ok := recover().(*conc.RecoveredPanic)
+			require.True(t, ok)
+			require.Equal(t, &conc.TaskInfo{Pool: "fetch", Index: 1}, recovered.TaskInfo)
+			require.NotEmpty(t, recovered.Goroutine)
+		}()
+		p.Wait()
+	})
+
+	t.Run("named task", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithName("fetch").WithErrors().WithPanicsAsErrors()
+		p.GoNamed("users", func() error { return nil })
+		p.GoNamed("orders", func() error { panic("super bad thing") })
+		var recovered *conc.RecoveredPanic
+		require.ErrorAs(t, p.Wait(), &recovered)
+		require.Equal(t, &conc.TaskInfo{Pool: "fetch", Name: "orders", Index: 1}, recovered.TaskInfo)
+	})
+
+	t.Run("context pool", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithName("fetch").WithContext(context.Background()).WithPanicsAsErrors()
+		p.GoNamed("users", func(context.Context) error { panic("super bad thing") })
+		var recovered *conc.RecoveredPanic
+		require.ErrorAs(t, p.Wait(), &recovered)
+		require.Equal(t, &conc.TaskInfo{Pool: "fetch", Name: "users", Index: 0}, recovered.TaskInfo)
+	})
+}
+
+func TestReentrancy(t *testing.T) {
+	t.Parallel()
+
+	// walk submits a task for each of the children of a node in a
+	// complete binary tree of the given depth.
+	var walk func(p *Pool, count *atomic.Int64, depth int)
+	walk = func(p *Pool, count *atomic.Int64, depth int) {
+		count.Add(1)
+		if depth == 0 {
+			return
+		}
+		for i := 0; i < 2; i++ {
+			p.Go(func() { walk(p, count, depth-1) })
+		}
+	}
+
+	for _, maxGoroutines := range []int{1, 2, 4} {
+		maxGoroutines := maxGoroutines
+		t.Run(fmt.Sprintf("tree with %d goroutines", maxGoroutines), func(t *testing.T) {
+			t.Parallel()
+			var count atomic.Int64
+			p := New().WithMaxGoroutines(maxGoroutines).WithReentrancy()
+			p.Go(func() { walk(p, &count, 8) })
+			p.Wait()
+			require.Equal(t, int64(1<<9-1), count.Load())
+		})
+	}
+
+	t.Run("unlimited goroutines", func(t *testing.T) {
+		t.Parallel()
+		var count atomic.Int64
+		p := New().WithUnlimitedGoroutines().WithReentrancy()
+		p.Go(func() {
+			// Give Wait a chance to return before the children are
+			// submitted, which it must not do.
+			time.Sleep(10 * time.Millisecond)
+			walk(p, &count, 6)
+		})
+		p.Wait()
+		require.Equal(t, int64(1<<7-1), count.Load())
+	})
+
+	t.Run("error pool", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithErrors().WithMaxGoroutines(1).WithReentrancy()
+		err := errors.New("leaf")
+		p.Go(func() error {
+			p.Go(func() error {
+				p.Go(func() error { return err })
+				return nil
+			})
+			return nil
+		})
+		require.ErrorIs(t, p.Wait(), err)
+	})
+
+	t.Run("inline tasks get the worker state", func(t *testing.T) {
+		t.Parallel()
+		var workers atomic.Int64
+		p := New().WithMaxGoroutines(1).WithReentrancy().WithWorkerInit(func() (any, error) {
+			return workers.Add(1), nil
+		})
+		var states []any
+		p.GoWithWorkerState(func(state any) {
+			states = append(states, state)
+			p.GoWithWorkerState(func(state any) {
+				states = append(states, state)
+			})
+		})
+		p.Wait()
+		require.Equal(t, []any{int64(1), int64(1)}, states)
+	})
+
+	t.Run("reusable pool", func(t *testing.T) {
+		t.Parallel()
+		var count atomic.Int64
+		p := New().WithMaxGoroutines(1).WithReentrancy().WithReuse()
+		defer p.Close()
+		for i := 0; i < 2; i++ {
+			p.Go(func() { walk(p, &count, 3) })
+			p.Wait()
+		}
+		require.Equal(t, int64(2*(1<<4-1)), count.Load())
+	})
+}
+
+func TestGoexit(t *testing.T) {
+	t.Parallel()
+
+	// requireGoexit must be deferred directly, so that it can recover.
+	requireGoexit := func(t *testing.T) {
+		recovered, ok := recover().(*conc.RecoveredPanic)
+		require.True(t, ok)
+		require.ErrorIs(t, recovered, conc.ErrGoexit)
+	}
+
+	t.Run("pool", func(t *testing.T) {
+		t.Parallel()
+		var count atomic.Int64
+		p := New().WithMaxGoroutines(1)
+		p.Go(runtime.Goexit)
+		for i := 0; i < 10; i++ {
+			p.Go(func() { count.Add(1) })
+		}
+		func() {
+			defer requireGoexit(t)
+			p.Wait()
+		}()
+		require.Equal(t, int64(10), count.Load())
+	})
+
+	t.Run("reusable pool", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithReuse()
+		defer p.Close()
+		p.Go(runtime.Goexit)
+		func() {
+			defer requireGoexit(t)
+			p.Wait()
+		}()
+		p.Go(func() {})
+		require.NotPanics(t, p.Wait)
+	})
+
+	t.Run("error pool does not succeed", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithErrors()
+		p.Go(func() error {
+			runtime.Goexit()
+			return nil
+		})
+		defer requireGoexit(t)
+		_ = p.Wait()
+		t.Fatal("Wait returned")
+	})
+}
+
+func TestGoBlocking(t *testing.T) {
+	t.Parallel()
+
+	// peakOf runs tasks that sleep, submitted to p with GoBlocking, and
+	// returns the highest number that ran at once.
+	peakOf := func(p *Pool, n int) int64 {
+		var current, peak atomic.Int64
+		for i := 0; i < n; i++ {
+			p.GoBlocking(func() {
+				cur := current.Add(1)
+				defer current.Add(-1)
+				for {
+					old := peak.Load()
+					if cur <= old || peak.CompareAndSwap(old, cur) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+			})
+		}
+		p.Wait()
+		return peak.Load()
+	}
+
+	t.Run("does not take a slot", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(1)
+		release := make(chan struct{})
+		p.GoBlocking(func() {
+			<-release
+		})
+		// With the blocked task holding the only slot, this would deadlock
+		p.Go(func() {
+			close(release)
+		})
+		p.Wait()
+	})
+
+	t.Run("unlimited by default", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, int64(4), peakOf(New().WithMaxGoroutines(1), 4))
+	})
+
+	t.Run("WithMaxBlocking falls back to the workers", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(1).WithMaxBlocking(1)
+		require.Equal(t, int64(2), peakOf(p, 6))
+		require.Panics(t, func() { New().WithMaxBlocking(0) })
+	})
+
+	t.Run("panics are propagated", func(t *testing.T) {
+		t.Parallel()
+		p := New()
+		p.GoBlocking(func() {
+			panic("super bad thing happened")
+		})
+		require.Panics(t, p.Wait)
+	})
+
+	t.Run("progress is reported", func(t *testing.T) {
+		t.Parallel()
+		var mu sync.Mutex
+		var last int
+		p := New().WithProgress(func(done, total int) {
+			mu.Lock()
+			last = done
+			mu.Unlock()
+		})
+		for i := 0; i < 5; i++ {
+			p.GoBlocking(func() {})
+			p.Go(func() {})
+		}
+		p.Wait()
+		require.Equal(t, 10, last)
+	})
+
+	t.Run("reusable pool", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(1).WithReuse()
+		defer p.Close()
+		for batch := 0; batch < 3; batch++ {
+			var count atomic.Int64
+			for i := 0; i < 5; i++ {
+				p.GoBlocking(func() {
+					time.Sleep(time.Millisecond)
+					count.Add(1)
+				})
+			}
+			p.Wait()
+			require.Equal(t, int64(5), count.Load())
+		}
+	})
+
+	t.Run("error pool", func(t *testing.T) {
+		t.Parallel()
+		err1 := errors.New("err1")
+		p := New().WithErrors()
+		p.GoBlocking(func() error { return err1 })
+		p.Go(func() error { return nil })
+		require.ErrorIs(t, p.Wait(), err1)
+	})
+
+	t.Run("context pool", func(t *testing.T) {
+		t.Parallel()
+		err1 := errors.New("err1")
+		p := New().WithContext(context.Background()).WithMaxGoroutines(1).WithIOLane(1)
+		p.GoBlocking(func(ctx context.Context) error {
+			// No-ops, since the task holds no slot to give up
+			EnterIO(ctx)
+			ExitIO(ctx)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		p.Go(func(ctx context.Context) error { return err1 })
+		err := p.Wait()
+		require.ErrorIs(t, err, err1)
+	})
+
+	t.Run("result pools", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]().WithMaxGoroutines(1)
+		p.GoBlocking(func() int { return 1 })
+		p.Go(func() int { return 2 })
+		res := p.Wait()
+		sort.Ints(res)
+		require.Equal(t, []int{1, 2}, res)
+
+		mp := NewWithMapResults[string, int]()
+		mp.GoBlocking("a", func() int { return 1 })
+		require.Equal(t, map[string]int{"a": 1}, mp.Wait())
+	})
+}
+
+func TestGoWeighted(t *testing.T) {
+	t.Parallel()
+
+	t.Run("weights count against the limit", func(t *testing.T) {
+		t.Parallel()
+		const limit = 10
+		var used, peak atomic.Int64
+		p := New().WithMaxGoroutines(limit)
+		for i := 0; i < 60; i++ {
+			weight := i%4*3 + 1
+			p.GoWeighted(weight, func() {
+				cur := used.Add(int64(weight))
+				defer used.Add(-int64(weight))
+				for {
+					old := peak.Load()
+					if cur <= old || peak.CompareAndSwap(old, cur) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+			})
+			p.Go(func() {
+				used.Add(1)
+				defer used.Add(-1)
+				time.Sleep(time.Millisecond)
+			})
+		}
+		p.Wait()
+		require.LessOrEqual(t, peak.Load(), int64(limit))
+	})
+
+	t.Run("heavier than the limit runs alone", func(t *testing.T) {
+		t.Parallel()
+		var running atomic.Int64
+		var alone atomic.Bool
+		p := New().WithMaxGoroutines(2)
+		p.Go(func() {
+			running.Add(1)
+			defer running.Add(-1)
+			time.Sleep(5 * time.Millisecond)
+		})
+		p.GoWeighted(5, func() {
+			alone.Store(running.Add(1) == 1)
+			defer running.Add(-1)
+		})
+		p.Wait()
+		require.True(t, alone.Load())
+	})
+
+	t.Run("heavy tasks are not starved", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(4)
+		var light atomic.Int64
+		var heavyDone atomic.Bool
+		for i := 0; i < 3; i++ {
+			p.Go(func() {
+				light.Add(1)
+				time.Sleep(5 * time.Millisecond)
+			})
+		}
+		p.GoWeighted(4, func() {
+			heavyDone.Store(true)
+		})
+		// The light tasks submitted after the heavy one wait for it
+		var after atomic.Int64
+		for i := 0; i < 3; i++ {
+			p.Go(func() {
+				if heavyDone.Load() {
+					after.Add(1)
+				}
+			})
+		}
+		p.Wait()
+		require.Equal(t, int64(3), after.Load())
+	})
+
+	t.Run("unlimited pools ignore weights", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines()
+		var count atomic.Int64
+		p.GoWeighted(100, func() { count.Add(1) })
+		p.Wait()
+		require.Equal(t, int64(1), count.Load())
+	})
+
+	t.Run("invalid weight", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { New().GoWeighted(0, func() {}) })
+		require.Panics(t, func() { New().WithErrors().GoWeighted(-1, func() error { return nil }) })
+	})
+
+	t.Run("wrappers", func(t *testing.T) {
+		t.Parallel()
+		err1 := errors.New("err1")
+		ep := New().WithErrors().WithMaxGoroutines(2)
+		ep.GoWeighted(2, func() error { return err1 })
+		require.ErrorIs(t, ep.Wait(), err1)
+
+		cp := New().WithContext(context.Background()).WithMaxGoroutines(2)
+		cp.GoWeighted(2, func(ctx context.Context) error { return err1 })
+		require.ErrorIs(t, cp.Wait(), err1)
+
+		rp := NewWithResults[int]().WithMaxGoroutines(2)
+		rp.GoWeighted(2, func() int { return 1 })
+		rp.Go(func() int { return 2 })
+		res := rp.Wait()
+		sort.Ints(res)
+		require.Equal(t, []int{1, 2}, res)
+
+		mp := NewWithMapResults[string, int]().WithMaxGoroutines(2)
+		mp.GoWeighted("a", 2, func() int { return 1 })
+		require.Equal(t, map[string]int{"a": 1}, mp.Wait())
+	})
+}
+
+func TestGoSized(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stays within the budget", func(t *testing.T) {
+		t.Parallel()
+		const budget = 1000
+		var used, peak atomic.Int64
+		p := New().WithUnlimitedGoroutines().WithMemoryBudget(budget)
+		for i := 0; i < 50; i++ {
+			bytes := int64(i%5+1) * 100
+			p.GoSized(bytes, func() {
+				cur := used.Add(bytes)
+				defer used.Add(-bytes)
+				for {
+					old := peak.Load()
+					if cur <= old || peak.CompareAndSwap(old, cur) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+			})
+		}
+		p.Wait()
+		require.LessOrEqual(t, peak.Load(), int64(budget))
+		// Several tasks fit in the budget at once
+		require.Greater(t, peak.Load(), int64(500))
+	})
+
+	t.Run("unsized tasks are not held back", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(2).WithMemoryBudget(10)
+		release := make(chan struct{})
+		p.GoSized(10, func() { <-release })
+		// With the budget used up, a sized task would wait for the first one
+		p.Go(func() { close(release) })
+		p.Wait()
+	})
+
+	t.Run("larger than the budget runs alone", func(t *testing.T) {
+		t.Parallel()
+		var running atomic.Int64
+		var alone atomic.Bool
+		p := New().WithMaxGoroutines(4).WithMemoryBudget(100)
+		p.GoSized(50, func() {
+			running.Add(1)
+			defer running.Add(-1)
+			time.Sleep(5 * time.Millisecond)
+		})
+		p.GoSized(500, func() {
+			alone.Store(running.Add(1) == 1)
+			defer running.Add(-1)
+		})
+		p.Wait()
+		require.True(t, alone.Load())
+	})
+
+	t.Run("invalid sizes", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { New().GoSized(-1, func() {}) })
+		require.Panics(t, func() { New().WithMemoryBudget(0) })
+	})
+
+	t.Run("wrappers", func(t *testing.T) {
+		t.Parallel()
+		err1 := errors.New("err1")
+		ep := New().WithErrors().WithMemoryBudget(10)
+		ep.GoSized(20, func() error { return err1 })
+		require.ErrorIs(t, ep.Wait(), err1)
+
+		cp := New().WithContext(context.Background()).WithMemoryBudget(10)
+		cp.GoSized(5, func(ctx context.Context) error { return err1 })
+		require.ErrorIs(t, cp.Wait(), err1)
+
+		rp := NewWithResults[int]().WithMemoryBudget(10)
+		rp.GoSized(5, func() int { return 1 })
+		rp.GoSized(5, func() int { return 2 })
+		res := rp.Wait()
+		sort.Ints(res)
+		require.Equal(t, []int{1, 2}, res)
+
+		mp := NewWithMapResults[string, int]().WithMemoryBudget(10)
+		mp.GoSized("a", 5, func() int { return 1 })
+		require.Equal(t, map[string]int{"a": 1}, mp.Wait())
+	})
+}
+
+func TestWaitContext(t *testing.T) {
+	t.Parallel()
+
+	bgctx := context.Background()
+
+	t.Run("returns once tasks finish", func(t *testing.T) {
+		t.Parallel()
+		p := New()
+		var completed atomic.Int64
+		for i := 0; i < 10; i++ {
+			p.Go(func() { completed.Add(1) })
+		}
+		require.NoError(t, p.WaitContext(bgctx))
+		require.Equal(t, int64(10), completed.Load())
+	})
+
+	t.Run("stops waiting for hung tasks", func(t *testing.T) {
+		t.Parallel()
+		p := New()
+		release := make(chan struct{})
+		defer close(release)
+		p.Go(func() { <-release })
+		require.ErrorIs(t, p.WaitTimeout(10*time.Millisecond), context.DeadlineExceeded)
+		require.PanicsWithValue(t, "pool: Go called after Wait", func() { p.Go(func() {}) })
+	})
+
+	t.Run("propagates panics", func(t *testing.T) {
+		t.Parallel()
+		p := New()
+		p.Go(func() { panic("super bad thing") })
+		require.Panics(t, func() { _ = p.WaitContext(bgctx) })
+	})
+
+	t.Run("error pool", func(t *testing.T) {
+		t.Parallel()
+		err := errors.New("failed")
+		p := New().WithErrors()
+		p.Go(func() error { return err })
+		require.ErrorIs(t, p.WaitContext(bgctx), err)
+	})
+
+	t.Run("context pool cancels outstanding tasks", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithContext(bgctx)
+		canceled := make(chan struct{})
+		p.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		})
+		require.ErrorIs(t, p.WaitTimeout(10*time.Millisecond), context.DeadlineExceeded)
+		<-canceled
+	})
+
+	t.Run("result pool", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]()
+		p.Go(func() int { return 1 })
+		res, err := p.WaitContext(bgctx)
+		require.NoError(t, err)
+		require.Equal(t, []int{1}, res)
+
+		ctx, cancel := context.WithCancel(bgctx)
+		cancel()
+		p = NewWithResults[int]()
+		release := make(chan struct{})
+		defer close(release)
+		p.Go(func() int { <-release; return 1 })
+		res, err = p.WaitContext(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Nil(t, res)
+	})
+}
+
+func TestPrewarm(t *testing.T) {
+	t.Parallel()
+
+	t.Run("starts workers", func(t *testing.T) {
+		p := New().WithMaxGoroutines(4)
+		p.Prewarm(2)
+		require.Equal(t, 2, p.Goroutines())
+
+		var completed atomic.Int64
+		for i := 0; i < 10; i++ {
+			p.Go(func() { completed.Add(1) })
+		}
+		p.Wait()
+		require.Equal(t, int64(10), completed.Load())
+		require.Equal(t, 0, p.Goroutines())
+	})
+
+	t.Run("never exceeds the limit", func(t *testing.T) {
+		p := New().WithMaxGoroutines(3)
+		p.Prewarm(10)
+		p.Prewarm(1)
+		require.Equal(t, 3, p.Goroutines())
+		p.Wait()
+	})
+
+	t.Run("initializes workers", func(t *testing.T) {
+		var inits, teardowns atomic.Int64
+		g := New().WithMaxGoroutines(3).WithErrors().
+			WithWorkerInit(func() (any, error) {
+				inits.Add(1)
+				return "conn", nil
+			}).
+			WithWorkerTeardown(func(any) { teardowns.Add(1) })
+		g.Prewarm(3)
+		require.Eventually(t, func() bool { return inits.Load() == 3 }, time.Second, time.Millisecond)
+
+		for i := 0; i < 10; i++ {
+			g.GoWithWorkerState(func(state any) error {
+				if state != "conn" {
+					return errors.New("missing state")
+				}
+				return nil
+			})
+		}
+		require.NoError(t, g.Wait())
+		require.Equal(t, int64(3), inits.Load())
+		require.Equal(t, int64(3), teardowns.Load())
+	})
+
+	t.Run("failed initialization is retried by tasks", func(t *testing.T) {
+		var inits atomic.Int64
+		err1 := errors.New("err1")
+		g := New().WithMaxGoroutines(2).WithErrors().WithWorkerInit(func() (any, error) {
+			if inits.Add(1) <= 2 {
+				return nil, err1
+			}
+			return "conn", nil
+		})
+		g.Prewarm(2)
+		require.Eventually(t, func() bool { return g.Goroutines() == 0 }, time.Second, time.Millisecond)
+
+		g.GoWithWorkerState(func(state any) error { return nil })
+		require.NoError(t, g.Wait())
+		require.Equal(t, int64(3), inits.Load())
+	})
+
+	t.Run("shared budget", func(t *testing.T) {
+		root := New().WithMaxGoroutines(2)
+		inner := New().WithMaxGoroutines(8).WithParentLimiter(root)
+		inner.Prewarm(8)
+		// The goroutine of its own and the two of the budget
+		require.Equal(t, 3, inner.Goroutines())
+		inner.Wait()
+		root.Wait()
+	})
+
+	t.Run("wrappers", func(t *testing.T) {
+		ep := New().WithErrors()
+		ep.Prewarm(1)
+		require.Equal(t, 1, ep.Goroutines())
+		require.NoError(t, ep.Wait())
+
+		cp := New().WithContext(context.Background())
+		cp.Prewarm(1)
+		require.Equal(t, 1, cp.Goroutines())
+		require.NoError(t, cp.Wait())
+
+		rp := NewWithResults[int]()
+		rp.Prewarm(1)
+		require.Equal(t, 1, rp.Goroutines())
+		require.Empty(t, rp.Wait())
+
+		mp := NewWithMapResults[string, int]()
+		mp.Prewarm(1)
+		require.Equal(t, 1, mp.Goroutines())
+		require.Empty(t, mp.Wait())
+
+		rep := NewWithResults[int]().WithErrors()
+		rep.Prewarm(1)
+		require.Equal(t, 1, rep.Goroutines())
+		res, err := rep.Wait()
+		require.NoError(t, err)
+		require.Empty(t, res)
+
+		rcp := NewWithResults[int]().WithContext(context.Background())
+		rcp.Prewarm(1)
+		require.Equal(t, 1, rcp.Goroutines())
+		res, err = rcp.Wait()
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		require.Panics(t, func() { New().Prewarm(-1) })
+
+		p := New()
+		p.Wait()
+		require.Panics(t, func() { p.Prewarm(1) })
+	})
+}
+
+func TestIdleShutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("workers exit when idle", func(t *testing.T) {
+		p := New().WithMaxGoroutines(4).WithIdleShutdown(10 * time.Millisecond)
+		var completed atomic.Int64
+		for i := 0; i < 10; i++ {
+			p.Go(func() { completed.Add(1) })
+		}
+		require.Eventually(t, func() bool { return p.Goroutines() == 0 }, time.Second, time.Millisecond)
+		require.Equal(t, int64(10), completed.Load())
+
+		// Workers are started again for new tasks
+		for i := 0; i < 10; i++ {
+			p.Go(func() { completed.Add(1) })
+		}
+		p.Wait()
+		require.Equal(t, int64(20), completed.Load())
+		require.Equal(t, 0, p.Goroutines())
+	})
+
+	t.Run("busy workers keep running", func(t *testing.T) {
+		p := New().WithMaxGoroutines(1).WithIdleShutdown(time.Hour)
+		p.Go(func() {})
+		require.Eventually(t, func() bool { return p.Goroutines() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		require.Equal(t, 1, p.Goroutines())
+		p.Wait()
+	})
+
+	t.Run("reusable pool", func(t *testing.T) {
+		p := New().WithMaxGoroutines(2).WithReuse().WithIdleShutdown(10 * time.Millisecond)
+		for batch := 0; batch < 2; batch++ {
+			var completed atomic.Int64
+			for i := 0; i < 5; i++ {
+				p.Go(func() { completed.Add(1) })
+			}
+			p.Wait()
+			require.Equal(t, int64(5), completed.Load())
+			require.Eventually(t, func() bool { return p.Goroutines() == 0 }, time.Second, time.Millisecond)
+		}
+		p.Close()
+	})
+
+	t.Run("tears down exiting workers", func(t *testing.T) {
+		var inits, teardowns atomic.Int64
+		g := New().WithMaxGoroutines(2).WithErrors().
+			WithIdleShutdown(10 * time.Millisecond).
+			WithWorkerInit(func() (any, error) {
+				inits.Add(1)
+				return nil, nil
+			}).
+			WithWorkerTeardown(func(any) { teardowns.Add(1) })
+		g.Go(func() error { return nil })
+		require.Eventually(t, func() bool { return teardowns.Load() == 1 }, time.Second, time.Millisecond)
+		g.Go(func() error { return nil })
+		require.NoError(t, g.Wait())
+		require.Equal(t, int64(2), inits.Load())
+		require.Equal(t, int64(2), teardowns.Load())
+	})
+
+	t.Run("panics on non-positive timeout", func(t *testing.T) {
+		require.Panics(t, func() { New().WithIdleShutdown(0) })
+	})
 }
@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"runtime"
+)
+
+// The profiles below create pools configured for common kinds of work, so
+// that the settings that matter most for each are right from the start. The
+// pools they return can be configured further, or converted with WithErrors
+// or WithContext, like any other pool.
+
+// ProfileCPUBound creates a pool for tasks that keep the CPU busy, such as
+// parsing, compression or hashing. It runs as many goroutines as there are
+// CPUs available to the process, since more goroutines would only add
+// scheduling overhead. Tasks that block, such as a call into a C library,
+// should be submitted with GoBlocking so that they do not hold a CPU slot.
+//
+// The pool does not observe its tasks, so that short tasks pay no overhead
+// for it.
+func ProfileCPUBound() *Pool {
+	return New().WithMaxGoroutines(runtime.GOMAXPROCS(0))
+}
+
+// ProfileIOBound creates a pool for tasks that spend most of their time
+// waiting on the network or a disk, such as requests to a remote service.
+// The number of goroutines is not what limits such tasks, so the pool runs up
+// to maxConns of them at once, which should be the number of requests the
+// service or the connection pool can take at once, rather than a multiple of
+// the number of CPUs.
+//
+// The pool samples the outcomes of its tasks, keeping every failure and one
+// in 100 successes among the last 100 outcomes, which SampledOutcomes
+// returns. Panics if maxConns < 1.
+func ProfileIOBound(maxConns int) *Pool {
+	if maxConns < 1 {
+		panic("max connections must be greater than zero")
+	}
+	return New().
+		WithMaxGoroutines(maxConns).
+		WithOutcomeSampling(100, 100)
+}
+
+// ProfileBatch creates a pool for jobs that run a large number of independent
+// tasks, such as processing every record of a file, where one bad record
+// should not stop or crash the job. It runs as many goroutines as there are
+// CPUs available to the process, and treats a panic in a task as the task's
+// error, so that it is returned by Wait with the other errors. Use
+// WithMaxErrors to give up after too many failures.
+//
+// The pool samples the outcomes of its tasks, keeping every failure and one
+// in 1000 successes among the last 100 outcomes, which SampledOutcomes
+// returns.
+func ProfileBatch() *ErrorPool {
+	return New().
+		WithMaxGoroutines(runtime.GOMAXPROCS(0)).
+		WithOutcomeSampling(1000, 100).
+		WithErrors().
+		WithPanicsAsErrors()
+}
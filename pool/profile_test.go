@@ -0,0 +1,54 @@
+package pool
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cpu bound", func(t *testing.T) {
+		t.Parallel()
+		p := ProfileCPUBound()
+		require.Equal(t, runtime.GOMAXPROCS(0), p.MaxGoroutines())
+		p.Go(func() {})
+		p.Wait()
+		require.Nil(t, p.SampledOutcomes())
+	})
+
+	t.Run("io bound", func(t *testing.T) {
+		t.Parallel()
+		p := ProfileIOBound(32).WithContext(context.Background())
+		require.Equal(t, 32, p.errorPool.pool.MaxGoroutines())
+		p.Go(func(ctx context.Context) error { return errors.New("connection refused") })
+		require.Error(t, p.Wait())
+		require.Len(t, p.SampledOutcomes(), 1)
+
+		require.Panics(t, func() { ProfileIOBound(0) })
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		t.Parallel()
+		p := ProfileBatch()
+		for i := 0; i < 10; i++ {
+			i := i
+			p.Go(func() error {
+				if i == 3 {
+					panic("bad record")
+				}
+				return nil
+			})
+		}
+		var recovered *conc.RecoveredPanic
+		require.ErrorAs(t, p.Wait(), &recovered)
+		// The failure and the first success are sampled
+		require.Len(t, p.SampledOutcomes(), 2)
+	})
+}
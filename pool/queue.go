@@ -0,0 +1,210 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sourcegraph/conc"
+)
+
+// Queue is a source of messages to be handled by Consume. A message stays in
+// the queue until the Delivery it was popped with is acknowledged, so a
+// queue backed by durable storage, such as a database table, gives
+// at-least-once handling that survives restarts: the messages that were
+// being handled when the process stopped are delivered again.
+//
+// MemoryQueue is an implementation that keeps its messages in memory.
+type Queue[T any] interface {
+	// Push adds msg to the queue.
+	Push(ctx context.Context, msg T) error
+	// Pop waits for a message to be available and returns it, or returns
+	// ctx.Err() if ctx is done first. The message is not delivered to other
+	// calls to Pop until Nack is called, or until the queue gives up on the
+	// delivery in a way of its own, such as a lease expiring.
+	Pop(ctx context.Context) (Delivery[T], error)
+}
+
+// Delivery is a message popped from a Queue, which must be settled by
+// calling either Ack or Nack once.
+type Delivery[T any] interface {
+	// Message returns the message.
+	Message() T
+	// Ack reports that the message has been handled, and removes it from
+	// the queue.
+	Ack() error
+	// Nack reports that the message has not been handled, and returns it to
+	// the queue to be delivered again.
+	Nack() error
+}
+
+// Consume pops messages from q and calls handle with each of them in p,
+// until ctx is done, so that p limits the number of messages being handled
+// at once. A message is popped only once p can accept a task, so that no
+// more messages are held than p can handle, apart from the one waiting to
+// be submitted.
+//
+// A message is acknowledged if handle returns nil, and returned to the queue
+// to be retried otherwise, including when handle panics. Panics stop the
+// consumer, and are propagated by Consume once the calls to handle in
+// progress have returned.
+//
+// Consume stops popping messages when ctx is done, or when q fails to pop,
+// acknowledge or return a message, and returns once the calls to handle in
+// progress have returned, with the errors of q as a conc.Errors, or with
+// ctx.Err(). handle is called with a context that is canceled when Consume
+// stops. Consume calls p.Wait, so p must not be used for anything else.
+func Consume[T any, Q Queue[T]](ctx context.Context, p *Pool, q Q, handle func(ctx context.Context, msg T) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu   sync.Mutex
+		errs conc.Errors
+	)
+	fail := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+		cancel()
+	}
+
+	for ctx.Err() == nil {
+		d, err := q.Pop(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				fail(err)
+			}
+			break
+		}
+		p.Go(func() {
+			completed := false
+			defer func() {
+				if !completed {
+					// handle panicked, so give the message back and stop
+					// before the panic is propagated by Wait
+					_ = d.Nack()
+					cancel()
+				}
+			}()
+
+			var err error
+			if ctx.Err() != nil {
+				// Consume stopped while the task was waiting to be submitted
+				err = ctx.Err()
+			} else {
+				err = handle(ctx, d.Message())
+			}
+			completed = true
+
+			if err != nil {
+				err = d.Nack()
+			} else {
+				err = d.Ack()
+			}
+			if err != nil {
+				fail(err)
+			}
+		})
+	}
+	p.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return ctx.Err()
+}
+
+// NewMemoryQueue creates a new, empty MemoryQueue.
+func NewMemoryQueue[T any]() *MemoryQueue[T] {
+	return &MemoryQueue[T]{ready: make(chan struct{}, 1)}
+}
+
+// MemoryQueue is a Queue that keeps its messages in memory, in the order in
+// which they were pushed. Messages returned with Nack are pushed again at the
+// back of the queue. It is not durable: the messages are lost with the
+// process.
+type MemoryQueue[T any] struct {
+	mu    sync.Mutex
+	items []T
+	// ready is signaled when items are pushed, to wake a waiting Pop
+	ready chan struct{}
+}
+
+// Push adds msg to the back of the queue. It never fails.
+func (q *MemoryQueue[T]) Push(_ context.Context, msg T) error {
+	q.mu.Lock()
+	q.items = append(q.items, msg)
+	q.mu.Unlock()
+	q.signal()
+	return nil
+}
+
+// Pop takes the message at the front of the queue, waiting for one to be
+// pushed if the queue is empty. See Queue.Pop.
+func (q *MemoryQueue[T]) Pop(ctx context.Context) (Delivery[T], error) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			msg := q.items[0]
+			var zero T
+			q.items[0] = zero
+			q.items = q.items[1:]
+			if len(q.items) > 0 {
+				// Pass the signal on to the next waiting Pop
+				q.signal()
+			}
+			q.mu.Unlock()
+			return &memoryDelivery[T]{queue: q, msg: msg}, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.ready:
+		}
+	}
+}
+
+// Len returns the number of messages in the queue, not counting those that
+// have been popped and not returned.
+func (q *MemoryQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *MemoryQueue[T]) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+type memoryDelivery[T any] struct {
+	queue   *MemoryQueue[T]
+	msg     T
+	settled atomic.Bool
+}
+
+func (d *memoryDelivery[T]) Message() T {
+	return d.msg
+}
+
+func (d *memoryDelivery[T]) Ack() error {
+	if d.settled.Swap(true) {
+		return errAlreadySettled
+	}
+	return nil
+}
+
+func (d *memoryDelivery[T]) Nack() error {
+	if d.settled.Swap(true) {
+		return errAlreadySettled
+	}
+	return d.queue.Push(context.Background(), d.msg)
+}
+
+var errAlreadySettled = errors.New("pool: delivery already settled")
@@ -0,0 +1,251 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleConsume() {
+	q := NewMemoryQueue[int]()
+	for i := 0; i < 5; i++ {
+		_ = q.Push(context.Background(), i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var handled []int
+	err := Consume(ctx, New().WithMaxGoroutines(2), q, func(ctx context.Context, i int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, i)
+		if len(handled) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	sort.Ints(handled)
+	fmt.Println(handled, err)
+
+	// Output:
+	// [0 1 2 3 4] context canceled
+}
+
+func TestConsume(t *testing.T) {
+	t.Parallel()
+
+	push := func(q Queue[int], n int) {
+		for i := 0; i < n; i++ {
+			require.NoError(t, q.Push(context.Background(), i))
+		}
+	}
+
+	t.Run("limits messages in progress", func(t *testing.T) {
+		t.Parallel()
+		q := NewMemoryQueue[int]()
+		push(q, 50)
+		ctx, cancel := context.WithCancel(context.Background())
+		var running, peak, count atomic.Int64
+		err := Consume(ctx, New().WithMaxGoroutines(3), q, func(ctx context.Context, i int) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			if count.Add(1) == 50 {
+				cancel()
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, int64(50), count.Load())
+		require.LessOrEqual(t, peak.Load(), int64(3))
+		require.Zero(t, q.Len())
+	})
+
+	t.Run("failed messages are retried", func(t *testing.T) {
+		t.Parallel()
+		q := NewMemoryQueue[int]()
+		push(q, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		var mu sync.Mutex
+		attempts := map[int]int{}
+		succeeded := 0
+		err := Consume(ctx, New().WithMaxGoroutines(4), q, func(ctx context.Context, i int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts[i]++
+			if attempts[i] < 3 {
+				return errors.New("try again")
+			}
+			succeeded++
+			if succeeded == 10 {
+				cancel()
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		for i := 0; i < 10; i++ {
+			require.Equal(t, 3, attempts[i])
+		}
+		require.Zero(t, q.Len())
+	})
+
+	t.Run("canceled messages are returned", func(t *testing.T) {
+		t.Parallel()
+		q := NewMemoryQueue[int]()
+		push(q, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		var started sync.WaitGroup
+		started.Add(2)
+		go func() {
+			started.Wait()
+			cancel()
+		}()
+		var handled atomic.Int64
+		err := Consume(ctx, New().WithMaxGoroutines(2), q, func(ctx context.Context, i int) error {
+			handled.Add(1)
+			started.Done()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, int64(2), handled.Load())
+		// The messages in progress were returned, and nothing was lost
+		require.Equal(t, 10, q.Len())
+	})
+
+	t.Run("panics are propagated", func(t *testing.T) {
+		t.Parallel()
+		q := NewMemoryQueue[int]()
+		push(q, 3)
+		require.Panics(t, func() {
+			_ = Consume(context.Background(), New().WithMaxGoroutines(1), q, func(ctx context.Context, i int) error {
+				if i == 1 {
+					panic("super bad thing happened")
+				}
+				return nil
+			})
+		})
+		// The message that panicked was returned to the queue
+		var left []int
+		for q.Len() > 0 {
+			d, err := q.Pop(context.Background())
+			require.NoError(t, err)
+			left = append(left, d.Message())
+		}
+		require.Contains(t, left, 1)
+		require.NotContains(t, left, 0)
+	})
+
+	t.Run("queue errors stop the consumer", func(t *testing.T) {
+		t.Parallel()
+		q := &failingQueue{MemoryQueue: NewMemoryQueue[int](), failAfter: 5}
+		push(q, 10)
+		var handled atomic.Int64
+		err := Consume(context.Background(), New().WithMaxGoroutines(1), q, func(ctx context.Context, i int) error {
+			handled.Add(1)
+			return nil
+		})
+		require.ErrorIs(t, err, errPopFailed)
+		// The last message popped may be handed to the worker after the
+		// failure stopped the consumer, in which case it is returned instead
+		require.GreaterOrEqual(t, handled.Load(), int64(4))
+		require.LessOrEqual(t, handled.Load(), int64(5))
+	})
+}
+
+func TestMemoryQueue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("delivers in order", func(t *testing.T) {
+		t.Parallel()
+		q := NewMemoryQueue[int]()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, q.Push(context.Background(), i))
+		}
+		for i := 0; i < 3; i++ {
+			d, err := q.Pop(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, i, d.Message())
+			require.NoError(t, d.Ack())
+		}
+		require.Zero(t, q.Len())
+	})
+
+	t.Run("nack redelivers", func(t *testing.T) {
+		t.Parallel()
+		q := NewMemoryQueue[string]()
+		require.NoError(t, q.Push(context.Background(), "a"))
+		require.NoError(t, q.Push(context.Background(), "b"))
+		d, err := q.Pop(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, d.Nack())
+		require.Error(t, d.Ack())
+		require.Error(t, d.Nack())
+
+		var got []string
+		for i := 0; i < 2; i++ {
+			d, err := q.Pop(context.Background())
+			require.NoError(t, err)
+			got = append(got, d.Message())
+		}
+		require.Equal(t, []string{"b", "a"}, got)
+	})
+
+	t.Run("pop waits", func(t *testing.T) {
+		t.Parallel()
+		q := NewMemoryQueue[int]()
+		const n = 20
+		var wg sync.WaitGroup
+		var sum atomic.Int64
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d, err := q.Pop(context.Background())
+				require.NoError(t, err)
+				sum.Add(int64(d.Message()))
+			}()
+		}
+		for i := 0; i < n; i++ {
+			require.NoError(t, q.Push(context.Background(), i))
+		}
+		wg.Wait()
+		require.Equal(t, int64(n*(n-1)/2), sum.Load())
+	})
+
+	t.Run("pop canceled", func(t *testing.T) {
+		t.Parallel()
+		q := NewMemoryQueue[int]()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := q.Pop(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+var errPopFailed = errors.New("pop failed")
+
+// failingQueue is a MemoryQueue whose Pop fails after failAfter messages.
+type failingQueue struct {
+	*MemoryQueue[int]
+	failAfter int
+	popped    atomic.Int64
+}
+
+func (q *failingQueue) Pop(ctx context.Context) (Delivery[int], error) {
+	if q.popped.Add(1) > int64(q.failAfter) {
+		return nil, errPopFailed
+	}
+	return q.MemoryQueue.Pop(ctx)
+}
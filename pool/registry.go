@@ -0,0 +1,100 @@
+package pool
+
+import (
+	"sort"
+	"sync"
+)
+
+// livePools is the registry of the pools that have been initialized and not
+// waited for yet, which LivePools filters for the pools that have goroutines
+// running. Pools are added once, when they are initialized, rather than
+// whenever they start their first goroutine, so that spawning a goroutine
+// never takes the lock of the registry. A pool is only removed by Wait, or by
+// Close or DrainContext, so a pool that is never waited for stays in it.
+var livePools = struct {
+	mu    sync.Mutex
+	pools map[*Pool]struct{}
+}{pools: make(map[*Pool]struct{})}
+
+// LivePool describes a pool that has goroutines running. See LivePools.
+type LivePool struct {
+	// Name is the qualified name of the pool. See Pool.Name.
+	Name string
+	// Goroutines is the number of goroutines the pool is running.
+	Goroutines int
+	// MaxGoroutines is the limit of the pool. See Pool.MaxGoroutines.
+	MaxGoroutines int
+	// Queued is the number of calls to Go blocked until the pool accepts
+	// their task, because all of its goroutines are busy.
+	Queued int
+}
+
+// LivePools lists the pools of every kind that have goroutines running, with
+// the number of goroutines each of them is running and of the tasks waiting
+// for one, from the pool running the most goroutines to the pool running the
+// fewest, so that a debug endpoint can show which pools the goroutines of a
+// process belong to without parsing a goroutine dump. Giving pools names with
+// WithName makes the list easier to read. A pool is listed from when it
+// starts its first goroutine until its goroutines have exited, which for most
+// pools is when Wait returns. See the concdebug package.
+//
+// The counts are read one pool at a time while the pools keep running, so
+// they are only a snapshot. ArgPool is not listed, to keep its tasks free of
+// any bookkeeping.
+func LivePools() []LivePool {
+	livePools.mu.Lock()
+	pools := make([]LivePool, 0, len(livePools.pools))
+	for p := range livePools.pools {
+		if p.Goroutines() == 0 {
+			continue
+		}
+		pools = append(pools, LivePool{
+			Name:          p.Name(),
+			Goroutines:    p.Goroutines(),
+			MaxGoroutines: p.MaxGoroutines(),
+			Queued:        int(p.queued.Load()),
+		})
+	}
+	livePools.mu.Unlock()
+
+	sort.SliceStable(pools, func(i, j int) bool {
+		if pools[i].Goroutines != pools[j].Goroutines {
+			return pools[i].Goroutines > pools[j].Goroutines
+		}
+		return pools[i].Name < pools[j].Name
+	})
+	return pools
+}
+
+// Goroutines returns the number of goroutines the pool is running, including
+// the idle workers that are waiting for a task and the goroutines running
+// tasks submitted with GoBlocking, but not the goroutines started directly by
+// Go. See WithUnlimitedGoroutines.
+func (p *Pool) Goroutines() int {
+	return int(p.goroutines.Load())
+}
+
+// spawn starts f on a goroutine of the pool, counted by Goroutines.
+func (p *Pool) spawn(f func()) {
+	p.goroutines.Add(1)
+	p.handle.Go(func() {
+		defer p.goroutines.Add(-1)
+		f()
+	})
+}
+
+// register adds the pool to the registry. It is called once, when the pool
+// is initialized.
+func (p *Pool) register() {
+	livePools.mu.Lock()
+	defer livePools.mu.Unlock()
+	livePools.pools[p] = struct{}{}
+}
+
+// unregister removes the pool from the registry once its goroutines have
+// exited.
+func (p *Pool) unregister() {
+	livePools.mu.Lock()
+	defer livePools.mu.Unlock()
+	delete(livePools.pools, p)
+}
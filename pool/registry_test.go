@@ -0,0 +1,130 @@
+package pool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLivePools(t *testing.T) {
+	t.Parallel()
+
+	find := func(name string) (LivePool, bool) {
+		for _, lp := range LivePools() {
+			if lp.Name == name {
+				return lp, true
+			}
+		}
+		return LivePool{}, false
+	}
+
+	t.Run("counts goroutines", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(3).WithName("TestLivePools/counts")
+		require.Zero(t, p.Goroutines())
+		_, ok := find("TestLivePools/counts")
+		require.False(t, ok)
+
+		var started sync.WaitGroup
+		started.Add(3)
+		release := make(chan struct{})
+		for i := 0; i < 3; i++ {
+			p.Go(func() {
+				started.Done()
+				<-release
+			})
+		}
+		started.Wait()
+		require.Equal(t, 3, p.Goroutines())
+		lp, ok := find("TestLivePools/counts")
+		require.True(t, ok)
+		require.Equal(t, 3, lp.Goroutines)
+
+		close(release)
+		p.Wait()
+		require.Zero(t, p.Goroutines())
+		_, ok = find("TestLivePools/counts")
+		require.False(t, ok)
+	})
+
+	t.Run("counts blocking but not direct goroutines", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines().WithName("TestLivePools/direct")
+		var started sync.WaitGroup
+		started.Add(2)
+		release := make(chan struct{})
+		task := func() {
+			started.Done()
+			<-release
+		}
+		p.Go(task)
+		p.GoBlocking(task)
+		started.Wait()
+		require.Equal(t, 1, p.Goroutines())
+		close(release)
+		p.Wait()
+		require.Zero(t, p.Goroutines())
+	})
+
+	t.Run("wrapped pools", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]().WithErrors().WithMaxGoroutines(2).WithName("TestLivePools/wrapped")
+		var started sync.WaitGroup
+		started.Add(2)
+		release := make(chan struct{})
+		for i := 0; i < 2; i++ {
+			p.Go(func() (int, error) {
+				started.Done()
+				<-release
+				return 1, nil
+			})
+		}
+		started.Wait()
+		require.Equal(t, 2, p.Goroutines())
+		lp, ok := find("TestLivePools/wrapped")
+		require.True(t, ok)
+		require.Equal(t, 2, lp.Goroutines)
+		close(release)
+		_, err := p.Wait()
+		require.NoError(t, err)
+		_, ok = find("TestLivePools/wrapped")
+		require.False(t, ok)
+	})
+
+	t.Run("spawning does not lock the registry", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(2).WithIdleShutdown(time.Millisecond)
+		p.Go(func() {})
+
+		// Hold the registry while the pool goes from no goroutines to some
+		// and back, which must not wait for it.
+		time.Sleep(10 * time.Millisecond)
+		livePools.mu.Lock()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var ran sync.WaitGroup
+			ran.Add(2)
+			p.Go(ran.Done)
+			p.Go(ran.Done)
+			ran.Wait()
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("spawning a goroutine waited for the registry")
+		}
+		livePools.mu.Unlock()
+		p.Wait()
+	})
+
+	t.Run("sorted by goroutines", func(t *testing.T) {
+		t.Parallel()
+		pools := LivePools()
+		for i := 1; i < len(pools); i++ {
+			require.GreaterOrEqual(t, pools[i-1].Goroutines, pools[i].Goroutines)
+		}
+	})
+}
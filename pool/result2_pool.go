@@ -0,0 +1,202 @@
+package pool
+
+import (
+	"context"
+)
+
+// NewWithResults2 creates a new Result2Pool for tasks with two results, of
+// types A and B.
+func NewWithResults2[A, B any]() *Result2Pool[A, B] {
+	return &Result2Pool[A, B]{
+		pool: NewWithResults[result2[A, B]](),
+	}
+}
+
+// Result2Pool is a ResultPool for tasks that return two results, such as a
+// value and its metadata, so that they do not need a struct of their own:
+//
+//	p := pool.NewWithResults2[*Page, http.Header]()
+//	for _, url := range urls {
+//		url := url
+//		p.Go(func() (*Page, http.Header) { return fetch(url) })
+//	}
+//	pages, headers := p.Wait()
+//
+// The results of a task are at the same index of the slices returned by
+// Wait. Result2Pool has the main settings of ResultPool; for the others, use
+// a ResultPool of a struct.
+type Result2Pool[A, B any] struct {
+	pool *ResultPool[result2[A, B]]
+}
+
+// result2 holds the results of a task of a Result2Pool.
+type result2[A, B any] struct {
+	a A
+	b B
+}
+
+func unzip2[A, B any](results []result2[A, B]) ([]A, []B) {
+	as := make([]A, len(results))
+	bs := make([]B, len(results))
+	for i, r := range results {
+		as[i], bs[i] = r.a, r.b
+	}
+	return as, bs
+}
+
+// Go submits a task to the pool.
+func (p *Result2Pool[A, B]) Go(f func() (A, B)) {
+	p.pool.Go(func() result2[A, B] {
+		a, b := f()
+		return result2[A, B]{a, b}
+	})
+}
+
+// Wait cleans up all spawned goroutines, propagating any panics, and returning
+// the results of the tasks. See ResultPool.Wait.
+func (p *Result2Pool[A, B]) Wait() ([]A, []B) {
+	return unzip2(p.pool.Wait())
+}
+
+// MaxGoroutines returns the maximum size of the pool.
+func (p *Result2Pool[A, B]) MaxGoroutines() int {
+	return p.pool.MaxGoroutines()
+}
+
+// WithErrors converts the pool to a Result2ErrorPool so the submitted tasks
+// can return errors.
+func (p *Result2Pool[A, B]) WithErrors() *Result2ErrorPool[A, B] {
+	return &Result2ErrorPool[A, B]{
+		pool: p.pool.WithErrors(),
+	}
+}
+
+// WithContext converts the pool to a Result2ContextPool for tasks that should
+// be canceled on first error.
+func (p *Result2Pool[A, B]) WithContext(ctx context.Context) *Result2ContextPool[A, B] {
+	return &Result2ContextPool[A, B]{
+		pool: p.pool.WithContext(ctx),
+	}
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *Result2Pool[A, B]) WithName(name string) *Result2Pool[A, B] {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithMaxGoroutines limits the number of goroutines in a pool. See
+// Pool.WithMaxGoroutines.
+func (p *Result2Pool[A, B]) WithMaxGoroutines(n int) *Result2Pool[A, B] {
+	p.pool.WithMaxGoroutines(n)
+	return p
+}
+
+// Result2ErrorPool is a Result2Pool for tasks that also return an error. See
+// ResultErrorPool.
+type Result2ErrorPool[A, B any] struct {
+	pool *ResultErrorPool[result2[A, B]]
+}
+
+// Go submits a task to the pool.
+func (p *Result2ErrorPool[A, B]) Go(f func() (A, B, error)) {
+	p.pool.Go(func() (result2[A, B], error) {
+		a, b, err := f()
+		return result2[A, B]{a, b}, err
+	})
+}
+
+// Wait cleans up any spawned goroutines, propagating any panics and
+// returning the results of the tasks and any errors. See
+// ResultErrorPool.Wait.
+func (p *Result2ErrorPool[A, B]) Wait() ([]A, []B, error) {
+	results, err := p.pool.Wait()
+	as, bs := unzip2(results)
+	return as, bs, err
+}
+
+// WithCollectErrored configures the pool to still collect the results of a
+// task even if the task returned an error. See
+// ResultErrorPool.WithCollectErrored.
+func (p *Result2ErrorPool[A, B]) WithCollectErrored() *Result2ErrorPool[A, B] {
+	p.pool.WithCollectErrored()
+	return p
+}
+
+// WithContext converts the pool to a Result2ContextPool for tasks that should
+// be canceled on first error.
+func (p *Result2ErrorPool[A, B]) WithContext(ctx context.Context) *Result2ContextPool[A, B] {
+	return &Result2ContextPool[A, B]{
+		pool: p.pool.WithContext(ctx),
+	}
+}
+
+// WithFirstError configures the pool to only return the first error returned
+// by a task. See ResultErrorPool.WithFirstError.
+func (p *Result2ErrorPool[A, B]) WithFirstError() *Result2ErrorPool[A, B] {
+	p.pool.WithFirstError()
+	return p
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *Result2ErrorPool[A, B]) WithName(name string) *Result2ErrorPool[A, B] {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithMaxGoroutines limits the number of goroutines in a pool. See
+// Pool.WithMaxGoroutines.
+func (p *Result2ErrorPool[A, B]) WithMaxGoroutines(n int) *Result2ErrorPool[A, B] {
+	p.pool.WithMaxGoroutines(n)
+	return p
+}
+
+// Result2ContextPool is a Result2Pool for tasks that take a context and also
+// return an error. See ResultContextPool.
+type Result2ContextPool[A, B any] struct {
+	pool *ResultContextPool[result2[A, B]]
+}
+
+// Go submits a task to the pool.
+func (p *Result2ContextPool[A, B]) Go(f func(context.Context) (A, B, error)) {
+	p.pool.Go(func(ctx context.Context) (result2[A, B], error) {
+		a, b, err := f(ctx)
+		return result2[A, B]{a, b}, err
+	})
+}
+
+// Wait cleans up all spawned goroutines, propagates any panics, and returns
+// the results of the tasks and any errors. See ResultContextPool.Wait.
+func (p *Result2ContextPool[A, B]) Wait() ([]A, []B, error) {
+	results, err := p.pool.Wait()
+	as, bs := unzip2(results)
+	return as, bs, err
+}
+
+// WithCollectErrored configures the pool to still collect the results of a
+// task even if the task returned an error. See
+// ResultContextPool.WithCollectErrored.
+func (p *Result2ContextPool[A, B]) WithCollectErrored() *Result2ContextPool[A, B] {
+	p.pool.WithCollectErrored()
+	return p
+}
+
+// WithFirstError configures the pool to only return the first error returned
+// by a task. See ResultContextPool.WithFirstError.
+func (p *Result2ContextPool[A, B]) WithFirstError() *Result2ContextPool[A, B] {
+	p.pool.WithFirstError()
+	return p
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *Result2ContextPool[A, B]) WithName(name string) *Result2ContextPool[A, B] {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithMaxGoroutines limits the number of goroutines in a pool. See
+// Pool.WithMaxGoroutines.
+func (p *Result2ContextPool[A, B]) WithMaxGoroutines(n int) *Result2ContextPool[A, B] {
+	p.pool.WithMaxGoroutines(n)
+	return p
+}
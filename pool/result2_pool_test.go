@@ -0,0 +1,82 @@
+package pool
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func TestResult2Pool(t *testing.T) {
+	t.Parallel()
+
+	err1 := errors.New("err1")
+
+	t.Run("results of a task are at the same index", func(t *testing.T) {
+		p := NewWithResults2[int, string]().WithMaxGoroutines(4)
+		for i := 0; i < 100; i++ {
+			i := i
+			p.Go(func() (int, string) { return i, strconv.Itoa(i) })
+		}
+		ints, strs := p.Wait()
+		require.Len(t, ints, 100)
+		require.Len(t, strs, 100)
+		for i := range ints {
+			require.Equal(t, strconv.Itoa(ints[i]), strs[i])
+		}
+		sort.Ints(ints)
+		for i, v := range ints {
+			require.Equal(t, i, v)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		p := NewWithResults2[int, string]().WithErrors()
+		p.Go(func() (int, string, error) { return 1, "1", nil })
+		p.Go(func() (int, string, error) { return 2, "2", err1 })
+		ints, strs, err := p.Wait()
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, []int{1}, ints)
+		require.Equal(t, []string{"1"}, strs)
+	})
+
+	t.Run("WithCollectErrored", func(t *testing.T) {
+		p := NewWithResults2[int, string]().WithErrors().WithCollectErrored().WithMaxGoroutines(1)
+		p.Go(func() (int, string, error) { return 1, "1", nil })
+		p.Go(func() (int, string, error) { return 2, "2", err1 })
+		ints, strs, err := p.Wait()
+		require.ErrorIs(t, err, err1)
+		require.Len(t, ints, 2)
+		require.Len(t, strs, 2)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		p := NewWithResults2[int, string]().WithContext(context.Background()).WithMaxGoroutines(2)
+		p.Go(func(ctx context.Context) (int, string, error) {
+			<-ctx.Done()
+			return 0, "", ctx.Err()
+		})
+		p.Go(func(ctx context.Context) (int, string, error) { return 0, "", err1 })
+		ints, strs, err := p.Wait()
+		require.ErrorIs(t, err, err1)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Empty(t, ints)
+		require.Empty(t, strs)
+	})
+
+	t.Run("WithFirstError", func(t *testing.T) {
+		p := NewWithResults2[int, string]().WithErrors().WithContext(context.Background()).WithFirstError().WithMaxGoroutines(2)
+		p.Go(func(ctx context.Context) (int, string, error) {
+			<-ctx.Done()
+			return 0, "", ctx.Err()
+		})
+		p.Go(func(ctx context.Context) (int, string, error) { return 0, "", err1 })
+		_, _, err := p.Wait()
+		require.ErrorIs(t, err, err1)
+		require.NotErrorIs(t, err, context.Canceled)
+	})
+}
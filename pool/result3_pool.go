@@ -0,0 +1,194 @@
+package pool
+
+import (
+	"context"
+)
+
+// NewWithResults3 creates a new Result3Pool for tasks with three results, of
+// types A, B and C.
+func NewWithResults3[A, B, C any]() *Result3Pool[A, B, C] {
+	return &Result3Pool[A, B, C]{
+		pool: NewWithResults[result3[A, B, C]](),
+	}
+}
+
+// Result3Pool is a ResultPool for tasks that return three results, so that
+// they do not need a struct of their own. It is like Result3Pool with a third
+// result.
+type Result3Pool[A, B, C any] struct {
+	pool *ResultPool[result3[A, B, C]]
+}
+
+// result3 holds the results of a task of a Result3Pool.
+type result3[A, B, C any] struct {
+	a A
+	b B
+	c C
+}
+
+func unzip3[A, B, C any](results []result3[A, B, C]) ([]A, []B, []C) {
+	as := make([]A, len(results))
+	bs := make([]B, len(results))
+	cs := make([]C, len(results))
+	for i, r := range results {
+		as[i], bs[i], cs[i] = r.a, r.b, r.c
+	}
+	return as, bs, cs
+}
+
+// Go submits a task to the pool.
+func (p *Result3Pool[A, B, C]) Go(f func() (A, B, C)) {
+	p.pool.Go(func() result3[A, B, C] {
+		a, b, c := f()
+		return result3[A, B, C]{a, b, c}
+	})
+}
+
+// Wait cleans up all spawned goroutines, propagating any panics, and returning
+// the results of the tasks. See ResultPool.Wait.
+func (p *Result3Pool[A, B, C]) Wait() ([]A, []B, []C) {
+	return unzip3(p.pool.Wait())
+}
+
+// MaxGoroutines returns the maximum size of the pool.
+func (p *Result3Pool[A, B, C]) MaxGoroutines() int {
+	return p.pool.MaxGoroutines()
+}
+
+// WithErrors converts the pool to a Result3ErrorPool so the submitted tasks
+// can return errors.
+func (p *Result3Pool[A, B, C]) WithErrors() *Result3ErrorPool[A, B, C] {
+	return &Result3ErrorPool[A, B, C]{
+		pool: p.pool.WithErrors(),
+	}
+}
+
+// WithContext converts the pool to a Result3ContextPool for tasks that should
+// be canceled on first error.
+func (p *Result3Pool[A, B, C]) WithContext(ctx context.Context) *Result3ContextPool[A, B, C] {
+	return &Result3ContextPool[A, B, C]{
+		pool: p.pool.WithContext(ctx),
+	}
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *Result3Pool[A, B, C]) WithName(name string) *Result3Pool[A, B, C] {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithMaxGoroutines limits the number of goroutines in a pool. See
+// Pool.WithMaxGoroutines.
+func (p *Result3Pool[A, B, C]) WithMaxGoroutines(n int) *Result3Pool[A, B, C] {
+	p.pool.WithMaxGoroutines(n)
+	return p
+}
+
+// Result3ErrorPool is a Result3Pool for tasks that also return an error. See
+// ResultErrorPool.
+type Result3ErrorPool[A, B, C any] struct {
+	pool *ResultErrorPool[result3[A, B, C]]
+}
+
+// Go submits a task to the pool.
+func (p *Result3ErrorPool[A, B, C]) Go(f func() (A, B, C, error)) {
+	p.pool.Go(func() (result3[A, B, C], error) {
+		a, b, c, err := f()
+		return result3[A, B, C]{a, b, c}, err
+	})
+}
+
+// Wait cleans up any spawned goroutines, propagating any panics and
+// returning the results of the tasks and any errors. See
+// ResultErrorPool.Wait.
+func (p *Result3ErrorPool[A, B, C]) Wait() ([]A, []B, []C, error) {
+	results, err := p.pool.Wait()
+	as, bs, cs := unzip3(results)
+	return as, bs, cs, err
+}
+
+// WithCollectErrored configures the pool to still collect the results of a
+// task even if the task returned an error. See
+// ResultErrorPool.WithCollectErrored.
+func (p *Result3ErrorPool[A, B, C]) WithCollectErrored() *Result3ErrorPool[A, B, C] {
+	p.pool.WithCollectErrored()
+	return p
+}
+
+// WithContext converts the pool to a Result3ContextPool for tasks that should
+// be canceled on first error.
+func (p *Result3ErrorPool[A, B, C]) WithContext(ctx context.Context) *Result3ContextPool[A, B, C] {
+	return &Result3ContextPool[A, B, C]{
+		pool: p.pool.WithContext(ctx),
+	}
+}
+
+// WithFirstError configures the pool to only return the first error returned
+// by a task. See ResultErrorPool.WithFirstError.
+func (p *Result3ErrorPool[A, B, C]) WithFirstError() *Result3ErrorPool[A, B, C] {
+	p.pool.WithFirstError()
+	return p
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *Result3ErrorPool[A, B, C]) WithName(name string) *Result3ErrorPool[A, B, C] {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithMaxGoroutines limits the number of goroutines in a pool. See
+// Pool.WithMaxGoroutines.
+func (p *Result3ErrorPool[A, B, C]) WithMaxGoroutines(n int) *Result3ErrorPool[A, B, C] {
+	p.pool.WithMaxGoroutines(n)
+	return p
+}
+
+// Result3ContextPool is a Result3Pool for tasks that take a context and also
+// return an error. See ResultContextPool.
+type Result3ContextPool[A, B, C any] struct {
+	pool *ResultContextPool[result3[A, B, C]]
+}
+
+// Go submits a task to the pool.
+func (p *Result3ContextPool[A, B, C]) Go(f func(context.Context) (A, B, C, error)) {
+	p.pool.Go(func(ctx context.Context) (result3[A, B, C], error) {
+		a, b, c, err := f(ctx)
+		return result3[A, B, C]{a, b, c}, err
+	})
+}
+
+// Wait cleans up all spawned goroutines, propagates any panics, and returns
+// the results of the tasks and any errors. See ResultContextPool.Wait.
+func (p *Result3ContextPool[A, B, C]) Wait() ([]A, []B, []C, error) {
+	results, err := p.pool.Wait()
+	as, bs, cs := unzip3(results)
+	return as, bs, cs, err
+}
+
+// WithCollectErrored configures the pool to still collect the results of a
+// task even if the task returned an error. See
+// ResultContextPool.WithCollectErrored.
+func (p *Result3ContextPool[A, B, C]) WithCollectErrored() *Result3ContextPool[A, B, C] {
+	p.pool.WithCollectErrored()
+	return p
+}
+
+// WithFirstError configures the pool to only return the first error returned
+// by a task. See ResultContextPool.WithFirstError.
+func (p *Result3ContextPool[A, B, C]) WithFirstError() *Result3ContextPool[A, B, C] {
+	p.pool.WithFirstError()
+	return p
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *Result3ContextPool[A, B, C]) WithName(name string) *Result3ContextPool[A, B, C] {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithMaxGoroutines limits the number of goroutines in a pool. See
+// Pool.WithMaxGoroutines.
+func (p *Result3ContextPool[A, B, C]) WithMaxGoroutines(n int) *Result3ContextPool[A, B, C] {
+	p.pool.WithMaxGoroutines(n)
+	return p
+}
@@ -0,0 +1,50 @@
+package pool
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func TestResult3Pool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("results of a task are at the same index", func(t *testing.T) {
+		p := NewWithResults3[int, string, bool]()
+		for i := 0; i < 100; i++ {
+			i := i
+			p.Go(func() (int, string, bool) { return i, strconv.Itoa(i), i%2 == 0 })
+		}
+		ints, strs, evens := p.Wait()
+		require.Len(t, ints, 100)
+		for i := range ints {
+			require.Equal(t, strconv.Itoa(ints[i]), strs[i])
+			require.Equal(t, ints[i]%2 == 0, evens[i])
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		err1 := errors.New("err1")
+		p := NewWithResults3[int, string, bool]().WithErrors().WithCollectErrored()
+		p.Go(func() (int, string, bool, error) { return 1, "1", true, err1 })
+		ints, strs, bools, err := p.Wait()
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, []int{1}, ints)
+		require.Equal(t, []string{"1"}, strs)
+		require.Equal(t, []bool{true}, bools)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		p := NewWithResults3[int, string, bool]().WithContext(context.Background()).WithMaxGoroutines(2)
+		p.Go(func(ctx context.Context) (int, string, bool, error) { return 1, "1", true, ctx.Err() })
+		ints, strs, bools, err := p.Wait()
+		require.NoError(t, err)
+		require.Equal(t, []int{1}, ints)
+		require.Equal(t, []string{"1"}, strs)
+		require.Equal(t, []bool{true}, bools)
+	})
+}
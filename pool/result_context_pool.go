@@ -2,6 +2,10 @@ package pool
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/sourcegraph/conc"
 )
 
 // ResultContextPool is a pool that runs tasks that take a context and return a
@@ -12,26 +16,178 @@ type ResultContextPool[T any] struct {
 	contextPool    ContextPool
 	agg            resultAggregator[T]
 	collectErrored bool
+
+	successThreshold int64
+	successes        atomic.Int64
 }
 
 // Go submits a task to the pool
 func (p *ResultContextPool[T]) Go(f func(context.Context) (T, error)) {
+	p.contextPool.Go(p.wrap(f))
+}
+
+// TryGo submits a task to the pool, unless the pool rejects it because its
+// parent context is done, in which case it returns the error of the parent
+// context. See ContextPool.TryGo.
+func (p *ResultContextPool[T]) TryGo(f func(context.Context) (T, error)) error {
+	return p.contextPool.TryGo(p.wrap(f))
+}
+
+// GoContext submits a task to the pool, propagating the values of any keys
+// configured with WithContextPropagation from ctx. See ContextPool.GoContext.
+func (p *ResultContextPool[T]) GoContext(ctx context.Context, f func(context.Context) (T, error)) {
+	p.contextPool.GoContext(ctx, p.wrap(f))
+}
+
+// GoNamed submits a task to the pool. If the task returns an error, it is
+// wrapped in a *TaskError with the given name and the index of the task. See
+// ErrorPool.GoNamed.
+func (p *ResultContextPool[T]) GoNamed(name string, f func(context.Context) (T, error)) {
+	p.contextPool.GoNamed(name, p.wrap(f))
+}
+
+// GoMemoized submits a task identified by key, so that tasks submitted with
+// the same key in a run of the pool share the result of the first one. See
+// ResultErrorPool.GoMemoized.
+func (p *ResultContextPool[T]) GoMemoized(key any, f func(context.Context) (T, error)) {
+	if !p.agg.memoize(key) {
+		return
+	}
 	p.contextPool.Go(func(ctx context.Context) error {
 		res, err := f(ctx)
-		if err == nil || p.collectErrored {
+		p.agg.memoized(key, res, p.collects(err))
+		return err
+	})
+}
+
+// GoWeighted submits a task that counts as weight tasks against the pool's
+// limit. See Pool.GoWeighted.
+func (p *ResultContextPool[T]) GoWeighted(weight int, f func(context.Context) (T, error)) {
+	p.contextPool.GoWeighted(weight, p.wrap(f))
+}
+
+// GoSized submits a task that is estimated to use bytes of memory while it
+// runs. See Pool.GoSized.
+func (p *ResultContextPool[T]) GoSized(bytes int64, f func(context.Context) (T, error)) {
+	p.contextPool.GoSized(bytes, p.wrap(f))
+}
+
+// GoBlocking submits a task that spends most of its time blocked, on a
+// goroutine that does not count toward the pool's limit. See
+// Pool.GoBlocking.
+func (p *ResultContextPool[T]) GoBlocking(f func(context.Context) (T, error)) {
+	p.contextPool.GoBlocking(p.wrap(f))
+}
+
+func (p *ResultContextPool[T]) wrap(f func(context.Context) (T, error)) func(context.Context) error {
+	return func(ctx context.Context) error {
+		res, err := f(ctx)
+		if p.collects(err) {
 			p.agg.add(res)
 		}
 		return err
-	})
+	}
+}
+
+// collects reports whether the result of a task that returned err is
+// collected, counting it toward the threshold set by WithSuccessThreshold if
+// it is successful.
+func (p *ResultContextPool[T]) collects(err error) bool {
+	if p.successThreshold > 0 {
+		return err == nil && p.successes.Add(1) <= p.successThreshold
+	}
+	return err == nil || p.collectErrored
+}
+
+// Drain shuts the tasks of the pool down gracefully, canceling them once
+// grace has passed. See ContextPool.Drain.
+func (p *ResultContextPool[T]) Drain(grace time.Duration) {
+	p.contextPool.Drain(grace)
+}
+
+// OnCancel registers f to be run once the context passed to the tasks is
+// canceled. See ContextPool.OnCancel.
+func (p *ResultContextPool[T]) OnCancel(f func()) (stop func() bool) {
+	return p.contextPool.OnCancel(f)
 }
 
 // Wait cleans up all spawned goroutines, propagates any panics, and
 // returns an error if any of the tasks errored.
 func (p *ResultContextPool[T]) Wait() ([]T, error) {
 	err := p.contextPool.Wait()
+	p.agg.flushRest()
 	return p.agg.results, err
 }
 
+// WaitContext is like Wait, but stops waiting when ctx is done, in which case
+// it cancels the remaining tasks and returns no results and ctx.Err(). See
+// ContextPool.WaitContext.
+func (p *ResultContextPool[T]) WaitContext(ctx context.Context) ([]T, error) {
+	var (
+		results []T
+		err     error
+	)
+	if ctxErr := waitContext(ctx, func() { results, err = p.Wait() }); ctxErr != nil {
+		p.contextPool.cancel(ctxErr)
+		return nil, ctxErr
+	}
+	return results, err
+}
+
+// WaitTimeout is like WaitContext, but stops waiting after d.
+func (p *ResultContextPool[T]) WaitTimeout(d time.Duration) ([]T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return p.WaitContext(ctx)
+}
+
+// Pause stops the pool from starting any new tasks until Resume is called.
+// See Pool.Pause for details.
+func (p *ResultContextPool[T]) Pause() {
+	p.contextPool.Pause()
+}
+
+// Resume undoes a call to Pause, allowing held and queued tasks to start.
+func (p *ResultContextPool[T]) Resume() {
+	p.contextPool.Resume()
+}
+
+// CancelWhere cancels the labeled tasks that have not started yet and whose
+// label matches the predicate, and returns how many were canceled. See
+// Pool.CancelWhere.
+func (p *ResultContextPool[T]) CancelWhere(pred func(label string) bool) int {
+	return p.contextPool.CancelWhere(pred)
+}
+
+// QueuedLabels returns the labels of the labeled tasks that have not started
+// yet. See Pool.QueuedLabels.
+func (p *ResultContextPool[T]) QueuedLabels() []string {
+	return p.contextPool.QueuedLabels()
+}
+
+// Name returns the name of the pool qualified by the names of its parents.
+// See Pool.Name for details.
+func (p *ResultContextPool[T]) Name() string {
+	return p.contextPool.Name()
+}
+
+// Goroutines returns the number of goroutines the pool is running. See
+// Pool.Goroutines.
+func (p *ResultContextPool[T]) Goroutines() int {
+	return p.contextPool.Goroutines()
+}
+
+// Prewarm starts up to n workers ahead of the first tasks. See Pool.Prewarm.
+func (p *ResultContextPool[T]) Prewarm(n int) {
+	p.contextPool.Prewarm(n)
+}
+
+// SampledOutcomes returns the outcomes of tasks sampled by the pool, starting
+// with the most recent. See Pool.SampledOutcomes.
+func (p *ResultContextPool[T]) SampledOutcomes() []TaskStats {
+	return p.contextPool.SampledOutcomes()
+}
+
 // WithCollectErrored configures the pool to still collect the result of a task
 // even if the task returned an error. By default, the result of tasks that errored
 // are ignored and only the error is collected.
@@ -40,6 +196,21 @@ func (p *ResultContextPool[T]) WithCollectErrored() *ResultContextPool[T] {
 	return p
 }
 
+// WithBaggage configures the pool to give every task a context carrying
+// value for key. See ContextPool.WithBaggage.
+func (p *ResultContextPool[T]) WithBaggage(key, value any) *ResultContextPool[T] {
+	p.contextPool.WithBaggage(key, value)
+	return p
+}
+
+// WithContextPropagation configures the pool to carry the values of the given
+// keys from the context passed to GoContext into the task's context. See
+// ContextPool.WithContextPropagation.
+func (p *ResultContextPool[T]) WithContextPropagation(keys ...any) *ResultContextPool[T] {
+	p.contextPool.WithContextPropagation(keys...)
+	return p
+}
+
 // WithFirstError configures the pool to only return the first error
 // returned by a task. By default, Wait() will return a combined error.
 func (p *ResultContextPool[T]) WithFirstError() *ResultContextPool[T] {
@@ -47,9 +218,240 @@ func (p *ResultContextPool[T]) WithFirstError() *ResultContextPool[T] {
 	return p
 }
 
+// WithMaxErrors configures the pool to stop running tasks once n of them have
+// failed. See ContextPool.WithMaxErrors.
+func (p *ResultContextPool[T]) WithMaxErrors(n int) *ResultContextPool[T] {
+	p.contextPool.WithMaxErrors(n)
+	return p
+}
+
+// WithFirstSuccess configures the pool to cancel the context passed to tasks
+// as soon as any task succeeds. Wait() returns only the result of the first
+// task to succeed, and only returns an error if no task succeeded. See
+// ContextPool.WithFirstSuccess.
+func (p *ResultContextPool[T]) WithFirstSuccess() *ResultContextPool[T] {
+	return p.WithSuccessThreshold(1)
+}
+
+// WithPanicsAsErrors configures the pool to treat a panic in a task as the
+// task's error. See ErrorPool.WithPanicsAsErrors.
+func (p *ResultContextPool[T]) WithPanicsAsErrors() *ResultContextPool[T] {
+	p.contextPool.WithPanicsAsErrors()
+	return p
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *ResultContextPool[T]) WithName(name string) *ResultContextPool[T] {
+	p.contextPool.WithName(name)
+	return p
+}
+
+// WithOptions applies the settings of opts that are set to the pool. See
+// Pool.WithOptions.
+func (p *ResultContextPool[T]) WithOptions(opts conc.Options) *ResultContextPool[T] {
+	p.contextPool.WithOptions(opts)
+	return p
+}
+
+// WithParent sets the parent of the pool in the naming hierarchy. See
+// Pool.WithParent.
+func (p *ResultContextPool[T]) WithParent(parent *Pool) *ResultContextPool[T] {
+	p.contextPool.WithParent(parent)
+	return p
+}
+
+// WithInterceptor adds an interceptor that wraps every task submitted to the
+// pool. See Pool.WithInterceptor.
+func (p *ResultContextPool[T]) WithInterceptor(i Interceptor) *ResultContextPool[T] {
+	p.contextPool.WithInterceptor(i)
+	return p
+}
+
+// WithWorkerInit configures the pool to call init in each of its goroutines
+// before it runs its first task. See Pool.WithWorkerInit.
+func (p *ResultContextPool[T]) WithWorkerInit(init func() (state any, err error)) *ResultContextPool[T] {
+	p.contextPool.WithWorkerInit(init)
+	return p
+}
+
+// WithWorkerTeardown configures the pool to call teardown with the state of
+// each of its goroutines when it exits. See Pool.WithWorkerTeardown.
+func (p *ResultContextPool[T]) WithWorkerTeardown(teardown func(state any)) *ResultContextPool[T] {
+	p.contextPool.WithWorkerTeardown(teardown)
+	return p
+}
+
+// WithMisuseDetection configures the pool to panic when it is misused in a
+// way that would otherwise deadlock. See Pool.WithMisuseDetection.
+func (p *ResultContextPool[T]) WithMisuseDetection() *ResultContextPool[T] {
+	p.contextPool.WithMisuseDetection()
+	return p
+}
+
+// WithReentrancy configures the pool so that its tasks can submit more tasks
+// to it without deadlocking. See Pool.WithReentrancy.
+func (p *ResultContextPool[T]) WithReentrancy() *ResultContextPool[T] {
+	p.contextPool.WithReentrancy()
+	return p
+}
+
+// WithLockOSThread configures each goroutine of the pool to lock itself to
+// its OS thread for as long as it runs. See Pool.WithLockOSThread.
+func (p *ResultContextPool[T]) WithLockOSThread() *ResultContextPool[T] {
+	p.contextPool.WithLockOSThread()
+	return p
+}
+
+// WithIdleShutdown configures each worker of the pool to exit once it has
+// been idle for d. See Pool.WithIdleShutdown.
+func (p *ResultContextPool[T]) WithIdleShutdown(d time.Duration) *ResultContextPool[T] {
+	p.contextPool.WithIdleShutdown(d)
+	return p
+}
+
+// WithMemoryBudget limits the estimated memory of the tasks submitted with
+// GoSized that run at once. See Pool.WithMemoryBudget.
+func (p *ResultContextPool[T]) WithMemoryBudget(bytes int64) *ResultContextPool[T] {
+	p.contextPool.WithMemoryBudget(bytes)
+	return p
+}
+
+// WithMaxBlocking limits the number of tasks submitted with GoBlocking that
+// run outside the pool's limit at once. See Pool.WithMaxBlocking.
+func (p *ResultContextPool[T]) WithMaxBlocking(n int) *ResultContextPool[T] {
+	p.contextPool.WithMaxBlocking(n)
+	return p
+}
+
+// WithScheduler registers the pool with s, which shares its limit fairly
+// between pools according to their weights. See Pool.WithScheduler.
+func (p *ResultContextPool[T]) WithScheduler(s *Scheduler, weight int) *ResultContextPool[T] {
+	p.contextPool.WithScheduler(s, weight)
+	return p
+}
+
+// WithParentLimiter configures the pool to borrow its goroutines from the
+// concurrency budget of parent. See Pool.WithParentLimiter.
+func (p *ResultContextPool[T]) WithParentLimiter(parent *Pool) *ResultContextPool[T] {
+	p.contextPool.WithParentLimiter(parent)
+	return p
+}
+
+// WithProgress configures the pool to call f every time a task completes.
+// See Pool.WithProgress.
+func (p *ResultContextPool[T]) WithProgress(f func(done, total int)) *ResultContextPool[T] {
+	p.contextPool.WithProgress(f)
+	return p
+}
+
+// WithTaskObserver configures the pool to call f with the timings and
+// outcome of every task once it finishes. See Pool.WithTaskObserver.
+func (p *ResultContextPool[T]) WithTaskObserver(f func(TaskStats)) *ResultContextPool[T] {
+	p.contextPool.WithTaskObserver(f)
+	return p
+}
+
+// WithOutcomeSampling configures the pool to keep every failure and one in
+// every successes successful tasks among its last size outcomes. See
+// Pool.WithOutcomeSampling.
+func (p *ResultContextPool[T]) WithOutcomeSampling(successes, size int) *ResultContextPool[T] {
+	p.contextPool.WithOutcomeSampling(successes, size)
+	return p
+}
+
+// WithTracer configures the pool to call f with every event in the life of
+// its tasks. See Pool.WithTracer.
+func (p *ResultContextPool[T]) WithTracer(f func(TraceEvent)) *ResultContextPool[T] {
+	p.contextPool.WithTracer(f)
+	return p
+}
+
+// WithSuccessThreshold configures the pool to cancel the context passed to
+// tasks as soon as n tasks have succeeded. Wait() returns only the results of
+// the first n tasks to succeed, and only returns an error if fewer than n
+// tasks succeeded. See ContextPool.WithSuccessThreshold.
+func (p *ResultContextPool[T]) WithSuccessThreshold(n int) *ResultContextPool[T] {
+	p.contextPool.WithSuccessThreshold(n)
+	p.successThreshold = int64(n)
+	return p
+}
+
+// WithIOLane gives the pool a second lane of n goroutines for tasks that are
+// blocked on IO. See ContextPool.WithIOLane.
+func (p *ResultContextPool[T]) WithIOLane(n int) *ResultContextPool[T] {
+	p.contextPool.WithIOLane(n)
+	return p
+}
+
+// SilentTasks returns the running tasks that have not called conc.Heartbeat
+// for longer than the heartbeat timeout. See ContextPool.SilentTasks.
+func (p *ResultContextPool[T]) SilentTasks() []SilentTask {
+	return p.contextPool.SilentTasks()
+}
+
+// WithHeartbeatTimeout configures the pool to track the heartbeats of its
+// tasks. See ContextPool.WithHeartbeatTimeout.
+func (p *ResultContextPool[T]) WithHeartbeatTimeout(d time.Duration) *ResultContextPool[T] {
+	p.contextPool.WithHeartbeatTimeout(d)
+	return p
+}
+
+// WithSilentTaskCancellation configures the pool to cancel tasks that go
+// without a heartbeat for longer than the heartbeat timeout. See
+// ContextPool.WithSilentTaskCancellation.
+func (p *ResultContextPool[T]) WithSilentTaskCancellation() *ResultContextPool[T] {
+	p.contextPool.WithSilentTaskCancellation()
+	return p
+}
+
+// WithCanceledPolicy configures what the pool does with the tasks submitted
+// once its parent context is done. See ContextPool.WithCanceledPolicy.
+func (p *ResultContextPool[T]) WithCanceledPolicy(policy CanceledPolicy) *ResultContextPool[T] {
+	p.contextPool.WithCanceledPolicy(policy)
+	return p
+}
+
+// WithRejectionHandler configures the pool to call f instead of running tasks
+// whose deadline passes while they wait to start. See
+// ContextPool.WithRejectionHandler.
+func (p *ResultContextPool[T]) WithRejectionHandler(f func(*TaskTimeoutError)) *ResultContextPool[T] {
+	p.contextPool.WithRejectionHandler(f)
+	return p
+}
+
+// WithTaskTimeout configures the pool to give each task a context that
+// expires d after the task starts. See ContextPool.WithTaskTimeout.
+func (p *ResultContextPool[T]) WithTaskTimeout(d time.Duration) *ResultContextPool[T] {
+	p.contextPool.WithTaskTimeout(d)
+	return p
+}
+
+// WithTaskTimeoutFromSubmit configures the timeout set by WithTaskTimeout to
+// be measured from when each task is submitted. See
+// ContextPool.WithTaskTimeoutFromSubmit.
+func (p *ResultContextPool[T]) WithTaskTimeoutFromSubmit() *ResultContextPool[T] {
+	p.contextPool.WithTaskTimeoutFromSubmit()
+	return p
+}
+
 // WithMaxGoroutines limits the number of goroutines in a pool.
 // Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
 func (p *ResultContextPool[T]) WithMaxGoroutines(n int) *ResultContextPool[T] {
 	p.contextPool.WithMaxGoroutines(n)
 	return p
 }
+
+// WithUnlimitedGoroutines removes the limit on the number of goroutines in a
+// pool. See Pool.WithUnlimitedGoroutines.
+func (p *ResultContextPool[T]) WithUnlimitedGoroutines() *ResultContextPool[T] {
+	p.contextPool.WithUnlimitedGoroutines()
+	return p
+}
+
+// WithResultFlush configures the pool to call fn with the results of its
+// tasks in batches of n as they are collected, rather than returning them all
+// from Wait. See ResultPool.WithResultFlush.
+func (p *ResultContextPool[T]) WithResultFlush(n int, fn func([]T)) *ResultContextPool[T] {
+	p.agg.setFlush(n, fn)
+	return p
+}
@@ -87,6 +87,18 @@ func TestResultContextPool(t *testing.T) {
 		require.ErrorIs(t, err, err1)
 	})
 
+	t.Run("WithContextPropagation", func(t *testing.T) {
+		type key struct{}
+		submitCtx := context.WithValue(context.Background(), key{}, 42)
+		g := NewWithResults[int]().WithContext(context.Background()).WithContextPropagation(key{})
+		g.GoContext(submitCtx, func(ctx context.Context) (int, error) {
+			return ctx.Value(key{}).(int), nil
+		})
+		res, err := g.Wait()
+		require.NoError(t, err)
+		require.Equal(t, []int{42}, res)
+	})
+
 	t.Run("WithFirstError", func(t *testing.T) {
 		t.Parallel()
 		g := NewWithResults[int]().WithContext(context.Background()).WithFirstError()
@@ -103,6 +115,40 @@ func TestResultContextPool(t *testing.T) {
 		require.NotErrorIs(t, err, context.Canceled)
 	})
 
+	t.Run("WithFirstSuccess", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithResults[int]().WithContext(context.Background()).WithFirstSuccess().WithMaxGoroutines(4)
+		for i := 0; i < 3; i++ {
+			g.Go(func(ctx context.Context) (int, error) {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			})
+		}
+		g.Go(func(ctx context.Context) (int, error) {
+			return 42, nil
+		})
+		res, err := g.Wait()
+		require.NoError(t, err)
+		require.Equal(t, []int{42}, res)
+	})
+
+	t.Run("WithSuccessThreshold", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithResults[int]().WithContext(context.Background()).WithSuccessThreshold(2).WithMaxGoroutines(4)
+		g.Go(func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		for i := 0; i < 3; i++ {
+			g.Go(func(ctx context.Context) (int, error) {
+				return 1, nil
+			})
+		}
+		res, err := g.Wait()
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 1}, res)
+	})
+
 	t.Run("limit", func(t *testing.T) {
 		t.Parallel()
 		for _, maxConcurrency := range []int{1, 10, 100} {
@@ -2,6 +2,9 @@ package pool
 
 import (
 	"context"
+	"time"
+
+	"github.com/sourcegraph/conc"
 )
 
 // ResultErrorPool is a pool that executes tasks that return a generic result
@@ -19,22 +22,151 @@ type ResultErrorPool[T any] struct {
 
 // Go submits a task to the pool
 func (p *ResultErrorPool[T]) Go(f func() (T, error)) {
+	p.errorPool.Go(p.wrap(f))
+}
+
+// GoNamed submits a task to the pool. If the task returns an error, it is
+// wrapped in a *TaskError with the given name and the index of the task. See
+// ErrorPool.GoNamed.
+func (p *ResultErrorPool[T]) GoNamed(name string, f func() (T, error)) {
+	p.errorPool.GoNamed(name, p.wrap(f))
+}
+
+// GoMemoized submits a task identified by key, so that tasks submitted with
+// the same key in a run of the pool share the result of the first one. The
+// error of the task is returned by Wait only once, and the submissions that
+// share its result get one only if the task's result is collected. See
+// ResultPool.GoMemoized.
+func (p *ResultErrorPool[T]) GoMemoized(key any, f func() (T, error)) {
+	if !p.agg.memoize(key) {
+		return
+	}
 	p.errorPool.Go(func() error {
 		res, err := f()
-		if err == nil || p.collectErrored {
+		p.agg.memoized(key, res, p.collects(err))
+		return err
+	})
+}
+
+// GoWeighted submits a task that counts as weight tasks against the pool's
+// limit. See Pool.GoWeighted.
+func (p *ResultErrorPool[T]) GoWeighted(weight int, f func() (T, error)) {
+	p.errorPool.GoWeighted(weight, p.wrap(f))
+}
+
+// GoSized submits a task that is estimated to use bytes of memory while it
+// runs. See Pool.GoSized.
+func (p *ResultErrorPool[T]) GoSized(bytes int64, f func() (T, error)) {
+	p.errorPool.GoSized(bytes, p.wrap(f))
+}
+
+// GoBlocking submits a task that spends most of its time blocked, on a
+// goroutine that does not count toward the pool's limit. See
+// Pool.GoBlocking.
+func (p *ResultErrorPool[T]) GoBlocking(f func() (T, error)) {
+	p.errorPool.GoBlocking(p.wrap(f))
+}
+
+func (p *ResultErrorPool[T]) wrap(f func() (T, error)) func() error {
+	return func() error {
+		res, err := f()
+		if p.collects(err) {
 			p.agg.add(res)
 		}
 		return err
-	})
+	}
+}
+
+// collects reports whether the result of a task that returned err is
+// collected.
+func (p *ResultErrorPool[T]) collects(err error) bool {
+	return err == nil || p.collectErrored
 }
 
 // Wait cleans up any spawned goroutines, propagating any panics and
 // returning the results and any errors from tasks.
 func (p *ResultErrorPool[T]) Wait() ([]T, error) {
+	if p.errorPool.pool.reusable {
+		defer p.agg.reset()
+	}
 	err := p.errorPool.Wait()
+	p.agg.flushRest()
 	return p.agg.results, err
 }
 
+// WaitContext is like Wait, but stops waiting when ctx is done, in which case
+// it returns no results and ctx.Err(). See Pool.WaitContext.
+func (p *ResultErrorPool[T]) WaitContext(ctx context.Context) ([]T, error) {
+	var (
+		results []T
+		err     error
+	)
+	if ctxErr := waitContext(ctx, func() { results, err = p.Wait() }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return results, err
+}
+
+// WaitTimeout is like WaitContext, but stops waiting after d.
+func (p *ResultErrorPool[T]) WaitTimeout(d time.Duration) ([]T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return p.WaitContext(ctx)
+}
+
+// Close stops the goroutines of a pool configured with WithReuse. See
+// Pool.Close.
+func (p *ResultErrorPool[T]) Close() {
+	p.errorPool.Close()
+}
+
+// Pause stops the pool from starting any new tasks until Resume is called.
+// See Pool.Pause for details.
+func (p *ResultErrorPool[T]) Pause() {
+	p.errorPool.Pause()
+}
+
+// Resume undoes a call to Pause, allowing held and queued tasks to start.
+func (p *ResultErrorPool[T]) Resume() {
+	p.errorPool.Resume()
+}
+
+// CancelWhere cancels the labeled tasks that have not started yet and whose
+// label matches the predicate, and returns how many were canceled. See
+// Pool.CancelWhere.
+func (p *ResultErrorPool[T]) CancelWhere(pred func(label string) bool) int {
+	return p.errorPool.CancelWhere(pred)
+}
+
+// QueuedLabels returns the labels of the labeled tasks that have not started
+// yet. See Pool.QueuedLabels.
+func (p *ResultErrorPool[T]) QueuedLabels() []string {
+	return p.errorPool.QueuedLabels()
+}
+
+// Name returns the name of the pool qualified by the names of its parents.
+// See Pool.Name for details.
+func (p *ResultErrorPool[T]) Name() string {
+	return p.errorPool.Name()
+}
+
+// Goroutines returns the number of goroutines the pool is running. See
+// Pool.Goroutines.
+func (p *ResultErrorPool[T]) Goroutines() int {
+	return p.errorPool.Goroutines()
+}
+
+// Prewarm starts up to n workers ahead of the first tasks. See Pool.Prewarm.
+func (p *ResultErrorPool[T]) Prewarm(n int) {
+	p.errorPool.Prewarm(n)
+}
+
+// SampledOutcomes returns the outcomes of tasks sampled by the pool, starting
+// with the most recent. See Pool.SampledOutcomes.
+func (p *ResultErrorPool[T]) SampledOutcomes() []TaskStats {
+	return p.errorPool.SampledOutcomes()
+}
+
 // WithCollectErrored configures the pool to still collect the result of a task
 // even if the task returned an error. By default, the result of tasks that errored
 // are ignored and only the error is collected.
@@ -58,9 +190,172 @@ func (p *ResultErrorPool[T]) WithFirstError() *ResultErrorPool[T] {
 	return p
 }
 
+// WithMaxErrors configures the pool to stop running tasks once n of them have
+// failed. See ErrorPool.WithMaxErrors.
+func (p *ResultErrorPool[T]) WithMaxErrors(n int) *ResultErrorPool[T] {
+	p.errorPool.WithMaxErrors(n)
+	return p
+}
+
+// WithPanicsAsErrors configures the pool to treat a panic in a task as the
+// task's error. See ErrorPool.WithPanicsAsErrors.
+func (p *ResultErrorPool[T]) WithPanicsAsErrors() *ResultErrorPool[T] {
+	p.errorPool.WithPanicsAsErrors()
+	return p
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *ResultErrorPool[T]) WithName(name string) *ResultErrorPool[T] {
+	p.errorPool.WithName(name)
+	return p
+}
+
+// WithOptions applies the settings of opts that are set to the pool. See
+// Pool.WithOptions.
+func (p *ResultErrorPool[T]) WithOptions(opts conc.Options) *ResultErrorPool[T] {
+	p.errorPool.WithOptions(opts)
+	return p
+}
+
+// WithParent sets the parent of the pool in the naming hierarchy. See
+// Pool.WithParent.
+func (p *ResultErrorPool[T]) WithParent(parent *Pool) *ResultErrorPool[T] {
+	p.errorPool.WithParent(parent)
+	return p
+}
+
+// WithReuse configures the pool so that Wait can be called more than once,
+// each time returning the results of the tasks submitted since the previous
+// call. See Pool.WithReuse.
+func (p *ResultErrorPool[T]) WithReuse() *ResultErrorPool[T] {
+	p.errorPool.WithReuse()
+	return p
+}
+
+// WithInterceptor adds an interceptor that wraps every task submitted to the
+// pool. See Pool.WithInterceptor.
+func (p *ResultErrorPool[T]) WithInterceptor(i Interceptor) *ResultErrorPool[T] {
+	p.errorPool.WithInterceptor(i)
+	return p
+}
+
+// WithWorkerInit configures the pool to call init in each of its goroutines
+// before it runs its first task. See Pool.WithWorkerInit.
+func (p *ResultErrorPool[T]) WithWorkerInit(init func() (state any, err error)) *ResultErrorPool[T] {
+	p.errorPool.WithWorkerInit(init)
+	return p
+}
+
+// WithWorkerTeardown configures the pool to call teardown with the state of
+// each of its goroutines when it exits. See Pool.WithWorkerTeardown.
+func (p *ResultErrorPool[T]) WithWorkerTeardown(teardown func(state any)) *ResultErrorPool[T] {
+	p.errorPool.WithWorkerTeardown(teardown)
+	return p
+}
+
+// WithMisuseDetection configures the pool to panic when it is misused in a
+// way that would otherwise deadlock. See Pool.WithMisuseDetection.
+func (p *ResultErrorPool[T]) WithMisuseDetection() *ResultErrorPool[T] {
+	p.errorPool.WithMisuseDetection()
+	return p
+}
+
+// WithReentrancy configures the pool so that its tasks can submit more tasks
+// to it without deadlocking. See Pool.WithReentrancy.
+func (p *ResultErrorPool[T]) WithReentrancy() *ResultErrorPool[T] {
+	p.errorPool.WithReentrancy()
+	return p
+}
+
+// WithLockOSThread configures each goroutine of the pool to lock itself to
+// its OS thread for as long as it runs. See Pool.WithLockOSThread.
+func (p *ResultErrorPool[T]) WithLockOSThread() *ResultErrorPool[T] {
+	p.errorPool.WithLockOSThread()
+	return p
+}
+
+// WithIdleShutdown configures each worker of the pool to exit once it has
+// been idle for d. See Pool.WithIdleShutdown.
+func (p *ResultErrorPool[T]) WithIdleShutdown(d time.Duration) *ResultErrorPool[T] {
+	p.errorPool.WithIdleShutdown(d)
+	return p
+}
+
+// WithMemoryBudget limits the estimated memory of the tasks submitted with
+// GoSized that run at once. See Pool.WithMemoryBudget.
+func (p *ResultErrorPool[T]) WithMemoryBudget(bytes int64) *ResultErrorPool[T] {
+	p.errorPool.WithMemoryBudget(bytes)
+	return p
+}
+
+// WithMaxBlocking limits the number of tasks submitted with GoBlocking that
+// run outside the pool's limit at once. See Pool.WithMaxBlocking.
+func (p *ResultErrorPool[T]) WithMaxBlocking(n int) *ResultErrorPool[T] {
+	p.errorPool.WithMaxBlocking(n)
+	return p
+}
+
+// WithScheduler registers the pool with s, which shares its limit fairly
+// between pools according to their weights. See Pool.WithScheduler.
+func (p *ResultErrorPool[T]) WithScheduler(s *Scheduler, weight int) *ResultErrorPool[T] {
+	p.errorPool.WithScheduler(s, weight)
+	return p
+}
+
+// WithParentLimiter configures the pool to borrow its goroutines from the
+// concurrency budget of parent. See Pool.WithParentLimiter.
+func (p *ResultErrorPool[T]) WithParentLimiter(parent *Pool) *ResultErrorPool[T] {
+	p.errorPool.WithParentLimiter(parent)
+	return p
+}
+
+// WithProgress configures the pool to call f every time a task completes.
+// See Pool.WithProgress.
+func (p *ResultErrorPool[T]) WithProgress(f func(done, total int)) *ResultErrorPool[T] {
+	p.errorPool.WithProgress(f)
+	return p
+}
+
+// WithTaskObserver configures the pool to call f with the timings and
+// outcome of every task once it finishes. See Pool.WithTaskObserver.
+func (p *ResultErrorPool[T]) WithTaskObserver(f func(TaskStats)) *ResultErrorPool[T] {
+	p.errorPool.WithTaskObserver(f)
+	return p
+}
+
+// WithOutcomeSampling configures the pool to keep every failure and one in
+// every successes successful tasks among its last size outcomes. See
+// Pool.WithOutcomeSampling.
+func (p *ResultErrorPool[T]) WithOutcomeSampling(successes, size int) *ResultErrorPool[T] {
+	p.errorPool.WithOutcomeSampling(successes, size)
+	return p
+}
+
+// WithTracer configures the pool to call f with every event in the life of
+// its tasks. See Pool.WithTracer.
+func (p *ResultErrorPool[T]) WithTracer(f func(TraceEvent)) *ResultErrorPool[T] {
+	p.errorPool.WithTracer(f)
+	return p
+}
+
 // WithMaxGoroutines limits the number of goroutines in a pool.
 // Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
 func (p *ResultErrorPool[T]) WithMaxGoroutines(n int) *ResultErrorPool[T] {
 	p.errorPool.WithMaxGoroutines(n)
 	return p
 }
+
+// WithUnlimitedGoroutines removes the limit on the number of goroutines in a
+// pool. See Pool.WithUnlimitedGoroutines.
+func (p *ResultErrorPool[T]) WithUnlimitedGoroutines() *ResultErrorPool[T] {
+	p.errorPool.WithUnlimitedGoroutines()
+	return p
+}
+
+// WithResultFlush configures the pool to call fn with the results of its
+// tasks in batches of n as they are collected, rather than returning them all
+// from Wait. See ResultPool.WithResultFlush.
+func (p *ResultErrorPool[T]) WithResultFlush(n int, fn func([]T)) *ResultErrorPool[T] {
+	p.agg.setFlush(n, fn)
+	return p
+}
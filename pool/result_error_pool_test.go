@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/sourcegraph/conc"
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 )
 
@@ -41,6 +42,49 @@ func TestResultErrorGroup(t *testing.T) {
 		require.ErrorIs(t, err, err1)
 	})
 
+	t.Run("memoized", func(t *testing.T) {
+		g := NewWithResults[int]().WithErrors().WithMaxGoroutines(2)
+		var calls atomic.Int64
+		for i := 0; i < 10; i++ {
+			key := i % 2
+			g.GoMemoized(key, func() (int, error) {
+				calls.Add(1)
+				if key == 1 {
+					return 0, err1
+				}
+				return 42, nil
+			})
+		}
+		res, err := g.Wait()
+		require.Equal(t, int64(2), calls.Load())
+		require.Equal(t, []int{42, 42, 42, 42, 42}, res)
+		// The error of the shared task is returned once
+		var errs conc.Errors
+		require.ErrorAs(t, err, &errs)
+		require.Equal(t, 1, errs.Len())
+		require.ErrorIs(t, err, err1)
+	})
+
+	t.Run("WithResultFlush", func(t *testing.T) {
+		var flushed []int
+		g := NewWithResults[int]().WithErrors().WithMaxGoroutines(1).WithResultFlush(2, func(batch []int) {
+			flushed = append(flushed, batch...)
+		})
+		for i := 0; i < 5; i++ {
+			i := i
+			g.Go(func() (int, error) {
+				if i == 2 {
+					return i, err1
+				}
+				return i, nil
+			})
+		}
+		res, err := g.Wait()
+		require.Empty(t, res)
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, []int{0, 1, 3, 4}, flushed)
+	})
+
 	t.Run("WithFirstError", func(t *testing.T) {
 		t.Parallel()
 		g := NewWithResults[int]().WithErrors().WithFirstError()
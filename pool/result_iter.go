@@ -0,0 +1,123 @@
+//go:build go1.23
+
+package pool
+
+import (
+	"iter"
+
+	"github.com/sourcegraph/conc"
+)
+
+// Iter returns an iterator over the results of the tasks, in the order they
+// complete, for use instead of Wait:
+//
+//	for res, err := range p.Iter() {
+//		...
+//	}
+//
+// The loop waits for the tasks as Wait would, so all tasks must have been
+// submitted before it starts, and panics are propagated once the results
+// collected before the panic have been yielded. The error is always nil for
+// a ResultPool, and is there for consistency with ResultErrorPool.Iter. If
+// the loop is exited early, it waits for the remaining tasks and discards
+// their results before returning. Iter cannot be used together with
+// WithResultFlush.
+func (p *ResultPool[T]) Iter() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		p.agg.iterate(func() error {
+			p.pool.Wait()
+			return nil
+		}, nil, yield)
+	}
+}
+
+// Iter returns an iterator over the results of the tasks, in the order they
+// complete, for use instead of Wait. Once the results have been yielded, the
+// error that Wait would return is yielded with the zero value of T, if it is
+// not nil. See ResultPool.Iter.
+func (p *ResultErrorPool[T]) Iter() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		p.agg.iterate(p.errorPool.Wait, nil, yield)
+	}
+}
+
+// Iter returns an iterator over the results of the tasks, in the order they
+// complete, for use instead of Wait. Once the results have been yielded, the
+// error that Wait would return is yielded with the zero value of T, if it is
+// not nil. If the loop is exited early, the context of the remaining tasks
+// is canceled before waiting for them. See ResultPool.Iter.
+func (p *ResultContextPool[T]) Iter() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		p.agg.iterate(p.contextPool.Wait, func() { p.contextPool.cancel(nil) }, yield)
+	}
+}
+
+// iterate yields the results as they are added, until wait, which waits for
+// the tasks, returns, after which it yields the error returned by wait, if
+// any. If yield returns false, cancel is called, if set, before waiting for
+// the tasks.
+func (r *resultAggregator[T]) iterate(wait func() error, cancel func(), yield func(T, error) bool) {
+	if r.flush != nil {
+		panic("pool: Iter cannot be used with WithResultFlush")
+	}
+
+	notify := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.notify = notify
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.notify = nil
+		r.results = nil
+		r.mu.Unlock()
+	}()
+
+	var (
+		pc     conc.PanicCatcher
+		err    error
+		waited = make(chan struct{})
+	)
+	go func() {
+		defer close(waited)
+		pc.Try(func() { err = wait() })
+	}()
+
+	// take removes and returns the results collected so far
+	take := func() []T {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		results := r.results
+		r.results = nil
+		return results
+	}
+
+	finished := false
+	for {
+		for _, res := range take() {
+			if !yield(res, nil) {
+				if cancel != nil {
+					cancel()
+				}
+				<-waited
+				pc.Repanic()
+				return
+			}
+		}
+		if finished {
+			break
+		}
+		select {
+		case <-notify:
+		case <-waited:
+			// No results are added once the tasks are done, so one more
+			// take collects all that remain.
+			finished = true
+		}
+	}
+
+	pc.Repanic()
+	if err != nil {
+		var zero T
+		yield(zero, err)
+	}
+}
@@ -0,0 +1,163 @@
+//go:build go1.23
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleResultPool_Iter() {
+	p := NewWithResults[int]()
+	for i := 0; i < 10; i++ {
+		i := i
+		p.Go(func() int {
+			return i * 2
+		})
+	}
+
+	var res []int
+	for v, err := range p.Iter() {
+		if err != nil {
+			panic(err)
+		}
+		res = append(res, v)
+	}
+	sort.Ints(res)
+	fmt.Println(res)
+
+	// Output:
+	// [0 2 4 6 8 10 12 14 16 18]
+}
+
+func TestResultIter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("yields results as tasks complete", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]().WithMaxGoroutines(2)
+		release := make(chan struct{})
+		p.Go(func() int { return 1 })
+		p.Go(func() int {
+			<-release
+			return 2
+		})
+
+		var res []int
+		for v, err := range p.Iter() {
+			require.NoError(t, err)
+			res = append(res, v)
+			if v == 1 {
+				// The first result arrives while the second task is
+				// still blocked
+				close(release)
+			}
+		}
+		require.Equal(t, []int{1, 2}, res)
+	})
+
+	t.Run("break waits for the remaining tasks", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]().WithMaxGoroutines(4)
+		var finished atomic.Int64
+		for i := 0; i < 20; i++ {
+			p.Go(func() int {
+				time.Sleep(time.Millisecond)
+				finished.Add(1)
+				return 1
+			})
+		}
+		for range p.Iter() {
+			break
+		}
+		require.Equal(t, int64(20), finished.Load())
+	})
+
+	t.Run("panics are propagated", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]()
+		p.Go(func() int { return 1 })
+		p.Go(func() int { panic("super bad thing happened") })
+		require.Panics(t, func() {
+			for range p.Iter() {
+			}
+		})
+	})
+
+	t.Run("error pool yields the error last", func(t *testing.T) {
+		t.Parallel()
+		err1 := errors.New("err1")
+		p := NewWithResults[int]().WithErrors()
+		p.Go(func() (int, error) { return 1, nil })
+		p.Go(func() (int, error) { return 0, err1 })
+		p.Go(func() (int, error) { return 3, nil })
+
+		var res []int
+		var errs []error
+		for v, err := range p.Iter() {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			require.Empty(t, errs, "results must come before the error")
+			res = append(res, v)
+		}
+		sort.Ints(res)
+		require.Equal(t, []int{1, 3}, res)
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], err1)
+	})
+
+	t.Run("context pool is canceled on break", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]().WithContext(context.Background()).WithMaxGoroutines(3)
+		var canceled atomic.Int64
+		p.Go(func(ctx context.Context) (int, error) { return 1, nil })
+		for i := 0; i < 2; i++ {
+			p.Go(func(ctx context.Context) (int, error) {
+				<-ctx.Done()
+				canceled.Add(1)
+				return 0, ctx.Err()
+			})
+		}
+		for v, err := range p.Iter() {
+			require.NoError(t, err)
+			require.Equal(t, 1, v)
+			break
+		}
+		require.Equal(t, int64(2), canceled.Load())
+	})
+
+	t.Run("reusable pool", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]().WithReuse()
+		defer p.Close()
+		for batch := 1; batch <= 2; batch++ {
+			for i := 0; i < 5; i++ {
+				p.Go(func() int { return batch })
+			}
+			sum := 0
+			for v := range p.Iter() {
+				sum += v
+			}
+			require.Equal(t, 5*batch, sum)
+		}
+	})
+
+	t.Run("panics with WithResultFlush", func(t *testing.T) {
+		t.Parallel()
+		p := NewWithResults[int]().WithResultFlush(1, func([]int) {})
+		require.Panics(t, func() {
+			for range p.Iter() {
+			}
+		})
+		p.Wait()
+	})
+}
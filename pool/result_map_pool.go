@@ -0,0 +1,301 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/conc"
+)
+
+// NewWithMapResults creates a new ResultMapPool for tasks with a key of type K
+// and a result of type V.
+func NewWithMapResults[K comparable, V any]() *ResultMapPool[K, V] {
+	return &ResultMapPool[K, V]{
+		pool: *New(),
+	}
+}
+
+// ResultMapPool is a pool that executes tasks that return a generic result
+// type, each submitted with a key. Tasks are executed in the pool with Go(),
+// then the results of the tasks are returned by Wait() as a map from each
+// task's key to its result.
+//
+// If more than one task is submitted with the same key, it is unspecified
+// which of their results is kept.
+type ResultMapPool[K comparable, V any] struct {
+	pool Pool
+	agg  mapAggregator[K, V]
+}
+
+// Go submits a task to the pool. The result of the task will be stored under
+// key in the map returned by Wait().
+func (p *ResultMapPool[K, V]) Go(key K, f func() V) {
+	p.pool.Go(func() {
+		p.agg.add(key, f())
+	})
+}
+
+// GoWeighted submits a task that counts as weight tasks against the pool's
+// limit, and whose result is stored under key. See Pool.GoWeighted.
+func (p *ResultMapPool[K, V]) GoWeighted(key K, weight int, f func() V) {
+	p.pool.GoWeighted(weight, func() {
+		p.agg.add(key, f())
+	})
+}
+
+// GoSized submits a task that is estimated to use bytes of memory while it
+// runs, and whose result is stored under key. See Pool.GoSized.
+func (p *ResultMapPool[K, V]) GoSized(key K, bytes int64, f func() V) {
+	p.pool.GoSized(bytes, func() {
+		p.agg.add(key, f())
+	})
+}
+
+// GoBlocking submits a task that spends most of its time blocked, on a
+// goroutine that does not count toward the pool's limit. See
+// Pool.GoBlocking.
+func (p *ResultMapPool[K, V]) GoBlocking(key K, f func() V) {
+	p.pool.GoBlocking(func() {
+		p.agg.add(key, f())
+	})
+}
+
+// Wait cleans up all spawned goroutines, propagating any panics, and returning
+// a map of results from tasks that did not panic.
+func (p *ResultMapPool[K, V]) Wait() map[K]V {
+	if p.pool.reusable {
+		defer p.agg.reset()
+	}
+	p.pool.Wait()
+	if p.agg.results == nil {
+		return map[K]V{}
+	}
+	return p.agg.results
+}
+
+// WaitContext is like Wait, but stops waiting when ctx is done, in which case
+// it returns a nil map and ctx.Err(). See Pool.WaitContext.
+func (p *ResultMapPool[K, V]) WaitContext(ctx context.Context) (map[K]V, error) {
+	var results map[K]V
+	if err := waitContext(ctx, func() { results = p.Wait() }); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// WaitTimeout is like WaitContext, but stops waiting after d.
+func (p *ResultMapPool[K, V]) WaitTimeout(d time.Duration) (map[K]V, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return p.WaitContext(ctx)
+}
+
+// Close stops the goroutines of a pool configured with WithReuse. See
+// Pool.Close.
+func (p *ResultMapPool[K, V]) Close() {
+	p.pool.Close()
+}
+
+// Pause stops the pool from starting any new tasks until Resume is called.
+// See Pool.Pause for details.
+func (p *ResultMapPool[K, V]) Pause() {
+	p.pool.Pause()
+}
+
+// Resume undoes a call to Pause, allowing held and queued tasks to start.
+func (p *ResultMapPool[K, V]) Resume() {
+	p.pool.Resume()
+}
+
+// Name returns the name of the pool qualified by the names of its parents.
+// See Pool.Name for details.
+func (p *ResultMapPool[K, V]) Name() string {
+	return p.pool.Name()
+}
+
+// Goroutines returns the number of goroutines the pool is running. See
+// Pool.Goroutines.
+func (p *ResultMapPool[K, V]) Goroutines() int {
+	return p.pool.Goroutines()
+}
+
+// Prewarm starts up to n workers ahead of the first tasks. See Pool.Prewarm.
+func (p *ResultMapPool[K, V]) Prewarm(n int) {
+	p.pool.Prewarm(n)
+}
+
+// SampledOutcomes returns the outcomes of tasks sampled by the pool, starting
+// with the most recent. See Pool.SampledOutcomes.
+func (p *ResultMapPool[K, V]) SampledOutcomes() []TaskStats {
+	return p.pool.SampledOutcomes()
+}
+
+// MaxGoroutines returns the maximum size of the pool.
+func (p *ResultMapPool[K, V]) MaxGoroutines() int {
+	return p.pool.MaxGoroutines()
+}
+
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *ResultMapPool[K, V]) WithName(name string) *ResultMapPool[K, V] {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithOptions applies the settings of opts that are set to the pool. See
+// Pool.WithOptions.
+func (p *ResultMapPool[K, V]) WithOptions(opts conc.Options) *ResultMapPool[K, V] {
+	p.pool.WithOptions(opts)
+	return p
+}
+
+// WithParent sets the parent of the pool in the naming hierarchy. See
+// Pool.WithParent.
+func (p *ResultMapPool[K, V]) WithParent(parent *Pool) *ResultMapPool[K, V] {
+	p.pool.WithParent(parent)
+	return p
+}
+
+// WithReuse configures the pool so that Wait can be called more than once,
+// each time returning the results of the tasks submitted since the previous
+// call. See Pool.WithReuse.
+func (p *ResultMapPool[K, V]) WithReuse() *ResultMapPool[K, V] {
+	p.pool.WithReuse()
+	return p
+}
+
+// WithInterceptor adds an interceptor that wraps every task submitted to the
+// pool. See Pool.WithInterceptor.
+func (p *ResultMapPool[K, V]) WithInterceptor(i Interceptor) *ResultMapPool[K, V] {
+	p.pool.WithInterceptor(i)
+	return p
+}
+
+// WithWorkerInit configures the pool to call init in each of its goroutines
+// before it runs its first task. See Pool.WithWorkerInit.
+func (p *ResultMapPool[K, V]) WithWorkerInit(init func() (state any, err error)) *ResultMapPool[K, V] {
+	p.pool.WithWorkerInit(init)
+	return p
+}
+
+// WithWorkerTeardown configures the pool to call teardown with the state of
+// each of its goroutines when it exits. See Pool.WithWorkerTeardown.
+func (p *ResultMapPool[K, V]) WithWorkerTeardown(teardown func(state any)) *ResultMapPool[K, V] {
+	p.pool.WithWorkerTeardown(teardown)
+	return p
+}
+
+// WithMisuseDetection configures the pool to panic when it is misused in a
+// way that would otherwise deadlock. See Pool.WithMisuseDetection.
+func (p *ResultMapPool[K, V]) WithMisuseDetection() *ResultMapPool[K, V] {
+	p.pool.WithMisuseDetection()
+	return p
+}
+
+// WithReentrancy configures the pool so that its tasks can submit more tasks
+// to it without deadlocking. See Pool.WithReentrancy.
+func (p *ResultMapPool[K, V]) WithReentrancy() *ResultMapPool[K, V] {
+	p.pool.WithReentrancy()
+	return p
+}
+
+// WithLockOSThread configures each goroutine of the pool to lock itself to
+// its OS thread for as long as it runs. See Pool.WithLockOSThread.
+func (p *ResultMapPool[K, V]) WithLockOSThread() *ResultMapPool[K, V] {
+	p.pool.WithLockOSThread()
+	return p
+}
+
+// WithIdleShutdown configures each worker of the pool to exit once it has
+// been idle for d. See Pool.WithIdleShutdown.
+func (p *ResultMapPool[K, V]) WithIdleShutdown(d time.Duration) *ResultMapPool[K, V] {
+	p.pool.WithIdleShutdown(d)
+	return p
+}
+
+// WithMemoryBudget limits the estimated memory of the tasks submitted with
+// GoSized that run at once. See Pool.WithMemoryBudget.
+func (p *ResultMapPool[K, V]) WithMemoryBudget(bytes int64) *ResultMapPool[K, V] {
+	p.pool.WithMemoryBudget(bytes)
+	return p
+}
+
+// WithMaxBlocking limits the number of tasks submitted with GoBlocking that
+// run outside the pool's limit at once. See Pool.WithMaxBlocking.
+func (p *ResultMapPool[K, V]) WithMaxBlocking(n int) *ResultMapPool[K, V] {
+	p.pool.WithMaxBlocking(n)
+	return p
+}
+
+// WithScheduler registers the pool with s, which shares its limit fairly
+// between pools according to their weights. See Pool.WithScheduler.
+func (p *ResultMapPool[K, V]) WithScheduler(s *Scheduler, weight int) *ResultMapPool[K, V] {
+	p.pool.WithScheduler(s, weight)
+	return p
+}
+
+// WithParentLimiter configures the pool to borrow its goroutines from the
+// concurrency budget of parent. See Pool.WithParentLimiter.
+func (p *ResultMapPool[K, V]) WithParentLimiter(parent *Pool) *ResultMapPool[K, V] {
+	p.pool.WithParentLimiter(parent)
+	return p
+}
+
+// WithProgress configures the pool to call f every time a task completes.
+// See Pool.WithProgress.
+func (p *ResultMapPool[K, V]) WithProgress(f func(done, total int)) *ResultMapPool[K, V] {
+	p.pool.WithProgress(f)
+	return p
+}
+
+// WithTaskObserver configures the pool to call f with the timings and
+// outcome of every task once it finishes. See Pool.WithTaskObserver.
+func (p *ResultMapPool[K, V]) WithTaskObserver(f func(TaskStats)) *ResultMapPool[K, V] {
+	p.pool.WithTaskObserver(f)
+	return p
+}
+
+// WithOutcomeSampling configures the pool to keep every failure and one in
+// every successes successful tasks among its last size outcomes. See
+// Pool.WithOutcomeSampling.
+func (p *ResultMapPool[K, V]) WithOutcomeSampling(successes, size int) *ResultMapPool[K, V] {
+	p.pool.WithOutcomeSampling(successes, size)
+	return p
+}
+
+// WithMaxGoroutines limits the number of goroutines in a pool.
+// Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
+func (p *ResultMapPool[K, V]) WithMaxGoroutines(n int) *ResultMapPool[K, V] {
+	p.pool.WithMaxGoroutines(n)
+	return p
+}
+
+// WithUnlimitedGoroutines removes the limit on the number of goroutines in a
+// pool. See Pool.WithUnlimitedGoroutines.
+func (p *ResultMapPool[K, V]) WithUnlimitedGoroutines() *ResultMapPool[K, V] {
+	p.pool.WithUnlimitedGoroutines()
+	return p
+}
+
+// mapAggregator is a utility type that lets us safely set map entries from
+// multiple goroutines. The zero value is valid and ready to use.
+type mapAggregator[K comparable, V any] struct {
+	mu      sync.Mutex
+	results map[K]V
+}
+
+func (r *mapAggregator[K, V]) add(key K, res V) {
+	r.mu.Lock()
+	if r.results == nil {
+		r.results = make(map[K]V)
+	}
+	r.results[key] = res
+	r.mu.Unlock()
+}
+
+// reset discards the collected results so that the aggregator can be reused.
+func (r *mapAggregator[K, V]) reset() {
+	r.mu.Lock()
+	r.results = nil
+	r.mu.Unlock()
+}
@@ -0,0 +1,86 @@
+package pool
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleResultMapPool() {
+	p := NewWithMapResults[string, int]()
+	for _, word := range []string{"one", "three", "five"} {
+		word := word
+		p.Go(word, func() int {
+			return len(word)
+		})
+	}
+	res := p.Wait()
+	fmt.Println(res["one"], res["three"], res["five"])
+
+	// Output:
+	// 3 5 4
+}
+
+func TestResultMapPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("basic", func(t *testing.T) {
+		g := NewWithMapResults[int, string]()
+		expected := map[int]string{}
+		for i := 0; i < 100; i++ {
+			i := i
+			expected[i] = strconv.Itoa(i)
+			g.Go(i, func() string {
+				return strconv.Itoa(i)
+			})
+		}
+		require.Equal(t, expected, g.Wait())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		g := NewWithMapResults[int, int]()
+		res := g.Wait()
+		require.NotNil(t, res)
+		require.Len(t, res, 0)
+	})
+
+	t.Run("panic is propagated", func(t *testing.T) {
+		g := NewWithMapResults[int, int]()
+		g.Go(1, func() int { panic("super bad thing") })
+		require.Panics(t, func() { g.Wait() })
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		t.Parallel()
+		for _, maxGoroutines := range []int{1, 10, 100} {
+			maxGoroutines := maxGoroutines
+			t.Run(strconv.Itoa(maxGoroutines), func(t *testing.T) {
+				g := NewWithMapResults[int, int]().WithMaxGoroutines(maxGoroutines)
+
+				var currentConcurrent atomic.Int64
+				var errCount atomic.Int64
+				taskCount := maxGoroutines * 10
+				for i := 0; i < taskCount; i++ {
+					i := i
+					g.Go(i, func() int {
+						cur := currentConcurrent.Add(1)
+						if cur > int64(maxGoroutines) {
+							errCount.Add(1)
+						}
+						time.Sleep(time.Millisecond)
+						currentConcurrent.Add(-1)
+						return i
+					})
+				}
+				res := g.Wait()
+				require.Len(t, res, taskCount)
+				require.Equal(t, int64(0), errCount.Load())
+				require.Equal(t, int64(0), currentConcurrent.Load())
+			})
+		}
+	})
+}
@@ -3,6 +3,9 @@ package pool
 import (
 	"context"
 	"sync"
+	"time"
+
+	"github.com/sourcegraph/conc"
 )
 
 // NewWithResults creates a new ResultPool for tasks with a result of type T.
@@ -31,13 +34,141 @@ func (p *ResultPool[T]) Go(f func() T) {
 	})
 }
 
+// GoLabeled submits a task with a label, so that it can be canceled by
+// CancelWhere until it starts. See Pool.GoLabeled.
+func (p *ResultPool[T]) GoLabeled(label string, f func() T) {
+	p.pool.GoLabeled(label, func() {
+		p.agg.add(f())
+	})
+}
+
+// GoMemoized submits a task identified by key, which must be comparable, so
+// that tasks submitted with the same key share a result. Only the first task
+// submitted with a key in a run of the pool is run, and its result is
+// collected once for every submission with that key, including those made
+// after it has finished. This is unlike singleflight, which only shares the
+// result of a call while it is in flight, and suits jobs whose inputs
+// contain duplicates. A run lasts until Wait, or until each call to Wait for
+// a pool configured with WithReuse.
+//
+// The submissions that reuse a result are not tasks of their own: they take
+// no goroutine, and are not counted by WithProgress nor seen by interceptors.
+// If the task panics, they have no result.
+func (p *ResultPool[T]) GoMemoized(key any, f func() T) {
+	if !p.agg.memoize(key) {
+		return
+	}
+	p.pool.Go(func() {
+		p.agg.memoized(key, f(), true)
+	})
+}
+
+// GoWeighted submits a task that counts as weight tasks against the pool's
+// limit. See Pool.GoWeighted.
+func (p *ResultPool[T]) GoWeighted(weight int, f func() T) {
+	p.pool.GoWeighted(weight, func() {
+		p.agg.add(f())
+	})
+}
+
+// GoSized submits a task that is estimated to use bytes of memory while it
+// runs. See Pool.GoSized.
+func (p *ResultPool[T]) GoSized(bytes int64, f func() T) {
+	p.pool.GoSized(bytes, func() {
+		p.agg.add(f())
+	})
+}
+
+// GoBlocking submits a task that spends most of its time blocked, on a
+// goroutine that does not count toward the pool's limit. See
+// Pool.GoBlocking.
+func (p *ResultPool[T]) GoBlocking(f func() T) {
+	p.pool.GoBlocking(func() {
+		p.agg.add(f())
+	})
+}
+
 // Wait cleans up all spawned goroutines, propagating any panics, and returning
 // a slice of results from tasks that did not panic.
 func (p *ResultPool[T]) Wait() []T {
+	if p.pool.reusable {
+		defer p.agg.reset()
+	}
 	p.pool.Wait()
+	p.agg.flushRest()
 	return p.agg.results
 }
 
+// WaitContext is like Wait, but stops waiting when ctx is done, in which case
+// it returns no results and ctx.Err(). See Pool.WaitContext.
+func (p *ResultPool[T]) WaitContext(ctx context.Context) ([]T, error) {
+	var results []T
+	if err := waitContext(ctx, func() { results = p.Wait() }); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// WaitTimeout is like WaitContext, but stops waiting after d.
+func (p *ResultPool[T]) WaitTimeout(d time.Duration) ([]T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return p.WaitContext(ctx)
+}
+
+// Close stops the goroutines of a pool configured with WithReuse. See
+// Pool.Close.
+func (p *ResultPool[T]) Close() {
+	p.pool.Close()
+}
+
+// Pause stops the pool from starting any new tasks until Resume is called.
+// See Pool.Pause for details.
+func (p *ResultPool[T]) Pause() {
+	p.pool.Pause()
+}
+
+// Resume undoes a call to Pause, allowing held and queued tasks to start.
+func (p *ResultPool[T]) Resume() {
+	p.pool.Resume()
+}
+
+// CancelWhere cancels the labeled tasks that have not started yet and whose
+// label matches the predicate, and returns how many were canceled. See
+// Pool.CancelWhere.
+func (p *ResultPool[T]) CancelWhere(pred func(label string) bool) int {
+	return p.pool.CancelWhere(pred)
+}
+
+// QueuedLabels returns the labels of the labeled tasks that have not started
+// yet. See Pool.QueuedLabels.
+func (p *ResultPool[T]) QueuedLabels() []string {
+	return p.pool.QueuedLabels()
+}
+
+// Name returns the name of the pool qualified by the names of its parents.
+// See Pool.Name for details.
+func (p *ResultPool[T]) Name() string {
+	return p.pool.Name()
+}
+
+// Goroutines returns the number of goroutines the pool is running. See
+// Pool.Goroutines.
+func (p *ResultPool[T]) Goroutines() int {
+	return p.pool.Goroutines()
+}
+
+// Prewarm starts up to n workers ahead of the first tasks. See Pool.Prewarm.
+func (p *ResultPool[T]) Prewarm(n int) {
+	p.pool.Prewarm(n)
+}
+
+// SampledOutcomes returns the outcomes of tasks sampled by the pool, starting
+// with the most recent. See Pool.SampledOutcomes.
+func (p *ResultPool[T]) SampledOutcomes() []TaskStats {
+	return p.pool.SampledOutcomes()
+}
+
 // MaxGoroutines returns the maximum size of the pool.
 func (p *ResultPool[T]) MaxGoroutines() int {
 	return p.pool.MaxGoroutines()
@@ -59,6 +190,140 @@ func (p *ResultPool[T]) WithContext(ctx context.Context) *ResultContextPool[T] {
 	}
 }
 
+// WithName sets the name of the pool. See Pool.WithName.
+func (p *ResultPool[T]) WithName(name string) *ResultPool[T] {
+	p.pool.WithName(name)
+	return p
+}
+
+// WithOptions applies the settings of opts that are set to the pool. See
+// Pool.WithOptions.
+func (p *ResultPool[T]) WithOptions(opts conc.Options) *ResultPool[T] {
+	p.pool.WithOptions(opts)
+	return p
+}
+
+// WithParent sets the parent of the pool in the naming hierarchy. See
+// Pool.WithParent.
+func (p *ResultPool[T]) WithParent(parent *Pool) *ResultPool[T] {
+	p.pool.WithParent(parent)
+	return p
+}
+
+// WithReuse configures the pool so that Wait can be called more than once,
+// each time returning the results of the tasks submitted since the previous
+// call. See Pool.WithReuse.
+func (p *ResultPool[T]) WithReuse() *ResultPool[T] {
+	p.pool.WithReuse()
+	return p
+}
+
+// WithInterceptor adds an interceptor that wraps every task submitted to the
+// pool. See Pool.WithInterceptor.
+func (p *ResultPool[T]) WithInterceptor(i Interceptor) *ResultPool[T] {
+	p.pool.WithInterceptor(i)
+	return p
+}
+
+// WithWorkerInit configures the pool to call init in each of its goroutines
+// before it runs its first task. See Pool.WithWorkerInit.
+func (p *ResultPool[T]) WithWorkerInit(init func() (state any, err error)) *ResultPool[T] {
+	p.pool.WithWorkerInit(init)
+	return p
+}
+
+// WithWorkerTeardown configures the pool to call teardown with the state of
+// each of its goroutines when it exits. See Pool.WithWorkerTeardown.
+func (p *ResultPool[T]) WithWorkerTeardown(teardown func(state any)) *ResultPool[T] {
+	p.pool.WithWorkerTeardown(teardown)
+	return p
+}
+
+// WithMisuseDetection configures the pool to panic when it is misused in a
+// way that would otherwise deadlock. See Pool.WithMisuseDetection.
+func (p *ResultPool[T]) WithMisuseDetection() *ResultPool[T] {
+	p.pool.WithMisuseDetection()
+	return p
+}
+
+// WithReentrancy configures the pool so that its tasks can submit more tasks
+// to it without deadlocking. See Pool.WithReentrancy.
+func (p *ResultPool[T]) WithReentrancy() *ResultPool[T] {
+	p.pool.WithReentrancy()
+	return p
+}
+
+// WithLockOSThread configures each goroutine of the pool to lock itself to
+// its OS thread for as long as it runs. See Pool.WithLockOSThread.
+func (p *ResultPool[T]) WithLockOSThread() *ResultPool[T] {
+	p.pool.WithLockOSThread()
+	return p
+}
+
+// WithIdleShutdown configures each worker of the pool to exit once it has
+// been idle for d. See Pool.WithIdleShutdown.
+func (p *ResultPool[T]) WithIdleShutdown(d time.Duration) *ResultPool[T] {
+	p.pool.WithIdleShutdown(d)
+	return p
+}
+
+// WithMemoryBudget limits the estimated memory of the tasks submitted with
+// GoSized that run at once. See Pool.WithMemoryBudget.
+func (p *ResultPool[T]) WithMemoryBudget(bytes int64) *ResultPool[T] {
+	p.pool.WithMemoryBudget(bytes)
+	return p
+}
+
+// WithMaxBlocking limits the number of tasks submitted with GoBlocking that
+// run outside the pool's limit at once. See Pool.WithMaxBlocking.
+func (p *ResultPool[T]) WithMaxBlocking(n int) *ResultPool[T] {
+	p.pool.WithMaxBlocking(n)
+	return p
+}
+
+// WithScheduler registers the pool with s, which shares its limit fairly
+// between pools according to their weights. See Pool.WithScheduler.
+func (p *ResultPool[T]) WithScheduler(s *Scheduler, weight int) *ResultPool[T] {
+	p.pool.WithScheduler(s, weight)
+	return p
+}
+
+// WithParentLimiter configures the pool to borrow its goroutines from the
+// concurrency budget of parent. See Pool.WithParentLimiter.
+func (p *ResultPool[T]) WithParentLimiter(parent *Pool) *ResultPool[T] {
+	p.pool.WithParentLimiter(parent)
+	return p
+}
+
+// WithProgress configures the pool to call f every time a task completes.
+// See Pool.WithProgress.
+func (p *ResultPool[T]) WithProgress(f func(done, total int)) *ResultPool[T] {
+	p.pool.WithProgress(f)
+	return p
+}
+
+// WithTaskObserver configures the pool to call f with the timings and
+// outcome of every task once it finishes. See Pool.WithTaskObserver.
+func (p *ResultPool[T]) WithTaskObserver(f func(TaskStats)) *ResultPool[T] {
+	p.pool.WithTaskObserver(f)
+	return p
+}
+
+// WithOutcomeSampling configures the pool to keep every failure and one in
+// every successes successful tasks among its last size outcomes. See
+// Pool.WithOutcomeSampling.
+func (p *ResultPool[T]) WithOutcomeSampling(successes, size int) *ResultPool[T] {
+	p.pool.WithOutcomeSampling(successes, size)
+	return p
+}
+
+// WithTracer configures the pool to call f with every event in the life of
+// its tasks. See Pool.WithTracer.
+func (p *ResultPool[T]) WithTracer(f func(TraceEvent)) *ResultPool[T] {
+	p.pool.WithTracer(f)
+	return p
+}
+
 // WithMaxGoroutines limits the number of goroutines in a pool.
 // Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
 func (p *ResultPool[T]) WithMaxGoroutines(n int) *ResultPool[T] {
@@ -66,15 +331,165 @@ func (p *ResultPool[T]) WithMaxGoroutines(n int) *ResultPool[T] {
 	return p
 }
 
+// WithUnlimitedGoroutines removes the limit on the number of goroutines in a
+// pool. See Pool.WithUnlimitedGoroutines.
+func (p *ResultPool[T]) WithUnlimitedGoroutines() *ResultPool[T] {
+	p.pool.WithUnlimitedGoroutines()
+	return p
+}
+
+// WithResultFlush configures the pool to call fn with the results of its
+// tasks in batches of n as they are collected, rather than returning them all
+// from Wait, so that the results of a large job do not all have to be kept
+// in memory. fn is called by the task whose result completes a batch, and by
+// Wait with the results that remain, if any. Calls to fn do not overlap, and
+// fn may keep the slices it is passed. Wait returns no results when fn is
+// set. Panics if n < 1.
+func (p *ResultPool[T]) WithResultFlush(n int, fn func([]T)) *ResultPool[T] {
+	p.agg.setFlush(n, fn)
+	return p
+}
+
 // resultAggregator is a utility type that lets us safely append from multiple
 // goroutines. The zero value is valid and ready to use.
 type resultAggregator[T any] struct {
 	mu      sync.Mutex
 	results []T
+
+	// flushSize and flush are set by WithResultFlush. flushMu is held while
+	// flush is called, so that calls do not overlap.
+	flushSize int
+	flush     func([]T)
+	flushMu   sync.Mutex
+
+	// notify, if set, is signaled whenever a result is added, for Iter
+	notify chan struct{}
+
+	// memo holds the tasks submitted with GoMemoized in this run, by key
+	memo map[any]*memoEntry[T]
+}
+
+// memoEntry is the state of a task submitted with GoMemoized.
+type memoEntry[T any] struct {
+	done bool
+	// collected is whether the result of the task was collected, which
+	// decides whether it is collected for the other submissions
+	collected bool
+	res       T
+	// waiting is the number of other submissions made while the task was
+	// running, whose results are added once it is done
+	waiting int
 }
 
 func (r *resultAggregator[T]) add(res T) {
 	r.mu.Lock()
+	if r.flush == nil {
+		r.results = append(r.results, res)
+		if r.notify != nil {
+			select {
+			case r.notify <- struct{}{}:
+			default:
+				// The iterator has yet to pick up an earlier result
+			}
+		}
+		r.mu.Unlock()
+		return
+	}
+
+	if r.results == nil {
+		r.results = make([]T, 0, r.flushSize)
+	}
 	r.results = append(r.results, res)
+	if len(r.results) < r.flushSize {
+		r.mu.Unlock()
+		return
+	}
+	batch := r.results
+	r.results = nil
+	// Take flushMu before releasing mu, so that batches are flushed in the
+	// order they were filled.
+	r.flushMu.Lock()
+	r.mu.Unlock()
+	defer r.flushMu.Unlock()
+	r.flush(batch)
+}
+
+// setFlush configures the aggregator to pass its results to flush in batches
+// of n. Panics if n < 1.
+func (r *resultAggregator[T]) setFlush(n int, flush func([]T)) {
+	if n < 1 {
+		panic("result flush size must be greater than zero")
+	}
+	r.flushSize = n
+	r.flush = flush
+}
+
+// flushRest passes the results that have not been flushed yet to the flush
+// callback, if there is one.
+func (r *resultAggregator[T]) flushRest() {
+	if r.flush == nil {
+		return
+	}
+	r.mu.Lock()
+	batch := r.results
+	r.results = nil
+	r.mu.Unlock()
+
+	if len(batch) > 0 {
+		r.flushMu.Lock()
+		defer r.flushMu.Unlock()
+		r.flush(batch)
+	}
+}
+
+// memoize reports whether the task submitted with key should be run, which
+// is the case for the first submission with key. Any other submission
+// shares the result of that task: it is added now if the task is done, or
+// when the task calls memoized.
+func (r *resultAggregator[T]) memoize(key any) bool {
+	r.mu.Lock()
+	if r.memo == nil {
+		r.memo = make(map[any]*memoEntry[T])
+	}
+	e, ok := r.memo[key]
+	if !ok {
+		r.memo[key] = &memoEntry[T]{}
+		r.mu.Unlock()
+		return true
+	}
+	if !e.done {
+		e.waiting++
+		r.mu.Unlock()
+		return false
+	}
+	res, collected := e.res, e.collected
+	r.mu.Unlock()
+	if collected {
+		r.add(res)
+	}
+	return false
+}
+
+// memoized records the result of the task run for key, and adds it for the
+// task and for each submission waiting for it, if it is to be collected.
+func (r *resultAggregator[T]) memoized(key any, res T, collected bool) {
+	r.mu.Lock()
+	e := r.memo[key]
+	e.done, e.collected, e.res = true, collected, res
+	n := e.waiting
+	e.waiting = 0
+	r.mu.Unlock()
+	if collected {
+		for i := 0; i <= n; i++ {
+			r.add(res)
+		}
+	}
+}
+
+// reset discards the collected results so that the aggregator can be reused.
+func (r *resultAggregator[T]) reset() {
+	r.mu.Lock()
+	r.results = nil
+	r.memo = nil
 	r.mu.Unlock()
 }
@@ -46,6 +46,118 @@ func TestResultGroup(t *testing.T) {
 		require.Equal(t, expected, res)
 	})
 
+	t.Run("reuse returns results per batch", func(t *testing.T) {
+		g := NewWithResults[int]().WithReuse()
+		defer g.Close()
+
+		for batch := 0; batch < 3; batch++ {
+			for i := 0; i < 10; i++ {
+				g.Go(func() int { return batch })
+			}
+			res := g.Wait()
+			require.Len(t, res, 10)
+			for _, r := range res {
+				require.Equal(t, batch, r)
+			}
+		}
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		var lastDone, lastTotal int
+		g := NewWithResults[int]().WithMaxGoroutines(4).WithProgress(func(done, total int) {
+			require.Equal(t, lastDone+1, done)
+			require.GreaterOrEqual(t, total, lastTotal)
+			require.LessOrEqual(t, done, total)
+			lastDone, lastTotal = done, total
+		})
+		for i := 0; i < 100; i++ {
+			i := i
+			g.Go(func() int { return i })
+		}
+		res := g.Wait()
+		require.Len(t, res, 100)
+		require.Equal(t, 100, lastDone)
+		require.Equal(t, 100, lastTotal)
+	})
+
+	t.Run("result flush", func(t *testing.T) {
+		t.Parallel()
+		var (
+			flushing atomic.Bool
+			batches  [][]int
+		)
+		g := NewWithResults[int]().WithMaxGoroutines(4).WithResultFlush(10, func(batch []int) {
+			require.False(t, flushing.Swap(true), "calls to fn overlap")
+			defer flushing.Store(false)
+			batches = append(batches, batch)
+		})
+		for i := 0; i < 95; i++ {
+			i := i
+			g.Go(func() int { return i })
+		}
+		require.Empty(t, g.Wait())
+
+		var all []int
+		for i, batch := range batches {
+			if i < len(batches)-1 {
+				require.Len(t, batch, 10)
+			}
+			all = append(all, batch...)
+		}
+		require.Len(t, batches, 10)
+		require.Len(t, batches[9], 5)
+		sort.Ints(all)
+		for i, res := range all {
+			require.Equal(t, i, res)
+		}
+	})
+
+	t.Run("memoized", func(t *testing.T) {
+		t.Parallel()
+		var calls [5]atomic.Int64
+		g := NewWithResults[int]().WithMaxGoroutines(4)
+		for i := 0; i < 100; i++ {
+			key := i % 5
+			g.GoMemoized(key, func() int {
+				calls[key].Add(1)
+				time.Sleep(time.Millisecond)
+				return key * 10
+			})
+		}
+		res := g.Wait()
+		// Every submission gets the result of its key, which was computed once
+		require.Len(t, res, 100)
+		counts := map[int]int{}
+		for _, r := range res {
+			counts[r]++
+		}
+		require.Equal(t, map[int]int{0: 20, 10: 20, 20: 20, 30: 20, 40: 20}, counts)
+		for i := range calls {
+			require.Equal(t, int64(1), calls[i].Load())
+		}
+	})
+
+	t.Run("memoized across batches of reuse", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithResults[int]().WithReuse()
+		defer g.Close()
+
+		var calls atomic.Int64
+		for batch := 0; batch < 2; batch++ {
+			g.GoMemoized("key", func() int { return int(calls.Add(1)) })
+			res := g.Wait()
+			// Submitted once the first task is done, so it cannot be in flight
+			g.GoMemoized("key", func() int { return int(calls.Add(1)) })
+			res = append(res, g.Wait()...)
+			require.Equal(t, []int{batch*2 + 1, batch*2 + 2}, res)
+		}
+	})
+
+	t.Run("result flush panics on invalid size", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { NewWithResults[int]().WithResultFlush(0, func([]int) {}) })
+	})
+
 	t.Run("limit", func(t *testing.T) {
 		t.Parallel()
 		for _, maxGoroutines := range []int{1, 10, 100} {
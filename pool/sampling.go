@@ -0,0 +1,97 @@
+package pool
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WithOutcomeSampling configures the pool to keep a sample of the outcomes of
+// its tasks, which can be retrieved with SampledOutcomes, so that the recent
+// activity of a job running millions of tasks can be inspected without
+// logging every task. Every task that fails or panics is sampled, along with
+// one in every successes tasks that succeed, starting with the first. The
+// last size samples are kept. Panics if successes < 1 or size < 1.
+//
+// Sampling applies to the tasks that start running, like WithTaskObserver,
+// and works alongside it.
+func (p *Pool) WithOutcomeSampling(successes, size int) *Pool {
+	if successes < 1 {
+		panic("success sampling rate must be greater than zero")
+	}
+	if size < 1 {
+		panic("sample size must be greater than zero")
+	}
+	p.sampler = &outcomeSampler{
+		successRate: int64(successes),
+		samples:     make([]TaskStats, 0, size),
+	}
+	return p
+}
+
+// SampledOutcomes returns the outcomes of tasks sampled by the pool, starting
+// with the most recent. It returns nil if the pool is not configured with
+// WithOutcomeSampling. It may be called while tasks are running.
+func (p *Pool) SampledOutcomes() []TaskStats {
+	if p.sampler == nil {
+		return nil
+	}
+	return p.sampler.snapshot()
+}
+
+// observed reports whether the outcomes of the pool's tasks must be reported
+// to observe.
+func (p *Pool) observed() bool {
+	return p.observer != nil || p.sampler != nil
+}
+
+// observe reports the outcome of a task to the task observer and the
+// sampler of the pool.
+func (p *Pool) observe(stats TaskStats) {
+	if p.sampler != nil {
+		p.sampler.record(stats)
+	}
+	if p.observer != nil {
+		p.observer(stats)
+	}
+}
+
+// outcomeSampler keeps a ring of sampled task outcomes. See
+// WithOutcomeSampling.
+type outcomeSampler struct {
+	successRate int64
+	successes   atomic.Int64
+
+	mu sync.Mutex
+	// samples is used as a ring once it is full, and next is the index of
+	// the oldest sample, which the next sample replaces
+	samples []TaskStats
+	next    int
+}
+
+func (s *outcomeSampler) record(stats TaskStats) {
+	succeeded := stats.Err == nil && !stats.Panicked
+	if succeeded && (s.successes.Add(1)-1)%s.successRate != 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.samples) < cap(s.samples) {
+		s.samples = append(s.samples, stats)
+		return
+	}
+	s.samples[s.next] = stats
+	s.next = (s.next + 1) % len(s.samples)
+}
+
+func (s *outcomeSampler) snapshot() []TaskStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := make([]TaskStats, 0, len(s.samples))
+	for i := len(s.samples) - 1; i >= 0; i-- {
+		samples = append(samples, s.samples[(s.next+i)%len(s.samples)])
+	}
+	return samples
+}
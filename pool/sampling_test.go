@@ -0,0 +1,121 @@
+package pool
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func TestWithOutcomeSampling(t *testing.T) {
+	t.Parallel()
+
+	err1 := errors.New("err1")
+
+	t.Run("samples one in n successes", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithMaxGoroutines(4).WithOutcomeSampling(10, 100)
+		for i := 0; i < 100; i++ {
+			p.Go(func() {})
+		}
+		p.Wait()
+		require.Len(t, p.SampledOutcomes(), 10)
+	})
+
+	t.Run("samples every failure", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithErrors().WithMaxGoroutines(4).WithOutcomeSampling(1000, 100)
+		for i := 0; i < 100; i++ {
+			i := i
+			p.Go(func() error {
+				if i%10 == 0 {
+					return err1
+				}
+				return nil
+			})
+		}
+		require.ErrorIs(t, p.Wait(), err1)
+
+		var failed int
+		for _, stats := range p.SampledOutcomes() {
+			if stats.Err != nil {
+				require.ErrorIs(t, stats.Err, err1)
+				failed++
+			}
+		}
+		require.Equal(t, 10, failed)
+		// And the first success
+		require.Len(t, p.SampledOutcomes(), 11)
+	})
+
+	t.Run("keeps the most recent", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithErrors().WithMaxGoroutines(1).WithOutcomeSampling(1, 3)
+		for i := 0; i < 10; i++ {
+			i := i
+			p.Go(func() error {
+				if i >= 8 {
+					return err1
+				}
+				return nil
+			})
+		}
+		require.ErrorIs(t, p.Wait(), err1)
+
+		samples := p.SampledOutcomes()
+		require.Len(t, samples, 3)
+		require.Error(t, samples[0].Err)
+		require.Error(t, samples[1].Err)
+		require.NoError(t, samples[2].Err)
+		require.False(t, samples[0].Finished.Before(samples[1].Finished))
+	})
+
+	t.Run("samples panics", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithOutcomeSampling(1000, 10)
+		p.Go(func() {})
+		p.Go(func() { panic("super bad thing") })
+		require.Panics(t, p.Wait)
+
+		var panicked int
+		for _, stats := range p.SampledOutcomes() {
+			if stats.Panicked {
+				panicked++
+			}
+		}
+		require.Equal(t, 1, panicked)
+	})
+
+	t.Run("alongside a task observer", func(t *testing.T) {
+		t.Parallel()
+		var observed atomic.Int64
+		p := New().WithTaskObserver(func(TaskStats) { observed.Add(1) }).WithOutcomeSampling(2, 10)
+		for i := 0; i < 4; i++ {
+			p.Go(func() {})
+		}
+		p.Wait()
+		require.Equal(t, int64(4), observed.Load())
+		require.Len(t, p.SampledOutcomes(), 2)
+	})
+
+	t.Run("unlimited pools", func(t *testing.T) {
+		t.Parallel()
+		p := New().WithUnlimitedGoroutines().WithOutcomeSampling(1, 10)
+		p.Go(func() {})
+		p.Wait()
+		require.Len(t, p.SampledOutcomes(), 1)
+	})
+
+	t.Run("nil without sampling", func(t *testing.T) {
+		t.Parallel()
+		require.Nil(t, New().SampledOutcomes())
+	})
+
+	t.Run("panics on invalid arguments", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { New().WithOutcomeSampling(0, 10) })
+		require.Panics(t, func() { New().WithOutcomeSampling(1, 0) })
+	})
+}
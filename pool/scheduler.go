@@ -0,0 +1,110 @@
+package pool
+
+import (
+	"sync"
+)
+
+// Scheduler shares one concurrency limit between several pools, so that a
+// busy pool cannot starve the other pools on the same machine. Pools are
+// registered with a Scheduler with WithScheduler, each with a weight. While
+// the limit is reached, the next slot to be freed goes to the waiting pool
+// that has started the fewest tasks relative to its weight, so a pool with
+// weight 2 starts twice as many tasks as a pool with weight 1 while both are
+// busy. Idle pools do not build up credit to spend later.
+//
+// A Scheduler must be created with NewScheduler. It is safe for concurrent
+// use by any number of pools.
+type Scheduler struct {
+	mu   sync.Mutex
+	free int
+	// vclock is the virtual start time of the most recently started task.
+	vclock float64
+	// backlogged holds the members with tasks waiting for a slot.
+	backlogged []*schedulerMember
+}
+
+// NewScheduler creates a Scheduler that lets at most n tasks run at once
+// across all of its pools. Panics if n < 1.
+func NewScheduler(n int) *Scheduler {
+	if n < 1 {
+		panic("scheduler limit must be greater than zero")
+	}
+	return &Scheduler{free: n}
+}
+
+// schedulerMember is the registration of a pool with a Scheduler.
+type schedulerMember struct {
+	scheduler *Scheduler
+	weight    int
+
+	// vtime is the virtual time at which the member's next task may start,
+	// guarded by scheduler.mu. Every start advances it by 1/weight.
+	vtime   float64
+	waiters []chan struct{}
+}
+
+// acquire blocks until the member's task may start.
+func (m *schedulerMember) acquire() {
+	s := m.scheduler
+	s.mu.Lock()
+	if s.free > 0 && len(s.backlogged) == 0 {
+		s.free--
+		s.start(m)
+		s.mu.Unlock()
+		return
+	}
+
+	ready := make(chan struct{})
+	if len(m.waiters) == 0 {
+		s.backlogged = append(s.backlogged, m)
+	}
+	m.waiters = append(m.waiters, ready)
+	s.mu.Unlock()
+	<-ready
+}
+
+// release frees the slot of a finished task, handing it to the next waiting
+// task, if any.
+func (m *schedulerMember) release() {
+	s := m.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.backlogged) == 0 {
+		s.free++
+		return
+	}
+
+	// Pick the member that is furthest behind. Ties go to the member that
+	// has been waiting the longest, which comes first.
+	next := 0
+	for i, candidate := range s.backlogged {
+		if s.startTime(candidate) < s.startTime(s.backlogged[next]) {
+			next = i
+		}
+	}
+	member := s.backlogged[next]
+	ready := member.waiters[0]
+	member.waiters = member.waiters[1:]
+	if len(member.waiters) == 0 {
+		s.backlogged = append(s.backlogged[:next], s.backlogged[next+1:]...)
+	}
+	s.start(member)
+	close(ready)
+}
+
+// startTime returns the virtual time at which m would start its next task.
+// Members that have been idle start from the current virtual time, so that
+// they cannot make up for the time they were idle.
+func (s *Scheduler) startTime(m *schedulerMember) float64 {
+	if m.vtime < s.vclock {
+		return s.vclock
+	}
+	return m.vtime
+}
+
+// start records that m starts a task.
+func (s *Scheduler) start(m *schedulerMember) {
+	s.vclock = s.startTime(m)
+	m.vtime = s.vclock + 1/float64(m.weight)
+}
@@ -0,0 +1,109 @@
+package pool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+
+	// waiting returns the number of tasks waiting for a slot in s.
+	waiting := func(s *Scheduler) int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		n := 0
+		for _, m := range s.backlogged {
+			n += len(m.waiters)
+		}
+		return n
+	}
+
+	t.Run("limits tasks across pools", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewScheduler(3)
+		var running, maxRunning atomic.Int64
+		task := func() {
+			cur := running.Add(1)
+			for {
+				seen := maxRunning.Load()
+				if cur <= seen || maxRunning.CompareAndSwap(seen, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+		}
+
+		pools := []*Pool{
+			New().WithMaxGoroutines(5).WithScheduler(s, 1),
+			New().WithMaxGoroutines(5).WithScheduler(s, 2),
+		}
+		for _, p := range pools {
+			for i := 0; i < 20; i++ {
+				p.Go(task)
+			}
+		}
+		for _, p := range pools {
+			p.Wait()
+		}
+		require.Equal(t, int64(3), maxRunning.Load())
+	})
+
+	t.Run("starts tasks in proportion to weights", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewScheduler(1)
+		blocker := New().WithScheduler(s, 1)
+		release := make(chan struct{})
+		started := make(chan struct{})
+		blocker.Go(func() {
+			close(started)
+			<-release
+		})
+		<-started
+
+		var (
+			mu    sync.Mutex
+			order []string
+		)
+		record := func(name string) func() {
+			return func() {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+			}
+		}
+		heavy := New().WithMaxGoroutines(10).WithScheduler(s, 3)
+		light := New().WithMaxGoroutines(10).WithScheduler(s, 1)
+		for i := 0; i < 10; i++ {
+			heavy.Go(record("heavy"))
+			light.Go(record("light"))
+		}
+		require.Eventually(t, func() bool { return waiting(s) == 20 }, time.Second, time.Millisecond)
+
+		close(release)
+		blocker.Wait()
+		heavy.Wait()
+		light.Wait()
+
+		heavyStarts := 0
+		for _, name := range order[:8] {
+			if name == "heavy" {
+				heavyStarts++
+			}
+		}
+		require.Equal(t, 6, heavyStarts)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { NewScheduler(0) })
+		require.Panics(t, func() { New().WithScheduler(NewScheduler(1), 0) })
+	})
+}
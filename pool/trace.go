@@ -0,0 +1,117 @@
+package pool
+
+import (
+	"strconv"
+	"time"
+)
+
+// TraceKind is the kind of a TraceEvent.
+type TraceKind int
+
+const (
+	// TraceSubmitted is recorded when a task is submitted to the pool.
+	TraceSubmitted TraceKind = iota
+	// TraceQueued is recorded when the call that submits a task has to wait
+	// for the pool to accept it, because the pool is saturated.
+	TraceQueued
+	// TraceStarted is recorded when a task starts running.
+	TraceStarted
+	// TraceFinished is recorded when a task returns or panics.
+	TraceFinished
+	// TraceCanceled is recorded when a task is canceled by CancelWhere.
+	TraceCanceled
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceSubmitted:
+		return "submitted"
+	case TraceQueued:
+		return "queued"
+	case TraceStarted:
+		return "started"
+	case TraceFinished:
+		return "finished"
+	case TraceCanceled:
+		return "canceled"
+	default:
+		return "TraceKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// TraceEvent is an event in the life of a task. See WithTracer.
+type TraceEvent struct {
+	Kind TraceKind
+	// Pool is the name of the pool, as returned by Pool.Name.
+	Pool string
+	// Name is the name or label the task was submitted with, if any.
+	Name string
+	// Task numbers the tasks of the pool in the order they were submitted,
+	// starting at 0, so that the events of a task can be told apart from
+	// those of other tasks with the same name.
+	Task int
+	// Goroutine is the ID of the goroutine the event happened on. The
+	// TraceStarted and TraceFinished events of tasks run by the same
+	// worker have the same Goroutine.
+	Goroutine uint64
+	Time      time.Time
+}
+
+// WithTracer configures the pool to call f with every event in the life of
+// its tasks, from their submission to their end, so that tests can check
+// the order in which tasks ran and which workers ran them rather than assume
+// it. See conctest.Record, which records the events into a trace.
+//
+// f is called synchronously from the goroutine the event happened on, so it
+// may be called concurrently, and if an event happens before another, f is
+// called for it first. Tracing finds the ID of the current goroutine for
+// every event, which makes it too slow for anything but tests.
+func (p *Pool) WithTracer(f func(TraceEvent)) *Pool {
+	p.tracer = f
+	return p
+}
+
+// taskTrace emits the events of a task traced by WithTracer. A nil taskTrace
+// is a task that is not traced.
+type taskTrace struct {
+	p    *Pool
+	name string
+	task int
+}
+
+// newTrace returns the trace of a task submitted with name, after emitting
+// its TraceSubmitted event, or nil if the pool is not traced.
+func (p *Pool) newTrace(name string) *taskTrace {
+	if p.tracer == nil {
+		return nil
+	}
+	t := &taskTrace{p: p, name: name, task: int(p.traced.Add(1) - 1)}
+	t.emit(TraceSubmitted)
+	return t
+}
+
+func (t *taskTrace) emit(kind TraceKind) {
+	if t == nil {
+		return
+	}
+	t.p.tracer(TraceEvent{
+		Kind:      kind,
+		Pool:      t.p.Name(),
+		Name:      t.name,
+		Task:      t.task,
+		Goroutine: goroutineID(),
+		Time:      time.Now(),
+	})
+}
+
+// wrap wraps f so that its start and end are traced.
+func (t *taskTrace) wrap(f func()) func() {
+	if t == nil {
+		return f
+	}
+	return func() {
+		t.emit(TraceStarted)
+		defer t.emit(TraceFinished)
+		f()
+	}
+}
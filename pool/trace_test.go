@@ -0,0 +1,159 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// traceRecorder records the events of a traced pool.
+type traceRecorder struct {
+	mu     sync.Mutex
+	events []TraceEvent
+}
+
+func (r *traceRecorder) record(e TraceEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// kinds returns the kinds of the events of the tasks named name.
+func (r *traceRecorder) kinds(name string) []TraceKind {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var kinds []TraceKind
+	for _, e := range r.events {
+		if e.Name == name {
+			kinds = append(kinds, e.Kind)
+		}
+	}
+	return kinds
+}
+
+func TestWithTracer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("traces the life of tasks", func(t *testing.T) {
+		t.Parallel()
+
+		var r traceRecorder
+		p := New().WithName("traced").WithMaxGoroutines(1).WithTracer(r.record)
+		p.GoLabeled("a", func() {})
+		p.GoLabeled("b", func() {})
+		p.Wait()
+		require.Equal(t, []TraceKind{TraceSubmitted, TraceStarted, TraceFinished}, r.kinds("a"))
+		require.Equal(t, TraceSubmitted, r.kinds("b")[0])
+		require.Equal(t, []TraceKind{TraceStarted, TraceFinished}, r.kinds("b")[len(r.kinds("b"))-2:])
+
+		var tasks []int
+		for _, e := range r.events {
+			require.Equal(t, "traced", e.Pool)
+			require.False(t, e.Time.IsZero())
+			if e.Kind == TraceSubmitted {
+				tasks = append(tasks, e.Task)
+			}
+		}
+		require.Equal(t, []int{0, 1}, tasks)
+	})
+
+	t.Run("traces the worker that runs a task", func(t *testing.T) {
+		t.Parallel()
+
+		var r traceRecorder
+		p := New().WithMaxGoroutines(1).WithTracer(r.record)
+		for i := 0; i < 3; i++ {
+			p.Go(func() {})
+		}
+		p.Wait()
+		var workers []uint64
+		for _, e := range r.events {
+			if e.Kind == TraceStarted {
+				workers = append(workers, e.Goroutine)
+			}
+		}
+		require.Len(t, workers, 3)
+		require.Equal(t, workers[0], workers[1])
+		require.Equal(t, workers[0], workers[2])
+		require.NotEqual(t, goroutineID(), workers[0])
+	})
+
+	t.Run("traces submissions waiting for a saturated pool", func(t *testing.T) {
+		t.Parallel()
+
+		var r traceRecorder
+		p := New().WithMaxGoroutines(1).WithTracer(r.record)
+		unblock := make(chan struct{})
+		p.GoLabeled("blocker", func() { <-unblock })
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(unblock)
+		}()
+		p.GoLabeled("waiting", func() {})
+		p.Wait()
+		require.Equal(t, []TraceKind{TraceSubmitted, TraceQueued, TraceStarted, TraceFinished}, r.kinds("waiting"))
+	})
+
+	t.Run("traces canceled tasks", func(t *testing.T) {
+		t.Parallel()
+
+		var r traceRecorder
+		p := New().WithMaxGoroutines(1).WithTracer(r.record)
+		p.Pause()
+		p.GoLabeled("drop", func() {})
+		require.Equal(t, 1, p.CancelWhere(func(string) bool { return true }))
+		p.Resume()
+		p.Wait()
+		require.Equal(t, []TraceKind{TraceSubmitted, TraceCanceled}, r.kinds("drop"))
+	})
+
+	t.Run("traces panicking tasks", func(t *testing.T) {
+		t.Parallel()
+
+		var r traceRecorder
+		p := New().WithTracer(r.record)
+		p.GoLabeled("panics", func() { panic("super bad thing") })
+		require.Panics(t, p.Wait)
+		require.Equal(t, []TraceKind{TraceSubmitted, TraceStarted, TraceFinished}, r.kinds("panics"))
+	})
+
+	t.Run("traces blocking tasks", func(t *testing.T) {
+		t.Parallel()
+
+		var r traceRecorder
+		p := New().WithTracer(r.record)
+		p.GoBlocking(func() {})
+		p.Wait()
+		require.Equal(t, []TraceKind{TraceSubmitted, TraceStarted, TraceFinished}, r.kinds(""))
+	})
+
+	t.Run("named tasks of derived pools", func(t *testing.T) {
+		t.Parallel()
+
+		var r traceRecorder
+		p := New().WithContext(context.Background()).WithTracer(r.record)
+		p.GoNamed("named", func(context.Context) error { return nil })
+		p.Go(func(context.Context) error { return nil })
+		require.NoError(t, p.Wait())
+		require.Equal(t, []TraceKind{TraceSubmitted, TraceStarted, TraceFinished}, r.kinds("named"))
+		require.Equal(t, []TraceKind{TraceSubmitted, TraceStarted, TraceFinished}, r.kinds(""))
+
+		var rr traceRecorder
+		rp := NewWithResults[int]().WithErrors().WithTracer(rr.record)
+		rp.GoNamed("result", func() (int, error) { return 1, nil })
+		res, err := rp.Wait()
+		require.NoError(t, err)
+		require.Equal(t, []int{1}, res)
+		require.Equal(t, []TraceKind{TraceSubmitted, TraceStarted, TraceFinished}, rr.kinds("result"))
+	})
+
+	t.Run("kinds", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, "started", TraceStarted.String())
+		require.Equal(t, "TraceKind(42)", TraceKind(42).String())
+	})
+}
@@ -0,0 +1,435 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrResourcePoolClosed is returned by ResourcePool.Acquire once Close has
+// been called.
+var ErrResourcePoolClosed = errors.New("conc: resource pool is closed")
+
+// NewResourcePool creates a ResourcePool that creates its resources with
+// create, and destroys them with destroy, which may be nil if resources need
+// no cleanup. The context passed to create is canceled once the pool is
+// closed, rather than when the caller of Acquire gives up, so that a
+// resource whose creation was abandoned can still be kept for the next
+// caller.
+func NewResourcePool[T any](create func(ctx context.Context) (T, error), destroy func(T)) *ResourcePool[T] {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ResourcePool[T]{
+		create:  create,
+		destroy: destroy,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// ResourcePool keeps a set of expensive resources, such as connections or
+// sessions, to be reused by the goroutines that need one. Unlike sync.Pool,
+// resources are only dropped when the pool decides to, are destroyed
+// properly, and their number can be bounded, in which case Acquire waits for
+// a resource to be released, or for its context to be done.
+//
+// A resource is acquired for exclusive use, and given back with Release, or
+// with Destroy if it turns out to be broken:
+//
+//	r, err := conns.Acquire(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer r.Release()
+//	return r.Value().Query(ctx, q)
+//
+// New resources are created in a goroutine of their own, so that a caller
+// whose context is done does not have to wait for the creation to finish,
+// and a panic in create is caught and returned by Acquire as a
+// *RecoveredPanic instead of crashing the program. The resource is then kept
+// for the next caller.
+//
+// A ResourcePool pairs with the worker state of the pools of the pool
+// package, to give each worker a resource for as long as it runs:
+//
+//	p := pool.New().WithMaxGoroutines(4).WithErrors().
+//		WithWorkerInit(func() (any, error) { return conns.Acquire(ctx) }).
+//		WithWorkerTeardown(func(r any) { r.(*conc.Resource[*Conn]).Release() })
+//
+// The With methods configure the pool, and must be called before it is
+// used. A ResourcePool must be created with NewResourcePool.
+type ResourcePool[T any] struct {
+	create      func(ctx context.Context) (T, error)
+	destroy     func(T)
+	maxSize     int
+	idleTimeout time.Duration
+	healthCheck func(ctx context.Context, value T) error
+
+	// ctx is passed to create, and is canceled once the pool is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// size is the number of resources that exist or are being created,
+	// including the slots handed to waiters to create one
+	size int
+	// idle are the idle resources, the least recently released first
+	idle []*resourceEntry[T]
+	// waiters are the calls to Acquire waiting for a resource, in the order
+	// they started to wait
+	waiters []chan resourceHandoff[T]
+	// evictTimer is set while an eviction of the idle resources is
+	// scheduled
+	evictTimer *time.Timer
+	closed     bool
+}
+
+// resourceEntry is a resource of a ResourcePool.
+type resourceEntry[T any] struct {
+	value T
+	// idleSince is the time the resource was last released
+	idleSince time.Time
+}
+
+// resourceHandoff is what a waiting call to Acquire is handed: an idle
+// resource, a slot to create a resource in, or an error.
+type resourceHandoff[T any] struct {
+	entry  *resourceEntry[T]
+	create bool
+	err    error
+}
+
+// WithMaxSize limits the number of resources of the pool, whether they are
+// in use, idle or being created, to n. Defaults to no limit. Panics if n < 1.
+func (p *ResourcePool[T]) WithMaxSize(n int) *ResourcePool[T] {
+	if n < 1 {
+		panic("max resource pool size must be greater than zero")
+	}
+	p.maxSize = n
+	return p
+}
+
+// WithIdleTimeout configures the pool to destroy the resources that have
+// been idle for d, so that a pool that is not used for a while releases its
+// resources. Defaults to keeping idle resources until Close. Panics if
+// d <= 0.
+func (p *ResourcePool[T]) WithIdleTimeout(d time.Duration) *ResourcePool[T] {
+	if d <= 0 {
+		panic("idle timeout must be greater than zero")
+	}
+	p.idleTimeout = d
+	return p
+}
+
+// WithHealthCheck configures the pool to call check, with the context passed
+// to Acquire, before handing out an idle resource. A resource for which
+// check returns an error or panics is destroyed, and Acquire moves on to
+// another one.
+func (p *ResourcePool[T]) WithHealthCheck(check func(ctx context.Context, value T) error) *ResourcePool[T] {
+	p.healthCheck = check
+	return p
+}
+
+// Acquire returns a resource for the exclusive use of the caller, which must
+// give it back with Release or Destroy. It reuses an idle resource if there
+// is one, or else creates a new one if the pool is below its maximum size,
+// or else waits for a resource to be released. Acquire returns ctx.Err() if
+// ctx is done first, the error returned by create if creating a resource
+// fails, and ErrResourcePoolClosed once the pool is closed.
+func (p *ResourcePool[T]) Acquire(ctx context.Context) (*Resource[T], error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		h := p.take(ctx)
+		switch {
+		case h.err != nil:
+			return nil, h.err
+		case h.create:
+			e, err := p.createEntry(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &Resource[T]{pool: p, entry: e}, nil
+		case p.healthy(ctx, h.entry):
+			return &Resource[T]{pool: p, entry: h.entry}, nil
+		}
+		p.destroyEntry(h.entry)
+	}
+}
+
+// Size returns the number of resources of the pool, whether they are in
+// use, idle or being created.
+func (p *ResourcePool[T]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// Idle returns the number of idle resources.
+func (p *ResourcePool[T]) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle)
+}
+
+// Close destroys the idle resources, and stops the pool from handing out
+// resources: the calls to Acquire that are waiting, and the ones after,
+// return ErrResourcePoolClosed, and the resources that are in use are
+// destroyed when they are released. The context passed to create is
+// canceled. Close can be called more than once.
+func (p *ResourcePool[T]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	idle, waiters := p.idle, p.waiters
+	p.idle, p.waiters = nil, nil
+	p.size -= len(idle)
+	if p.evictTimer != nil {
+		p.evictTimer.Stop()
+		p.evictTimer = nil
+	}
+	p.mu.Unlock()
+
+	p.cancel()
+	for _, w := range waiters {
+		w <- resourceHandoff[T]{err: ErrResourcePoolClosed}
+	}
+	for _, e := range idle {
+		p.destroyValue(e.value)
+	}
+}
+
+// take takes an idle resource or a slot to create one, waiting for one to
+// be handed over if there is none.
+func (p *ResourcePool[T]) take(ctx context.Context) resourceHandoff[T] {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return resourceHandoff[T]{err: ErrResourcePoolClosed}
+	}
+	if n := len(p.idle); n > 0 {
+		e := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return resourceHandoff[T]{entry: e}
+	}
+	if p.maxSize == 0 || p.size < p.maxSize {
+		p.size++
+		p.mu.Unlock()
+		return resourceHandoff[T]{create: true}
+	}
+	w := make(chan resourceHandoff[T], 1)
+	p.waiters = append(p.waiters, w)
+	p.mu.Unlock()
+
+	select {
+	case h := <-w:
+		return h
+	case <-ctx.Done():
+		p.mu.Lock()
+		removed := false
+		for i, other := range p.waiters {
+			if other == w {
+				p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+				removed = true
+				break
+			}
+		}
+		p.mu.Unlock()
+		if !removed {
+			// Something was handed over as the context was done, so pass it
+			// on rather than lose it.
+			p.giveBack(<-w)
+		}
+		return resourceHandoff[T]{err: ctx.Err()}
+	}
+}
+
+// giveBack returns what a waiter was handed to the pool.
+func (p *ResourcePool[T]) giveBack(h resourceHandoff[T]) {
+	switch {
+	case h.entry != nil:
+		p.put(h.entry)
+	case h.create:
+		p.releaseSlot()
+	}
+}
+
+// createEntry creates a resource in the slot taken by the caller. If ctx is
+// done first, it returns ctx.Err(), and the resource is added to the idle
+// resources once created.
+func (p *ResourcePool[T]) createEntry(ctx context.Context) (*resourceEntry[T], error) {
+	type created struct {
+		entry     *resourceEntry[T]
+		err       error
+		recovered *RecoveredPanic
+	}
+	result := make(chan created)
+	abandoned := make(chan struct{})
+	go func() {
+		var c created
+		var pc PanicCatcher
+		pc.Try(func() {
+			value, err := p.create(p.ctx)
+			c.entry, c.err = &resourceEntry[T]{value: value}, err
+		})
+		if c.recovered = pc.Recovered(); c.recovered != nil {
+			c.err = c.recovered
+		}
+		if c.err != nil {
+			p.releaseSlot()
+		}
+		select {
+		case result <- c:
+		case <-abandoned:
+			// Nobody is waiting for the resource anymore.
+			ReportPanic(c.recovered)
+			if c.err == nil {
+				p.put(c.entry)
+			}
+		}
+	}()
+
+	select {
+	case c := <-result:
+		return c.entry, c.err
+	case <-ctx.Done():
+		close(abandoned)
+		return nil, ctx.Err()
+	}
+}
+
+// healthy reports whether e passes the health check, if there is one.
+func (p *ResourcePool[T]) healthy(ctx context.Context, e *resourceEntry[T]) bool {
+	if p.healthCheck == nil {
+		return true
+	}
+	var err error
+	var pc PanicCatcher
+	recovered := pc.TryRecovered(func() { err = p.healthCheck(ctx, e.value) })
+	ReportPanic(recovered)
+	return recovered == nil && err == nil
+}
+
+// put returns e to the pool, handing it to the first waiter if there is one.
+func (p *ResourcePool[T]) put(e *resourceEntry[T]) {
+	p.mu.Lock()
+	if p.closed {
+		p.size--
+		p.mu.Unlock()
+		p.destroyValue(e.value)
+		return
+	}
+	if len(p.waiters) > 0 {
+		w := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.mu.Unlock()
+		w <- resourceHandoff[T]{entry: e}
+		return
+	}
+	e.idleSince = time.Now()
+	p.idle = append(p.idle, e)
+	p.scheduleEviction()
+	p.mu.Unlock()
+}
+
+// destroyEntry destroys e and frees its slot.
+func (p *ResourcePool[T]) destroyEntry(e *resourceEntry[T]) {
+	p.destroyValue(e.value)
+	p.releaseSlot()
+}
+
+// releaseSlot frees the slot of a resource that is gone, handing it to the
+// first waiter if there is one, so that it creates a resource in its place.
+func (p *ResourcePool[T]) releaseSlot() {
+	p.mu.Lock()
+	if len(p.waiters) > 0 {
+		w := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.mu.Unlock()
+		w <- resourceHandoff[T]{create: true}
+		return
+	}
+	p.size--
+	p.mu.Unlock()
+}
+
+// destroyValue destroys value, catching a panic in destroy, which is passed
+// to ReportPanic.
+func (p *ResourcePool[T]) destroyValue(value T) {
+	if p.destroy == nil {
+		return
+	}
+	var pc PanicCatcher
+	ReportPanic(pc.TryRecovered(func() { p.destroy(value) }))
+}
+
+// scheduleEviction schedules the eviction of the oldest idle resource, if
+// the pool has an idle timeout and no eviction is scheduled. The caller must
+// hold p.mu.
+func (p *ResourcePool[T]) scheduleEviction() {
+	if p.idleTimeout == 0 || p.evictTimer != nil || len(p.idle) == 0 {
+		return
+	}
+	p.evictTimer = time.AfterFunc(time.Until(p.idle[0].idleSince.Add(p.idleTimeout)), p.evict)
+}
+
+// evict destroys the resources that have been idle for the idle timeout.
+func (p *ResourcePool[T]) evict() {
+	p.mu.Lock()
+	p.evictTimer = nil
+	now := time.Now()
+	n := 0
+	for n < len(p.idle) && now.Sub(p.idle[n].idleSince) >= p.idleTimeout {
+		n++
+	}
+	expired := append([]*resourceEntry[T](nil), p.idle[:n]...)
+	p.idle = append(p.idle[:0], p.idle[n:]...)
+	if !p.closed {
+		p.scheduleEviction()
+	}
+	p.mu.Unlock()
+
+	for _, e := range expired {
+		p.destroyEntry(e)
+	}
+}
+
+// Resource is a resource acquired from a ResourcePool, for the exclusive use
+// of the caller of Acquire until it calls Release or Destroy.
+type Resource[T any] struct {
+	pool  *ResourcePool[T]
+	entry *resourceEntry[T]
+	done  atomic.Bool
+}
+
+// Value returns the resource.
+func (r *Resource[T]) Value() T {
+	return r.entry.value
+}
+
+// Release gives the resource back to the pool, to be reused. Panics if the
+// resource has already been released or destroyed.
+func (r *Resource[T]) Release() {
+	r.finish()
+	r.pool.put(r.entry)
+}
+
+// Destroy destroys the resource rather than give it back to the pool, for a
+// resource that is broken, such as a connection that was closed by the
+// other end. Panics if the resource has already been released or destroyed.
+func (r *Resource[T]) Destroy() {
+	r.finish()
+	r.pool.destroyEntry(r.entry)
+}
+
+func (r *Resource[T]) finish() {
+	if r.done.Swap(true) {
+		panic("conc: Resource released more than once")
+	}
+}
@@ -0,0 +1,281 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleResourcePool() {
+	var created atomic.Int64
+	conns := NewResourcePool(func(ctx context.Context) (int64, error) {
+		return created.Add(1), nil
+	}, nil).WithMaxSize(2)
+	defer conns.Close()
+
+	for i := 0; i < 3; i++ {
+		r, err := conns.Acquire(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println("using connection", r.Value())
+		r.Release()
+	}
+
+	// Output:
+	// using connection 1
+	// using connection 1
+	// using connection 1
+}
+
+// counter creates ints, counting their destruction.
+type counter struct {
+	created   atomic.Int64
+	destroyed atomic.Int64
+}
+
+func (c *counter) create(context.Context) (int64, error) {
+	return c.created.Add(1), nil
+}
+
+func (c *counter) destroy(int64) {
+	c.destroyed.Add(1)
+}
+
+func TestResourcePool(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("reuses resources", func(t *testing.T) {
+		var c counter
+		p := NewResourcePool(c.create, c.destroy)
+		r1, err := p.Acquire(ctx)
+		require.NoError(t, err)
+		r2, err := p.Acquire(ctx)
+		require.NoError(t, err)
+		require.NotEqual(t, r1.Value(), r2.Value())
+		r1.Release()
+		r2.Release()
+		require.Equal(t, 2, p.Idle())
+
+		r3, err := p.Acquire(ctx)
+		require.NoError(t, err)
+		require.Equal(t, r2.Value(), r3.Value())
+		r3.Release()
+		require.Equal(t, int64(2), c.created.Load())
+
+		p.Close()
+		require.Equal(t, int64(2), c.destroyed.Load())
+		require.Equal(t, 0, p.Size())
+	})
+
+	t.Run("waits for a resource at the max size", func(t *testing.T) {
+		var c counter
+		p := NewResourcePool(c.create, c.destroy).WithMaxSize(1)
+		defer p.Close()
+		r1, err := p.Acquire(ctx)
+		require.NoError(t, err)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err = p.Acquire(timeoutCtx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		acquired := make(chan *Resource[int64])
+		go func() {
+			r, err := p.Acquire(ctx)
+			require.NoError(t, err)
+			acquired <- r
+		}()
+		time.Sleep(10 * time.Millisecond)
+		r1.Release()
+		r2 := <-acquired
+		require.Equal(t, r1.Value(), r2.Value())
+		r2.Release()
+		require.Equal(t, 1, p.Size())
+	})
+
+	t.Run("destroying makes room for a new resource", func(t *testing.T) {
+		var c counter
+		p := NewResourcePool(c.create, c.destroy).WithMaxSize(1)
+		defer p.Close()
+		r1, err := p.Acquire(ctx)
+		require.NoError(t, err)
+
+		acquired := make(chan *Resource[int64])
+		go func() {
+			r, err := p.Acquire(ctx)
+			require.NoError(t, err)
+			acquired <- r
+		}()
+		time.Sleep(10 * time.Millisecond)
+		r1.Destroy()
+		r2 := <-acquired
+		require.Equal(t, int64(2), r2.Value())
+		require.Equal(t, int64(1), c.destroyed.Load())
+		r2.Release()
+	})
+
+	t.Run("idle resources are evicted", func(t *testing.T) {
+		var c counter
+		p := NewResourcePool(c.create, c.destroy).WithIdleTimeout(10 * time.Millisecond)
+		defer p.Close()
+		r, err := p.Acquire(ctx)
+		require.NoError(t, err)
+		r.Release()
+		require.Eventually(t, func() bool { return p.Size() == 0 }, time.Second, time.Millisecond)
+		require.Equal(t, int64(1), c.destroyed.Load())
+		require.Equal(t, 0, p.Idle())
+	})
+
+	t.Run("unhealthy resources are replaced", func(t *testing.T) {
+		var c counter
+		p := NewResourcePool(c.create, c.destroy).
+			WithHealthCheck(func(ctx context.Context, value int64) error {
+				if value == 1 {
+					return errors.New("unhealthy")
+				}
+				return nil
+			})
+		defer p.Close()
+		r, err := p.Acquire(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), r.Value())
+		r.Release()
+
+		r, err = p.Acquire(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), r.Value())
+		require.Equal(t, int64(1), c.destroyed.Load())
+		r.Release()
+	})
+
+	t.Run("creation errors are returned", func(t *testing.T) {
+		errFailed := errors.New("failed")
+		p := NewResourcePool(func(context.Context) (int, error) { return 0, errFailed }, nil).WithMaxSize(1)
+		defer p.Close()
+		_, err := p.Acquire(ctx)
+		require.ErrorIs(t, err, errFailed)
+		_, err = p.Acquire(ctx)
+		require.ErrorIs(t, err, errFailed)
+		require.Equal(t, 0, p.Size())
+	})
+
+	t.Run("creation panics are caught", func(t *testing.T) {
+		p := NewResourcePool(func(context.Context) (int, error) { panic("super bad thing") }, nil)
+		defer p.Close()
+		_, err := p.Acquire(ctx)
+		var recovered *RecoveredPanic
+		require.ErrorAs(t, err, &recovered)
+		require.Equal(t, "super bad thing", recovered.Value)
+		require.Equal(t, 0, p.Size())
+	})
+
+	t.Run("abandoned creations are kept", func(t *testing.T) {
+		unblock := make(chan struct{})
+		p := NewResourcePool(func(context.Context) (int, error) {
+			<-unblock
+			return 42, nil
+		}, nil)
+		defer p.Close()
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := p.Acquire(timeoutCtx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		close(unblock)
+		require.Eventually(t, func() bool { return p.Idle() == 1 }, time.Second, time.Millisecond)
+
+		r, err := p.Acquire(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 42, r.Value())
+		r.Release()
+	})
+
+	t.Run("close", func(t *testing.T) {
+		var c counter
+		var createCtx context.Context
+		p := NewResourcePool(func(ctx context.Context) (int64, error) {
+			createCtx = ctx
+			return c.create(ctx)
+		}, c.destroy).WithMaxSize(1)
+		r, err := p.Acquire(ctx)
+		require.NoError(t, err)
+
+		waited := make(chan error)
+		go func() {
+			_, err := p.Acquire(ctx)
+			waited <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		p.Close()
+		p.Close()
+		require.ErrorIs(t, <-waited, ErrResourcePoolClosed)
+		require.Error(t, createCtx.Err())
+
+		_, err = p.Acquire(ctx)
+		require.ErrorIs(t, err, ErrResourcePoolClosed)
+
+		// Resources in use are destroyed once released
+		require.Equal(t, int64(0), c.destroyed.Load())
+		r.Release()
+		require.Equal(t, int64(1), c.destroyed.Load())
+		require.Equal(t, 0, p.Size())
+	})
+
+	t.Run("panics when released twice", func(t *testing.T) {
+		var c counter
+		p := NewResourcePool(c.create, c.destroy)
+		defer p.Close()
+		r, err := p.Acquire(ctx)
+		require.NoError(t, err)
+		r.Release()
+		require.Panics(t, r.Release)
+		require.Panics(t, r.Destroy)
+	})
+
+	t.Run("panics on invalid options", func(t *testing.T) {
+		var c counter
+		require.Panics(t, func() { NewResourcePool(c.create, nil).WithMaxSize(0) })
+		require.Panics(t, func() { NewResourcePool(c.create, nil).WithIdleTimeout(0) })
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		var c counter
+		var inUse, maxInUse atomic.Int64
+		p := NewResourcePool(c.create, c.destroy).WithMaxSize(3)
+		defer p.Close()
+		var wg WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Go(func() {
+				for j := 0; j < 20; j++ {
+					r, err := p.Acquire(ctx)
+					require.NoError(t, err)
+					n := inUse.Add(1)
+					for {
+						prev := maxInUse.Load()
+						if n <= prev || maxInUse.CompareAndSwap(prev, n) {
+							break
+						}
+					}
+					inUse.Add(-1)
+					if j%5 == 0 {
+						r.Destroy()
+					} else {
+						r.Release()
+					}
+				}
+			})
+		}
+		wg.Wait()
+		require.LessOrEqual(t, maxInUse.Load(), int64(3))
+		require.LessOrEqual(t, p.Size(), 3)
+		require.Equal(t, c.created.Load()-c.destroyed.Load(), int64(p.Size()))
+	})
+}
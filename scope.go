@@ -0,0 +1,107 @@
+package conc
+
+import (
+	"context"
+	"sync"
+)
+
+// Scope calls f with a Nursery, and returns once f and every goroutine
+// started with the Nursery's Go method have returned, so that no goroutine
+// outlives the call to Scope:
+//
+//	err := conc.Scope(ctx, func(s *conc.Nursery) error {
+//		s.Go(func(ctx context.Context) error {
+//			return fetch(ctx, a)
+//		})
+//		s.Go(func(ctx context.Context) error {
+//			return fetch(ctx, b)
+//		})
+//		return nil
+//	})
+//
+// The goroutines are passed a context derived from ctx, which is canceled as
+// soon as f or any of the goroutines returns an error or panics, so that the
+// others can stop early. Scope returns the first error, and not the errors
+// returned by the other goroutines once they were canceled, or propagates the
+// first panic once everything has returned. Goroutines may start more
+// goroutines with the same Nursery, which Scope also waits for.
+//
+// A Nursery is only valid until Scope returns, and it is a misuse to keep it
+// beyond that: calling Go once Scope has returned panics rather than start a
+// goroutine that nothing waits for.
+func Scope(ctx context.Context, f func(s *Nursery) error) error {
+	s := &Nursery{}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	defer s.cancel()
+
+	if recovered := s.pc.TryRecovered(func() { s.fail(f(s)) }); recovered != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	// A goroutine may have started another one after the counter dropped to
+	// zero, if a leaked Nursery was used, so wait again once Go is closed.
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	s.pc.Repanic()
+	return s.err
+}
+
+// Nursery starts the goroutines of a call to Scope, which waits for them. See
+// Scope.
+type Nursery struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	wg sync.WaitGroup
+	pc PanicCatcher
+
+	mu sync.Mutex
+	// err is the first error returned by f or a goroutine
+	err error
+	// closed is set once Scope is about to return
+	closed bool
+}
+
+// Go starts f in a new goroutine of the scope, with the scope's context.
+// Panics if Scope has returned.
+func (s *Nursery) Go(f func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		panic("conc: Nursery.Go called after its Scope returned")
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		recovered := s.pc.TryRecovered(func() { s.fail(f(s.ctx)) })
+		if recovered != nil {
+			s.cancel()
+		}
+		ReportPanic(recovered)
+	}()
+}
+
+// Context returns the context passed to the goroutines of the scope, which
+// is canceled once one of them fails.
+func (s *Nursery) Context() context.Context {
+	return s.ctx
+}
+
+// fail records err, if it is the first error of the scope, and cancels the
+// scope's context.
+func (s *Nursery) fail(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
@@ -0,0 +1,163 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleScope() {
+	var total atomic.Int64
+	err := Scope(context.Background(), func(s *Nursery) error {
+		for i := 1; i <= 3; i++ {
+			i := i
+			s.Go(func(ctx context.Context) error {
+				total.Add(int64(i))
+				return nil
+			})
+		}
+		return nil
+	})
+	fmt.Println(total.Load(), err)
+
+	// Output:
+	// 6 <nil>
+}
+
+func TestScope(t *testing.T) {
+	t.Parallel()
+
+	err1 := errors.New("err1")
+
+	t.Run("waits for goroutines", func(t *testing.T) {
+		t.Parallel()
+		var done atomic.Int64
+		err := Scope(context.Background(), func(s *Nursery) error {
+			for i := 0; i < 10; i++ {
+				s.Go(func(ctx context.Context) error {
+					time.Sleep(time.Millisecond)
+					done.Add(1)
+					return nil
+				})
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(10), done.Load())
+	})
+
+	t.Run("waits for nested goroutines", func(t *testing.T) {
+		t.Parallel()
+		var done atomic.Bool
+		err := Scope(context.Background(), func(s *Nursery) error {
+			s.Go(func(ctx context.Context) error {
+				s.Go(func(ctx context.Context) error {
+					time.Sleep(10 * time.Millisecond)
+					done.Store(true)
+					return nil
+				})
+				return nil
+			})
+			return nil
+		})
+		require.NoError(t, err)
+		require.True(t, done.Load())
+	})
+
+	t.Run("error cancels the others", func(t *testing.T) {
+		t.Parallel()
+		err := Scope(context.Background(), func(s *Nursery) error {
+			s.Go(func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			s.Go(func(ctx context.Context) error {
+				return err1
+			})
+			return nil
+		})
+		require.Equal(t, err1, err)
+	})
+
+	t.Run("body error cancels the goroutines", func(t *testing.T) {
+		t.Parallel()
+		var canceled atomic.Bool
+		err := Scope(context.Background(), func(s *Nursery) error {
+			s.Go(func(ctx context.Context) error {
+				<-ctx.Done()
+				canceled.Store(true)
+				return nil
+			})
+			return err1
+		})
+		require.Equal(t, err1, err)
+		require.True(t, canceled.Load())
+	})
+
+	t.Run("parent cancellation", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		err := Scope(ctx, func(s *Nursery) error {
+			s.Go(func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("panic is propagated after waiting", func(t *testing.T) {
+		t.Parallel()
+		var canceled atomic.Bool
+		require.Panics(t, func() {
+			_ = Scope(context.Background(), func(s *Nursery) error {
+				s.Go(func(ctx context.Context) error {
+					<-ctx.Done()
+					canceled.Store(true)
+					return nil
+				})
+				s.Go(func(ctx context.Context) error {
+					panic("super bad thing")
+				})
+				return nil
+			})
+		})
+		require.True(t, canceled.Load())
+	})
+
+	t.Run("body panic waits for goroutines", func(t *testing.T) {
+		t.Parallel()
+		var canceled atomic.Bool
+		require.Panics(t, func() {
+			_ = Scope(context.Background(), func(s *Nursery) error {
+				s.Go(func(ctx context.Context) error {
+					<-ctx.Done()
+					canceled.Store(true)
+					return nil
+				})
+				panic("super bad thing")
+			})
+		})
+		require.True(t, canceled.Load())
+	})
+
+	t.Run("go after return panics", func(t *testing.T) {
+		t.Parallel()
+		var leaked *Nursery
+		require.NoError(t, Scope(context.Background(), func(s *Nursery) error {
+			leaked = s
+			return nil
+		}))
+		require.ErrorIs(t, leaked.Context().Err(), context.Canceled)
+		require.Panics(t, func() {
+			leaked.Go(func(ctx context.Context) error { return nil })
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package conc
+
+import (
+	"runtime"
+	"sort"
+)
+
+// minSortRun is the smallest number of elements that Sort gives a goroutine
+// of its own, below which splitting the work costs more than it saves.
+const minSortRun = 1 << 12
+
+// Sort sorts s in place, in the order given by less, using up to
+// runtime.GOMAXPROCS goroutines: s is split into one run per goroutine, the
+// runs are sorted concurrently, and then merged pairwise, with the merges of
+// each round also running concurrently. The sort is stable, and needs a
+// buffer as large as s. Slices too small to be worth splitting are sorted
+// with sort.SliceStable. A panic in less is propagated once all goroutines
+// have returned, leaving s in an unspecified order.
+func Sort[T any](s []T, less func(a, b T) bool) {
+	n := runtime.GOMAXPROCS(0)
+	if limit := len(s) / minSortRun; limit < n {
+		n = limit
+	}
+	if n <= 1 {
+		sortRun(s, less)
+		return
+	}
+
+	runs := make([]int, n+1)
+	for i := range runs {
+		runs[i] = i * len(s) / n
+	}
+	var wg WaitGroup
+	for i := 0; i < n; i++ {
+		run := s[runs[i]:runs[i+1]]
+		wg.Go(func() { sortRun(run, less) })
+	}
+	wg.Wait()
+	mergeRuns(s, runs, less)
+}
+
+// MergeSorted merges the slices of sorted, each of which must already be
+// sorted in the order given by less, into a new sorted slice, merging them
+// pairwise and concurrently as Sort does. The merge is stable: equal elements
+// keep their order within a slice, and the elements of earlier slices come
+// first. The slices are not modified.
+func MergeSorted[T any](sorted [][]T, less func(a, b T) bool) []T {
+	runs := make([]int, 1, len(sorted)+1)
+	total := 0
+	for _, s := range sorted {
+		total += len(s)
+		runs = append(runs, total)
+	}
+	merged := make([]T, 0, total)
+	for _, s := range sorted {
+		merged = append(merged, s...)
+	}
+	mergeRuns(merged, runs, less)
+	return merged
+}
+
+func sortRun[T any](s []T, less func(a, b T) bool) {
+	sort.SliceStable(s, func(i, j int) bool { return less(s[i], s[j]) })
+}
+
+// mergeRuns merges the sorted runs of s, where run i is s[runs[i]:runs[i+1]],
+// until s is sorted.
+func mergeRuns[T any](s []T, runs []int, less func(a, b T) bool) {
+	if len(runs) <= 2 {
+		return
+	}
+	src, dst := s, make([]T, len(s))
+	for len(runs) > 2 {
+		runs = mergeRound(dst, src, runs, less)
+		src, dst = dst, src
+	}
+	if len(s) > 0 && &src[0] != &s[0] {
+		copy(s, src)
+	}
+}
+
+// mergeRound merges the pairs of adjacent runs of src into dst
+// concurrently, returning the boundaries of the merged runs. A run left
+// without a pair is copied as is.
+func mergeRound[T any](dst, src []T, runs []int, less func(a, b T) bool) []int {
+	merged := make([]int, 1, len(runs)/2+1)
+	var wg WaitGroup
+	for i := 0; i+1 < len(runs); i += 2 {
+		lo, mid := runs[i], runs[i+1]
+		if i+2 == len(runs) {
+			wg.Go(func() { copy(dst[lo:mid], src[lo:mid]) })
+			merged = append(merged, mid)
+			break
+		}
+		hi := runs[i+2]
+		wg.Go(func() { merge(dst[lo:hi], src[lo:mid], src[mid:hi], less) })
+		merged = append(merged, hi)
+	}
+	wg.Wait()
+	return merged
+}
+
+// merge merges the sorted slices a and b into dst, which must have room for
+// both. Elements of a come first among equal elements.
+func merge[T any](dst, a, b []T, less func(a, b T) bool) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if less(b[j], a[i]) {
+			dst[k] = b[j]
+			j++
+		} else {
+			dst[k] = a[i]
+			i++
+		}
+		k++
+	}
+	k += copy(dst[k:], a[i:])
+	copy(dst[k:], b[j:])
+}
@@ -0,0 +1,102 @@
+package conc
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSort(t *testing.T) {
+	t.Parallel()
+
+	less := func(a, b int) bool { return a < b }
+
+	for _, n := range []int{0, 1, 10, minSortRun - 1, 3*minSortRun + 17, 100000} {
+		n := n
+		t.Run(fmt.Sprintf("sorts %d elements", n), func(t *testing.T) {
+			ints := rand.Perm(n)
+			expected := append([]int{}, ints...)
+			sort.Ints(expected)
+			Sort(ints, less)
+			require.Equal(t, expected, ints)
+		})
+	}
+
+	t.Run("stable", func(t *testing.T) {
+		type pair struct{ key, pos int }
+		pairs := make([]pair, 50000)
+		for i := range pairs {
+			pairs[i] = pair{key: rand.Intn(10), pos: i}
+		}
+		Sort(pairs, func(a, b pair) bool { return a.key < b.key })
+		require.True(t, sort.SliceIsSorted(pairs, func(i, j int) bool {
+			if pairs[i].key != pairs[j].key {
+				return pairs[i].key < pairs[j].key
+			}
+			return pairs[i].pos < pairs[j].pos
+		}))
+	})
+
+	t.Run("panics are propagated", func(t *testing.T) {
+		ints := rand.Perm(100000)
+		require.Panics(t, func() {
+			Sort(ints, func(a, b int) bool {
+				if a == 42 {
+					panic("super bad thing")
+				}
+				return a < b
+			})
+		})
+	})
+}
+
+func TestMergeSorted(t *testing.T) {
+	t.Parallel()
+
+	less := func(a, b int) bool { return a < b }
+
+	t.Run("merges", func(t *testing.T) {
+		var (
+			sorted   [][]int
+			expected []int
+		)
+		for i := 0; i < 7; i++ {
+			s := make([]int, rand.Intn(1000))
+			for j := range s {
+				s[j] = rand.Intn(500)
+			}
+			sort.Ints(s)
+			sorted = append(sorted, s)
+			expected = append(expected, s...)
+		}
+		sort.Ints(expected)
+		require.Equal(t, expected, MergeSorted(sorted, less))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		require.Empty(t, MergeSorted(nil, less))
+		require.Empty(t, MergeSorted([][]int{nil, {}}, less))
+		require.Equal(t, []int{1, 2}, MergeSorted([][]int{nil, {1, 2}, {}}, less))
+	})
+
+	t.Run("does not modify the slices", func(t *testing.T) {
+		a, b := []int{1, 3}, []int{2, 4}
+		require.Equal(t, []int{1, 2, 3, 4}, MergeSorted([][]int{a, b}, less))
+		require.Equal(t, []int{1, 3}, a)
+		require.Equal(t, []int{2, 4}, b)
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		type pair struct{ key, slice int }
+		sorted := [][]pair{
+			{{1, 0}, {2, 0}},
+			{{1, 1}, {2, 1}},
+			{{1, 2}},
+		}
+		merged := MergeSorted(sorted, func(a, b pair) bool { return a.key < b.key })
+		require.Equal(t, []pair{{1, 0}, {1, 1}, {1, 2}, {2, 0}, {2, 1}}, merged)
+	})
+}
@@ -0,0 +1,41 @@
+package conc
+
+import (
+	"context"
+)
+
+// Go runs f in a new goroutine, as a drop-in replacement for a bare go
+// statement in code that cannot easily be restructured around a WaitGroup or
+// pool. A panic in f is caught and passed to the handler set with
+// SetDefaultPanicHandler. If there is no handler, the panic is raised again,
+// which crashes the program like a panic in a bare goroutine would, but with
+// the stack trace of the original panic.
+//
+// Unlike WaitGroup.Go, nothing waits for the goroutine to exit, so prefer a
+// WaitGroup whenever the goroutine has an obvious owner.
+func Go(f func()) {
+	go func() {
+		var pc PanicCatcher
+		pc.Try(f)
+		handlePanic(pc.Recovered())
+	}()
+}
+
+// GoCtx is like Go, but f is passed ctx. This makes it possible to hand work
+// off to a goroutine that stops when ctx is canceled, without capturing ctx
+// in a closure.
+func GoCtx(ctx context.Context, f func(ctx context.Context)) {
+	Go(func() { f(ctx) })
+}
+
+// handlePanic passes recovered to the default panic handler, or panics with
+// it if there is no handler.
+func handlePanic(recovered *RecoveredPanic) {
+	if recovered == nil {
+		return
+	}
+	if DefaultPanicHandler() == nil {
+		panic(recovered)
+	}
+	ReportPanic(recovered)
+}
@@ -0,0 +1,43 @@
+package conc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGo(t *testing.T) {
+	t.Run("runs the function", func(t *testing.T) {
+		done := make(chan struct{})
+		Go(func() { close(done) })
+		<-done
+	})
+
+	t.Run("panics are passed to the default handler", func(t *testing.T) {
+		caught := make(chan *RecoveredPanic, 1)
+		SetDefaultPanicHandler(func(recovered *RecoveredPanic) {
+			caught <- recovered
+		})
+		defer SetDefaultPanicHandler(nil)
+
+		Go(func() { panic("super bad thing") })
+		require.Equal(t, "super bad thing", (<-caught).Value)
+	})
+
+	t.Run("GoCtx passes the context", func(t *testing.T) {
+		type key struct{}
+		ctx := context.WithValue(context.Background(), key{}, "value")
+		got := make(chan any, 1)
+		GoCtx(ctx, func(ctx context.Context) { got <- ctx.Value(key{}) })
+		require.Equal(t, "value", <-got)
+	})
+}
+
+func TestDefaultPanicHandler(t *testing.T) {
+	require.Nil(t, DefaultPanicHandler())
+	SetDefaultPanicHandler(func(*RecoveredPanic) {})
+	require.NotNil(t, DefaultPanicHandler())
+	SetDefaultPanicHandler(nil)
+	require.Nil(t, DefaultPanicHandler())
+}
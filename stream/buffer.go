@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"math/bits"
+	"sync"
+)
+
+const (
+	// Buffers are pooled in size classes that are powers of two, from
+	// 1 << minBufferClass to 1 << maxBufferClass bytes.
+	minBufferClass = 10
+	maxBufferClass = 30
+)
+
+// BufferPool is a pool of byte slices for tasks that process large blocks of
+// data, so that steady-state processing does not allocate a new buffer for
+// every block. Buffers are grouped in size classes that are powers of two, so
+// buffers of similar sizes can be reused for each other. Buffers larger than
+// 1GiB are not pooled.
+//
+// The zero value is ready to use. A BufferPool is safe for concurrent use.
+type BufferPool struct {
+	classes [maxBufferClass - minBufferClass + 1]sync.Pool
+}
+
+// Get returns a buffer of length size, reusing a buffer released with Put if
+// one is available. The contents of the buffer are undefined. Panics if
+// size < 0.
+func (p *BufferPool) Get(size int) []byte {
+	if size < 0 {
+		panic("buffer size must not be negative")
+	}
+	class := bits.Len(uint(size - 1))
+	if class < minBufferClass {
+		class = minBufferClass
+	}
+	if class > maxBufferClass || size == 0 {
+		return make([]byte, size)
+	}
+	if buf, ok := p.classes[class-minBufferClass].Get().(*[]byte); ok {
+		return (*buf)[:size]
+	}
+	return make([]byte, size, 1<<class)
+}
+
+// Put releases buf so that it can be returned by a later call to Get. buf must
+// not be used after it has been released. Buffers that were not obtained from
+// Get may be released too.
+func (p *BufferPool) Put(buf []byte) {
+	// Buffers are pooled in the largest class that fits in their capacity,
+	// so that every buffer in a class is at least as large as the class.
+	class := bits.Len(uint(cap(buf))) - 1
+	if class < minBufferClass || class > maxBufferClass {
+		return
+	}
+	buf = buf[:cap(buf)]
+	p.classes[class-minBufferClass].Put(&buf)
+}
+
+var defaultBufferPool BufferPool
+
+// GetBuffer returns a buffer of length size from a BufferPool shared by the
+// whole process. See BufferPool.Get.
+func GetBuffer(size int) []byte {
+	return defaultBufferPool.Get(size)
+}
+
+// PutBuffer releases a buffer to the BufferPool used by GetBuffer. See
+// BufferPool.Put.
+func PutBuffer(buf []byte) {
+	defaultBufferPool.Put(buf)
+}
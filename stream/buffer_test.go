@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rounds up to a size class", func(t *testing.T) {
+		var p BufferPool
+		buf := p.Get(3000)
+		require.Len(t, buf, 3000)
+		require.Equal(t, 4096, cap(buf))
+
+		small := p.Get(1)
+		require.Len(t, small, 1)
+		require.Equal(t, 1<<minBufferClass, cap(small))
+	})
+
+	t.Run("reuses released buffers", func(t *testing.T) {
+		var p BufferPool
+		// sync.Pool may drop items at any time, so only check that a reused
+		// buffer has the right size.
+		p.Put(make([]byte, 5000))
+		buf := p.Get(4000)
+		require.Len(t, buf, 4000)
+		require.GreaterOrEqual(t, cap(buf), 4000)
+	})
+
+	t.Run("does not pool tiny or huge buffers", func(t *testing.T) {
+		var p BufferPool
+		require.NotPanics(t, func() { p.Put(make([]byte, 10)) })
+		require.NotPanics(t, func() { p.Put(nil) })
+		require.Len(t, p.Get(0), 0)
+		require.Panics(t, func() { p.Get(-1) })
+	})
+
+	t.Run("default pool", func(t *testing.T) {
+		buf := GetBuffer(2048)
+		require.Len(t, buf, 2048)
+		PutBuffer(buf)
+	})
+}
+
+func TestSharesBuffer(t *testing.T) {
+	t.Parallel()
+
+	buf := make([]byte, 10)
+	require.True(t, sharesBuffer(buf, buf[:5]))
+	require.True(t, sharesBuffer(buf, buf[2:4]))
+	require.False(t, sharesBuffer(buf, make([]byte, 10)))
+	require.False(t, sharesBuffer(buf, nil))
+}
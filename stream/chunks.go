@@ -0,0 +1,174 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// NewChunkPipeline creates a ChunkPipeline that splits its input into chunks
+// of chunkSize bytes. Panics if chunkSize < 1.
+func NewChunkPipeline(chunkSize int) *ChunkPipeline {
+	if chunkSize < 1 {
+		panic("chunk size must be greater than zero")
+	}
+	return &ChunkPipeline{
+		chunkSize:     chunkSize,
+		maxGoroutines: runtime.GOMAXPROCS(0),
+		buffers:       &defaultBufferPool,
+	}
+}
+
+// ChunkPipeline processes the contents of an io.Reader in fixed-size chunks
+// concurrently, writing the processed chunks to an io.Writer in their
+// original order. It is intended for transformations that work on independent
+// blocks of data, such as block compression or encryption.
+//
+// Chunk buffers are taken from a BufferPool and released once each chunk has
+// been written, so the number of chunks in memory is bounded by the number of
+// goroutines. A ChunkPipeline can be used for any number of calls to Copy,
+// including concurrent ones.
+type ChunkPipeline struct {
+	chunkSize     int
+	maxGoroutines int
+	buffers       *BufferPool
+	pooledOutput  bool
+}
+
+// WithMaxGoroutines limits the number of chunks processed at once.
+// Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
+func (p *ChunkPipeline) WithMaxGoroutines(n int) *ChunkPipeline {
+	if n < 1 {
+		panic("max goroutines must be greater than zero")
+	}
+	p.maxGoroutines = n
+	return p
+}
+
+// WithBufferPool configures the pipeline to take its chunk buffers from
+// buffers. Defaults to the pool used by GetBuffer.
+func (p *ChunkPipeline) WithBufferPool(buffers *BufferPool) *ChunkPipeline {
+	p.buffers = buffers
+	return p
+}
+
+// WithPooledOutput configures the pipeline to release the output of f to its
+// BufferPool once it has been written, so that f can take its output buffers
+// from the same pool and avoid allocating in the steady state. The output
+// must then either be taken from the pool or share the chunk's buffer, and f
+// must not retain it.
+func (p *ChunkPipeline) WithPooledOutput() *ChunkPipeline {
+	p.pooledOutput = true
+	return p
+}
+
+// Copy reads r until EOF, calls f concurrently with each chunk, and writes the
+// output of f for each chunk to w in the order the chunks were read. Every
+// chunk is chunkSize bytes long, except for the last one, which may be
+// shorter. The chunk's buffer is reused once the output of f has been
+// written, so f may transform the chunk in place and return it, but must not
+// retain it. The output of f is not retained after it has been written.
+//
+// Copy stops at the first error from r, f or w, and returns it along with the
+// number of bytes written to w. The output of every chunk before the one that
+// failed is still written, so w then holds the output of a prefix of r. A
+// panic in f is propagated once the chunks that are still being processed
+// have finished.
+func (p *ChunkPipeline) Copy(w io.Writer, r io.Reader, f func(chunk []byte) ([]byte, error)) (written int64, err error) {
+	// Chunks are numbered in the order they are read. Once a chunk fails,
+	// later chunks are skipped, while earlier chunks are still written.
+	failed := newFirstFailure()
+
+	s := New().WithMaxGoroutines(p.maxGoroutines)
+	for seq := 0; !failed.before(seq); seq++ {
+		buf := p.buffers.Get(p.chunkSize)
+		n, readErr := io.ReadFull(r, buf)
+		if n == 0 {
+			p.buffers.Put(buf)
+		} else {
+			seq := seq
+			s.Go(func() Callback {
+				// The buffer is released by the callback, since the output
+				// of f may share it.
+				release := func() { p.buffers.Put(buf) }
+				if failed.before(seq) {
+					return release
+				}
+				out, err := f(buf[:n])
+				if err != nil {
+					failed.fail(seq, err)
+					return release
+				}
+				if p.pooledOutput && !sharesBuffer(out, buf) {
+					release = func() {
+						p.buffers.Put(buf)
+						p.buffers.Put(out)
+					}
+				}
+				// Callbacks run one at a time, so written needs no
+				// synchronization.
+				return func() {
+					defer release()
+					if failed.before(seq) {
+						return
+					}
+					m, err := w.Write(out)
+					written += int64(m)
+					if err != nil {
+						failed.fail(seq, err)
+					}
+				}
+			})
+		}
+
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			failed.fail(seq, readErr)
+		}
+	}
+	s.Wait()
+
+	return written, failed.err
+}
+
+// sharesBuffer reports whether a and b are slices of the same array, which
+// means they end at the same address once extended to their capacity.
+func sharesBuffer(a, b []byte) bool {
+	if cap(a) == 0 || cap(b) == 0 {
+		return false
+	}
+	return &a[:cap(a)][cap(a)-1] == &b[:cap(b)][cap(b)-1]
+}
+
+// firstFailure records the error of the first item of a sequence to fail, in
+// the order of the sequence rather than the order in which the items failed.
+// It is safe for concurrent use.
+type firstFailure struct {
+	mu  sync.Mutex
+	at  int
+	err error
+}
+
+func newFirstFailure() *firstFailure {
+	return &firstFailure{at: math.MaxInt}
+}
+
+// fail records that item seq failed with err, unless an earlier item failed.
+func (f *firstFailure) fail(seq int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if seq < f.at {
+		f.at, f.err = seq, err
+	}
+}
+
+// before reports whether item seq or an earlier item failed.
+func (f *firstFailure) before(seq int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.at <= seq
+}
@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleChunkPipeline() {
+	var out bytes.Buffer
+	p := NewChunkPipeline(4).WithMaxGoroutines(2)
+	_, err := p.Copy(&out, strings.NewReader("abcdefghij"), func(chunk []byte) ([]byte, error) {
+		return bytes.ToUpper(chunk), nil
+	})
+	fmt.Println(out.String(), err)
+	// Output:
+	// ABCDEFGHIJ <nil>
+}
+
+func TestChunkPipeline(t *testing.T) {
+	t.Parallel()
+
+	t.Run("preserves order", func(t *testing.T) {
+		var in bytes.Buffer
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&in, "%04d", i)
+		}
+		want := in.String()
+
+		var out bytes.Buffer
+		p := NewChunkPipeline(4).WithMaxGoroutines(8)
+		written, err := p.Copy(&out, &in, func(chunk []byte) ([]byte, error) {
+			return append([]byte(nil), chunk...), nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(len(want)), written)
+		require.Equal(t, want, out.String())
+	})
+
+	t.Run("short last chunk", func(t *testing.T) {
+		var sizes []int
+		p := NewChunkPipeline(4).WithMaxGoroutines(1)
+		_, err := p.Copy(&bytes.Buffer{}, strings.NewReader("abcdefghij"), func(chunk []byte) ([]byte, error) {
+			sizes = append(sizes, len(chunk))
+			return nil, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{4, 4, 2}, sizes)
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		p := NewChunkPipeline(4)
+		written, err := p.Copy(&bytes.Buffer{}, strings.NewReader(""), func(chunk []byte) ([]byte, error) {
+			t.Fatal("f should not be called")
+			return nil, nil
+		})
+		require.NoError(t, err)
+		require.Zero(t, written)
+	})
+
+	t.Run("stops on error from f", func(t *testing.T) {
+		errBad := errors.New("bad chunk")
+		var out bytes.Buffer
+		p := NewChunkPipeline(1).WithMaxGoroutines(1)
+		_, err := p.Copy(&out, strings.NewReader("abcdef"), func(chunk []byte) ([]byte, error) {
+			if chunk[0] == 'c' {
+				return nil, errBad
+			}
+			return chunk, nil
+		})
+		require.ErrorIs(t, err, errBad)
+		require.Equal(t, "ab", out.String())
+	})
+
+	t.Run("returns read errors", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		p := NewChunkPipeline(4)
+		_, err := p.Copy(&bytes.Buffer{}, errReader{errRead}, func(chunk []byte) ([]byte, error) {
+			return chunk, nil
+		})
+		require.ErrorIs(t, err, errRead)
+	})
+
+	t.Run("propagates panics", func(t *testing.T) {
+		p := NewChunkPipeline(4)
+		require.Panics(t, func() {
+			_, _ = p.Copy(&bytes.Buffer{}, strings.NewReader("abcdefgh"), func(chunk []byte) ([]byte, error) {
+				panic("super bad thing")
+			})
+		})
+	})
+
+	t.Run("pooled output", func(t *testing.T) {
+		var buffers BufferPool
+		var out bytes.Buffer
+		p := NewChunkPipeline(1024).WithBufferPool(&buffers).WithPooledOutput()
+		in := strings.Repeat(strings.Repeat("a", 1024)+strings.Repeat("b", 1024), 4)
+		_, err := p.Copy(&out, strings.NewReader(in), func(chunk []byte) ([]byte, error) {
+			if chunk[0] == 'a' {
+				// Transform in place
+				copy(chunk, bytes.ToUpper(chunk))
+				return chunk, nil
+			}
+			res := buffers.Get(len(chunk))
+			copy(res, bytes.ToUpper(chunk))
+			return res, nil
+		})
+		require.NoError(t, err)
+		require.True(t, strings.ToUpper(in) == out.String())
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		require.Panics(t, func() { NewChunkPipeline(0) })
+		require.Panics(t, func() { NewChunkPipeline(1).WithMaxGoroutines(0) })
+	})
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,155 @@
+package stream
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"runtime"
+)
+
+// Framing is the way an Encoder delimits the encoded values it writes, so
+// that they can be told apart when read back.
+type Framing int
+
+const (
+	// NewlineFraming follows each encoded value with a newline, as in JSON
+	// Lines. The encoded values must not contain newlines themselves, which
+	// holds for json.Marshal.
+	NewlineFraming Framing = iota
+
+	// LengthPrefixFraming precedes each encoded value with its length in
+	// bytes, as a varint encoded with binary.PutUvarint. This is the
+	// size-delimited format of protocol buffers, which can hold any bytes.
+	LengthPrefixFraming
+)
+
+// NewEncoder creates an Encoder that encodes each value with encode, such as
+// a function calling json.Marshal or proto.Marshal, and delimits the encoded
+// values with framing. encode is called concurrently, and must return a
+// slice that it does not retain, since the frame may be built in its spare
+// capacity.
+func NewEncoder[T any](encode func(T) ([]byte, error), framing Framing) *Encoder[T] {
+	return &Encoder[T]{
+		encode:        encode,
+		framing:       framing,
+		maxGoroutines: runtime.GOMAXPROCS(0),
+	}
+}
+
+// NewJSONEncoder creates an Encoder that writes values as JSON Lines: each
+// value is encoded with json.Marshal and followed by a newline.
+func NewJSONEncoder[T any]() *Encoder[T] {
+	return NewEncoder(func(v T) ([]byte, error) { return json.Marshal(v) }, NewlineFraming)
+}
+
+// Encoder encodes values concurrently and writes the encoded values to an
+// io.Writer in their original order, one frame per value, so that encoding,
+// which is often the bottleneck of an export, is spread across goroutines
+// while the output stays a sequential stream. Each frame is written with a
+// single call to Write.
+//
+// The number of encoded values in memory is bounded by the number of
+// goroutines. An Encoder can be used for any number of calls to Encode and
+// EncodeChan, including concurrent ones.
+type Encoder[T any] struct {
+	encode        func(T) ([]byte, error)
+	framing       Framing
+	maxGoroutines int
+}
+
+// WithMaxGoroutines limits the number of values encoded at once. Defaults to
+// runtime.GOMAXPROCS(0). Panics if n < 1.
+func (e *Encoder[T]) WithMaxGoroutines(n int) *Encoder[T] {
+	if n < 1 {
+		panic("max goroutines must be greater than zero")
+	}
+	e.maxGoroutines = n
+	return e
+}
+
+// Encode encodes values concurrently and writes their frames to w in order.
+//
+// Encode stops at the first error from the encoder or from w, and returns it
+// along with the number of bytes written to w. The frames of the values
+// before the one that failed are still written, so w then holds the frames of
+// a prefix of values. A panic in the encoder is propagated once the values
+// that are still being encoded have been.
+func (e *Encoder[T]) Encode(w io.Writer, values []T) (written int64, err error) {
+	i := 0
+	return e.encodeAll(w, func() (T, bool) {
+		if i == len(values) {
+			var zero T
+			return zero, false
+		}
+		i++
+		return values[i-1], true
+	})
+}
+
+// EncodeChan is like Encode, for the values received from values until it
+// is closed. If encoding fails, EncodeChan stops receiving, so the sender
+// must be able to stop sending, for example by also selecting on a context
+// that the caller cancels once EncodeChan returns.
+func (e *Encoder[T]) EncodeChan(w io.Writer, values <-chan T) (written int64, err error) {
+	return e.encodeAll(w, func() (T, bool) {
+		v, ok := <-values
+		return v, ok
+	})
+}
+
+// encodeAll encodes the values returned by next, until it returns false, as
+// ChunkPipeline.Copy processes chunks.
+func (e *Encoder[T]) encodeAll(w io.Writer, next func() (T, bool)) (written int64, err error) {
+	// Values are numbered in the order they are received. Once a value
+	// fails, later values are skipped, while earlier values are still
+	// written.
+	failed := newFirstFailure()
+
+	s := New().WithMaxGoroutines(e.maxGoroutines)
+	for seq := 0; !failed.before(seq); seq++ {
+		v, ok := next()
+		if !ok {
+			break
+		}
+		seq := seq
+		s.Go(func() Callback {
+			if failed.before(seq) {
+				return func() {}
+			}
+			frame, err := e.frame(v)
+			if err != nil {
+				failed.fail(seq, err)
+				return func() {}
+			}
+			// Callbacks run one at a time, so written needs no
+			// synchronization.
+			return func() {
+				if failed.before(seq) {
+					return
+				}
+				n, err := w.Write(frame)
+				written += int64(n)
+				if err != nil {
+					failed.fail(seq, err)
+				}
+			}
+		})
+	}
+	s.Wait()
+
+	return written, failed.err
+}
+
+// frame encodes v, and delimits it according to the framing of the encoder.
+func (e *Encoder[T]) frame(v T) ([]byte, error) {
+	data, err := e.encode(v)
+	if err != nil {
+		return nil, err
+	}
+	if e.framing == LengthPrefixFraming {
+		frame := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+		n := binary.PutUvarint(frame, uint64(len(data)))
+		return append(frame[:n], data...), nil
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,132 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleEncoder() {
+	type row struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	rows := []row{{1, "a"}, {2, "b"}, {3, "c"}}
+	_, err := NewJSONEncoder[row]().WithMaxGoroutines(2).Encode(os.Stdout, rows)
+	fmt.Println(err)
+	// Output:
+	// {"id":1,"name":"a"}
+	// {"id":2,"name":"b"}
+	// {"id":3,"name":"c"}
+	// <nil>
+}
+
+func TestEncoder(t *testing.T) {
+	t.Parallel()
+
+	itoa := func(i int) ([]byte, error) { return []byte(strconv.Itoa(i)), nil }
+	ints := make([]int, 1000)
+	for i := range ints {
+		ints[i] = i
+	}
+
+	t.Run("newline framing preserves order", func(t *testing.T) {
+		var out bytes.Buffer
+		written, err := NewEncoder(itoa, NewlineFraming).WithMaxGoroutines(8).Encode(&out, ints)
+		require.NoError(t, err)
+		require.Equal(t, int64(out.Len()), written)
+
+		scanner := bufio.NewScanner(&out)
+		var got []int
+		for scanner.Scan() {
+			i, err := strconv.Atoi(scanner.Text())
+			require.NoError(t, err)
+			got = append(got, i)
+		}
+		require.Equal(t, ints, got)
+	})
+
+	t.Run("length prefix framing", func(t *testing.T) {
+		var out bytes.Buffer
+		_, err := NewEncoder(itoa, LengthPrefixFraming).WithMaxGoroutines(8).Encode(&out, ints)
+		require.NoError(t, err)
+
+		r := bufio.NewReader(&out)
+		var got []int
+		for {
+			n, err := binary.ReadUvarint(r)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			data := make([]byte, n)
+			_, err = io.ReadFull(r, data)
+			require.NoError(t, err)
+			i, err := strconv.Atoi(string(data))
+			require.NoError(t, err)
+			got = append(got, i)
+		}
+		require.Equal(t, ints, got)
+	})
+
+	t.Run("channel", func(t *testing.T) {
+		values := make(chan int)
+		go func() {
+			defer close(values)
+			for i := 0; i < 3; i++ {
+				values <- i
+			}
+		}()
+		var out bytes.Buffer
+		_, err := NewEncoder(itoa, NewlineFraming).EncodeChan(&out, values)
+		require.NoError(t, err)
+		require.Equal(t, "0\n1\n2\n", out.String())
+	})
+
+	t.Run("stops on encoding error", func(t *testing.T) {
+		errBad := errors.New("bad value")
+		var out bytes.Buffer
+		_, err := NewEncoder(func(i int) ([]byte, error) {
+			if i == 500 {
+				return nil, errBad
+			}
+			return itoa(i)
+		}, NewlineFraming).WithMaxGoroutines(8).Encode(&out, ints)
+		require.ErrorIs(t, err, errBad)
+
+		// Every value before the failed one was written, in order
+		lines := bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n"))
+		require.Len(t, lines, 500)
+		require.Equal(t, "499", string(lines[499]))
+	})
+
+	t.Run("returns write errors", func(t *testing.T) {
+		written, err := NewEncoder(itoa, NewlineFraming).Encode(failingWriter{}, ints)
+		require.ErrorIs(t, err, errWriteFailed)
+		require.Equal(t, int64(0), written)
+	})
+
+	t.Run("propagates panics", func(t *testing.T) {
+		require.Panics(t, func() {
+			_, _ = NewEncoder(func(int) ([]byte, error) { panic("super bad thing") }, NewlineFraming).Encode(&bytes.Buffer{}, ints)
+		})
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		require.Panics(t, func() { NewJSONEncoder[int]().WithMaxGoroutines(0) })
+	})
+}
+
+var errWriteFailed = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errWriteFailed }
@@ -0,0 +1,310 @@
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sourcegraph/conc"
+)
+
+// NewOf creates a new Of with default settings.
+func NewOf[T any]() *Of[T] {
+	return &Of[T]{
+		stream: *New(),
+	}
+}
+
+// Of is a stream of tasks that each produce a value of type T. The tasks are
+// executed concurrently, and their results are delivered in the order the
+// tasks were submitted to every consumer attached with WithConsumer.
+//
+// Each consumer runs in its own goroutine and receives every result, so
+// several sinks can process the same ordered results concurrently. A slow
+// consumer only holds back the stream once its buffer is full.
+//
+// As with Stream, Wait() must be called once all tasks have been submitted.
+// If a consumer panics, it stops receiving results, but the stream and other
+// consumers are not affected. The panic is propagated by Wait().
+type Of[T any] struct {
+	stream         Stream
+	consumers      []*consumer[T]
+	consumerHandle conc.WaitGroup
+
+	// sizer is set by WithMaxBufferedBytes
+	sizer  func(T) int
+	budget byteBudget
+	goMu   sync.Mutex
+
+	initOnce sync.Once
+}
+
+// Go schedules a task to be run in the stream's pool. The result of the task
+// will be delivered to each consumer after the results of all previously
+// submitted tasks.
+func (s *Of[T]) Go(f func() T) {
+	s.init()
+
+	if s.sizer == nil {
+		s.stream.Go(func() Callback {
+			res := f()
+			return func() { s.deliver(res) }
+		})
+		return
+	}
+
+	// Tasks are handed to the stream in sequence order, so the task whose
+	// result is next to be delivered has always started and can never be
+	// starved of budget by the tasks after it.
+	s.goMu.Lock()
+	defer s.goMu.Unlock()
+
+	seq := s.budget.submitted
+	s.budget.submitted++
+	s.stream.Go(func() Callback {
+		completed := false
+		defer func() {
+			if !completed {
+				s.budget.skip(seq)
+			}
+		}()
+
+		res := f()
+		size := s.sizer(res)
+		s.budget.acquire(seq, size)
+		completed = true
+		return func() {
+			s.deliver(res)
+			s.budget.release(size)
+		}
+	})
+}
+
+func (s *Of[T]) deliver(res T) {
+	for _, c := range s.consumers {
+		select {
+		case c.ch <- res:
+		case <-s.stream.closed:
+			// Nobody may be reading any more
+			return
+		}
+	}
+}
+
+// Wait signals to the stream that all tasks have been submitted. Wait will
+// not return until all tasks have been run and every consumer has processed
+// all results.
+func (s *Of[T]) Wait() {
+	s.init()
+
+	defer func() {
+		for _, c := range s.consumers {
+			close(c.ch)
+		}
+		s.consumerHandle.Wait()
+	}()
+
+	s.stream.Wait()
+}
+
+// Context returns the context of the stream, which is canceled when Close is
+// called or once Wait returns. See Stream.Context.
+func (s *Of[T]) Context() context.Context {
+	return s.stream.Context()
+}
+
+// Close signals that the consumer of the stream has gone away, so that tasks
+// that have not started are skipped and no further results are delivered.
+// A consumer reading from Results should call Close if it stops reading
+// before the channel is closed, so that the stream does not block forever.
+// See Stream.Close.
+func (s *Of[T]) Close() {
+	s.stream.Close()
+	// Tasks waiting for budget must not wait for results that are never
+	// going to be delivered.
+	s.budget.close()
+}
+
+// Results returns a channel that receives every result in submission order,
+// as an alternative to WithConsumer. The channel is closed once Wait has
+// finished running all tasks, including when Wait propagates a panic.
+//
+// Results must be called before any tasks are submitted, and the channel must
+// be read concurrently with submitting tasks and calling Wait, since the
+// stream blocks until each result has been received.
+func (s *Of[T]) Results() <-chan T {
+	c := &consumer[T]{
+		ch: make(chan T),
+	}
+	s.consumers = append(s.consumers, c)
+	return c.ch
+}
+
+// WithConsumer attaches a consumer that will be called with every result in
+// submission order. Up to bufferSize results can be waiting for the consumer
+// before the stream blocks. Consumers must be attached before any tasks are
+// submitted. Panics if bufferSize < 0.
+func (s *Of[T]) WithConsumer(bufferSize int, f func(T)) *Of[T] {
+	if bufferSize < 0 {
+		panic("consumer buffer size must not be negative")
+	}
+	s.consumers = append(s.consumers, &consumer[T]{
+		ch: make(chan T, bufferSize),
+		f:  f,
+	})
+	return s
+}
+
+// WithMaxBufferedBytes limits the total size of results that have been
+// computed but not yet delivered to the consumers, as measured by sizer.
+// Once the limit is reached, tasks that finish out of order block until
+// earlier results have been delivered, which applies backpressure to task
+// execution. The next result to be delivered is always let through, even if
+// it alone exceeds the limit. Results waiting in consumer buffers are not
+// counted. Panics if n < 1.
+func (s *Of[T]) WithMaxBufferedBytes(n int, sizer func(T) int) *Of[T] {
+	if n < 1 {
+		panic("max buffered bytes must be greater than zero")
+	}
+	s.budget.max = n
+	s.sizer = sizer
+	return s
+}
+
+// WithMaxGoroutines limits the number of goroutines used to execute tasks.
+// Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
+func (s *Of[T]) WithMaxGoroutines(n int) *Of[T] {
+	s.stream.WithMaxGoroutines(n)
+	return s
+}
+
+// WithContext configures the stream's context to be derived from ctx. See
+// Stream.WithContext.
+func (s *Of[T]) WithContext(ctx context.Context) *Of[T] {
+	s.stream.WithContext(ctx)
+	return s
+}
+
+// WithMaxBufferedResults limits the number of tasks whose results are waiting
+// to be delivered to the consumers, including tasks that are still running.
+// See Stream.WithMaxBufferedResults.
+func (s *Of[T]) WithMaxBufferedResults(n int) *Of[T] {
+	s.stream.WithMaxBufferedResults(n)
+	return s
+}
+
+func (s *Of[T]) init() {
+	s.initOnce.Do(func() {
+		for _, c := range s.consumers {
+			// Consumers created by Results are read by the caller
+			if c.f != nil {
+				s.consumerHandle.Go(c.run)
+			}
+		}
+	})
+}
+
+type consumer[T any] struct {
+	ch chan T
+	// f is nil if ch is read directly by the caller
+	f func(T)
+}
+
+// run calls the consumer's function for every result. After the function
+// panics, results are still drained so the stream is never blocked.
+func (c *consumer[T]) run() {
+	var panicCatcher conc.PanicCatcher
+	defer panicCatcher.Repanic()
+
+	for res := range c.ch {
+		if panicCatcher.Recovered() == nil {
+			res := res
+			conc.ReportPanic(panicCatcher.TryRecovered(func() { c.f(res) }))
+		}
+	}
+}
+
+// byteBudget bounds the total size of results that are waiting to be
+// delivered. Results are identified by their sequence number, and are
+// delivered in sequence order.
+type byteBudget struct {
+	max int
+
+	// submitted is the sequence number of the next task, guarded by Of.goMu
+	submitted int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	used int
+	// next is the sequence number of the next result to be delivered
+	next int
+	// skipped holds the sequence numbers of tasks that panicked and will
+	// never deliver a result
+	skipped map[int]struct{}
+	// closed is set once the stream is closed, after which results are not
+	// delivered and the budget no longer applies
+	closed bool
+}
+
+// acquire blocks until there is room for a result of the given size, unless
+// it is the next result to be delivered.
+func (b *byteBudget) acquire(seq, size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cond == nil {
+		b.cond = sync.NewCond(&b.mu)
+	}
+	for seq != b.next && b.used+size > b.max && !b.closed {
+		b.cond.Wait()
+	}
+	b.used += size
+}
+
+// close lifts the budget, waking any tasks waiting for room.
+func (b *byteBudget) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	if b.cond != nil {
+		b.cond.Broadcast()
+	}
+}
+
+// release is called once the next result, of the given size, is delivered.
+func (b *byteBudget) release(size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used -= size
+	b.advance()
+}
+
+// skip marks the result with the given sequence number as never arriving.
+func (b *byteBudget) skip(seq int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq != b.next {
+		if b.skipped == nil {
+			b.skipped = make(map[int]struct{})
+		}
+		b.skipped[seq] = struct{}{}
+		return
+	}
+	b.advance()
+}
+
+func (b *byteBudget) advance() {
+	b.next++
+	for {
+		if _, ok := b.skipped[b.next]; !ok {
+			break
+		}
+		delete(b.skipped, b.next)
+		b.next++
+	}
+	if b.cond != nil {
+		b.cond.Broadcast()
+	}
+}
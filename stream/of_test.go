@@ -0,0 +1,246 @@
+package stream
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleOf() {
+	times := []int{20, 52, 16, 45, 4, 80}
+
+	s := NewOf[time.Duration]().
+		WithConsumer(0, func(dur time.Duration) {
+			// This will print in the order the tasks were submitted
+			fmt.Println(dur)
+		})
+	for _, millis := range times {
+		dur := time.Duration(millis) * time.Millisecond
+		s.Go(func() time.Duration {
+			time.Sleep(dur)
+			return dur
+		})
+	}
+	s.Wait()
+
+	// Output:
+	// 20ms
+	// 52ms
+	// 16ms
+	// 45ms
+	// 4ms
+	// 80ms
+}
+
+func TestOf(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no consumers", func(t *testing.T) {
+		s := NewOf[int]()
+		for i := 0; i < 5; i++ {
+			s.Go(func() int { return 1 })
+		}
+		s.Wait()
+	})
+
+	t.Run("every consumer receives ordered results", func(t *testing.T) {
+		var fast, slow []int
+		s := NewOf[int]().
+			WithMaxGoroutines(5).
+			WithConsumer(0, func(i int) {
+				fast = append(fast, i)
+			}).
+			WithConsumer(3, func(i int) {
+				time.Sleep(time.Millisecond)
+				slow = append(slow, i)
+			})
+
+		expected := make([]int, 50)
+		for i := 0; i < 50; i++ {
+			i := i
+			expected[i] = i
+			s.Go(func() int {
+				time.Sleep(time.Duration(50-i) * 10 * time.Microsecond)
+				return i
+			})
+		}
+		s.Wait()
+		require.Equal(t, expected, fast)
+		require.Equal(t, expected, slow)
+	})
+
+	t.Run("results channel", func(t *testing.T) {
+		s := NewOf[int]().WithMaxGoroutines(5)
+		results := s.Results()
+
+		go func() {
+			for i := 0; i < 20; i++ {
+				i := i
+				s.Go(func() int {
+					time.Sleep(time.Duration(20-i) * 10 * time.Microsecond)
+					return i
+				})
+			}
+			s.Wait()
+		}()
+
+		var res []int
+		for i := range results {
+			res = append(res, i)
+		}
+		expected := make([]int, 20)
+		for i := range expected {
+			expected[i] = i
+		}
+		require.Equal(t, expected, res)
+	})
+
+	t.Run("results channel is closed on panic", func(t *testing.T) {
+		s := NewOf[int]()
+		results := s.Results()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range results {
+			}
+		}()
+
+		s.Go(func() int {
+			panic("something really bad happened in the task")
+		})
+		require.Panics(t, s.Wait)
+		<-done
+	})
+
+	t.Run("panic in consumer is propagated", func(t *testing.T) {
+		var other []int
+		s := NewOf[int]().
+			WithConsumer(0, func(int) {
+				panic("something really bad happened in the consumer")
+			}).
+			WithConsumer(0, func(i int) {
+				other = append(other, i)
+			})
+		for i := 0; i < 10; i++ {
+			i := i
+			s.Go(func() int { return i })
+		}
+		require.Panics(t, s.Wait)
+		require.Len(t, other, 10)
+	})
+
+	t.Run("panic in task is propagated", func(t *testing.T) {
+		s := NewOf[int]().WithConsumer(0, func(int) {})
+		s.Go(func() int {
+			panic("something really bad happened in the task")
+		})
+		require.Panics(t, s.Wait)
+	})
+
+	t.Run("max buffered bytes applies backpressure", func(t *testing.T) {
+		s := NewOf[int]().
+			WithMaxGoroutines(4).
+			WithMaxBufferedBytes(2, func(int) int { return 1 })
+		var got []int
+		s.WithConsumer(0, func(i int) { got = append(got, i) })
+
+		var started atomic.Int64
+		release := make(chan struct{})
+		submitted := make(chan struct{})
+		go func() {
+			defer close(submitted)
+			for i := 0; i < 20; i++ {
+				i := i
+				s.Go(func() int {
+					started.Add(1)
+					if i == 0 {
+						<-release
+					}
+					return i
+				})
+			}
+		}()
+
+		// The first task holds up delivery, so the tasks after it fill the
+		// budget and then block, holding on to their goroutines.
+		time.Sleep(20 * time.Millisecond)
+		require.LessOrEqual(t, started.Load(), int64(6))
+		close(release)
+		<-submitted
+		s.Wait()
+
+		expected := make([]int, 20)
+		for i := range expected {
+			expected[i] = i
+		}
+		require.Equal(t, expected, got)
+	})
+
+	t.Run("max buffered bytes with panicking task", func(t *testing.T) {
+		s := NewOf[int]().
+			WithMaxGoroutines(4).
+			WithMaxBufferedBytes(1, func(int) int { return 1 }).
+			WithConsumer(0, func(int) {})
+		for i := 0; i < 10; i++ {
+			i := i
+			s.Go(func() int {
+				if i == 3 {
+					panic("something really bad happened in the task")
+				}
+				return i
+			})
+		}
+		require.Panics(t, s.Wait)
+	})
+
+	t.Run("consumer abandons the stream", func(t *testing.T) {
+		s := NewOf[int]().WithMaxGoroutines(2)
+		results := s.Results()
+		var ran atomic.Int64
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 1000 && s.Context().Err() == nil; i++ {
+				i := i
+				s.Go(func() int {
+					ran.Add(1)
+					return i
+				})
+			}
+			s.Wait()
+		}()
+
+		require.Equal(t, 0, <-results)
+		s.Close()
+		<-done
+		require.Less(t, ran.Load(), int64(1000))
+	})
+
+	t.Run("close with max buffered bytes", func(t *testing.T) {
+		s := NewOf[int]().WithMaxGoroutines(4).WithMaxBufferedBytes(1, func(int) int { return 1 })
+		results := s.Results()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				i := i
+				s.Go(func() int { return i })
+			}
+			s.Wait()
+		}()
+
+		<-results
+		s.Close()
+		<-done
+	})
+
+	t.Run("panics on negative buffer size", func(t *testing.T) {
+		require.Panics(t, func() { NewOf[int]().WithConsumer(-1, func(int) {}) })
+	})
+}
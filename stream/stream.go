@@ -2,7 +2,9 @@
 package stream
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/sourcegraph/conc"
 	"github.com/sourcegraph/conc/pool"
@@ -26,9 +28,19 @@ func New() *Stream {
 // Once all your tasks have been submitted, Wait() must be called to clean up
 // running goroutines and propagate any panics.
 //
-// In the case of panic during execution of a task or a callback, all other
-// tasks and callbacks will still execute. The panic will be propagated to the
-// caller when Wait() is called.
+// Everything done by a task happens before its callback is called, and
+// everything done by a callback happens before the following callbacks are
+// called and before Wait returns, in the sense of the Go memory model. This
+// means that a task can hand its result to its callback without
+// synchronization, and that callbacks can collect results into plain
+// variables. See Barrier to order tasks, rather than callbacks.
+//
+// In the case of panic during execution of a task, all other tasks and
+// callbacks will still execute. If a callback panics, by default no further
+// callbacks are executed, though tasks continue to run so that producers are
+// never blocked. Either way, the panic will be propagated to the caller when
+// Wait() is called. See WithCallbackPanicPolicy and WithCallbackPanicHandler
+// to configure the behavior for callback panics.
 //
 // A Stream is efficient, but not zero cost. It should not be used for very
 // short tasks. Startup and teardown adds an overhead of a couple of
@@ -37,11 +49,46 @@ func New() *Stream {
 type Stream struct {
 	pool             pool.Pool
 	callbackerHandle conc.WaitGroup
-	queue            chan callbackCh
+	queue            chan queueEntry
+	maxBuffered      int
+
+	// barrier is the latest barrier added by Barrier, which tasks submitted
+	// after it wait for.
+	barrier atomic.Pointer[chan struct{}]
+
+	panicPolicy  CallbackPanicPolicy
+	panicHandler func(*conc.RecoveredPanic)
+
+	// aborted is closed if callbacks are aborted because one panicked, after
+	// setting abortPanic.
+	aborted    chan struct{}
+	abortPanic *conc.RecoveredPanic
+
+	// parentCtx is set by WithContext. ctx is derived from it, and is
+	// canceled by Close or once Wait returns.
+	parentCtx context.Context
+	ctx       context.Context
+	cancel    context.CancelFunc
+	// closed is closed by Close
+	closed    chan struct{}
+	closeOnce sync.Once
 
 	initOnce sync.Once
 }
 
+// CallbackPanicPolicy determines how a Stream behaves when a callback panics.
+type CallbackPanicPolicy int
+
+const (
+	// AbortOnPanic skips all callbacks after the first one that panics. The
+	// panic is propagated by Wait(). This is the default.
+	AbortOnPanic CallbackPanicPolicy = iota
+
+	// ContinueOnPanic keeps running callbacks after one panics. The first
+	// panic is propagated by Wait().
+	ContinueOnPanic
+)
+
 // Stream task is a task that is submitted to the stream. Submitted tasks will
 // be executed concurrently. It returns a callback that will be called after
 // the task has completed.
@@ -63,30 +110,136 @@ func (s *Stream) Go(f StreamTask) {
 	ch := getCh()
 
 	// Queue the channel for the callbacker
-	s.queue <- ch
+	s.queue <- queueEntry{ch: ch}
+
+	barrier := s.barrier.Load()
 
 	// Submit the task for execution
 	s.pool.Go(func() {
+		if barrier != nil {
+			<-*barrier
+		}
+		if s.isClosed() {
+			// The consumer is gone, so the task is not worth running
+			ch <- func() {}
+			return
+		}
+
+		completed := false
 		defer func() {
-			// In the case of a panic from f, we don't want the callbacker to
-			// starve waiting for a callback from this channel, so give it an
-			// empty callback.
-			if r := recover(); r != nil {
+			// In the case of a panic from f, or of f calling runtime.Goexit,
+			// we don't want the callbacker to starve waiting for a callback
+			// from this channel, so give it an empty callback.
+			if !completed {
 				ch <- func() {}
-				panic(r)
 			}
 		}()
 
 		// Run the task, sending its callback down this task's channel
 		callback := f()
+		completed = true
 		ch <- callback
 	})
 }
 
+// Flush blocks until the callbacks of all tasks submitted before the call to
+// Flush have been executed, without waiting for any tasks submitted after it.
+// This makes it possible to checkpoint progress while continuing to submit
+// tasks. Flush returns early with ctx.Err() if ctx is done first.
+//
+// If callbacks were aborted because a callback panicked, Flush returns the
+// recovered panic, and if the stream was closed, it returns context.Canceled.
+// Flush must not be called after Wait.
+func (s *Stream) Flush(ctx context.Context) error {
+	s.init()
+
+	done := make(chan struct{})
+	ch := getCh()
+	ch <- func() { close(done) }
+
+	select {
+	case s.queue <- queueEntry{ch: ch}:
+	case <-ctx.Done():
+		<-ch
+		putCh(ch)
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-s.aborted:
+		select {
+		case <-done:
+			// The flush completed before the abort
+			return nil
+		default:
+			return s.abortPanic
+		}
+	case <-s.closed:
+		// Callbacks are skipped once the stream is closed, including the
+		// one that completes the flush.
+		return context.Canceled
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Barrier makes the tasks submitted after the call to Barrier wait to start
+// until every task submitted before it has returned and its callback has
+// been executed. Everything done by those tasks and callbacks happens before
+// the tasks submitted after the barrier start, in the sense of the Go memory
+// model, so that they can read the results of the earlier tasks without
+// synchronization, as callbacks can.
+//
+// Unlike Flush, Barrier does not block the caller, which can keep submitting
+// tasks. The tasks submitted after the barrier still occupy goroutines of the
+// stream while they wait. Barrier must not be called concurrently with Go or
+// after Wait.
+func (s *Stream) Barrier() {
+	s.init()
+
+	barrier := make(chan struct{})
+	s.queue <- queueEntry{barrier: barrier}
+	s.barrier.Store(&barrier)
+}
+
+// Context returns the context of the stream, which is canceled when Close is
+// called or once Wait returns. Tasks should use it so that they stop early if
+// the consumer abandons the stream.
+func (s *Stream) Context() context.Context {
+	s.init()
+	return s.ctx
+}
+
+// Close signals that the consumer of the stream has gone away, for example
+// because the client of a request has disconnected, so that the producer can
+// stop early. It cancels the stream's context, tasks that have not started
+// yet are skipped, and no further callbacks are run. Close may be called any
+// number of times, concurrently with Go. Wait must still be called to clean
+// up the stream.
+func (s *Stream) Close() {
+	s.init()
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.cancel()
+	})
+}
+
+func (s *Stream) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
 // Wait signals to the stream that all tasks have been submitted. Wait will
 // not return until all tasks and callbacks have been run.
 func (s *Stream) Wait() {
 	s.init()
+	defer s.cancel()
 
 	// Defer the callbacker cleanup so that it occurs even in the case
 	// that one of the tasks panics and is propagated up by s.pool.Wait()
@@ -99,14 +252,74 @@ func (s *Stream) Wait() {
 	s.pool.Wait()
 }
 
+// WithMaxGoroutines limits the number of goroutines used to execute tasks.
+// Defaults to runtime.GOMAXPROCS(0). Panics if n < 1.
 func (s *Stream) WithMaxGoroutines(n int) *Stream {
 	s.pool.WithMaxGoroutines(n)
 	return s
 }
 
+// WithOptions applies the settings of opts that are set to the stream. The
+// name and progress apply to the pool that runs its tasks. See
+// pool.Pool.WithOptions.
+func (s *Stream) WithOptions(opts conc.Options) *Stream {
+	s.pool.WithOptions(opts)
+	return s
+}
+
+// WithContext configures the stream's context, as returned by Context, to be
+// derived from ctx. Defaults to context.Background().
+func (s *Stream) WithContext(ctx context.Context) *Stream {
+	s.parentCtx = ctx
+	return s
+}
+
+// WithMaxBufferedResults limits the number of tasks whose callbacks are
+// waiting to be executed, including tasks that are still running. Once the
+// limit is reached, Go blocks until the next callback has been executed. This
+// makes it possible to keep results flowing from fast tasks while a slow
+// callback catches up, independently of WithMaxGoroutines. A limit lower than
+// the number of goroutines also limits how many tasks run at once. Defaults to
+// one more than the number of goroutines. Panics if n < 1.
+func (s *Stream) WithMaxBufferedResults(n int) *Stream {
+	if n < 1 {
+		panic("max buffered results must be greater than zero")
+	}
+	s.maxBuffered = n
+	return s
+}
+
+// WithCallbackPanicPolicy configures what happens to the remaining callbacks
+// when a callback panics. Defaults to AbortOnPanic.
+func (s *Stream) WithCallbackPanicPolicy(policy CallbackPanicPolicy) *Stream {
+	s.panicPolicy = policy
+	return s
+}
+
+// WithCallbackPanicHandler configures the stream to call handler with every
+// panic raised by a callback instead of propagating it from Wait(). The stream
+// keeps running callbacks after a panic. The handler is called from the same
+// goroutine that runs the callbacks, so it should return quickly.
+func (s *Stream) WithCallbackPanicHandler(handler func(*conc.RecoveredPanic)) *Stream {
+	s.panicHandler = handler
+	return s
+}
+
 func (s *Stream) init() {
 	s.initOnce.Do(func() {
-		s.queue = make(chan callbackCh, s.pool.MaxGoroutines()+1)
+		maxBuffered := s.maxBuffered
+		if maxBuffered == 0 {
+			maxBuffered = s.pool.MaxGoroutines() + 1
+		}
+		s.queue = make(chan queueEntry, maxBuffered)
+		s.aborted = make(chan struct{})
+		s.closed = make(chan struct{})
+
+		parent := s.parentCtx
+		if parent == nil {
+			parent = context.Background()
+		}
+		s.ctx, s.cancel = context.WithCancel(parent)
 
 		// Start the callbacker
 		s.callbackerHandle.Go(s.callbacker)
@@ -119,21 +332,62 @@ func (s *Stream) callbacker() {
 	var panicCatcher conc.PanicCatcher
 	defer panicCatcher.Repanic()
 
+	aborted := false
+
 	// For every scheduled task, read that tasks channel from the queue.
-	for callbackCh := range s.queue {
+	for entry := range s.queue {
+		if entry.barrier != nil {
+			// Release the tasks waiting for the barrier, even if callbacks
+			// were aborted, since they would block forever otherwise.
+			close(entry.barrier)
+			continue
+		}
+
 		// Wait for the task to complete and get its callback from the channel
+		callbackCh := entry.ch
 		callback := <-callbackCh
 
-		// Execute the callback (with panic protection)
-		panicCatcher.Try(callback)
+		// Execute the callback (with panic protection). Even once aborted
+		// or closed, we keep draining the channels so the tasks never block.
+		if !aborted && !s.isClosed() {
+			aborted = s.runCallback(&panicCatcher, callback)
+			if aborted {
+				s.abortPanic = panicCatcher.Recovered()
+				close(s.aborted)
+			}
+		}
 
 		// Return the channel to the pool of unused channels
 		putCh(callbackCh)
 	}
 }
 
+// runCallback executes a callback, handling any panic according to the
+// configured panic policy. It returns true if no further callbacks should
+// be executed.
+func (s *Stream) runCallback(panicCatcher *conc.PanicCatcher, callback func()) bool {
+	if s.panicHandler != nil {
+		var pc conc.PanicCatcher
+		pc.Try(callback)
+		if recovered := pc.Recovered(); recovered != nil {
+			s.panicHandler(recovered)
+		}
+		return false
+	}
+
+	conc.ReportPanic(panicCatcher.TryRecovered(callback))
+	return s.panicPolicy == AbortOnPanic && panicCatcher.Recovered() != nil
+}
+
 type callbackCh chan func()
 
+// queueEntry is either the channel of a task, on which it sends its callback,
+// or a barrier to close once the callbacks before it have been executed.
+type queueEntry struct {
+	ch      callbackCh
+	barrier chan struct{}
+}
+
 var callbackChPool = sync.Pool{
 	New: func() any {
 		return make(callbackCh, 1)
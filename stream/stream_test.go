@@ -1,12 +1,17 @@
 package stream
 
 import (
+	"context"
 	"fmt"
+	"runtime"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/conc"
+	"github.com/sourcegraph/conc/conctest"
 )
 
 func ExampleStream() {
@@ -112,6 +117,267 @@ func TestStream(t *testing.T) {
 		}
 		require.Panics(t, s.Wait)
 	})
+
+	t.Run("panic in callback aborts remaining callbacks", func(t *testing.T) {
+		s := New().WithMaxGoroutines(5)
+		var ran atomic.Int64
+		s.Go(func() Callback {
+			return func() {
+				panic("something really bad happened in the callback")
+			}
+		})
+		for i := 0; i < 10; i++ {
+			s.Go(func() Callback {
+				return func() { ran.Add(1) }
+			})
+		}
+		require.Panics(t, s.Wait)
+		require.Equal(t, int64(0), ran.Load())
+	})
+
+	t.Run("ContinueOnPanic runs remaining callbacks", func(t *testing.T) {
+		s := New().WithMaxGoroutines(5).WithCallbackPanicPolicy(ContinueOnPanic)
+		var ran atomic.Int64
+		s.Go(func() Callback {
+			return func() {
+				panic("something really bad happened in the callback")
+			}
+		})
+		for i := 0; i < 10; i++ {
+			s.Go(func() Callback {
+				return func() { ran.Add(1) }
+			})
+		}
+		require.Panics(t, s.Wait)
+		require.Equal(t, int64(10), ran.Load())
+	})
+
+	t.Run("WithCallbackPanicHandler", func(t *testing.T) {
+		var handled []any
+		s := New().WithMaxGoroutines(5).WithCallbackPanicHandler(func(rp *conc.RecoveredPanic) {
+			handled = append(handled, rp.Value)
+		})
+		var res []int
+		for i := 0; i < 5; i++ {
+			i := i
+			s.Go(func() Callback {
+				return func() {
+					if i%2 == 1 {
+						panic(i)
+					}
+					res = append(res, i)
+				}
+			})
+		}
+		require.NotPanics(t, s.Wait)
+		require.Equal(t, []int{0, 2, 4}, res)
+		require.Equal(t, []any{1, 3}, handled)
+	})
+
+	t.Run("WithMaxBufferedResults", func(t *testing.T) {
+		s := New().WithMaxGoroutines(1).WithMaxBufferedResults(10)
+		release := make(chan struct{})
+		var ran atomic.Int64
+		for i := 0; i < 10; i++ {
+			s.Go(func() Callback {
+				ran.Add(1)
+				return func() { <-release }
+			})
+		}
+		// All tasks run while the first callback is blocked
+		require.Eventually(t, func() bool { return ran.Load() == 10 }, time.Second, time.Millisecond)
+		close(release)
+		s.Wait()
+
+		require.Panics(t, func() { New().WithMaxBufferedResults(0) })
+	})
+
+	t.Run("close", func(t *testing.T) {
+		s := New().WithContext(context.Background())
+		var callbacks atomic.Int64
+		started := make(chan struct{})
+		s.Go(func() Callback {
+			close(started)
+			<-s.Context().Done()
+			return func() { callbacks.Add(1) }
+		})
+		<-started
+		s.Close()
+		s.Close()
+		s.Go(func() Callback {
+			t.Error("task should not run after Close")
+			return func() {}
+		})
+		require.ErrorIs(t, s.Flush(context.Background()), context.Canceled)
+		s.Wait()
+		require.Zero(t, callbacks.Load())
+	})
+
+	t.Run("context is canceled after Wait", func(t *testing.T) {
+		s := New()
+		ctx := s.Context()
+		s.Go(func() Callback { return func() {} })
+		s.Wait()
+		require.Error(t, ctx.Err())
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		s := New().WithMaxGoroutines(5)
+		var res []int
+		for i := 0; i < 10; i++ {
+			i := i
+			s.Go(func() Callback {
+				time.Sleep(time.Duration(10-i) * 100 * time.Microsecond)
+				return func() { res = append(res, i) }
+			})
+		}
+		require.NoError(t, s.Flush(context.Background()))
+		require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, res)
+
+		// The stream is still usable after a flush
+		s.Go(func() Callback {
+			return func() { res = append(res, 10) }
+		})
+		s.Wait()
+		require.Len(t, res, 11)
+	})
+
+	t.Run("task writes happen before callbacks", func(t *testing.T) {
+		// Run with -race: the plain writes and reads below are only free of
+		// data races because of the ordering guaranteed by the stream.
+		s := New().WithMaxGoroutines(4)
+		results := make([]int, 20)
+		sum := 0
+		for i := range results {
+			i := i
+			s.Go(func() Callback {
+				results[i] = i
+				return func() { sum += results[i] }
+			})
+		}
+		s.Wait()
+		require.Equal(t, 190, sum)
+	})
+
+	t.Run("barrier orders tasks", func(t *testing.T) {
+		// Run with -race: the tasks after the barrier read what the tasks
+		// and callbacks before it wrote, without synchronization.
+		s := New().WithMaxGoroutines(4)
+		results := make([]int, 10)
+		sum := 0
+		for i := range results {
+			i := i
+			s.Go(func() Callback {
+				time.Sleep(time.Duration(10-i) * 100 * time.Microsecond)
+				results[i] = i
+				return func() { sum += i }
+			})
+		}
+		s.Barrier()
+		var seen [5]int
+		for i := range seen {
+			i := i
+			s.Go(func() Callback {
+				seen[i] = sum
+				for _, res := range results {
+					seen[i] += res
+				}
+				return func() {}
+			})
+		}
+		s.Wait()
+		require.Equal(t, [5]int{90, 90, 90, 90, 90}, seen)
+	})
+
+	t.Run("barrier releases tasks after abort", func(t *testing.T) {
+		s := New()
+		s.Go(func() Callback {
+			return func() { panic("something really bad happened in the callback") }
+		})
+		s.Barrier()
+		var ran atomic.Bool
+		s.Go(func() Callback {
+			ran.Store(true)
+			return func() {}
+		})
+		require.Panics(t, s.Wait)
+		require.True(t, ran.Load())
+	})
+
+	t.Run("barrier releases tasks after close", func(t *testing.T) {
+		s := New()
+		s.Go(func() Callback {
+			s.Close()
+			return func() {}
+		})
+		s.Barrier()
+		s.Go(func() Callback {
+			return func() {}
+		})
+		s.Wait()
+	})
+
+	t.Run("flush is canceled with context", func(t *testing.T) {
+		s := New()
+		unblock := make(chan struct{})
+		s.Go(func() Callback {
+			<-unblock
+			return func() {}
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, s.Flush(ctx), context.DeadlineExceeded)
+		close(unblock)
+		s.Wait()
+	})
+
+	t.Run("options", func(t *testing.T) {
+		var calls []int
+		s := New().WithOptions(conc.Options{
+			MaxGoroutines: 2,
+			OnProgress:    func(done, total int) { calls = append(calls, done) },
+		})
+		for i := 0; i < 3; i++ {
+			s.Go(func() Callback { return func() {} })
+		}
+		s.Wait()
+		require.Equal(t, []int{1, 2, 3}, calls)
+	})
+
+	t.Run("flush returns panic after abort", func(t *testing.T) {
+		s := New()
+		s.Go(func() Callback {
+			return func() { panic("something really bad happened in the callback") }
+		})
+		var rp *conc.RecoveredPanic
+		require.ErrorAs(t, s.Flush(context.Background()), &rp)
+		require.Panics(t, s.Wait)
+	})
+}
+
+func TestBarrierStress(t *testing.T) {
+	conctest.Stress(t, conctest.Options{Iterations: 40}, func(t *conctest.T) {
+		s := New().WithMaxGoroutines(3)
+		results := make([]int, 6)
+		for i := range results {
+			i := i
+			s.Go(func() Callback {
+				t.Yield()
+				results[i] = i
+				return func() {}
+			})
+		}
+		s.Barrier()
+		sum := 0
+		s.Go(func() Callback {
+			for _, res := range results {
+				sum += res
+			}
+			return func() {}
+		})
+		s.Wait()
+		require.Equal(t, 15, sum)
+	})
 }
 
 func BenchmarkStream(b *testing.B) {
@@ -136,3 +402,51 @@ func BenchmarkStream(b *testing.B) {
 		s.Wait()
 	})
 }
+
+func TestGoexit(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	var delivered []int
+	s.Go(func() Callback { return func() { delivered = append(delivered, 0) } })
+	s.Go(func() Callback {
+		runtime.Goexit()
+		return func() {}
+	})
+	s.Go(func() Callback { return func() { delivered = append(delivered, 2) } })
+	defer func() {
+		recovered, ok := recover().(*conc.RecoveredPanic)
+		require.True(t, ok)
+		require.ErrorIs(t, recovered, conc.ErrGoexit)
+		require.Equal(t, []int{0, 2}, delivered)
+	}()
+	s.Wait()
+}
+
+func TestDefaultPanicHandler(t *testing.T) {
+	var values []any
+	conc.SetDefaultPanicHandler(func(recovered *conc.RecoveredPanic) {
+		values = append(values, recovered.Value)
+	})
+	defer conc.SetDefaultPanicHandler(nil)
+
+	t.Run("reports callback panics once", func(t *testing.T) {
+		values = nil
+		s := New()
+		s.Go(func() Callback {
+			return func() { panic("super bad thing") }
+		})
+		require.Panics(t, s.Wait)
+		require.Equal(t, []any{"super bad thing"}, values)
+	})
+
+	t.Run("does not report panics passed to the callback panic handler", func(t *testing.T) {
+		values = nil
+		s := New().WithCallbackPanicHandler(func(*conc.RecoveredPanic) {})
+		s.Go(func() Callback {
+			return func() { panic("super bad thing") }
+		})
+		require.NotPanics(t, s.Wait)
+		require.Empty(t, values)
+	})
+}
@@ -0,0 +1,100 @@
+package conc
+
+import (
+	"sync"
+)
+
+// TaskInfo identifies a task run by a pool, so that a panic can be traced back
+// to the task that raised it. See RecoveredPanic.TaskInfo.
+type TaskInfo struct {
+	// Pool is the name of the pool that ran the task, or "" if the pool is
+	// unnamed.
+	Pool string
+	// Name is the name the task was submitted with, or "" if it has none.
+	Name string
+	// Index is the position of the task in the order tasks were submitted to
+	// the pool.
+	Index int
+}
+
+// RunTask runs f, and if f panics, records the TaskInfo returned by info in
+// the RecoveredPanic created when the panic is recovered, for example by a
+// PanicCatcher. info is only called if f panics, so it costs nothing for tasks
+// that succeed. RunTask is meant for code that runs tasks on behalf of its
+// callers, such as the pools in the pool package, which call it for every
+// task.
+func RunTask(info func() TaskInfo, f func()) {
+	completed := false
+	defer func() {
+		if !completed {
+			recordPanic(currentGoroutineID(), func(p *panicInfo) {
+				// Keep the innermost task
+				if p.task == nil {
+					p.task = safeTaskInfo(info)
+				}
+			})
+		}
+	}()
+	f()
+	completed = true
+}
+
+// safeTaskInfo returns the result of info, or nil if info panics, so that a
+// broken info function cannot replace the panic being recorded.
+func safeTaskInfo(info func() TaskInfo) (task *TaskInfo) {
+	defer func() {
+		if recover() != nil {
+			task = nil
+		}
+	}()
+	t := info()
+	return &t
+}
+
+// panicInfo is the metadata recorded for a panic while it unwinds through
+// calls to Label and RunTask.
+type panicInfo struct {
+	label string
+	// labels are the labels of every call to Label, outermost first
+	labels []string
+	task   *TaskInfo
+}
+
+// panicking maps the ID of a goroutine that is unwinding a panic to the
+// metadata recorded for it, until the panic is recovered by
+// NewRecoveredPanic.
+var panicking struct {
+	mu    sync.Mutex
+	infos map[uint64]*panicInfo
+}
+
+// recordPanic calls update with the metadata of the panic unwinding on
+// goroutine gid.
+func recordPanic(gid uint64, update func(*panicInfo)) {
+	panicking.mu.Lock()
+	defer panicking.mu.Unlock()
+
+	if panicking.infos == nil {
+		panicking.infos = make(map[uint64]*panicInfo)
+	}
+	info, ok := panicking.infos[gid]
+	if !ok {
+		info = &panicInfo{}
+		panicking.infos[gid] = info
+	}
+	update(info)
+}
+
+// takePanicInfo returns and forgets the metadata recorded for a panic on
+// goroutine gid.
+func takePanicInfo(gid uint64) panicInfo {
+	panicking.mu.Lock()
+	defer panicking.mu.Unlock()
+
+	info, ok := panicking.infos[gid]
+	if !ok {
+		return panicInfo{}
+	}
+	delete(panicking.infos, gid)
+	return *info
+}
@@ -0,0 +1,72 @@
+package conc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTask(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records the task of a panic", func(t *testing.T) {
+		t.Parallel()
+		var pc PanicCatcher
+		pc.Try(func() {
+			RunTask(func() TaskInfo {
+				return TaskInfo{Pool: "fetch", Name: "users", Index: 3}
+			}, func() { panicWith("boom") })
+		})
+		recovered := pc.Recovered()
+		require.NotNil(t, recovered)
+		require.Equal(t, &TaskInfo{Pool: "fetch", Name: "users", Index: 3}, recovered.TaskInfo)
+		require.Equal(t, recovered.TaskInfo, recovered.Report().TaskInfo)
+	})
+
+	t.Run("keeps the innermost task", func(t *testing.T) {
+		t.Parallel()
+		var pc PanicCatcher
+		pc.Try(func() {
+			RunTask(func() TaskInfo { return TaskInfo{Name: "outer"} }, func() {
+				RunTask(func() TaskInfo { return TaskInfo{Name: "inner"} }, func() {
+					panicWith("boom")
+				})
+			})
+		})
+		require.Equal(t, "inner", pc.Recovered().TaskInfo.Name)
+	})
+
+	t.Run("info that panics is ignored", func(t *testing.T) {
+		t.Parallel()
+		var pc PanicCatcher
+		pc.Try(func() {
+			RunTask(func() TaskInfo { panic("broken info") }, func() { panicWith("boom") })
+		})
+		require.Equal(t, "boom", pc.Recovered().Value)
+		require.Nil(t, pc.Recovered().TaskInfo)
+	})
+
+	t.Run("info is not called without a panic", func(t *testing.T) {
+		t.Parallel()
+		ran := false
+		RunTask(func() TaskInfo {
+			t.Fatal("info called")
+			return TaskInfo{}
+		}, func() { ran = true })
+		require.True(t, ran)
+
+		var pc PanicCatcher
+		pc.Try(func() { panicWith("boom") })
+		require.Nil(t, pc.Recovered().TaskInfo)
+	})
+
+	t.Run("goroutine header", func(t *testing.T) {
+		t.Parallel()
+		var pc PanicCatcher
+		pc.Try(func() { panicWith("boom") })
+		header := pc.Recovered().Goroutine
+		require.True(t, strings.HasPrefix(header, "goroutine "), header)
+		require.True(t, strings.HasSuffix(header, ":"), header)
+	})
+}
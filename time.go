@@ -0,0 +1,151 @@
+package conc
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is a source of time and timers. Sleep and Tick use the Clock attached
+// to their context with WithClock, which makes it possible to test code that
+// sleeps with a fake clock instead of waiting in real time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a Timer that fires once, after d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single timer created by a Clock. See time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer
+	// fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if the timer has
+	// already fired or been stopped.
+	Stop() bool
+}
+
+type clockKey struct{}
+
+// WithClock returns a copy of ctx that carries clock, to be used by Sleep and
+// Tick.
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// ClockFromContext returns the Clock attached to ctx with WithClock, or a
+// Clock backed by the time package if there is none.
+func ClockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock
+	}
+	return realClock{}
+}
+
+// Sleep pauses the current goroutine for at least d, or until ctx is done,
+// whichever happens first. It returns ctx.Err() if ctx is done before d has
+// passed, and nil otherwise. Unlike time.After, the timer is always stopped,
+// so canceled sleeps do not hold on to resources.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	timer := ClockFromContext(ctx).NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Tick returns a channel that delivers the current time every d until ctx is
+// done, at which point the channel is closed and the underlying timer is
+// stopped. As with time.Ticker, ticks are dropped to make up for slow
+// receivers. Panics if d <= 0.
+func Tick(ctx context.Context, d time.Duration) <-chan time.Time {
+	if d <= 0 {
+		panic("tick interval must be greater than zero")
+	}
+
+	clock := ClockFromContext(ctx)
+	ch := make(chan time.Time, 1)
+	// This goroutine is scoped to ctx: it exits as soon as ctx is done.
+	go func() {
+		defer close(ch)
+		// Ticks are scheduled relative to the start rather than the previous
+		// tick, so that they do not drift.
+		next := clock.Now().Add(d)
+		for {
+			timer := clock.NewTimer(next.Sub(clock.Now()))
+			select {
+			case t := <-timer.C():
+				select {
+				case ch <- t:
+				default:
+					// The receiver has not read the previous tick yet
+				}
+				for !next.After(t) {
+					next = next.Add(d)
+				}
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// AfterFunc is like time.AfterFunc, but a panic in f is caught and passed to
+// the handler set with SetDefaultPanicHandler, as for Go, since nothing owns
+// the goroutine that f runs in. If there is no handler, the panic is raised
+// again, with the stack trace of the original panic.
+func AfterFunc(d time.Duration, f func()) *time.Timer {
+	return time.AfterFunc(d, func() {
+		var pc PanicCatcher
+		pc.Try(f)
+		handlePanic(pc.Recovered())
+	})
+}
+
+// TickFunc calls f every d until ctx is done, from a goroutine of its own,
+// with the ticks of Tick. Calls are never concurrent, and ticks are dropped
+// while f is running late. A panic in f is caught and passed to the handler
+// set with SetDefaultPanicHandler, after which the ticks go on. If there is
+// no handler, the panic is raised again, as for Go. Panics if d <= 0.
+func TickFunc(ctx context.Context, d time.Duration, f func()) {
+	ticks := Tick(ctx, d)
+	// This goroutine is scoped to ctx: it exits once Tick closes ticks.
+	go func() {
+		for range ticks {
+			var pc PanicCatcher
+			pc.Try(f)
+			handlePanic(pc.Recovered())
+		}
+	}()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,210 @@
+package conc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSleep(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sleeps", func(t *testing.T) {
+		start := time.Now()
+		require.NoError(t, Sleep(context.Background(), 10*time.Millisecond))
+		require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, Sleep(ctx, time.Hour), context.DeadlineExceeded)
+	})
+
+	t.Run("already canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, Sleep(ctx, 0), context.Canceled)
+	})
+
+	t.Run("fake clock", func(t *testing.T) {
+		clock := newFakeClock()
+		ctx := WithClock(context.Background(), clock)
+
+		done := make(chan error)
+		go func() { done <- Sleep(ctx, time.Hour) }()
+
+		require.Eventually(t, func() bool { return clock.timers() == 1 }, time.Second, time.Millisecond)
+		clock.Advance(time.Hour)
+		require.NoError(t, <-done)
+	})
+}
+
+func TestTick(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ticks until canceled", func(t *testing.T) {
+		clock := newFakeClock()
+		ctx, cancel := context.WithCancel(WithClock(context.Background(), clock))
+
+		ticks := Tick(ctx, time.Minute)
+		for i := 1; i <= 3; i++ {
+			require.Eventually(t, func() bool { return clock.timers() == 1 }, time.Second, time.Millisecond)
+			clock.Advance(time.Minute)
+			tick := <-ticks
+			require.Equal(t, clock.start.Add(time.Duration(i)*time.Minute), tick)
+		}
+
+		cancel()
+		for range ticks {
+		}
+	})
+
+	t.Run("panics on invalid interval", func(t *testing.T) {
+		require.Panics(t, func() { Tick(context.Background(), 0) })
+	})
+}
+
+func TestAfterFunc(t *testing.T) {
+	t.Run("calls f", func(t *testing.T) {
+		done := make(chan struct{})
+		AfterFunc(time.Millisecond, func() { close(done) })
+		<-done
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		timer := AfterFunc(time.Hour, func() { t.Fatal("should not be called") })
+		require.True(t, timer.Stop())
+	})
+
+	t.Run("panics are passed to the default handler", func(t *testing.T) {
+		caught := make(chan *RecoveredPanic, 1)
+		SetDefaultPanicHandler(func(recovered *RecoveredPanic) {
+			caught <- recovered
+		})
+		defer SetDefaultPanicHandler(nil)
+
+		AfterFunc(time.Millisecond, func() { panic("timer panic") })
+		require.Equal(t, "timer panic", (<-caught).Value)
+	})
+}
+
+func TestTickFunc(t *testing.T) {
+	t.Run("calls f on each tick until canceled", func(t *testing.T) {
+		clock := newFakeClock()
+		ctx, cancel := context.WithCancel(WithClock(context.Background(), clock))
+		defer cancel()
+
+		calls := make(chan struct{})
+		TickFunc(ctx, time.Minute, func() { calls <- struct{}{} })
+		for i := 0; i < 3; i++ {
+			require.Eventually(t, func() bool { return clock.timers() == 1 }, time.Second, time.Millisecond)
+			clock.Advance(time.Minute)
+			<-calls
+		}
+	})
+
+	t.Run("keeps ticking after a panic", func(t *testing.T) {
+		panics := collectPanics(t)
+		clock := newFakeClock()
+		ctx, cancel := context.WithCancel(WithClock(context.Background(), clock))
+		defer cancel()
+
+		calls := make(chan int, 2)
+		n := 0
+		TickFunc(ctx, time.Minute, func() {
+			n++
+			calls <- n
+			if n == 1 {
+				panic("ticker panic")
+			}
+		})
+		for i := 1; i <= 2; i++ {
+			require.Eventually(t, func() bool { return clock.timers() == 1 }, time.Second, time.Millisecond)
+			clock.Advance(time.Minute)
+			require.Equal(t, i, <-calls)
+		}
+		require.Eventually(t, func() bool { return len(panics()) == 1 }, time.Second, time.Millisecond)
+		require.Equal(t, []any{"ticker panic"}, panics())
+	})
+
+	t.Run("panics on invalid interval", func(t *testing.T) {
+		require.Panics(t, func() { TickFunc(context.Background(), 0, func() {}) })
+	})
+}
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	start time.Time
+
+	mu      sync.Mutex
+	now     time.Time
+	pending []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &fakeClock{start: start, now: start}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), ch: make(chan time.Time, 1)}
+	c.pending = append(c.pending, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing any timers that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.pending[:0]
+	for _, t := range c.pending {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.pending = pending
+}
+
+// timers returns the number of timers that have not fired or been stopped.
+func (c *fakeClock) timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	ch    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, pending := range t.clock.pending {
+		if pending == t {
+			t.clock.pending = append(t.clock.pending[:i], t.clock.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrTrackerClosed is returned by Tracker.Go once Shutdown has been called.
+var ErrTrackerClosed = errors.New("conc: tracker is shut down")
+
+// NewTracker creates a Tracker that runs at most n tasks at once. Panics if
+// n < 1.
+func NewTracker(n int) *Tracker {
+	if n < 1 {
+		panic("max tasks in a tracker must be greater than zero")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tracker{
+		sem:     make(chan struct{}, n),
+		ctx:     ctx,
+		cancel:  cancel,
+		closing: make(chan struct{}),
+		running: make(map[uint64]string),
+	}
+}
+
+// Tracker owns background tasks that legitimately outlive the code that
+// starts them, such as the work an HTTP handler hands off before responding,
+// in place of untracked go statements. Unlike a pool, nothing waits for the
+// tasks until the Tracker is shut down, typically when the server stops.
+//
+// The number of tasks running at once is bounded. A panic in a task is caught
+// and passed to the handler set with SetDefaultPanicHandler, rather than
+// crashing the program, and is available from RecentPanics. Tasks are named,
+// and run with their name as a Label, so that the tasks in progress can be
+// listed with Running or RunningLabels, and the tasks that did not finish
+// when shutting down can be reported.
+//
+// A Tracker must be created with NewTracker.
+type Tracker struct {
+	sem chan struct{}
+
+	// ctx is passed to the tasks, and is canceled once Shutdown returns
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// closing is closed once Shutdown is called, and closed is set then
+	closing chan struct{}
+	closed  bool
+	wg      sync.WaitGroup
+	nextID  uint64
+	running map[uint64]string
+}
+
+// Go starts f in a new goroutine, once fewer tasks than the limit are
+// running, blocking until then. f is passed a context that is canceled when
+// Shutdown returns, so that tasks still running then can stop. name describes
+// the task in Running and in the error returned by Shutdown. Go returns
+// ErrTrackerClosed without starting f if Shutdown has been called.
+func (t *Tracker) Go(name string, f func(ctx context.Context)) error {
+	select {
+	case t.sem <- struct{}{}:
+	case <-t.closing:
+		return ErrTrackerClosed
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		<-t.sem
+		return ErrTrackerClosed
+	}
+	id := t.nextID
+	t.nextID++
+	t.running[id] = name
+	t.wg.Add(1)
+	t.mu.Unlock()
+
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			delete(t.running, id)
+			t.mu.Unlock()
+			<-t.sem
+			t.wg.Done()
+		}()
+		var pc PanicCatcher
+		ReportPanic(pc.TryRecovered(func() {
+			Label(name, func() { f(t.ctx) })
+		}))
+	}()
+	return nil
+}
+
+// Running returns the names of the tasks in progress, in the order they
+// started.
+func (t *Tracker) Running() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make([]uint64, 0, len(t.running))
+	for id := range t.running {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = t.running[id]
+	}
+	return names
+}
+
+// Shutdown stops the tracker from starting new tasks, including the calls to
+// Go that are blocked, and waits for the running tasks to finish, or for ctx
+// to be done. Either way, it then cancels the context passed to the tasks. If
+// ctx is done first, the tasks still running are abandoned: they are left to
+// stop in the background, and Shutdown returns a *ShutdownError that lists
+// them. Shutdown can be called more than once.
+func (t *Tracker) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.closing)
+	}
+	t.mu.Unlock()
+	defer t.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return &ShutdownError{Abandoned: t.Running(), Err: ctx.Err()}
+	}
+}
+
+// ShutdownError is returned by Tracker.Shutdown if some tasks were still
+// running when it gave up waiting for them.
+type ShutdownError struct {
+	// Abandoned are the names of the tasks that were still running, in the
+	// order they started.
+	Abandoned []string
+	// Err is the error of the context passed to Shutdown.
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("conc: abandoned %d background tasks (%s): %s", len(e.Abandoned), strings.Join(e.Abandoned, ", "), e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
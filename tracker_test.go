@@ -0,0 +1,120 @@
+package conc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("shutdown waits for tasks", func(t *testing.T) {
+		t.Parallel()
+		tr := NewTracker(4)
+		var done atomic.Int64
+		for i := 0; i < 10; i++ {
+			require.NoError(t, tr.Go("work", func(ctx context.Context) {
+				time.Sleep(time.Millisecond)
+				done.Add(1)
+			}))
+		}
+		require.NoError(t, tr.Shutdown(context.Background()))
+		require.Equal(t, int64(10), done.Load())
+		require.ErrorIs(t, tr.Go("late", func(ctx context.Context) {}), ErrTrackerClosed)
+	})
+
+	t.Run("limits running tasks", func(t *testing.T) {
+		t.Parallel()
+		tr := NewTracker(2)
+		var running, peak atomic.Int64
+		for i := 0; i < 20; i++ {
+			require.NoError(t, tr.Go("work", func(ctx context.Context) {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+			}))
+		}
+		require.NoError(t, tr.Shutdown(context.Background()))
+		require.LessOrEqual(t, peak.Load(), int64(2))
+	})
+
+	t.Run("shutdown abandons tasks", func(t *testing.T) {
+		t.Parallel()
+		tr := NewTracker(2)
+		started := make(chan struct{})
+		stopped := make(chan struct{})
+		require.NoError(t, tr.Go("send email", func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+			close(stopped)
+		}))
+		<-started
+		require.Equal(t, []string{"send email"}, tr.Running())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := tr.Shutdown(ctx)
+		var shutdownErr *ShutdownError
+		require.ErrorAs(t, err, &shutdownErr)
+		require.Equal(t, []string{"send email"}, shutdownErr.Abandoned)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Contains(t, err.Error(), "send email")
+
+		// The abandoned task is told to stop
+		<-stopped
+	})
+
+	t.Run("shutdown releases blocked calls", func(t *testing.T) {
+		t.Parallel()
+		tr := NewTracker(1)
+		release := make(chan struct{})
+		require.NoError(t, tr.Go("slow", func(ctx context.Context) { <-release }))
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		var blockedErr error
+		go func() {
+			defer wg.Done()
+			blockedErr = tr.Go("blocked", func(ctx context.Context) {})
+		}()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+		require.NoError(t, tr.Shutdown(context.Background()))
+		wg.Wait()
+		require.ErrorIs(t, blockedErr, ErrTrackerClosed)
+	})
+
+	t.Run("panics are caught", func(t *testing.T) {
+		t.Parallel()
+		tr := NewTracker(1)
+		require.NoError(t, tr.Go("panicky", func(ctx context.Context) { panic("tracker panic") }))
+		require.NoError(t, tr.Shutdown(context.Background()))
+
+		var found bool
+		for _, recovered := range RecentPanics() {
+			if recovered.Value == "tracker panic" {
+				require.Equal(t, "panicky", recovered.Task)
+				found = true
+			}
+		}
+		require.True(t, found)
+	})
+
+	t.Run("panics on invalid limit", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() { NewTracker(0) })
+	})
+}
@@ -19,12 +19,14 @@ func (h *WaitGroup) Go(f func()) {
 	h.wg.Add(1)
 	go func() {
 		defer h.wg.Done()
-		h.pc.Try(f)
+		ReportPanic(h.pc.TryRecovered(f))
 	}()
 }
 
 // Wait will block until all goroutines spawned with Go exit and will
-// propagate any panics spawned in a child goroutine.
+// propagate any panics spawned in a child goroutine. Everything done by the
+// goroutines happens before Wait returns, in the sense of the Go memory
+// model, so their results can be read without further synchronization.
 func (h *WaitGroup) Wait() {
 	h.wg.Wait()
 
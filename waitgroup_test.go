@@ -2,6 +2,7 @@ package conc
 
 import (
 	"fmt"
+	"runtime"
 	"sync/atomic"
 	"testing"
 
@@ -39,7 +40,35 @@ func TestWaitGroup(t *testing.T) {
 		require.Equal(t, count.Load(), int64(100))
 	})
 
+	t.Run("writes happen before Wait returns", func(t *testing.T) {
+		// Run with -race: the plain writes and reads below are only free of
+		// data races because of the ordering guaranteed by Wait.
+		results := make([]int, 100)
+		var wg WaitGroup
+		for i := range results {
+			i := i
+			wg.Go(func() {
+				results[i] = i * 2
+			})
+		}
+		wg.Wait()
+		for i, res := range results {
+			require.Equal(t, i*2, res)
+		}
+	})
+
 	t.Run("panic", func(t *testing.T) {
+		t.Run("Goexit is propagated", func(t *testing.T) {
+			var wg WaitGroup
+			wg.Go(runtime.Goexit)
+			defer func() {
+				recovered, ok := recover().(*RecoveredPanic)
+				require.True(t, ok)
+				require.ErrorIs(t, recovered, ErrGoexit)
+			}()
+			wg.Wait()
+		})
+
 		t.Run("is propagated", func(t *testing.T) {
 			var wg WaitGroup
 			wg.Go(func() {
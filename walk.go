@@ -0,0 +1,178 @@
+package conc
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// WalkOrder is the order in which a Walker visits the nodes it has found.
+type WalkOrder int
+
+const (
+	// DepthFirst visits the children of the node visited last before any
+	// other nodes. This is the default, and keeps the number of nodes that
+	// have been found but not visited small for deep graphs.
+	DepthFirst WalkOrder = iota
+
+	// BreadthFirst visits nodes in the order they were found, so that nodes
+	// closer to the roots are visited first.
+	BreadthFirst
+)
+
+// Walker can be used to configure the behaviour of Walk. The zero value is
+// safe to use with reasonable defaults.
+type Walker[T any] struct {
+	// MaxGoroutines is the maximum number of nodes visited at once. If
+	// unset, it defaults to runtime.GOMAXPROCS(0).
+	MaxGoroutines int
+
+	// Order is the order in which nodes are visited. With more than one
+	// goroutine, nodes are started in this order but may finish in any
+	// order.
+	Order WalkOrder
+
+	// Visited, if set, is called with every node that is found, including
+	// the roots, and the node is skipped if it returns true. To walk a graph
+	// that has cycles or shared nodes, it should record the nodes in a set
+	// and report whether they were already in it. Calls to Visited are never
+	// concurrent, so the set needs no synchronization of its own. If unset,
+	// every node found is visited, which suits trees.
+	Visited func(T) bool
+}
+
+// Walk visits the nodes of a graph in parallel, starting from roots, with
+// the default Walker. See Walker.Walk.
+func Walk[T any](roots []T, children func(T) []T, visit func(T) error) error {
+	return Walker[T]{}.Walk(roots, children, visit)
+}
+
+// Walk calls visit concurrently for roots and every node reachable from them
+// through children, which is called with each node after it has been
+// visited successfully. Walk returns once every node has been visited.
+//
+// If visit returns an error, no new nodes are visited, and Walk returns the
+// first error once the calls to visit in progress have returned, unless it
+// is ErrStop, in which case Walk returns nil. Panics in visit or children are
+// propagated by Walk after the calls in progress have returned.
+func (w Walker[T]) Walk(roots []T, children func(T) []T, visit func(T) error) error {
+	workers := w.MaxGoroutines
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var (
+		mu   sync.Mutex
+		cond = sync.NewCond(&mu)
+		// pending holds the nodes that have been found but not visited
+		pending []T
+		// busy is the number of nodes being visited
+		busy    int
+		stopped bool
+		err     error
+	)
+
+	// found adds nodes to pending, unless they were visited already. It must
+	// be called with mu held.
+	found := func(nodes []T) {
+		if w.Order == DepthFirst {
+			// pending is used as a stack, so push the nodes in reverse, for
+			// the first one to be visited first.
+			for i := len(nodes) - 1; i >= 0; i-- {
+				if w.Visited == nil || !w.Visited(nodes[i]) {
+					pending = append(pending, nodes[i])
+				}
+			}
+		} else {
+			for _, node := range nodes {
+				if w.Visited == nil || !w.Visited(node) {
+					pending = append(pending, node)
+				}
+			}
+		}
+		cond.Broadcast()
+	}
+
+	// next waits for a node to visit, and returns false if there are none
+	// left. It must be called with mu held.
+	next := func() (T, bool) {
+		for len(pending) == 0 && busy > 0 && !stopped {
+			cond.Wait()
+		}
+		var node T
+		if stopped || len(pending) == 0 {
+			return node, false
+		}
+		if w.Order == DepthFirst {
+			node = pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+		} else {
+			node = pending[0]
+			pending = pending[1:]
+		}
+		busy++
+		return node, true
+	}
+
+	// step visits node and adds its children to pending, stopping the walk
+	// if visit fails or either function panics.
+	step := func(node T) {
+		completed := false
+		defer func() {
+			if !completed {
+				mu.Lock()
+				busy--
+				stopped = true
+				cond.Broadcast()
+				mu.Unlock()
+			}
+		}()
+
+		visitErr := visit(node)
+		var nodes []T
+		if visitErr == nil {
+			nodes = children(node)
+		}
+		completed = true
+
+		mu.Lock()
+		defer mu.Unlock()
+		busy--
+		if visitErr != nil {
+			if !stopped && !errors.Is(visitErr, ErrStop) {
+				err = visitErr
+			}
+			stopped = true
+			cond.Broadcast()
+			return
+		}
+		if !stopped {
+			found(nodes)
+		}
+		if busy == 0 && len(pending) == 0 {
+			// The walk is over, so wake the idle workers to let them exit
+			cond.Broadcast()
+		}
+	}
+
+	mu.Lock()
+	found(roots)
+	mu.Unlock()
+
+	var wg WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Go(func() {
+			for {
+				mu.Lock()
+				node, ok := next()
+				mu.Unlock()
+				if !ok {
+					return
+				}
+				step(node)
+			}
+		})
+	}
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,180 @@
+package conc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleWalk() {
+	// A tree of the numbers below 16, where the children of n are 2n and 2n+1
+	children := func(n int) []int {
+		if 2*n >= 16 {
+			return nil
+		}
+		return []int{2 * n, 2*n + 1}
+	}
+
+	var sum atomic.Int64
+	_ = Walk([]int{1}, children, func(n int) error {
+		sum.Add(int64(n))
+		return nil
+	})
+	fmt.Println(sum.Load())
+	// Output:
+	// 120
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+
+	// tree returns the children of n in a binary tree of the numbers below
+	// size.
+	tree := func(size int) func(int) []int {
+		return func(n int) []int {
+			var children []int
+			for _, child := range []int{2 * n, 2*n + 1} {
+				if child < size {
+					children = append(children, child)
+				}
+			}
+			return children
+		}
+	}
+
+	t.Run("visits every node", func(t *testing.T) {
+		t.Parallel()
+		var (
+			mu      sync.Mutex
+			visited []int
+		)
+		err := Walk([]int{1}, tree(1000), func(n int) error {
+			mu.Lock()
+			visited = append(visited, n)
+			mu.Unlock()
+			return nil
+		})
+		require.NoError(t, err)
+		sort.Ints(visited)
+		require.Len(t, visited, 999)
+		for i, n := range visited {
+			require.Equal(t, i+1, n)
+		}
+	})
+
+	t.Run("no roots", func(t *testing.T) {
+		t.Parallel()
+		err := Walk(nil, tree(10), func(int) error {
+			t.Fatal("visit called")
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("order", func(t *testing.T) {
+		t.Parallel()
+		for _, tc := range []struct {
+			order    WalkOrder
+			expected []int
+		}{
+			{DepthFirst, []int{1, 2, 4, 5, 3, 6, 7}},
+			{BreadthFirst, []int{1, 2, 3, 4, 5, 6, 7}},
+		} {
+			var visited []int
+			w := Walker[int]{MaxGoroutines: 1, Order: tc.order}
+			err := w.Walk([]int{1}, tree(8), func(n int) error {
+				visited = append(visited, n)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, visited)
+		}
+	})
+
+	t.Run("visited set breaks cycles", func(t *testing.T) {
+		t.Parallel()
+		// Every node links to every other node
+		children := func(n int) []int { return []int{0, 1, 2, 3, 4} }
+		seen := make(map[int]bool)
+		var count atomic.Int64
+		w := Walker[int]{Visited: func(n int) bool {
+			if seen[n] {
+				return true
+			}
+			seen[n] = true
+			return false
+		}}
+		err := w.Walk([]int{0, 0}, children, func(int) error {
+			count.Add(1)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(5), count.Load())
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		t.Parallel()
+		var current, peak atomic.Int64
+		w := Walker[int]{MaxGoroutines: 3}
+		err := w.Walk([]int{1}, tree(200), func(int) error {
+			n := current.Add(1)
+			defer current.Add(-1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.LessOrEqual(t, peak.Load(), int64(3))
+	})
+
+	t.Run("error stops the walk", func(t *testing.T) {
+		t.Parallel()
+		err1 := errors.New("err1")
+		var count atomic.Int64
+		w := Walker[int]{MaxGoroutines: 1}
+		err := w.Walk([]int{1}, tree(1000), func(n int) error {
+			count.Add(1)
+			if n == 4 {
+				return err1
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, err1)
+		require.Equal(t, int64(3), count.Load())
+	})
+
+	t.Run("ErrStop stops the walk without an error", func(t *testing.T) {
+		t.Parallel()
+		var count atomic.Int64
+		err := Walk([]int{1}, tree(1000), func(n int) error {
+			count.Add(1)
+			if n == 1 {
+				return ErrStop
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count.Load())
+	})
+
+	t.Run("panics are propagated", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() {
+			_ = Walk([]int{1}, tree(1000), func(n int) error {
+				if n == 10 {
+					panic("super bad thing")
+				}
+				return nil
+			})
+		})
+	})
+}